@@ -11,6 +11,7 @@ import (
 	"github.com/DeBrosOfficial/network/pkg/config"
 	"github.com/DeBrosOfficial/network/pkg/gateway"
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"go.uber.org/zap"
 )
 
@@ -87,6 +88,67 @@ func parseGatewayConfig(logger *logging.ColoredLogger) *gateway.Config {
 		IPFSAPIURL            string   `yaml:"ipfs_api_url"`
 		IPFSTimeout           string   `yaml:"ipfs_timeout"`
 		IPFSReplicationFactor int      `yaml:"ipfs_replication_factor"`
+		IPFSEnableEncryption  *bool    `yaml:"ipfs_enable_encryption"`
+		IPFSEncryptionKey     string   `yaml:"ipfs_encryption_key"`
+		IPFSMaxObjectSize     int64    `yaml:"ipfs_max_object_size"`
+		IPFSUploadDir         string   `yaml:"ipfs_upload_dir"`
+		IPFSUploadSessionTTL  string   `yaml:"ipfs_upload_session_ttl"`
+		IPFSMaxUploadSessions int      `yaml:"ipfs_max_upload_sessions"`
+		IPFSMaxUploadBytes    int64    `yaml:"ipfs_max_upload_bytes"`
+		IPFSPinMaxAttempts    int      `yaml:"ipfs_pin_max_attempts"`
+		IPFSReconcileInterval string   `yaml:"ipfs_reconcile_interval"`
+		IPFSLifecycleInterval string   `yaml:"ipfs_lifecycle_interval"`
+		IPFSPresignKey        string   `yaml:"ipfs_presign_key"`
+		PubSubRetention       string   `yaml:"pubsub_retention"`
+		PubSubMaxMessages     int      `yaml:"pubsub_max_messages"`
+		PubSubMaxPayload      int      `yaml:"pubsub_max_payload"`
+		PubSubPublishRate     int      `yaml:"pubsub_publish_rate"`
+		PubSubEnvelopeKey     string   `yaml:"pubsub_envelope_key"`
+		TrustedProxies        []string `yaml:"trusted_proxies"`
+		CORS                  struct {
+			AllowedOrigins   []string `yaml:"allowed_origins"`
+			AllowedHeaders   []string `yaml:"allowed_headers"`
+			ExposedHeaders   []string `yaml:"exposed_headers"`
+			AllowCredentials bool     `yaml:"allow_credentials"`
+			MaxAge           string   `yaml:"max_age"`
+		} `yaml:"cors"`
+		RequestLog struct {
+			Disabled      bool    `yaml:"disabled"`
+			BufferSize    int     `yaml:"buffer_size"`
+			BatchSize     int     `yaml:"batch_size"`
+			FlushInterval string  `yaml:"flush_interval"`
+			SampleRate    float64 `yaml:"sample_rate"`
+			Retention     string  `yaml:"retention"`
+		} `yaml:"request_log"`
+		RateLimit struct {
+			Enabled  *bool  `yaml:"enabled"`
+			DMap     string `yaml:"dmap"`
+			Policies map[string]struct {
+				Requests int    `yaml:"requests"`
+				Window   string `yaml:"window"`
+			} `yaml:"policies"`
+		} `yaml:"rate_limit"`
+		Metering struct {
+			Disabled      bool   `yaml:"disabled"`
+			FlushInterval string `yaml:"flush_interval"`
+			CacheTTL      string `yaml:"cache_ttl"`
+			Quotas        map[string]struct {
+				Soft float64 `yaml:"soft"`
+				Hard float64 `yaml:"hard"`
+			} `yaml:"quotas"`
+		} `yaml:"metering"`
+		SIWE struct {
+			Domains    []string `yaml:"domains"`
+			ChainIDs   []int64  `yaml:"chain_ids"`
+			RPCURL     string   `yaml:"rpc_url"`
+			RPCTimeout string   `yaml:"rpc_timeout"`
+			ClockSkew  string   `yaml:"clock_skew"`
+		} `yaml:"siwe"`
+		Push struct {
+			ExpoURL         string `yaml:"expo_url"`
+			ExpoAccessToken string `yaml:"expo_access_token"`
+			ReceiptDelay    string `yaml:"receipt_delay"`
+		} `yaml:"push"`
 	}
 
 	data, err := os.ReadFile(configPath)
@@ -122,6 +184,7 @@ func parseGatewayConfig(logger *logging.ColoredLogger) *gateway.Config {
 		IPFSAPIURL:            "",
 		IPFSTimeout:           0,
 		IPFSReplicationFactor: 0,
+		RateLimit:             gateway.RateLimitConfig{Enabled: true},
 	}
 
 	if v := strings.TrimSpace(y.ListenAddr); v != "" {
@@ -190,6 +253,163 @@ func parseGatewayConfig(logger *logging.ColoredLogger) *gateway.Config {
 	if y.IPFSReplicationFactor > 0 {
 		cfg.IPFSReplicationFactor = y.IPFSReplicationFactor
 	}
+	if y.IPFSEnableEncryption != nil {
+		cfg.IPFSEnableEncryption = *y.IPFSEnableEncryption
+	}
+	cfg.IPFSEncryptionKey = strings.TrimSpace(y.IPFSEncryptionKey)
+	cfg.IPFSPresignKey = strings.TrimSpace(y.IPFSPresignKey)
+	cfg.IPFSMaxObjectSize = y.IPFSMaxObjectSize
+	cfg.IPFSUploadDir = strings.TrimSpace(y.IPFSUploadDir)
+	if v := strings.TrimSpace(y.IPFSUploadSessionTTL); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.IPFSUploadSessionTTL = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid ipfs_upload_session_ttl, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+	cfg.IPFSMaxUploadSessions = y.IPFSMaxUploadSessions
+	cfg.IPFSMaxUploadBytes = y.IPFSMaxUploadBytes
+	cfg.IPFSPinMaxAttempts = y.IPFSPinMaxAttempts
+	if v := strings.TrimSpace(y.IPFSReconcileInterval); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.IPFSReconcileInterval = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid ipfs_reconcile_interval, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+	if v := strings.TrimSpace(y.IPFSLifecycleInterval); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.IPFSLifecycleInterval = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid ipfs_lifecycle_interval, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+
+	// Durable pub/sub topic defaults
+	if v := strings.TrimSpace(y.PubSubRetention); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.PubSubRetention = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid pubsub_retention, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+	cfg.PubSubMaxMessages = y.PubSubMaxMessages
+	cfg.PubSubMaxPayload = y.PubSubMaxPayload
+	cfg.PubSubPublishRate = y.PubSubPublishRate
+	cfg.PubSubEnvelopeKey = strings.TrimSpace(y.PubSubEnvelopeKey)
+
+	// CORS defaults (namespaces may override via the API)
+	cfg.CORS.AllowedOrigins = y.CORS.AllowedOrigins
+	cfg.CORS.AllowedHeaders = y.CORS.AllowedHeaders
+	cfg.CORS.ExposedHeaders = y.CORS.ExposedHeaders
+	cfg.CORS.AllowCredentials = y.CORS.AllowCredentials
+	if v := strings.TrimSpace(y.CORS.MaxAge); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.CORS.MaxAge = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid cors max_age, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+
+	// Request log configuration
+	cfg.RequestLog.Disabled = y.RequestLog.Disabled
+	cfg.RequestLog.BufferSize = y.RequestLog.BufferSize
+	cfg.RequestLog.BatchSize = y.RequestLog.BatchSize
+	cfg.RequestLog.SampleRate = y.RequestLog.SampleRate
+	if v := strings.TrimSpace(y.RequestLog.FlushInterval); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.RequestLog.FlushInterval = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid request_log flush_interval, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+	if v := strings.TrimSpace(y.RequestLog.Retention); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.RequestLog.Retention = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid request_log retention, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+
+	// Proxies whose X-Forwarded-* headers are believed
+	cfg.TrustedProxies = y.TrustedProxies
+
+	// Rate limit configuration (enabled by default)
+	if y.RateLimit.Enabled != nil {
+		cfg.RateLimit.Enabled = *y.RateLimit.Enabled
+	}
+	if v := strings.TrimSpace(y.RateLimit.DMap); v != "" {
+		cfg.RateLimit.DMap = v
+	}
+	if len(y.RateLimit.Policies) > 0 {
+		cfg.RateLimit.Policies = make(map[string]gateway.RateLimitPolicy, len(y.RateLimit.Policies))
+		for class, p := range y.RateLimit.Policies {
+			policy := gateway.RateLimitPolicy{Requests: p.Requests}
+			if v := strings.TrimSpace(p.Window); v != "" {
+				if parsed, err := time.ParseDuration(v); err == nil {
+					policy.Window = parsed
+				} else {
+					logger.ComponentWarn(logging.ComponentGeneral, "invalid rate_limit window", zap.String("class", class), zap.String("value", v), zap.Error(err))
+				}
+			} else {
+				policy.Window = time.Minute
+			}
+			cfg.RateLimit.Policies[class] = policy
+		}
+	}
+
+	// Usage metering and default quotas
+	cfg.Metering.Disabled = y.Metering.Disabled
+	if v := strings.TrimSpace(y.Metering.FlushInterval); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.Metering.FlushInterval = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid metering flush_interval, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+	if v := strings.TrimSpace(y.Metering.CacheTTL); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.Metering.CacheTTL = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid metering cache_ttl, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+	if len(y.Metering.Quotas) > 0 {
+		cfg.Metering.Quotas = make(metering.Quotas, len(y.Metering.Quotas))
+		for metric, q := range y.Metering.Quotas {
+			cfg.Metering.Quotas[metric] = metering.Quota{Soft: q.Soft, Hard: q.Hard}
+		}
+	}
+
+	// Sign-In with Ethereum
+	cfg.SIWE.Domains = y.SIWE.Domains
+	cfg.SIWE.ChainIDs = y.SIWE.ChainIDs
+	cfg.SIWE.RPCURL = strings.TrimSpace(y.SIWE.RPCURL)
+	if v := strings.TrimSpace(y.SIWE.RPCTimeout); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.SIWE.RPCTimeout = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid siwe rpc_timeout, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+	if v := strings.TrimSpace(y.SIWE.ClockSkew); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.SIWE.ClockSkew = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid siwe clock_skew, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
+
+	// Push notifications (Expo)
+	cfg.Push.ExpoURL = strings.TrimSpace(y.Push.ExpoURL)
+	cfg.Push.AccessToken = strings.TrimSpace(y.Push.ExpoAccessToken)
+	if v := strings.TrimSpace(y.Push.ReceiptDelay); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.Push.ReceiptDelay = parsed
+		} else {
+			logger.ComponentWarn(logging.ComponentGeneral, "invalid push receipt_delay, using default", zap.String("value", v), zap.Error(err))
+		}
+	}
 
 	// Validate configuration
 	if errs := cfg.ValidateConfig(); len(errs) > 0 {
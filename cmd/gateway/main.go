@@ -53,7 +53,7 @@ func main() {
 		// Set up ACME manager
 		manager := &autocert.Manager{
 			Prompt:     autocert.AcceptTOS,
-			HostPolicy: autocert.HostWhitelist(cfg.DomainName),
+			HostPolicy: gw.CertHostPolicy(cfg.DomainName),
 		}
 
 		// Set cache directory if specified
@@ -0,0 +1,18 @@
+-- Orama Network - Per-namespace CORS policies
+-- Browser origins allowed to call the gateway on behalf of a namespace
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS namespace_cors_policies (
+    namespace         TEXT PRIMARY KEY,
+    allowed_origins   TEXT NOT NULL,             -- JSON array of origins ("*" or scheme://host[:port], "https://*.example.com")
+    allow_credentials BOOLEAN NOT NULL DEFAULT FALSE,
+    exposed_headers   TEXT,                      -- JSON array of extra response headers exposed to browsers
+    max_age_seconds   INTEGER NOT NULL DEFAULT 600,
+    updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by        TEXT
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (5);
+
+COMMIT;
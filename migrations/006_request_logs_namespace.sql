@@ -0,0 +1,13 @@
+-- Orama Network - Namespace-scoped request logs
+-- Records the namespace of each request so usage can be queried per tenant
+
+BEGIN;
+
+ALTER TABLE request_logs ADD COLUMN namespace TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_request_logs_ns_time ON request_logs(namespace, created_at);
+CREATE INDEX IF NOT EXISTS idx_request_logs_ns_path ON request_logs(namespace, path);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (6);
+
+COMMIT;
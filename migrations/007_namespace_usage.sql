@@ -0,0 +1,27 @@
+-- Orama Network - Usage metering and quotas
+-- Per-namespace consumption aggregated by billing period, plus quota overrides
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS namespace_usage (
+    namespace  TEXT NOT NULL,
+    period     TEXT NOT NULL,                -- UTC calendar month, e.g. 2026-10
+    metric     TEXT NOT NULL,                -- db_statements, cache_bytes, function_gb_seconds, ...
+    value      REAL NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (namespace, period, metric)
+);
+
+-- Overrides for the gateway's default quotas (0 or NULL = unlimited)
+CREATE TABLE IF NOT EXISTS namespace_quotas (
+    namespace  TEXT NOT NULL,
+    metric     TEXT NOT NULL,
+    soft_limit REAL,
+    hard_limit REAL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (namespace, metric)
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (7);
+
+COMMIT;
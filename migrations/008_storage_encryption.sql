@@ -0,0 +1,33 @@
+-- Orama Network - Storage envelope encryption
+-- Per-namespace keys (wrapped by the master key) and per-object data keys
+-- (wrapped by the namespace key), recorded alongside the object's CID
+
+BEGIN;
+
+-- Cluster master key, only used when the gateways have no storage encryption key configured
+CREATE TABLE IF NOT EXISTS storage_master_key (
+    id         INTEGER PRIMARY KEY CHECK (id = 1),
+    key_hex    TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS storage_namespace_keys (
+    namespace   TEXT PRIMARY KEY,
+    wrapped_key TEXT NOT NULL,                -- base64, AES-256-GCM under the master key
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS storage_object_keys (
+    cid            TEXT PRIMARY KEY,
+    namespace      TEXT NOT NULL,
+    algorithm      TEXT NOT NULL,             -- AES-256-GCM-STREAM-64K
+    wrapped_key    TEXT NOT NULL,             -- base64, AES-256-GCM under the namespace key
+    plaintext_size INTEGER NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_object_keys_namespace ON storage_object_keys(namespace);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (8);
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- Orama Network - Storage object index
+-- Records which objects each namespace owns so they can be listed, searched and tagged
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS storage_objects (
+    namespace    TEXT NOT NULL,
+    cid          TEXT NOT NULL,
+    name         TEXT NOT NULL DEFAULT '',
+    size         INTEGER NOT NULL DEFAULT 0,   -- plaintext size in bytes
+    content_type TEXT NOT NULL DEFAULT '',
+    tags         TEXT NOT NULL DEFAULT '[]',   -- JSON array of strings
+    encrypted    BOOLEAN NOT NULL DEFAULT FALSE,
+    pinned       BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by   TEXT NOT NULL DEFAULT '',     -- wallet address or API key fingerprint
+    created_at   TEXT NOT NULL,                -- RFC3339
+    updated_at   TEXT NOT NULL,                -- RFC3339
+    PRIMARY KEY (namespace, cid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_objects_ns_created ON storage_objects(namespace, created_at);
+CREATE INDEX IF NOT EXISTS idx_storage_objects_ns_name ON storage_objects(namespace, name);
+CREATE INDEX IF NOT EXISTS idx_storage_objects_cid ON storage_objects(cid);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (9);
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- Orama Network - Per-namespace pin references
+-- Identical content uploaded by different namespaces shares a CID. Each namespace
+-- holds its own reference, and the cluster pin is dropped only when none remain.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS storage_pin_refs (
+    namespace  TEXT NOT NULL,
+    cid        TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (namespace, cid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_pin_refs_cid ON storage_pin_refs(cid);
+
+-- Objects already pinned through the index keep their reference
+INSERT OR IGNORE INTO storage_pin_refs (namespace, cid)
+SELECT namespace, cid FROM storage_objects WHERE pinned;
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (10);
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- Orama Network - Durable storage pin queue
+-- Pins requested at upload time are queued here and retried with backoff until
+-- the cluster confirms them, so content is never silently left unpinned.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS storage_pin_jobs (
+    namespace          TEXT NOT NULL,
+    cid                TEXT NOT NULL,
+    name               TEXT NOT NULL DEFAULT '',
+    size               INTEGER NOT NULL DEFAULT 0,
+    replication_factor INTEGER NOT NULL DEFAULT 0,
+    state              TEXT NOT NULL DEFAULT 'queued', -- queued | pinning | pinned | failed
+    attempts           INTEGER NOT NULL DEFAULT 0,
+    last_error         TEXT NOT NULL DEFAULT '',
+    next_attempt_at    TEXT NOT NULL,
+    created_at         TEXT NOT NULL,
+    updated_at         TEXT NOT NULL,
+    PRIMARY KEY (namespace, cid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_pin_jobs_due ON storage_pin_jobs(state, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_storage_pin_jobs_cid ON storage_pin_jobs(cid);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (11);
+
+COMMIT;
@@ -0,0 +1,35 @@
+-- Orama Network - Static sites hosted from IPFS directories
+-- A site binds a namespace-scoped name (and optionally a custom domain) to the
+-- root CID of a UnixFS directory. Redeploys switch root_cid in one statement.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS storage_sites (
+    namespace   TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    root_cid    TEXT NOT NULL,
+    domain      TEXT NOT NULL DEFAULT '',
+    spa         BOOLEAN NOT NULL DEFAULT FALSE,
+    files       INTEGER NOT NULL DEFAULT 0,
+    size        INTEGER NOT NULL DEFAULT 0,
+    created_at  TEXT NOT NULL,
+    updated_at  TEXT NOT NULL,
+    deployed_at TEXT NOT NULL,
+    PRIMARY KEY (namespace, name)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_storage_sites_domain ON storage_sites(domain) WHERE domain != '';
+CREATE INDEX IF NOT EXISTS idx_storage_sites_root ON storage_sites(root_cid);
+
+-- File manifest of each deployed root, used to resolve paths without listing IPFS
+CREATE TABLE IF NOT EXISTS storage_site_files (
+    root_cid     TEXT NOT NULL,
+    path         TEXT NOT NULL,
+    size         INTEGER NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (root_cid, path)
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (12);
+
+COMMIT;
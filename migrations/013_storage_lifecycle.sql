@@ -0,0 +1,19 @@
+-- Orama Network - Storage lifecycle
+-- Object expiry (upload TTLs), last-access tracking and per-namespace lifecycle rules
+
+BEGIN;
+
+ALTER TABLE storage_objects ADD COLUMN expires_at TEXT NOT NULL DEFAULT '';  -- RFC3339; '' never expires
+ALTER TABLE storage_objects ADD COLUMN accessed_at TEXT NOT NULL DEFAULT ''; -- RFC3339; refreshed at most daily
+
+CREATE INDEX IF NOT EXISTS idx_storage_objects_expires ON storage_objects(expires_at);
+
+CREATE TABLE IF NOT EXISTS storage_lifecycle_policies (
+    namespace  TEXT PRIMARY KEY,
+    rules      TEXT NOT NULL DEFAULT '[]',  -- JSON array of lifecycle rules
+    updated_at TEXT NOT NULL                -- RFC3339
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (13);
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- Orama Network - Presigned storage URLs
+-- Cluster signing key, only used when the gateways have no presign key configured
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS storage_presign_key (
+    id         INTEGER PRIMARY KEY CHECK (id = 1),
+    key_hex    TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (14);
+
+COMMIT;
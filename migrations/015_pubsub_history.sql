@@ -0,0 +1,30 @@
+-- Orama Network - Durable pub/sub topics
+-- Opt-in per-topic message log used for history and replay after reconnects
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS pubsub_durable_topics (
+    namespace    TEXT NOT NULL,
+    topic        TEXT NOT NULL,
+    retention    INTEGER NOT NULL,  -- seconds a message is kept
+    max_messages INTEGER NOT NULL,  -- newest messages kept per topic
+    created_at   TEXT NOT NULL,     -- RFC3339
+    updated_at   TEXT NOT NULL,     -- RFC3339
+    PRIMARY KEY (namespace, topic)
+);
+
+-- seq is shared by all topics, so it only ever increases within one
+CREATE TABLE IF NOT EXISTS pubsub_messages (
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    namespace  TEXT NOT NULL,
+    topic      TEXT NOT NULL,
+    data       TEXT NOT NULL,  -- base64
+    created_at TEXT NOT NULL   -- RFC3339
+);
+
+CREATE INDEX IF NOT EXISTS idx_pubsub_messages_topic ON pubsub_messages(namespace, topic, seq);
+CREATE INDEX IF NOT EXISTS idx_pubsub_messages_created ON pubsub_messages(created_at);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (15);
+
+COMMIT;
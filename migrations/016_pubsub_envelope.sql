@@ -0,0 +1,11 @@
+-- Orama Network - Pub/sub message envelopes
+-- Stores the message ID and publisher of durable topic messages for replay
+
+BEGIN;
+
+ALTER TABLE pubsub_messages ADD COLUMN message_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE pubsub_messages ADD COLUMN publisher TEXT NOT NULL DEFAULT '';  -- wallet or API key fingerprint
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (16);
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- Orama Network - Pub/sub topic ACLs
+-- Per-topic publish/subscribe rules and the roles they may refer to
+
+BEGIN;
+
+-- topic may be a wildcard pattern; a rule list of '' allows anyone
+CREATE TABLE IF NOT EXISTS pubsub_topic_acls (
+    namespace  TEXT NOT NULL,
+    topic      TEXT NOT NULL,
+    publish    TEXT NOT NULL DEFAULT '',  -- JSON array of principals
+    subscribe  TEXT NOT NULL DEFAULT '',  -- JSON array of principals
+    updated_at TEXT NOT NULL,             -- RFC3339
+    updated_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (namespace, topic)
+);
+
+CREATE TABLE IF NOT EXISTS pubsub_roles (
+    namespace  TEXT NOT NULL,
+    role       TEXT NOT NULL,
+    members    TEXT NOT NULL,  -- JSON array of principals
+    updated_at TEXT NOT NULL,  -- RFC3339
+    updated_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (namespace, role)
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (17);
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- Orama Network - Pub/sub topic policies
+-- Per-topic message schemas, payload limits and publish rates
+
+BEGIN;
+
+-- topic may be a wildcard pattern; zero limits use the gateway defaults
+CREATE TABLE IF NOT EXISTS pubsub_topic_policies (
+    namespace    TEXT NOT NULL,
+    topic        TEXT NOT NULL,
+    schema       TEXT NOT NULL DEFAULT '',   -- JSON Schema of the message data; '' accepts any bytes
+    max_payload  INTEGER NOT NULL DEFAULT 0, -- bytes
+    publish_rate INTEGER NOT NULL DEFAULT 0, -- messages per second per connection
+    updated_at   TEXT NOT NULL,              -- RFC3339
+    updated_by   TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (namespace, topic)
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (18);
+
+COMMIT;
@@ -0,0 +1,32 @@
+-- Orama Network - Push notification tokens
+-- Device tokens registered per wallet and namespace, and the topics and
+-- segments each device asked to be notified about
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS push_tokens (
+    namespace  TEXT NOT NULL,
+    token      TEXT NOT NULL,  -- Expo push token
+    wallet     TEXT NOT NULL,
+    platform   TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL,  -- RFC3339
+    updated_at TEXT NOT NULL,  -- RFC3339
+    PRIMARY KEY (namespace, token)
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_tokens_wallet ON push_tokens(namespace, wallet);
+
+-- kind is 'topic' or 'segment'
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    namespace TEXT NOT NULL,
+    token     TEXT NOT NULL,
+    kind      TEXT NOT NULL,
+    value     TEXT NOT NULL,
+    PRIMARY KEY (namespace, kind, value, token)
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_token ON push_subscriptions(namespace, token);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (19);
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- Orama Network - Pub/sub envelope signing
+-- Cluster key signing message envelopes, only used when the gateways have no envelope key configured
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS pubsub_envelope_key (
+    id         INTEGER PRIMARY KEY CHECK (id = 1),
+    key_hex    TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (20);
+
+COMMIT;
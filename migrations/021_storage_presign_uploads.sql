@@ -0,0 +1,17 @@
+-- Orama Network - Single-use presigned uploads
+-- Nonces of presigned upload URLs that have been used; kept until the URL expires
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS storage_presign_uploads (
+    nonce      TEXT PRIMARY KEY,
+    namespace  TEXT NOT NULL,
+    expires_at TEXT NOT NULL,  -- RFC3339 expiry of the presigned URL
+    used_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_presign_uploads_expires ON storage_presign_uploads(expires_at);
+
+INSERT OR IGNORE INTO schema_migrations(version) VALUES (21);
+
+COMMIT;
@@ -37,6 +37,9 @@ type Client struct {
 	pubsub   *pubSubBridge
 	storage  *StorageClientImpl
 
+	// gatewayPubSub replaces pubsub when PubSub uses the gateway transport
+	gatewayPubSub *gatewayPubSub
+
 	// State
 	connected bool
 	startTime time.Time
@@ -56,6 +59,12 @@ func NewClient(config *ClientConfig) (NetworkClient, error) {
 		return nil, fmt.Errorf("app name is required")
 	}
 
+	switch config.pubSubTransport() {
+	case PubSubTransportLibp2p, PubSubTransportGateway:
+	default:
+		return nil, fmt.Errorf("%w: unknown pubsub transport %q", ErrInvalidConfig, config.PubSubTransport)
+	}
+
 	// Create zap logger via helper for consistency
 	logger, err := newClientLogger(config.QuietMode)
 	if err != nil {
@@ -83,6 +92,9 @@ func (c *Client) Database() DatabaseClient {
 
 // PubSub returns the pub/sub client
 func (c *Client) PubSub() PubSubClient {
+	if c.gatewayPubSub != nil {
+		return c.gatewayPubSub
+	}
 	return c.pubsub
 }
 
@@ -129,6 +141,21 @@ func (c *Client) Connect() error {
 	}
 	c.resolvedNamespace = ns
 
+	// Without bootstrap peers, pubsub goes through the gateway and no host is needed
+	if c.config.pubSubTransport() == PubSubTransportGateway {
+		gps, err := newGatewayPubSub(c, c.config)
+		if err != nil {
+			return err
+		}
+		gps.start()
+		c.gatewayPubSub = gps
+		c.connected = true
+		c.logger.Info("Client connected through gateway",
+			zap.String("namespace", ns),
+			zap.String("gateway_url", c.config.GatewayURL))
+		return nil
+	}
+
 	// Create LibP2P host with optional Anyone proxy for TCP and optional QUIC disable
 	var opts []libp2p.Option
 	opts = append(opts,
@@ -162,11 +189,10 @@ func (c *Client) Connect() error {
 	// Create LibP2P GossipSub with PeerExchange enabled (gossip-based peer exchange).
 	// Peer exchange helps propagate peer addresses via pubsub gossip and is enabled
 	// globally so discovery works without Anchat-specific branches.
-	var ps *libp2ppubsub.PubSub
-	ps, err = libp2ppubsub.NewGossipSub(context.Background(), h,
+	// Flood publishing, peer scoring and message validation use the defaults.
+	ps, validator, err := pubsub.NewGossipSub(context.Background(), h, pubsub.Config{},
 		libp2ppubsub.WithPeerExchange(true),
-		libp2ppubsub.WithFloodPublish(true), // Ensure messages reach all peers, not just mesh
-		libp2ppubsub.WithDirectPeers(nil),   // Enable direct peer connections
+		libp2ppubsub.WithDirectPeers(nil), // Enable direct peer connections
 	)
 	if err != nil {
 		h.Close()
@@ -188,7 +214,7 @@ func (c *Client) Connect() error {
 	c.logger.Info("App namespace retrieved", zap.String("namespace", namespace))
 
 	c.logger.Info("Calling pubsub.NewClientAdapter...")
-	adapter := pubsub.NewClientAdapter(c.libp2pPS, namespace)
+	adapter := pubsub.NewClientAdapter(c.libp2pPS, namespace, validator)
 	c.logger.Info("pubsub.NewClientAdapter completed successfully")
 
 	c.logger.Info("Creating pubSubBridge...")
@@ -255,6 +281,15 @@ func (c *Client) Connect() error {
 
 // Disconnect closes the connection to the network
 func (c *Client) Disconnect() error {
+	// Close gateway pubsub without holding the lock; its handlers may use the client
+	c.mu.Lock()
+	gps := c.gatewayPubSub
+	c.gatewayPubSub = nil
+	c.mu.Unlock()
+	if gps != nil {
+		_ = gps.Close()
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -303,6 +338,9 @@ func (c *Client) Health() (*HealthStatus, error) {
 	if !c.connected {
 		checks["connection"] = "disconnected"
 	}
+	if c.gatewayPubSub != nil && !c.gatewayPubSub.connected() {
+		checks["pubsub"] = "reconnecting"
+	}
 
 	return &HealthStatus{
 		Status:       status,
@@ -18,6 +18,30 @@ type ClientConfig struct {
 	QuietMode         bool          `json:"quiet_mode"` // Suppress debug/info logs
 	APIKey            string        `json:"api_key"`    // API key for gateway auth
 	JWT               string        `json:"jwt"`        // Optional JWT bearer token
+
+	// PubSub joins the libp2p mesh when BootstrapPeers are set, and otherwise
+	// uses the gateway's WebSocket at GatewayURL. PubSubTransport overrides
+	// the choice.
+	PubSubTransport string `json:"pubsub_transport"`  // "libp2p" or "gateway"
+	PubSubQueueSize int    `json:"pubsub_queue_size"` // Frames the gateway transport buffers while disconnected (default: 256)
+}
+
+// PubSub transports
+const (
+	PubSubTransportLibp2p  = "libp2p"
+	PubSubTransportGateway = "gateway"
+)
+
+// pubSubTransport returns the transport PubSub uses.
+func (c *ClientConfig) pubSubTransport() string {
+	switch {
+	case c.PubSubTransport != "":
+		return c.PubSubTransport
+	case len(c.BootstrapPeers) == 0 && c.GatewayURL != "":
+		return PubSubTransportGateway
+	default:
+		return PubSubTransportLibp2p
+	}
 }
 
 // DefaultClientConfig returns a default client configuration
@@ -21,6 +21,17 @@ var (
 
 	// ErrNamespaceMismatch indicates a namespace mismatch
 	ErrNamespaceMismatch = errors.New("namespace mismatch")
+
+	// ErrNotFound indicates the requested object does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrPubSubQueueFull indicates the gateway pubsub transport has too many
+	// frames waiting to be sent
+	ErrPubSubQueueFull = errors.New("pubsub queue full")
+
+	// ErrGatewayConnLost indicates the gateway connection dropped before a
+	// frame was acknowledged; the gateway may or may not have processed it
+	ErrGatewayConnLost = errors.New("gateway connection lost")
 )
 
 // ClientError represents a client-specific error with additional context
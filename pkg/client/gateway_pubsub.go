@@ -0,0 +1,620 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPubSubQueueSize bounds the frames waiting for the gateway connection
+	defaultPubSubQueueSize = 256
+
+	// Reconnect backoff, doubled after each failed attempt
+	minGatewayReconnectDelay = 500 * time.Millisecond
+	maxGatewayReconnectDelay = 30 * time.Second
+
+	// gatewayReadTimeout ends a connection that went silent; the gateway
+	// pings every 30 seconds
+	gatewayReadTimeout = 75 * time.Second
+
+	// gatewayFrameTimeout bounds writing a frame and waiting for its ack
+	gatewayFrameTimeout = 10 * time.Second
+)
+
+// PubSubError is a frame the gateway refused, such as a publish over the
+// topic's rate or a subscription the caller's ACL does not allow
+type PubSubError struct {
+	Message    string
+	Code       string        // payload_too_large, invalid_json, schema_violation or rate_limited
+	Details    []string      // schema violations
+	RetryAfter time.Duration // when rate limited
+}
+
+func (e *PubSubError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("gateway refused frame: %s (%s)", e.Message, e.Code)
+	}
+	return "gateway refused frame: " + e.Message
+}
+
+// gatewayFrame is a frame of the gateway's multi-topic WebSocket protocol
+type gatewayFrame struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Topic      string                 `json:"topic,omitempty"`
+	Data       string                 `json:"data,omitempty"` // base64
+	Since      *int64                 `json:"since,omitempty"`
+	Seq        int64                  `json:"seq,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Code       string                 `json:"code,omitempty"`
+	Details    []string               `json:"details,omitempty"`
+	RetryAfter int                    `json:"retry_after,omitempty"`
+	MemberID   string                 `json:"member_id,omitempty"`
+	Meta       map[string]interface{} `json:"meta,omitempty"`
+}
+
+// gatewayRequest is a client frame waiting to be sent and acknowledged
+type gatewayRequest struct {
+	ctx   context.Context
+	frame gatewayFrame
+	done  chan error // receives the outcome once
+}
+
+// gatewaySubscription is a topic or pattern restored after every reconnect
+type gatewaySubscription struct {
+	handler MessageHandler
+	opts    SubscribeOptions
+	lastSeq int64 // resume point of exact topics subscribed with Since
+}
+
+// gatewayPubSub implements PubSubClient over the gateway's /v1/pubsub/ws
+// WebSocket. It reconnects with backoff, restores subscriptions and presence,
+// and queues publishes while disconnected. Handlers run on the connection's
+// read loop and should return quickly.
+type gatewayPubSub struct {
+	client *Client
+	url    string
+	header http.Header
+	dialer *websocket.Dialer
+	logger *zap.Logger
+
+	queue  chan *gatewayRequest
+	nextID atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	conn    *websocket.Conn // nil while disconnected
+	subs    map[string]*gatewaySubscription
+	pending map[string]*gatewayRequest // sent frames by id, waiting for their ack
+}
+
+var (
+	_ PubSubClient           = (*gatewayPubSub)(nil)
+	_ PubSubOptionSubscriber = (*gatewayPubSub)(nil)
+)
+
+// newGatewayPubSub creates the gateway transport for cfg. Call start to connect.
+func newGatewayPubSub(c *Client, cfg *ClientConfig) (*gatewayPubSub, error) {
+	u, err := url.Parse(strings.TrimSuffix(cfg.GatewayURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gateway URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("invalid gateway URL: unsupported scheme %q", u.Scheme)
+	}
+	u.Path += "/v1/pubsub/ws"
+
+	queueSize := cfg.PubSubQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultPubSubQueueSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &gatewayPubSub{
+		client:  c,
+		url:     u.String(),
+		header:  authHeader(cfg),
+		dialer:  &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: cfg.ConnectTimeout},
+		logger:  c.logger,
+		queue:   make(chan *gatewayRequest, queueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		subs:    make(map[string]*gatewaySubscription),
+		pending: make(map[string]*gatewayRequest),
+	}, nil
+}
+
+// start connects to the gateway and keeps the connection up until Close. A
+// failed first attempt is retried in the background.
+func (g *gatewayPubSub) start() {
+	conn, err := g.dial()
+	if err != nil {
+		g.logger.Warn("Failed to connect to gateway pubsub, retrying in background",
+			zap.String("url", g.url),
+			zap.Error(err))
+	} else {
+		// Subscriptions made right after Connect wait for their ack
+		g.conn = conn
+	}
+	go g.run(conn)
+}
+
+// Close ends the connection and fails the frames still queued.
+func (g *gatewayPubSub) Close() error {
+	g.cancel()
+	<-g.done
+	for {
+		select {
+		case req := <-g.queue:
+			req.done <- ErrNotConnected
+		default:
+			return nil
+		}
+	}
+}
+
+// connected reports whether the gateway connection is up.
+func (g *gatewayPubSub) connected() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.conn != nil
+}
+
+func (g *gatewayPubSub) dial() (*websocket.Conn, error) {
+	conn, resp, err := g.dialer.DialContext(g.ctx, g.url, g.header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w (status %d)", err, resp.StatusCode)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// run serves conn, then reconnects with backoff until Close.
+func (g *gatewayPubSub) run(conn *websocket.Conn) {
+	defer close(g.done)
+	delay := minGatewayReconnectDelay
+	for {
+		if conn != nil {
+			g.serve(conn)
+			delay = minGatewayReconnectDelay
+		}
+		if g.ctx.Err() != nil {
+			return
+		}
+
+		// Jitter keeps clients from reconnecting in lockstep after a gateway restart
+		wait := delay/2 + rand.N(delay/2+1)
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, maxGatewayReconnectDelay)
+
+		var err error
+		if conn, err = g.dial(); err != nil {
+			g.logger.Debug("Gateway pubsub reconnect failed", zap.String("url", g.url), zap.Error(err))
+			continue
+		}
+		g.logger.Info("Reconnected to gateway pubsub", zap.String("url", g.url))
+	}
+}
+
+// serve restores the subscriptions on conn, then sends queued frames until
+// the connection fails or the transport is closed.
+func (g *gatewayPubSub) serve(conn *websocket.Conn) {
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		g.readLoop(conn)
+	}()
+	defer func() {
+		_ = conn.Close()
+		<-readDone
+		g.mu.Lock()
+		g.conn = nil
+		pending := g.pending
+		g.pending = make(map[string]*gatewayRequest)
+		g.mu.Unlock()
+		for _, req := range pending {
+			req.done <- ErrGatewayConnLost
+		}
+	}()
+
+	g.mu.Lock()
+	g.conn = conn
+	g.mu.Unlock()
+
+	if err := g.resubscribe(conn, readDone); err != nil {
+		if !errors.Is(err, ErrGatewayConnLost) {
+			g.logger.Warn("Failed to restore gateway pubsub subscriptions", zap.Error(err))
+		}
+		return
+	}
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-readDone:
+			return
+		case req := <-g.queue:
+			if req.ctx.Err() != nil {
+				// The caller stopped waiting; don't send what it gave up on
+				continue
+			}
+			if err := g.send(conn, req); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send tracks req for its ack and writes its frame. Only serve writes to conn.
+func (g *gatewayPubSub) send(conn *websocket.Conn, req *gatewayRequest) error {
+	g.mu.Lock()
+	g.pending[req.frame.ID] = req
+	g.mu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(gatewayFrameTimeout))
+	return conn.WriteJSON(req.frame)
+}
+
+// resubscribe restores every subscription, and its presence, on a new connection.
+func (g *gatewayPubSub) resubscribe(conn *websocket.Conn, readDone <-chan struct{}) error {
+	g.mu.Lock()
+	frames := make([]gatewayFrame, 0, len(g.subs))
+	for topic, sub := range g.subs {
+		frames = append(frames, sub.subscribeFrame(topic))
+	}
+	g.mu.Unlock()
+
+	for _, f := range frames {
+		err := g.roundTrip(conn, readDone, f)
+		var refused *PubSubError
+		if errors.As(err, &refused) {
+			// Permissions may have changed while disconnected; keep the others
+			g.logger.Warn("Gateway refused resubscription", zap.String("topic", f.Topic), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		g.mu.Lock()
+		sub, ok := g.subs[f.Topic]
+		var presence *PresenceOptions
+		if ok {
+			presence = sub.opts.Presence
+		}
+		g.mu.Unlock()
+		if presence == nil {
+			continue
+		}
+		if err := g.roundTrip(conn, readDone, g.presenceFrame(f.Topic, presence)); err != nil && !errors.As(err, &refused) {
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTrip sends f on conn and waits for its ack.
+func (g *gatewayPubSub) roundTrip(conn *websocket.Conn, readDone <-chan struct{}, f gatewayFrame) error {
+	f.ID = g.newID()
+	req := &gatewayRequest{ctx: g.ctx, frame: f, done: make(chan error, 1)}
+	if err := g.send(conn, req); err != nil {
+		return ErrGatewayConnLost
+	}
+	timer := time.NewTimer(gatewayFrameTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-req.done:
+		return err
+	case <-readDone:
+		return ErrGatewayConnLost
+	case <-g.ctx.Done():
+		return ErrGatewayConnLost
+	case <-timer.C:
+		return ErrGatewayConnLost
+	}
+}
+
+// readLoop dispatches gateway frames until the connection fails.
+func (g *gatewayPubSub) readLoop(conn *websocket.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(gatewayReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(gatewayReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(gatewayFrameTimeout))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if g.ctx.Err() == nil {
+				g.logger.Info("Gateway pubsub connection lost", zap.Error(err))
+			}
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(gatewayReadTimeout))
+
+		var f gatewayFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			g.logger.Debug("Ignoring invalid gateway pubsub frame", zap.Error(err))
+			continue
+		}
+		switch f.Type {
+		case "message":
+			g.deliver(f)
+		case "ack", "error":
+			g.mu.Lock()
+			req, ok := g.pending[f.ID]
+			delete(g.pending, f.ID)
+			g.mu.Unlock()
+			if !ok {
+				if f.Type == "error" {
+					g.logger.Warn("Gateway pubsub error", zap.String("error", f.Error))
+				}
+				continue
+			}
+			if f.Type == "error" {
+				req.done <- &PubSubError{
+					Message:    f.Error,
+					Code:       f.Code,
+					Details:    f.Details,
+					RetryAfter: time.Duration(f.RetryAfter) * time.Second,
+				}
+			} else {
+				req.done <- nil
+			}
+		}
+	}
+}
+
+// deliver passes a message to the handlers of the subscriptions matching its topic.
+func (g *gatewayPubSub) deliver(f gatewayFrame) {
+	data, err := base64.StdEncoding.DecodeString(f.Data)
+	if err != nil {
+		g.logger.Debug("Ignoring gateway pubsub message with invalid data", zap.String("topic", f.Topic))
+		return
+	}
+	var handlers []MessageHandler
+	g.mu.Lock()
+	for pattern, sub := range g.subs {
+		if !matchGatewayTopic(pattern, f.Topic) {
+			continue
+		}
+		if pattern == f.Topic && f.Seq > sub.lastSeq {
+			sub.lastSeq = f.Seq
+		}
+		handlers = append(handlers, sub.handler)
+	}
+	g.mu.Unlock()
+
+	for _, h := range handlers {
+		if err := h(f.Topic, data); err != nil {
+			g.logger.Debug("Pubsub handler failed", zap.String("topic", f.Topic), zap.Error(err))
+		}
+	}
+}
+
+// request queues f and waits for the gateway's ack.
+func (g *gatewayPubSub) request(ctx context.Context, f gatewayFrame) error {
+	if g.ctx.Err() != nil {
+		return ErrNotConnected
+	}
+	f.ID = g.newID()
+	req := &gatewayRequest{ctx: ctx, frame: f, done: make(chan error, 1)}
+	select {
+	case g.queue <- req:
+	default:
+		return ErrPubSubQueueFull
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-g.done:
+		return ErrNotConnected
+	case <-ctx.Done():
+		g.mu.Lock()
+		delete(g.pending, f.ID)
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (g *gatewayPubSub) newID() string {
+	return strconv.FormatUint(g.nextID.Add(1), 10)
+}
+
+func (s *gatewaySubscription) subscribeFrame(topic string) gatewayFrame {
+	f := gatewayFrame{Type: "subscribe", Topic: topic}
+	// Wildcards match topics with unrelated sequences, so only exact topics resume
+	if s.opts.Since != nil && !isGatewayPattern(topic) {
+		since := s.lastSeq
+		f.Since = &since
+	}
+	return f
+}
+
+func (g *gatewayPubSub) presenceFrame(topic string, p *PresenceOptions) gatewayFrame {
+	return gatewayFrame{Type: "presence", Topic: topic, MemberID: p.MemberID, Meta: p.Meta}
+}
+
+func (g *gatewayPubSub) Subscribe(ctx context.Context, topic string, handler MessageHandler) error {
+	return g.SubscribeWithOptions(ctx, topic, handler, SubscribeOptions{})
+}
+
+// SubscribeWithOptions subscribes to a topic or wildcard pattern such as
+// chat.room.*. A second subscription to the same topic replaces the first.
+// While disconnected the subscription is recorded and made on reconnect.
+func (g *gatewayPubSub) SubscribeWithOptions(ctx context.Context, topic string, handler MessageHandler, opts SubscribeOptions) error {
+	if err := g.client.requireAccess(ctx); err != nil {
+		return fmt.Errorf("authentication required: %w - run CLI commands to authenticate automatically", err)
+	}
+	if opts.Presence != nil && (opts.Presence.MemberID == "" || isGatewayPattern(topic)) {
+		return fmt.Errorf("presence requires a member ID and a topic without wildcards")
+	}
+	sub := &gatewaySubscription{handler: handler, opts: opts}
+	if opts.Since != nil {
+		sub.lastSeq = *opts.Since
+	}
+
+	g.mu.Lock()
+	prev := g.subs[topic]
+	g.subs[topic] = sub
+	connected := g.conn != nil
+	g.mu.Unlock()
+	if !connected {
+		return nil
+	}
+
+	err := g.request(ctx, sub.subscribeFrame(topic))
+	if err == nil && opts.Presence != nil {
+		err = g.request(ctx, g.presenceFrame(topic, opts.Presence))
+	}
+	if err != nil && !errors.Is(err, ErrGatewayConnLost) {
+		g.mu.Lock()
+		if g.subs[topic] == sub {
+			if prev != nil {
+				g.subs[topic] = prev
+			} else {
+				delete(g.subs, topic)
+			}
+		}
+		g.mu.Unlock()
+		return err
+	}
+	// A lost connection restores the subscription when it comes back
+	return nil
+}
+
+// UpdatePresence changes the metadata of the member joined on topic.
+func (g *gatewayPubSub) UpdatePresence(ctx context.Context, topic string, meta map[string]interface{}) error {
+	if err := g.client.requireAccess(ctx); err != nil {
+		return fmt.Errorf("authentication required: %w - run CLI commands to authenticate automatically", err)
+	}
+	g.mu.Lock()
+	sub, ok := g.subs[topic]
+	if !ok || sub.opts.Presence == nil {
+		g.mu.Unlock()
+		return fmt.Errorf("no presence joined on topic %q", topic)
+	}
+	presence := &PresenceOptions{MemberID: sub.opts.Presence.MemberID, Meta: meta}
+	sub.opts.Presence = presence
+	connected := g.conn != nil
+	g.mu.Unlock()
+	if !connected {
+		return nil
+	}
+	if err := g.request(ctx, g.presenceFrame(topic, presence)); err != nil && !errors.Is(err, ErrGatewayConnLost) {
+		return err
+	}
+	return nil
+}
+
+// Publish sends data to topic and waits for the gateway to accept it. Publishes
+// made while disconnected are queued until the connection is restored; a full
+// queue fails with ErrPubSubQueueFull.
+func (g *gatewayPubSub) Publish(ctx context.Context, topic string, data []byte) error {
+	if err := g.client.requireAccess(ctx); err != nil {
+		return fmt.Errorf("authentication required: %w - run CLI commands to authenticate automatically", err)
+	}
+	return g.request(ctx, gatewayFrame{Type: "publish", Topic: topic, Data: base64.StdEncoding.EncodeToString(data)})
+}
+
+func (g *gatewayPubSub) Unsubscribe(ctx context.Context, topic string) error {
+	if err := g.client.requireAccess(ctx); err != nil {
+		return fmt.Errorf("authentication required: %w - run CLI commands to authenticate automatically", err)
+	}
+	g.mu.Lock()
+	_, ok := g.subs[topic]
+	delete(g.subs, topic)
+	connected := g.conn != nil
+	g.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("not subscribed to %q", topic)
+	}
+	if !connected {
+		return nil
+	}
+	err := g.request(ctx, gatewayFrame{Type: "unsubscribe", Topic: topic})
+	var refused *PubSubError
+	if err != nil && !errors.Is(err, ErrGatewayConnLost) && !errors.As(err, &refused) {
+		return err
+	}
+	return nil
+}
+
+// ListTopics returns the namespace's topics the gateway is subscribed to.
+func (g *gatewayPubSub) ListTopics(ctx context.Context) ([]string, error) {
+	if err := g.client.requireAccess(ctx); err != nil {
+		return nil, fmt.Errorf("authentication required: %w - run CLI commands to authenticate automatically", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getGatewayURL(g.client)+"/v1/pubsub/topics", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	addAuthHeaders(req, g.client)
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list topics failed with status %d", resp.StatusCode)
+	}
+	var out struct {
+		Topics []string `json:"topics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return out.Topics, nil
+}
+
+// isGatewayPattern reports whether topic is a wildcard subscription.
+func isGatewayPattern(topic string) bool {
+	for _, seg := range strings.Split(topic, ".") {
+		if seg == "*" || seg == ">" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGatewayTopic reports whether topic matches a subscription: a "*"
+// segment matches one segment and a final ">" one or more.
+func matchGatewayTopic(pattern, topic string) bool {
+	ps := strings.Split(pattern, ".")
+	ts := strings.Split(topic, ".")
+	for i, p := range ps {
+		if p == ">" && i == len(ps)-1 {
+			return len(ts) > i
+		}
+		if i >= len(ts) || (p != "*" && p != ts[i]) {
+			return false
+		}
+	}
+	return len(ps) == len(ts)
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// gatewayStandIn speaks the gateway's multi-topic WebSocket protocol. It
+// acks every frame except subscriptions to "secret", and records the frames
+// of each connection.
+type gatewayStandIn struct {
+	mu     sync.Mutex
+	conns  []*websocket.Conn
+	frames [][]gatewayFrame
+	auth   string
+}
+
+func (s *gatewayStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.auth = r.Header.Get("Authorization")
+	s.conns = append(s.conns, conn)
+	s.frames = append(s.frames, nil)
+	n := len(s.conns) - 1
+	s.mu.Unlock()
+
+	for {
+		var f gatewayFrame
+		if err := conn.ReadJSON(&f); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.frames[n] = append(s.frames[n], f)
+		if f.Type == "subscribe" && f.Topic == "secret" {
+			_ = conn.WriteJSON(gatewayFrame{Type: "error", ID: f.ID, Error: "topic forbidden"})
+		} else {
+			_ = conn.WriteJSON(gatewayFrame{Type: "ack", ID: f.ID, Topic: f.Topic})
+		}
+		s.mu.Unlock()
+	}
+}
+
+// send writes a frame on the latest connection.
+func (s *gatewayStandIn) send(f gatewayFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.conns[len(s.conns)-1].WriteJSON(f)
+}
+
+// drop closes every connection.
+func (s *gatewayStandIn) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+// lastFrames returns the frames of the latest connection and its authorization.
+func (s *gatewayStandIn) lastFrames() ([]gatewayFrame, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]gatewayFrame(nil), s.frames[len(s.frames)-1]...), s.auth
+}
+
+func newGatewayTestClient(t *testing.T, gatewayURL string, queueSize int) *Client {
+	t.Helper()
+	cfg := &ClientConfig{
+		AppName:         "test",
+		GatewayURL:      gatewayURL,
+		APIKey:          "ak_test:ns",
+		ConnectTimeout:  time.Second,
+		QuietMode:       true,
+		PubSubQueueSize: queueSize,
+	}
+	nc, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if err := nc.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	t.Cleanup(func() { _ = nc.Disconnect() })
+	return nc.(*Client)
+}
+
+func TestGatewayPubSub_ReconnectsAndResubscribes(t *testing.T) {
+	gw := &gatewayStandIn{}
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	c := newGatewayTestClient(t, srv.URL, 0)
+	ps, ok := c.PubSub().(PubSubOptionSubscriber)
+	if !ok || c.host != nil {
+		t.Fatalf("Expected the gateway transport without a libp2p host when no peers are set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan string, 4)
+	since := int64(5)
+	err := ps.SubscribeWithOptions(ctx, "chat", func(topic string, data []byte) error {
+		received <- string(data)
+		return nil
+	}, SubscribeOptions{Since: &since, Presence: &PresenceOptions{MemberID: "m1"}})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	var refused *PubSubError
+	if err := c.PubSub().Subscribe(ctx, "secret", func(string, []byte) error { return nil }); !errors.As(err, &refused) {
+		t.Fatalf("Expected the refused subscription to fail with a PubSubError, got %v", err)
+	}
+	if _, auth := gw.lastFrames(); auth != "Bearer ak_test:ns" {
+		t.Fatalf("Expected the API key to be sent, got %q", auth)
+	}
+
+	gw.send(gatewayFrame{Type: "message", Topic: "chat", Seq: 9, Data: base64.StdEncoding.EncodeToString([]byte("hi"))})
+	select {
+	case d := <-received:
+		if d != "hi" {
+			t.Fatalf("Expected hi, got %q", d)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the message")
+	}
+
+	// A publish during the outage is queued and sent once resubscribed
+	gw.drop()
+	for c.gatewayPubSub.connected() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.PubSub().Publish(ctx, "chat", []byte("back")); err != nil {
+		t.Fatalf("Publish across the reconnect failed: %v", err)
+	}
+	frames, _ := gw.lastFrames()
+	if len(frames) != 3 || frames[0].Type != "subscribe" || frames[0].Topic != "chat" || frames[0].Since == nil || *frames[0].Since != 9 ||
+		frames[1].Type != "presence" || frames[1].MemberID != "m1" || frames[2].Type != "publish" {
+		t.Fatalf("Expected chat resumed after seq 9 with presence, then the publish; got %+v", frames)
+	}
+	if status, _ := c.Health(); status.Checks["pubsub"] != "ok" {
+		t.Fatalf("Expected pubsub to be healthy after reconnecting, got %q", status.Checks["pubsub"])
+	}
+}
+
+func TestGatewayPubSub_QueueBoundWhileDisconnected(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	c := newGatewayTestClient(t, srv.URL, 1)
+	srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	queued := make(chan error, 1)
+	go func() { queued <- c.PubSub().Publish(ctx, "chat", []byte("1")) }()
+	for len(c.gatewayPubSub.queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.PubSub().Publish(ctx, "chat", []byte("2")); !errors.Is(err, ErrPubSubQueueFull) {
+		t.Fatalf("Expected ErrPubSubQueueFull, got %v", err)
+	}
+	if err := <-queued; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the queued publish to wait for the connection, got %v", err)
+	}
+	if status, _ := c.Health(); status.Checks["pubsub"] != "reconnecting" {
+		t.Fatalf("Expected pubsub to be reconnecting, got %q", status.Checks["pubsub"])
+	}
+}
+
+func TestClientConfig_PubSubTransport(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  ClientConfig
+		want string
+	}{
+		{ClientConfig{BootstrapPeers: []string{"/ip4/127.0.0.1/tcp/4001"}, GatewayURL: "http://gw"}, PubSubTransportLibp2p},
+		{ClientConfig{GatewayURL: "http://gw"}, PubSubTransportGateway},
+		{ClientConfig{}, PubSubTransportLibp2p},
+		{ClientConfig{GatewayURL: "http://gw", PubSubTransport: PubSubTransportLibp2p}, PubSubTransportLibp2p},
+	} {
+		if got := tt.cfg.pubSubTransport(); got != tt.want {
+			t.Errorf("%+v: expected %s, got %s", tt.cfg, tt.want, got)
+		}
+	}
+	for pattern, topics := range map[string][2]string{
+		"chat.room.*": {"chat.room.7", "chat.room.7.typing"},
+		"chat.>":      {"chat.room.7", "chat"},
+		"chat":        {"chat", "chat.room"},
+	} {
+		if !matchGatewayTopic(pattern, topics[0]) || matchGatewayTopic(pattern, topics[1]) {
+			t.Errorf("%s: expected to match %s but not %s", pattern, topics[0], topics[1])
+		}
+	}
+}
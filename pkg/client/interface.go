@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
 )
 
 // NetworkClient provides the main interface for applications to interact with the network
@@ -46,6 +48,20 @@ type PubSubClient interface {
 	ListTopics(ctx context.Context) ([]string, error)
 }
 
+// PubSubPublishReporter is implemented by PubSubClients that can report how a
+// publish reached the mesh
+type PubSubPublishReporter interface {
+	PublishWithResult(ctx context.Context, topic string, data []byte) (*PublishResult, error)
+}
+
+// PubSubOptionSubscriber is implemented by PubSubClients that can replay
+// durable topics and join a topic's presence
+type PubSubOptionSubscriber interface {
+	SubscribeWithOptions(ctx context.Context, topic string, handler MessageHandler, opts SubscribeOptions) error
+	// UpdatePresence changes the metadata of a presence joined with SubscribeWithOptions
+	UpdatePresence(ctx context.Context, topic string, meta map[string]interface{}) error
+}
+
 // NetworkInfo provides network status and peer information
 type NetworkInfo interface {
 	GetPeers(ctx context.Context) ([]PeerInfo, error)
@@ -59,17 +75,39 @@ type StorageClient interface {
 	// Upload uploads content to IPFS and pins it
 	Upload(ctx context.Context, reader io.Reader, name string) (*StorageUploadResult, error)
 
+	// UploadWithOptions streams content to IPFS with pin, encryption, tag,
+	// TTL and replication options, reporting progress to opts.Progress
+	UploadWithOptions(ctx context.Context, reader io.Reader, name string, opts *UploadOptions) (*StorageUploadResult, error)
+
+	// UploadDir uploads every file below dir, named by its path relative to dir
+	UploadDir(ctx context.Context, dir string, opts *UploadOptions) ([]StorageUploadResult, error)
+
 	// Pin pins an existing CID
 	Pin(ctx context.Context, cid string, name string) (*StoragePinResult, error)
 
+	// PinWithOptions pins an existing CID with a name and replication factor
+	PinWithOptions(ctx context.Context, cid string, opts *PinOptions) (*StoragePinResult, error)
+
+	// PinMany pins several CIDs, reporting the outcome of each
+	PinMany(ctx context.Context, cids []string, opts *PinOptions) ([]StorageBatchResult, error)
+
 	// Status gets the pin status for a CID
 	Status(ctx context.Context, cid string) (*StorageStatus, error)
 
+	// Stat returns the namespace's index entry for a CID
+	Stat(ctx context.Context, cid string) (*StorageObject, error)
+
 	// Get retrieves content from IPFS by CID
 	Get(ctx context.Context, cid string) (io.ReadCloser, error)
 
 	// Unpin removes a pin from a CID
 	Unpin(ctx context.Context, cid string) error
+
+	// UnpinMany unpins several CIDs, reporting the outcome of each
+	UnpinMany(ctx context.Context, cids []string) ([]StorageBatchResult, error)
+
+	// List returns objects recorded for the namespace, filtered and paginated by opts
+	List(ctx context.Context, opts *StorageListOptions) (*StorageObjectList, error)
 }
 
 // MessageHandler is called when a pub/sub message is received
@@ -121,6 +159,7 @@ type NetworkStatus struct {
 	Uptime       time.Duration        `json:"uptime"`
 	IPFS         *IPFSPeerInfo        `json:"ipfs,omitempty"`
 	IPFSCluster  *IPFSClusterPeerInfo `json:"ipfs_cluster,omitempty"`
+	PubSub       *pubsub.Metrics      `json:"pubsub,omitempty"` // Validated, rejected and throttled messages
 }
 
 // IPFSPeerInfo contains IPFS peer information for discovery
@@ -131,8 +170,8 @@ type IPFSPeerInfo struct {
 
 // IPFSClusterPeerInfo contains IPFS Cluster peer information for cluster discovery
 type IPFSClusterPeerInfo struct {
-	PeerID    string   `json:"peer_id"`    // Cluster peer ID (different from IPFS peer ID)
-	Addresses []string `json:"addresses"`  // Cluster multiaddresses (e.g., /ip4/x.x.x.x/tcp/9098)
+	PeerID    string   `json:"peer_id"`   // Cluster peer ID (different from IPFS peer ID)
+	Addresses []string `json:"addresses"` // Cluster multiaddresses (e.g., /ip4/x.x.x.x/tcp/9098)
 }
 
 // HealthStatus contains health check information
@@ -143,11 +182,41 @@ type HealthStatus struct {
 	ResponseTime time.Duration     `json:"response_time"`
 }
 
+// PublishResult represents the result of publishing a pub/sub message
+type PublishResult struct {
+	Peers int `json:"peers"` // Peers in the topic when the message was sent
+}
+
+// SubscribeOptions configures a PubSubOptionSubscriber subscription
+type SubscribeOptions struct {
+	Since    *int64           // replay a durable topic after this sequence number
+	Presence *PresenceOptions // join the topic's presence once subscribed
+}
+
+// PresenceOptions identifies a member in a topic's presence
+type PresenceOptions struct {
+	MemberID string                 `json:"member_id"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+}
+
 // StorageUploadResult represents the result of uploading content to IPFS
 type StorageUploadResult struct {
-	Cid  string `json:"cid"`
-	Name string `json:"name"`
-	Size int64  `json:"size"`
+	Cid         string     `json:"cid"`
+	Name        string     `json:"name"`
+	Size        int64      `json:"size"`
+	ContentType string     `json:"content_type"`
+	Encrypted   bool       `json:"encrypted"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// UploadOptions configures StorageClient uploads. Zero values use the gateway defaults.
+type UploadOptions struct {
+	Pin               *bool                   // Pin after upload (default true)
+	Public            bool                    // Store unencrypted, readable from any IPFS gateway
+	Tags              []string                // Labels recorded in the object index
+	TTL               time.Duration           // Expire the object after this long (whole seconds)
+	ReplicationFactor int                     // Replicas to pin; 0 uses the gateway default
+	Progress          func(sent, total int64) // Called as bytes are sent; total is -1 if unknown
 }
 
 // StoragePinResult represents the result of pinning a CID
@@ -156,6 +225,57 @@ type StoragePinResult struct {
 	Name string `json:"name"`
 }
 
+// PinOptions configures StorageClient pins. Zero values use the gateway defaults.
+type PinOptions struct {
+	Name              string // Human-readable pin name (ignored by PinMany)
+	ReplicationFactor int    // Replicas to pin; 0 uses the gateway default
+}
+
+// StorageBatchResult is the outcome for one CID of a batch pin or unpin
+type StorageBatchResult struct {
+	Cid   string
+	Error error // nil on success
+}
+
+// StorageObject is an entry in the namespace's object index
+type StorageObject struct {
+	Cid         string     `json:"cid"`
+	Name        string     `json:"name"`
+	Size        int64      `json:"size"`
+	ContentType string     `json:"content_type"`
+	Tags        []string   `json:"tags"`
+	Encrypted   bool       `json:"encrypted"`
+	Pinned      bool       `json:"pinned"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	AccessedAt  *time.Time `json:"accessed_at,omitempty"`
+}
+
+// StorageListOptions filters and paginates StorageClient.List. Zero values are ignored.
+type StorageListOptions struct {
+	Prefix      string   // Name prefix
+	Query       string   // Name substring
+	Tags        []string // Objects must carry every tag
+	ContentType string   // Exact type, or a family such as "image/"
+	Pinned      *bool    // Only pinned or only unpinned objects
+	CreatedBy   string   // Wallet address or API key fingerprint
+	Sort        string   // "created_at" (default), "updated_at", "name" or "size"
+	Ascending   bool     // Sort ascending instead of descending
+	Limit       int      // Page size (default 100, max 1000)
+	Offset      int      // Number of matching objects to skip
+}
+
+// StorageObjectList is a page of the namespace's object index
+type StorageObjectList struct {
+	Objects []StorageObject `json:"objects"`
+	Count   int             `json:"count"`
+	Total   int64           `json:"total"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+}
+
 // StorageStatus represents the status of a pinned CID
 type StorageStatus struct {
 	Cid               string   `json:"cid"`
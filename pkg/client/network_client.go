@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 )
@@ -117,6 +118,12 @@ func (n *NetworkInfoImpl) GetStatus(ctx context.Context) (*NetworkStatus, error)
 	// Try to get IPFS Cluster peer info (optional - don't fail if unavailable)
 	ipfsClusterInfo := queryIPFSClusterPeerInfo()
 
+	var pubsubMetrics *pubsub.Metrics
+	if n.client.pubsub != nil && n.client.pubsub.adapter != nil {
+		m := n.client.pubsub.adapter.Metrics()
+		pubsubMetrics = &m
+	}
+
 	return &NetworkStatus{
 		NodeID:       host.ID().String(),
 		PeerID:       host.ID().String(),
@@ -126,6 +133,7 @@ func (n *NetworkInfoImpl) GetStatus(ctx context.Context) (*NetworkStatus, error)
 		Uptime:       time.Since(n.client.startTime),
 		IPFS:         ipfsInfo,
 		IPFSCluster:  ipfsClusterInfo,
+		PubSub:       pubsubMetrics,
 	}, nil
 }
 
@@ -31,6 +31,17 @@ func (p *pubSubBridge) Publish(ctx context.Context, topic string, data []byte) e
 	return p.adapter.Publish(ctx, topic, data)
 }
 
+func (p *pubSubBridge) PublishWithResult(ctx context.Context, topic string, data []byte) (*PublishResult, error) {
+	if err := p.client.requireAccess(ctx); err != nil {
+		return nil, fmt.Errorf("authentication required: %w - run CLI commands to authenticate automatically", err)
+	}
+	res, err := p.adapter.PublishWithResult(ctx, topic, data)
+	if err != nil {
+		return nil, err
+	}
+	return &PublishResult{Peers: res.Peers}, nil
+}
+
 func (p *pubSubBridge) Unsubscribe(ctx context.Context, topic string) error {
 	if err := p.client.requireAccess(ctx); err != nil {
 		return fmt.Errorf("authentication required: %w - run CLI commands to authenticate automatically", err)
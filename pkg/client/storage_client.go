@@ -4,13 +4,29 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// storageBatchConcurrency bounds concurrent requests in PinMany and UnpinMany
+	storageBatchConcurrency = 8
+
+	// maxStorageRetryDelay caps the backoff between retried requests
+	maxStorageRetryDelay = 30 * time.Second
+)
+
 // StorageClientImpl implements StorageClient using HTTP requests to the gateway
 type StorageClientImpl struct {
 	client *Client
@@ -18,44 +34,94 @@ type StorageClientImpl struct {
 
 // Upload uploads content to IPFS and pins it
 func (s *StorageClientImpl) Upload(ctx context.Context, reader io.Reader, name string) (*StorageUploadResult, error) {
+	return s.UploadWithOptions(ctx, reader, name, nil)
+}
+
+// UploadWithOptions streams content to the gateway. Failed uploads are retried
+// only when reader is an io.Seeker, since the content must be sent again.
+func (s *StorageClientImpl) UploadWithOptions(ctx context.Context, reader io.Reader, name string, opts *UploadOptions) (*StorageUploadResult, error) {
 	if err := s.client.requireAccess(ctx); err != nil {
 		return nil, fmt.Errorf("authentication required: %w", err)
 	}
 
-	gatewayURL := s.getGatewayURL()
-
-	// Create multipart form
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	// Add file field
-	part, err := writer.CreateFormFile("file", name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
+	// The multipart filename loses directories, so the name is also sent as a parameter
+	params := opts.query()
+	if strings.Contains(name, "/") {
+		params.Set("name", name)
+	}
+	reqURL := s.getGatewayURL() + "/v1/storage/upload"
+	if len(params) > 0 {
+		reqURL += "?" + params.Encode()
 	}
 
-	if _, err := io.Copy(part, reader); err != nil {
-		return nil, fmt.Errorf("failed to copy data: %w", err)
+	// A seekable reader has a known size and can be rewound for retries
+	total := int64(-1)
+	seeker, _ := reader.(io.Seeker)
+	var start int64
+	if seeker != nil {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seeker = nil
+		} else if end, err := seeker.Seek(0, io.SeekEnd); err != nil {
+			return nil, fmt.Errorf("failed to determine size: %w", err)
+		} else {
+			total = end - start
+		}
 	}
 
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close writer: %w", err)
+	var progress func(sent, total int64)
+	if opts != nil {
+		progress = opts.Progress
 	}
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "POST", gatewayURL+"/v1/storage/upload", &buf)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	// Each attempt streams a fresh multipart body; the previous writer must
+	// finish before the reader is rewound
+	var prev chan struct{}
+	newReq := func() (*http.Request, error) {
+		if prev != nil {
+			<-prev
+		}
+		if seeker != nil {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("failed to rewind content: %w", err)
+			}
+		}
+
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+		done := make(chan struct{})
+		prev = done
+		go func() {
+			defer close(done)
+			part, err := writer.CreateFormFile("file", name)
+			if err == nil {
+				_, err = io.Copy(part, &progressReader{r: reader, total: total, fn: progress})
+			}
+			if err == nil {
+				err = writer.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+
+		req, err := http.NewRequestWithContext(ctx, "POST", reqURL, pr)
+		if err != nil {
+			pr.Close()
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		s.addAuthHeaders(req)
+		return req, nil
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	s.addAuthHeaders(req)
+	retries := s.retryAttempts()
+	if seeker == nil {
+		retries = 0
+	}
 
-	// Execute request
-	client := &http.Client{Timeout: 5 * time.Minute} // Large timeout for file uploads
-	resp, err := client.Do(req)
+	// Uploads are streamed, so only ctx bounds their duration
+	resp, err := s.do(ctx, &http.Client{}, retries, newReq)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -72,8 +138,75 @@ func (s *StorageClientImpl) Upload(ctx context.Context, reader io.Reader, name s
 	return &result, nil
 }
 
+// UploadDir uploads every regular file below dir, naming each object by its
+// slash-separated path relative to dir. Progress, if set, reports bytes across
+// all files. On error the files uploaded so far are returned with it.
+func (s *StorageClientImpl) UploadDir(ctx context.Context, dir string, opts *UploadOptions) ([]StorageUploadResult, error) {
+	type dirFile struct {
+		path string
+		name string
+		size int64
+	}
+	var files []dirFile
+	var total int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, dirFile{path: p, name: filepath.ToSlash(rel), size: info.Size()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	fileOpts := UploadOptions{}
+	if opts != nil {
+		fileOpts = *opts
+	}
+	results := make([]StorageUploadResult, 0, len(files))
+	var sent int64
+	for _, f := range files {
+		if opts != nil && opts.Progress != nil {
+			offset := sent
+			fileOpts.Progress = func(n, _ int64) { opts.Progress(offset+n, total) }
+		}
+		result, err := s.uploadFile(ctx, f.path, f.name, &fileOpts)
+		if err != nil {
+			return results, fmt.Errorf("failed to upload %s: %w", f.name, err)
+		}
+		results = append(results, *result)
+		sent += f.size
+	}
+	return results, nil
+}
+
+// uploadFile uploads the file at path under name.
+func (s *StorageClientImpl) uploadFile(ctx context.Context, path, name string, opts *UploadOptions) (*StorageUploadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.UploadWithOptions(ctx, f, name, opts)
+}
+
 // Pin pins an existing CID
 func (s *StorageClientImpl) Pin(ctx context.Context, cid string, name string) (*StoragePinResult, error) {
+	return s.PinWithOptions(ctx, cid, &PinOptions{Name: name})
+}
+
+// PinWithOptions pins an existing CID with a name and replication factor
+func (s *StorageClientImpl) PinWithOptions(ctx context.Context, cid string, opts *PinOptions) (*StoragePinResult, error) {
 	if err := s.client.requireAccess(ctx); err != nil {
 		return nil, fmt.Errorf("authentication required: %w", err)
 	}
@@ -83,8 +216,13 @@ func (s *StorageClientImpl) Pin(ctx context.Context, cid string, name string) (*
 	reqBody := map[string]interface{}{
 		"cid": cid,
 	}
-	if name != "" {
-		reqBody["name"] = name
+	if opts != nil {
+		if opts.Name != "" {
+			reqBody["name"] = opts.Name
+		}
+		if opts.ReplicationFactor > 0 {
+			reqBody["replication_factor"] = opts.ReplicationFactor
+		}
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
@@ -92,18 +230,18 @@ func (s *StorageClientImpl) Pin(ctx context.Context, cid string, name string) (*
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", gatewayURL+"/v1/storage/pin", bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	s.addAuthHeaders(req)
-
 	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := s.do(ctx, client, s.retryAttempts(), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", gatewayURL+"/v1/storage/pin", bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		s.addAuthHeaders(req)
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -120,25 +258,28 @@ func (s *StorageClientImpl) Pin(ctx context.Context, cid string, name string) (*
 	return &result, nil
 }
 
+// PinMany pins several CIDs concurrently. Results follow the order of cids;
+// the returned error is non-nil if any pin failed.
+func (s *StorageClientImpl) PinMany(ctx context.Context, cids []string, opts *PinOptions) ([]StorageBatchResult, error) {
+	var pinOpts PinOptions
+	if opts != nil {
+		pinOpts.ReplicationFactor = opts.ReplicationFactor
+	}
+	return s.batch(ctx, "pin", cids, func(cid string) error {
+		_, err := s.PinWithOptions(ctx, cid, &pinOpts)
+		return err
+	})
+}
+
 // Status gets the pin status for a CID
 func (s *StorageClientImpl) Status(ctx context.Context, cid string) (*StorageStatus, error) {
 	if err := s.client.requireAccess(ctx); err != nil {
 		return nil, fmt.Errorf("authentication required: %w", err)
 	}
 
-	gatewayURL := s.getGatewayURL()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", gatewayURL+"/v1/storage/status/"+cid, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	s.addAuthHeaders(req)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := s.get(ctx, "/v1/storage/status/"+cid, 30*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -155,25 +296,44 @@ func (s *StorageClientImpl) Status(ctx context.Context, cid string) (*StorageSta
 	return &result, nil
 }
 
-// Get retrieves content from IPFS by CID
-func (s *StorageClientImpl) Get(ctx context.Context, cid string) (io.ReadCloser, error) {
+// Stat returns the namespace's index entry for a CID. It returns an error
+// wrapping ErrNotFound if the namespace has no such object.
+func (s *StorageClientImpl) Stat(ctx context.Context, cid string) (*StorageObject, error) {
 	if err := s.client.requireAccess(ctx); err != nil {
 		return nil, fmt.Errorf("authentication required: %w", err)
 	}
 
-	gatewayURL := s.getGatewayURL()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", gatewayURL+"/v1/storage/get/"+cid, nil)
+	resp, err := s.get(ctx, "/v1/storage/objects/"+url.PathEscape(cid), 30*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	s.addAuthHeaders(req)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("stat %s: %w", cid, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("stat failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result StorageObject
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
 
-	client := &http.Client{Timeout: 5 * time.Minute} // Large timeout for file downloads
-	resp, err := client.Do(req)
+// Get retrieves content from IPFS by CID
+func (s *StorageClientImpl) Get(ctx context.Context, cid string) (io.ReadCloser, error) {
+	if err := s.client.requireAccess(ctx); err != nil {
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	resp, err := s.get(ctx, "/v1/storage/get/"+cid, 5*time.Minute) // Large timeout for file downloads
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -192,26 +352,248 @@ func (s *StorageClientImpl) Unpin(ctx context.Context, cid string) error {
 
 	gatewayURL := s.getGatewayURL()
 
-	req, err := http.NewRequestWithContext(ctx, "DELETE", gatewayURL+"/v1/storage/unpin/"+cid, nil)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := s.do(ctx, client, s.retryAttempts(), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "DELETE", gatewayURL+"/v1/storage/unpin/"+cid, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		s.addAuthHeaders(req)
+		return req, nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
+	defer resp.Body.Close()
 
-	s.addAuthHeaders(req)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unpin failed with status %d: %s", resp.StatusCode, string(body))
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	return nil
+}
+
+// UnpinMany unpins several CIDs concurrently. Results follow the order of
+// cids; the returned error is non-nil if any unpin failed.
+func (s *StorageClientImpl) UnpinMany(ctx context.Context, cids []string) ([]StorageBatchResult, error) {
+	return s.batch(ctx, "unpin", cids, func(cid string) error {
+		return s.Unpin(ctx, cid)
+	})
+}
+
+// List returns objects recorded for the namespace
+func (s *StorageClientImpl) List(ctx context.Context, opts *StorageListOptions) (*StorageObjectList, error) {
+	if err := s.client.requireAccess(ctx); err != nil {
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	params := url.Values{}
+	if opts != nil {
+		if opts.Prefix != "" {
+			params.Set("prefix", opts.Prefix)
+		}
+		if opts.Query != "" {
+			params.Set("q", opts.Query)
+		}
+		for _, tag := range opts.Tags {
+			params.Add("tag", tag)
+		}
+		if opts.ContentType != "" {
+			params.Set("content_type", opts.ContentType)
+		}
+		if opts.Pinned != nil {
+			params.Set("pinned", strconv.FormatBool(*opts.Pinned))
+		}
+		if opts.CreatedBy != "" {
+			params.Set("created_by", opts.CreatedBy)
+		}
+		if opts.Sort != "" {
+			params.Set("sort", opts.Sort)
+		}
+		if opts.Ascending {
+			params.Set("order", "asc")
+		}
+		if opts.Limit > 0 {
+			params.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		if opts.Offset > 0 {
+			params.Set("offset", strconv.Itoa(opts.Offset))
+		}
+	}
+
+	path := "/v1/storage/objects"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	resp, err := s.get(ctx, path, 30*time.Second)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unpin failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("list failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	var result StorageObjectList
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// get sends an authenticated GET for path, retrying transient failures.
+func (s *StorageClientImpl) get(ctx context.Context, path string, timeout time.Duration) (*http.Response, error) {
+	reqURL := s.getGatewayURL() + path
+	client := &http.Client{Timeout: timeout}
+	return s.do(ctx, client, s.retryAttempts(), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		s.addAuthHeaders(req)
+		return req, nil
+	})
+}
+
+// do sends the request built by newReq, retrying up to retries times on
+// network errors and transient gateway or cluster failures. newReq is called
+// once per attempt. The last response is returned for the caller to check.
+func (s *StorageClientImpl) do(ctx context.Context, client *http.Client, retries int, newReq func() (*http.Request, error)) (*http.Response, error) {
+	delay := s.retryDelay()
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if attempt >= retries || ctx.Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("request failed: %w", err)
+			}
+			return resp, nil
+		}
+		if err == nil {
+			if !retryableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("request failed: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxStorageRetryDelay)
+	}
+}
+
+// batch runs op for each CID with bounded concurrency.
+func (s *StorageClientImpl) batch(ctx context.Context, opName string, cids []string, op func(cid string) error) ([]StorageBatchResult, error) {
+	if err := s.client.requireAccess(ctx); err != nil {
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	results := make([]StorageBatchResult, len(cids))
+	sem := make(chan struct{}, storageBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, cid := range cids {
+		results[i].Cid = cid
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cid string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Error = op(cid)
+		}(i, cid)
+	}
+	wg.Wait()
+
+	var failed int
+	var errs []error
+	for _, r := range results {
+		if r.Error != nil {
+			failed++
+			errs = append(errs, fmt.Errorf("%s: %w", r.Cid, r.Error))
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d %s requests failed: %w", failed, len(cids), opName, errors.Join(errs...))
+	}
+	return results, nil
+}
+
+// retryAttempts returns how often failed requests are retried.
+func (s *StorageClientImpl) retryAttempts() int {
+	if cfg := s.client.Config(); cfg != nil && cfg.RetryAttempts > 0 {
+		return cfg.RetryAttempts
+	}
+	return 0
+}
+
+// retryDelay returns the delay before the first retry.
+func (s *StorageClientImpl) retryDelay() time.Duration {
+	if cfg := s.client.Config(); cfg != nil && cfg.RetryDelay > 0 {
+		return cfg.RetryDelay
+	}
+	return time.Second
+}
+
+// retryableStatus reports whether a gateway status indicates a transient
+// failure, such as an unreachable or overloaded IPFS cluster.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// query returns the upload query parameters for o.
+func (o *UploadOptions) query() url.Values {
+	params := url.Values{}
+	if o == nil {
+		return params
+	}
+	if o.Pin != nil {
+		params.Set("pin", strconv.FormatBool(*o.Pin))
+	}
+	if o.Public {
+		params.Set("public", "true")
+	}
+	if len(o.Tags) > 0 {
+		params.Set("tags", strings.Join(o.Tags, ","))
+	}
+	if o.TTL > 0 {
+		params.Set("ttl", strconv.FormatInt(int64(o.TTL/time.Second), 10))
+	}
+	if o.ReplicationFactor > 0 {
+		params.Set("replication_factor", strconv.Itoa(o.ReplicationFactor))
+	}
+	return params
+}
+
+// progressReader reports the bytes read so far to fn.
+type progressReader struct {
+	r     io.Reader
+	sent  int64
+	total int64
+	fn    func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 && p.fn != nil {
+		p.sent += int64(n)
+		p.fn(p.sent, p.total)
+	}
+	return n, err
 }
 
 // getGatewayURL returns the gateway URL from config
@@ -3,11 +3,16 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestStorageClientImpl_Upload(t *testing.T) {
@@ -376,3 +381,247 @@ func TestStorageClientImpl_addAuthHeaders(t *testing.T) {
 		}
 	})
 }
+
+func TestStorageClientImpl_List(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/storage/objects" {
+				t.Errorf("Expected path '/v1/storage/objects', got %s", r.URL.Path)
+			}
+			q := r.URL.Query()
+			if got := q["tag"]; len(got) != 2 || got[0] != "photos" || got[1] != "2026" {
+				t.Errorf("Expected tags [photos 2026], got %v", got)
+			}
+			if q.Get("content_type") != "image/" || q.Get("pinned") != "true" || q.Get("limit") != "10" || q.Get("offset") != "20" {
+				t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+			}
+
+			response := StorageObjectList{
+				Objects: []StorageObject{{Cid: "QmList123", Name: "cat.png", ContentType: "image/png", Tags: []string{"photos", "2026"}, Pinned: true}},
+				Count:   1,
+				Total:   21,
+				Limit:   10,
+				Offset:  20,
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		}))
+		defer server.Close()
+
+		cfg := &ClientConfig{
+			GatewayURL: server.URL,
+			AppName:    "test-app",
+			APIKey:     "ak_test:test-app", // Required for requireAccess check
+		}
+		client := &Client{config: cfg}
+		storage := &StorageClientImpl{client: client}
+
+		pinned := true
+		list, err := storage.List(context.Background(), &StorageListOptions{
+			Tags:        []string{"photos", "2026"},
+			ContentType: "image/",
+			Pinned:      &pinned,
+			Limit:       10,
+			Offset:      20,
+		})
+		if err != nil {
+			t.Fatalf("Failed to list objects: %v", err)
+		}
+
+		if list.Total != 21 || len(list.Objects) != 1 {
+			t.Fatalf("Expected 1 of 21 objects, got %d of %d", len(list.Objects), list.Total)
+		}
+		if list.Objects[0].Cid != "QmList123" || list.Objects[0].ContentType != "image/png" {
+			t.Errorf("Unexpected object: %+v", list.Objects[0])
+		}
+	})
+}
+
+func newTestStorageClient(serverURL string, retries int) *StorageClientImpl {
+	cfg := &ClientConfig{
+		GatewayURL:    serverURL,
+		AppName:       "test-app",
+		APIKey:        "ak_test:test-app", // Required for requireAccess check
+		RetryAttempts: retries,
+		RetryDelay:    time.Millisecond,
+	}
+	return &StorageClientImpl{client: &Client{config: cfg}}
+}
+
+func TestStorageClientImpl_UploadWithOptions(t *testing.T) {
+	t.Run("options_progress_and_retry", func(t *testing.T) {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if q.Get("pin") != "false" || q.Get("public") != "true" || q.Get("tags") != "a,b" ||
+				q.Get("ttl") != "3600" || q.Get("replication_factor") != "2" {
+				t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+			}
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("Failed to get file: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			if string(data) != "hello world" {
+				t.Errorf("Expected full content on every attempt, got %q", data)
+			}
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			json.NewEncoder(w).Encode(StorageUploadResult{Cid: "QmOpts", Size: int64(len(data))})
+		}))
+		defer server.Close()
+
+		storage := newTestStorageClient(server.URL, 2)
+		pin := false
+		var lastSent, lastTotal int64
+		result, err := storage.UploadWithOptions(context.Background(), strings.NewReader("hello world"), "a.txt", &UploadOptions{
+			Pin:               &pin,
+			Public:            true,
+			Tags:              []string{"a", "b"},
+			TTL:               time.Hour,
+			ReplicationFactor: 2,
+			Progress:          func(sent, total int64) { lastSent, lastTotal = sent, total },
+		})
+		if err != nil {
+			t.Fatalf("Failed to upload: %v", err)
+		}
+		if result.Cid != "QmOpts" || attempts != 2 {
+			t.Errorf("Expected success on the second attempt, got %+v after %d attempts", result, attempts)
+		}
+		if lastSent != 11 || lastTotal != 11 {
+			t.Errorf("Expected progress 11/11, got %d/%d", lastSent, lastTotal)
+		}
+	})
+
+	t.Run("unseekable_not_retried", func(t *testing.T) {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		storage := newTestStorageClient(server.URL, 3)
+		_, err := storage.UploadWithOptions(context.Background(), io.LimitReader(strings.NewReader("stream"), 6), "s.txt", nil)
+		if err == nil || attempts != 1 {
+			t.Fatalf("Expected a single failed attempt, got %d attempts (err %v)", attempts, err)
+		}
+	})
+}
+
+func TestStorageClientImpl_UploadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "img"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "img", "logo.png"), []byte("png-data"), 0o644)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("Failed to get file: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		name := header.Filename
+		if v := r.URL.Query().Get("name"); v != "" {
+			name = v
+		}
+		json.NewEncoder(w).Encode(StorageUploadResult{Cid: "Qm" + name, Name: name, Size: int64(len(data))})
+	}))
+	defer server.Close()
+
+	storage := newTestStorageClient(server.URL, 0)
+	var lastSent, lastTotal int64
+	results, err := storage.UploadDir(context.Background(), dir, &UploadOptions{
+		Progress: func(sent, total int64) { lastSent, lastTotal = sent, total },
+	})
+	if err != nil {
+		t.Fatalf("Failed to upload directory: %v", err)
+	}
+	if len(results) != 2 || results[0].Name != "img/logo.png" || results[1].Name != "index.html" {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	if lastSent != 14 || lastTotal != 14 {
+		t.Errorf("Expected progress 14/14 across files, got %d/%d", lastSent, lastTotal)
+	}
+}
+
+func TestStorageClientImpl_PinMany(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["replication_factor"] != float64(2) {
+			t.Errorf("Expected replication_factor 2, got %v", req["replication_factor"])
+		}
+		if req["cid"] == "QmBad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(StoragePinResult{Cid: req["cid"].(string)})
+	}))
+	defer server.Close()
+
+	storage := newTestStorageClient(server.URL, 0)
+	results, err := storage.PinMany(context.Background(), []string{"QmA", "QmBad", "QmB"}, &PinOptions{ReplicationFactor: 2})
+	if err == nil {
+		t.Fatal("Expected an error for the failed pin")
+	}
+	if len(results) != 3 || results[0].Error != nil || results[1].Error == nil || results[2].Error != nil || results[1].Cid != "QmBad" {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+}
+
+func TestStorageClientImpl_UnpinMany(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Method != http.MethodDelete || !strings.HasPrefix(r.URL.Path, "/v1/storage/unpin/") {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	storage := newTestStorageClient(server.URL, 0)
+	results, err := storage.UnpinMany(context.Background(), []string{"QmA", "QmB"})
+	if err != nil || len(results) != 2 || calls != 2 {
+		t.Fatalf("Expected two successful unpins, got %+v (err %v, %d calls)", results, err, calls)
+	}
+}
+
+func TestStorageClientImpl_Stat(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/storage/objects/QmStat":
+			// The first attempt hits a transient cluster error
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			json.NewEncoder(w).Encode(StorageObject{Cid: "QmStat", Name: "a.txt", Size: 5, Pinned: true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	storage := newTestStorageClient(server.URL, 1)
+	obj, err := storage.Stat(context.Background(), "QmStat")
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+	if obj.Name != "a.txt" || obj.Size != 5 || !obj.Pinned {
+		t.Errorf("Unexpected object: %+v", obj)
+	}
+
+	if _, err := storage.Stat(context.Background(), "QmMissing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
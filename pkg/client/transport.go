@@ -16,20 +16,28 @@ func getGatewayURL(c *Client) string {
 
 // addAuthHeaders adds authentication headers to the request
 func addAuthHeaders(req *http.Request, c *Client) {
-	cfg := c.Config()
+	for k, v := range authHeader(c.Config()) {
+		req.Header[k] = v
+	}
+}
+
+// authHeader returns the authentication headers for cfg
+func authHeader(cfg *ClientConfig) http.Header {
+	h := http.Header{}
 	if cfg == nil {
-		return
+		return h
 	}
 
 	// Prefer JWT if available
 	if cfg.JWT != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.JWT)
-		return
+		h.Set("Authorization", "Bearer "+cfg.JWT)
+		return h
 	}
 
 	// Fallback to API key
 	if cfg.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
-		req.Header.Set("X-API-Key", cfg.APIKey)
+		h.Set("Authorization", "Bearer "+cfg.APIKey)
+		h.Set("X-API-Key", cfg.APIKey)
 	}
+	return h
 }
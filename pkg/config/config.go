@@ -12,6 +12,7 @@ type Config struct {
 	Node        NodeConfig        `yaml:"node"`
 	Database    DatabaseConfig    `yaml:"database"`
 	Discovery   DiscoveryConfig   `yaml:"discovery"`
+	PubSub      PubSubConfig      `yaml:"pubsub"`
 	Security    SecurityConfig    `yaml:"security"`
 	Logging     LoggingConfig     `yaml:"logging"`
 	HTTPGateway HTTPGatewayConfig `yaml:"http_gateway"`
@@ -63,6 +64,26 @@ func (c *Config) Validate() []error {
 		RaftAdvAddress:    c.Discovery.RaftAdvAddress,
 	})...)
 
+	// Validate pub/sub config
+	errs = append(errs, validate.ValidatePubSub(validate.PubSubConfig{
+		MeshD:                       c.PubSub.MeshD,
+		MeshDlo:                     c.PubSub.MeshDlo,
+		MeshDhi:                     c.PubSub.MeshDhi,
+		MeshDlazy:                   c.PubSub.MeshDlazy,
+		HistoryLength:               c.PubSub.HistoryLength,
+		HistoryGossip:               c.PubSub.HistoryGossip,
+		GossipThreshold:             c.PubSub.GossipThreshold,
+		PublishThreshold:            c.PubSub.PublishThreshold,
+		GraylistThreshold:           c.PubSub.GraylistThreshold,
+		AcceptPXThreshold:           c.PubSub.AcceptPXThreshold,
+		OpportunisticGraftThreshold: c.PubSub.OpportunisticGraftThreshold,
+		MaxMessageSize:              c.PubSub.MaxMessageSize,
+		PeerMessageRate:             c.PubSub.PeerMessageRate,
+		AllowedNamespaces:           c.PubSub.AllowedNamespaces,
+		ValidateQueueSize:           c.PubSub.ValidateQueueSize,
+		ValidateThrottle:            c.PubSub.ValidateThrottle,
+	})...)
+
 	// Validate security config
 	errs = append(errs, validate.ValidateSecurity(validate.SecurityConfig{
 		EnableTLS:       c.Security.EnableTLS,
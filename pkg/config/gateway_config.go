@@ -19,6 +19,14 @@ type HTTPGatewayConfig struct {
 	IPFSClusterAPIURL string        `yaml:"ipfs_cluster_api_url"` // IPFS Cluster API URL
 	IPFSAPIURL        string        `yaml:"ipfs_api_url"`         // IPFS API URL
 	IPFSTimeout       time.Duration `yaml:"ipfs_timeout"`         // Timeout for IPFS operations
+
+	TrustedProxies []string               `yaml:"trusted_proxies"` // CIDRs of proxies whose forwarding headers are trusted
+	RateLimit      GatewayRateLimitConfig `yaml:"rate_limit"`      // Per-client request rate limiting
+}
+
+// GatewayRateLimitConfig contains the rate limiting settings of the gateway
+type GatewayRateLimitConfig struct {
+	Enabled *bool `yaml:"enabled"` // Enable rate limiting (default: true)
 }
 
 // HTTPSConfig contains HTTPS/TLS configuration for the gateway
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+// PubSubConfig tunes GossipSub, peer scoring and the validation of incoming
+// pub/sub messages. Zero values use the defaults.
+type PubSubConfig struct {
+	// Mesh degree: target, low and high watermarks, and peers gossiped to outside the mesh
+	MeshD             int           `yaml:"mesh_d"`             // default: 6
+	MeshDlo           int           `yaml:"mesh_dlo"`           // default: 5
+	MeshDhi           int           `yaml:"mesh_dhi"`           // default: 12
+	MeshDlazy         int           `yaml:"mesh_dlazy"`         // default: 6
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"` // default: 1s
+	HistoryLength     int           `yaml:"history_length"`     // Heartbeats messages are cached for (default: 5)
+	HistoryGossip     int           `yaml:"history_gossip"`     // Heartbeats messages are gossiped for (default: 3)
+	FloodPublish      *bool         `yaml:"flood_publish"`      // Send own messages to every topic peer (default: true)
+
+	// Peer scoring
+	DisableScoring              bool    `yaml:"disable_scoring"`
+	GossipThreshold             float64 `yaml:"gossip_threshold"`              // default: -500
+	PublishThreshold            float64 `yaml:"publish_threshold"`             // default: -1000
+	GraylistThreshold           float64 `yaml:"graylist_threshold"`            // default: -2500
+	AcceptPXThreshold           float64 `yaml:"accept_px_threshold"`           // default: 0
+	OpportunisticGraftThreshold float64 `yaml:"opportunistic_graft_threshold"` // default: 1
+
+	// Message validation
+	MaxMessageSize    int      `yaml:"max_message_size"`    // Bytes (default: 1 MiB)
+	PeerMessageRate   float64  `yaml:"peer_message_rate"`   // Messages per second per publisher and topic (default: unlimited)
+	AllowedNamespaces []string `yaml:"allowed_namespaces"`  // Empty accepts any valid namespace
+	ValidateQueueSize int      `yaml:"validate_queue_size"` // default: 32
+	ValidateThrottle  int      `yaml:"validate_throttle"`   // default: 8192
+}
@@ -0,0 +1,105 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// PubSubConfig represents the pub/sub configuration for validation purposes.
+type PubSubConfig struct {
+	MeshD, MeshDlo, MeshDhi, MeshDlazy int
+	HistoryLength, HistoryGossip       int
+	GossipThreshold                    float64
+	PublishThreshold                   float64
+	GraylistThreshold                  float64
+	AcceptPXThreshold                  float64
+	OpportunisticGraftThreshold        float64
+	MaxMessageSize                     int
+	PeerMessageRate                    float64
+	AllowedNamespaces                  []string
+	ValidateQueueSize                  int
+	ValidateThrottle                   int
+}
+
+var namespaceRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,63}$`)
+
+// ValidatePubSub performs validation of the pub/sub configuration.
+func ValidatePubSub(ps PubSubConfig) []error {
+	var errs []error
+
+	// Counts and sizes; zero uses the default
+	for path, v := range map[string]float64{
+		"pubsub.mesh_d":              float64(ps.MeshD),
+		"pubsub.mesh_dlo":            float64(ps.MeshDlo),
+		"pubsub.mesh_dhi":            float64(ps.MeshDhi),
+		"pubsub.mesh_dlazy":          float64(ps.MeshDlazy),
+		"pubsub.history_length":      float64(ps.HistoryLength),
+		"pubsub.history_gossip":      float64(ps.HistoryGossip),
+		"pubsub.max_message_size":    float64(ps.MaxMessageSize),
+		"pubsub.peer_message_rate":   ps.PeerMessageRate,
+		"pubsub.validate_queue_size": float64(ps.ValidateQueueSize),
+		"pubsub.validate_throttle":   float64(ps.ValidateThrottle),
+	} {
+		if v < 0 {
+			errs = append(errs, ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("must be >= 0 (0 uses the default); got %v", v),
+			})
+		}
+	}
+
+	// Mesh degrees, where set
+	if ps.MeshDlo > 0 && ps.MeshD > 0 && ps.MeshDlo > ps.MeshD {
+		errs = append(errs, ValidationError{
+			Path:    "pubsub.mesh_dlo",
+			Message: fmt.Sprintf("must not exceed mesh_d; got %d > %d", ps.MeshDlo, ps.MeshD),
+		})
+	}
+	if ps.MeshDhi > 0 && ps.MeshD > 0 && ps.MeshD > ps.MeshDhi {
+		errs = append(errs, ValidationError{
+			Path:    "pubsub.mesh_dhi",
+			Message: fmt.Sprintf("must be at least mesh_d; got %d < %d", ps.MeshDhi, ps.MeshD),
+		})
+	}
+	if ps.HistoryLength > 0 && ps.HistoryGossip > ps.HistoryLength {
+		errs = append(errs, ValidationError{
+			Path:    "pubsub.history_gossip",
+			Message: fmt.Sprintf("must not exceed history_length; got %d > %d", ps.HistoryGossip, ps.HistoryLength),
+		})
+	}
+
+	// Score thresholds, where set
+	if ps.GossipThreshold != 0 || ps.PublishThreshold != 0 || ps.GraylistThreshold != 0 {
+		if ps.GossipThreshold > 0 || ps.PublishThreshold > ps.GossipThreshold || ps.GraylistThreshold > ps.PublishThreshold {
+			errs = append(errs, ValidationError{
+				Path:    "pubsub.gossip_threshold",
+				Message: fmt.Sprintf("thresholds out of order: gossip %v, publish %v, graylist %v", ps.GossipThreshold, ps.PublishThreshold, ps.GraylistThreshold),
+				Hint:    "expected graylist_threshold <= publish_threshold <= gossip_threshold <= 0",
+			})
+		}
+	}
+	if ps.AcceptPXThreshold < 0 {
+		errs = append(errs, ValidationError{
+			Path:    "pubsub.accept_px_threshold",
+			Message: fmt.Sprintf("must be >= 0; got %v", ps.AcceptPXThreshold),
+		})
+	}
+	if ps.OpportunisticGraftThreshold < 0 {
+		errs = append(errs, ValidationError{
+			Path:    "pubsub.opportunistic_graft_threshold",
+			Message: fmt.Sprintf("must be >= 0; got %v", ps.OpportunisticGraftThreshold),
+		})
+	}
+
+	for i, ns := range ps.AllowedNamespaces {
+		if !namespaceRe.MatchString(ns) {
+			errs = append(errs, ValidationError{
+				Path:    fmt.Sprintf("pubsub.allowed_namespaces[%d]", i),
+				Message: fmt.Sprintf("invalid namespace %q", ns),
+				Hint:    "letters, digits, '-' and '_', up to 64 characters",
+			})
+		}
+	}
+
+	return errs
+}
@@ -370,3 +370,33 @@ func TestValidateCompleteConfig(t *testing.T) {
 		t.Errorf("valid config should not have errors: %v", errs)
 	}
 }
+
+func TestValidatePubSub(t *testing.T) {
+	tests := []struct {
+		name        string
+		pubsub      PubSubConfig
+		shouldError bool
+	}{
+		{"defaults", PubSubConfig{}, false},
+		{"valid mesh", PubSubConfig{MeshD: 8, MeshDlo: 6, MeshDhi: 12}, false},
+		{"valid thresholds", PubSubConfig{GossipThreshold: -100, PublishThreshold: -200, GraylistThreshold: -400}, false},
+		{"mesh dlo above d", PubSubConfig{MeshD: 4, MeshDlo: 6}, true},
+		{"thresholds out of order", PubSubConfig{GossipThreshold: -200, PublishThreshold: -100, GraylistThreshold: -400}, true},
+		{"negative rate", PubSubConfig{PeerMessageRate: -1}, true},
+		{"invalid namespace", PubSubConfig{AllowedNamespaces: []string{"a.b"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfigForNode()
+			cfg.PubSub = tt.pubsub
+			errs := cfg.Validate()
+			if tt.shouldError && len(errs) == 0 {
+				t.Errorf("expected error, got none")
+			}
+			if !tt.shouldError && len(errs) > 0 {
+				t.Errorf("unexpected errors: %v", errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Envelope encryption for objects stored on IPFS.
+//
+// Every object is encrypted with its own random data key. Data keys are
+// wrapped (AES-256-GCM) with a per-namespace key, which is in turn wrapped
+// with the cluster master key; only wrapped keys are ever persisted.
+//
+// Object bodies use a segmented AES-256-GCM stream so arbitrarily large
+// objects can be encrypted and decrypted without buffering:
+//
+//	header  = magic "DBE1" || 7-byte random nonce prefix
+//	segment = AES-GCM(plaintext[i*64KiB:(i+1)*64KiB])
+//	nonce_i = prefix || uint32 big-endian i || last-segment flag
+//
+// The last-segment flag makes truncation and reordering detectable.
+
+// StreamAlgorithm identifies the object body format in stored metadata.
+const StreamAlgorithm = "AES-256-GCM-STREAM-64K"
+
+// KeySize is the size of data, namespace and master keys.
+const KeySize = 32
+
+const (
+	segmentSize     = 64 << 10
+	noncePrefixSize = 7
+	tagSize         = 16
+)
+
+var streamMagic = []byte("DBE1")
+
+// HeaderSize is the size of the stream header preceding the first segment.
+const HeaderSize = 4 + noncePrefixSize
+
+// ErrDecrypt is returned when ciphertext fails authentication.
+var ErrDecrypt = errors.New("encryption: message authentication failed")
+
+// NewKey returns a random 256-bit key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// WrapKey encrypts key with kek. aad binds the wrapped key to its owner
+// (e.g. the namespace) so it cannot be transplanted.
+func WrapKey(kek, key, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, key, aad), nil
+}
+
+// UnwrapKey reverses WrapKey.
+func UnwrapKey(kek, wrapped, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ct := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+	key, err := gcm.Open(nil, nonce, ct, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption: key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func segmentNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[noncePrefixSize+4] = 1
+	}
+	return nonce
+}
+
+// EncryptingReader encrypts a plaintext stream as it is read.
+type EncryptingReader struct {
+	src     *bufio.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte // pending ciphertext
+	out     []byte
+	plain   []byte
+	n       int64
+	done    bool
+	err     error
+}
+
+// NewEncryptingReader returns a reader producing the encrypted form of r
+// under dataKey.
+func NewEncryptingReader(r io.Reader, dataKey []byte) (*EncryptingReader, error) {
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	header := append(append([]byte{}, streamMagic...), prefix...)
+	return &EncryptingReader{
+		src:    bufio.NewReaderSize(r, segmentSize),
+		gcm:    gcm,
+		prefix: prefix,
+		buf:    header,
+		out:    make([]byte, 0, segmentSize+tagSize),
+		plain:  make([]byte, segmentSize),
+	}, nil
+}
+
+// PlaintextSize returns the number of plaintext bytes consumed so far.
+func (e *EncryptingReader) PlaintextSize() int64 {
+	return e.n
+}
+
+func (e *EncryptingReader) Read(p []byte) (int, error) {
+	for len(e.buf) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		if e.done {
+			return 0, io.EOF
+		}
+		e.sealNext()
+	}
+	n := copy(p, e.buf)
+	e.buf = e.buf[n:]
+	return n, nil
+}
+
+func (e *EncryptingReader) sealNext() {
+	n, err := io.ReadFull(e.src, e.plain)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		e.err = err
+		return
+	default:
+		// A full segment is the last one only if nothing follows it
+		if _, perr := e.src.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			e.err = perr
+			return
+		}
+	}
+	if e.counter == ^uint32(0) {
+		e.err = fmt.Errorf("encryption: object too large")
+		return
+	}
+	e.n += int64(n)
+	e.out = e.gcm.Seal(e.out[:0], segmentNonce(e.prefix, e.counter, last), e.plain[:n], nil)
+	e.buf = e.out
+	e.counter++
+	e.done = last
+}
+
+// DecryptingReader authenticates and decrypts a stream produced by
+// EncryptingReader.
+type DecryptingReader struct {
+	src     *bufio.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	size    int64 // plaintext size when known, else -1
+	buf     []byte
+	seg     []byte
+	done    bool
+	err     error
+}
+
+// NewDecryptingReader returns a reader yielding the plaintext of r. Read
+// fails with ErrDecrypt if the stream was tampered with or truncated.
+func NewDecryptingReader(r io.Reader, dataKey []byte) (*DecryptingReader, error) {
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &DecryptingReader{
+		src:  bufio.NewReaderSize(r, segmentSize+tagSize),
+		gcm:  gcm,
+		size: -1,
+		seg:  make([]byte, segmentSize+tagSize),
+	}, nil
+}
+
+// NewSegmentDecryptingReader decrypts a stream that starts at a segment
+// boundary, as located by SegmentSpan. header is the stream's first
+// HeaderSize bytes and plaintextSize the size of the whole object. Because
+// the final segment is identified from plaintextSize, r may end after any
+// segment; reading past it then fails.
+func NewSegmentDecryptingReader(r io.Reader, dataKey, header []byte, segment uint32, plaintextSize int64) (*DecryptingReader, error) {
+	d, err := NewDecryptingReader(r, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) != HeaderSize || string(header[:len(streamMagic)]) != string(streamMagic) {
+		return nil, fmt.Errorf("encryption: unknown stream format")
+	}
+	d.prefix = append([]byte{}, header[len(streamMagic):]...)
+	d.counter = segment
+	d.size = plaintextSize
+	return d, nil
+}
+
+// SegmentSpan locates the plaintext bytes [off, off+n) in an encrypted stream.
+// It returns the index of the first segment covering them, the ciphertext
+// offset and length of the covering segments, and the number of plaintext
+// bytes to skip in the first segment. The length may extend past the end of
+// the stream.
+func SegmentSpan(off, n int64) (segment uint32, cipherOffset, cipherLength, skip int64) {
+	first := off / segmentSize
+	last := (off + max(n, 1) - 1) / segmentSize
+	cipherOffset = HeaderSize + first*(segmentSize+tagSize)
+	return uint32(first), cipherOffset, (last - first + 1) * (segmentSize + tagSize), off - first*segmentSize
+}
+
+func (d *DecryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.openNext()
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *DecryptingReader) openNext() {
+	if d.prefix == nil {
+		header := make([]byte, HeaderSize)
+		if _, err := io.ReadFull(d.src, header); err != nil {
+			d.err = ErrDecrypt
+			return
+		}
+		if string(header[:len(streamMagic)]) != string(streamMagic) {
+			d.err = fmt.Errorf("encryption: unknown stream format")
+			return
+		}
+		d.prefix = header[len(streamMagic):]
+	}
+
+	if d.size >= 0 {
+		d.openKnownSize()
+		return
+	}
+
+	n, err := io.ReadFull(d.src, d.seg)
+	last := false
+	switch {
+	case err == io.EOF:
+		d.err = ErrDecrypt // stream ended without a final segment
+		return
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		d.err = err
+		return
+	default:
+		if _, perr := d.src.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			d.err = perr
+			return
+		}
+	}
+
+	plain, err := d.gcm.Open(d.seg[:0], segmentNonce(d.prefix, d.counter, last), d.seg[:n], nil)
+	if err != nil {
+		d.err = ErrDecrypt
+		return
+	}
+	d.counter++
+	d.buf = plain
+	d.done = last
+}
+
+// openKnownSize reads the next segment when the plaintext size is known, so
+// the final segment is identified by position rather than by end of stream.
+func (d *DecryptingReader) openKnownSize() {
+	segments := max((d.size+segmentSize-1)/segmentSize, 1)
+	if int64(d.counter) >= segments {
+		d.done = true
+		return
+	}
+	last := int64(d.counter) == segments-1
+	n := segmentSize + tagSize
+	if last {
+		n = int(d.size-int64(d.counter)*segmentSize) + tagSize
+	}
+	if _, err := io.ReadFull(d.src, d.seg[:n]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrDecrypt
+		}
+		d.err = err
+		return
+	}
+	plain, err := d.gcm.Open(d.seg[:0], segmentNonce(d.prefix, d.counter, last), d.seg[:n], nil)
+	if err != nil {
+		d.err = ErrDecrypt
+		return
+	}
+	d.counter++
+	d.buf = plain
+	d.done = last
+}
+
+// CiphertextSize returns the encrypted size of a plaintext of n bytes.
+func CiphertextSize(n int64) int64 {
+	segments := (n + segmentSize - 1) / segmentSize
+	if segments == 0 {
+		segments = 1
+	}
+	return int64(len(streamMagic)+noncePrefixSize) + n + segments*tagSize
+}
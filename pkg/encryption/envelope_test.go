@@ -0,0 +1,131 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	key, _ := NewKey()
+	sizes := []int{0, 1, 100, segmentSize - 1, segmentSize, segmentSize + 1, 3*segmentSize + 17}
+	for _, size := range sizes {
+		plain := make([]byte, size)
+		_, _ = rand.Read(plain)
+
+		enc, err := NewEncryptingReader(bytes.NewReader(plain), key)
+		if err != nil {
+			t.Fatalf("NewEncryptingReader: %v", err)
+		}
+		ct, err := io.ReadAll(enc)
+		if err != nil {
+			t.Fatalf("size %d: encrypt: %v", size, err)
+		}
+		if enc.PlaintextSize() != int64(size) {
+			t.Errorf("size %d: PlaintextSize = %d", size, enc.PlaintextSize())
+		}
+		if int64(len(ct)) != CiphertextSize(int64(size)) {
+			t.Errorf("size %d: ciphertext is %d bytes, CiphertextSize = %d", size, len(ct), CiphertextSize(int64(size)))
+		}
+		if size > 16 && bytes.Contains(ct, plain[:16]) {
+			t.Errorf("size %d: ciphertext contains plaintext", size)
+		}
+
+		dec, _ := NewDecryptingReader(bytes.NewReader(ct), key)
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestSegmentDecryptingReader(t *testing.T) {
+	key, _ := NewKey()
+	size := 3*segmentSize + 17
+	plain := make([]byte, size)
+	_, _ = rand.Read(plain)
+	enc, _ := NewEncryptingReader(bytes.NewReader(plain), key)
+	ct, _ := io.ReadAll(enc)
+
+	ranges := [][2]int{{0, 10}, {segmentSize - 5, 10}, {segmentSize, segmentSize}, {2*segmentSize + 3, segmentSize + 14}, {size - 1, 1}}
+	for _, rg := range ranges {
+		off, n := rg[0], rg[1]
+		seg, cipherOff, cipherLen, skip := SegmentSpan(int64(off), int64(n))
+
+		// Only the segments covering the range are supplied
+		end := min(int(cipherOff+cipherLen), len(ct))
+		dec, err := NewSegmentDecryptingReader(bytes.NewReader(ct[cipherOff:end]), key, ct[:HeaderSize], seg, int64(size))
+		if err != nil {
+			t.Fatalf("NewSegmentDecryptingReader: %v", err)
+		}
+		if _, err := io.CopyN(io.Discard, dec, skip); err != nil {
+			t.Fatalf("range %v: skip: %v", rg, err)
+		}
+		got := make([]byte, n)
+		if _, err := io.ReadFull(dec, got); err != nil {
+			t.Fatalf("range %v: read: %v", rg, err)
+		}
+		if !bytes.Equal(got, plain[off:off+n]) {
+			t.Errorf("range %v: mismatch", rg)
+		}
+	}
+
+	// A segment decrypted under the wrong index must fail authentication
+	_, cipherOff, _, _ := SegmentSpan(segmentSize, 1)
+	dec, _ := NewSegmentDecryptingReader(bytes.NewReader(ct[cipherOff:]), key, ct[:HeaderSize], 2, int64(size))
+	if _, err := io.ReadAll(dec); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for misplaced segment, got %v", err)
+	}
+}
+
+func TestStreamTamperAndTruncation(t *testing.T) {
+	key, _ := NewKey()
+	plain := make([]byte, 2*segmentSize+10)
+	enc, _ := NewEncryptingReader(bytes.NewReader(plain), key)
+	ct, _ := io.ReadAll(enc)
+
+	decrypt := func(b []byte, k []byte) error {
+		dec, _ := NewDecryptingReader(bytes.NewReader(b), k)
+		_, err := io.ReadAll(dec)
+		return err
+	}
+
+	flipped := append([]byte(nil), ct...)
+	flipped[len(flipped)/2] ^= 1
+	if err := decrypt(flipped, key); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("tampered: got %v, want ErrDecrypt", err)
+	}
+
+	// Drop the final segment: the previous one was not sealed as last
+	header := len(streamMagic) + noncePrefixSize
+	truncated := ct[:header+2*(segmentSize+tagSize)]
+	if err := decrypt(truncated, key); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("truncated: got %v, want ErrDecrypt", err)
+	}
+
+	other, _ := NewKey()
+	if err := decrypt(ct, other); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong key: got %v, want ErrDecrypt", err)
+	}
+}
+
+func TestWrapKey(t *testing.T) {
+	kek, _ := NewKey()
+	key, _ := NewKey()
+	wrapped, err := WrapKey(kek, key, []byte("ns-a"))
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	got, err := UnwrapKey(kek, wrapped, []byte("ns-a"))
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("UnwrapKey: %v", err)
+	}
+	if _, err := UnwrapKey(kek, wrapped, []byte("ns-b")); err == nil {
+		t.Error("expected unwrap with a different namespace to fail")
+	}
+}
@@ -0,0 +1,231 @@
+package encryption
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// DB is the subset of rqlite.Client used by the Keyring.
+type DB interface {
+	Query(ctx context.Context, dest any, query string, args ...any) error
+	Exec(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ObjectKey is a freshly generated data key for one object.
+type ObjectKey struct {
+	Namespace  string
+	DataKey    []byte // Plaintext key; never persisted
+	WrappedKey []byte // DataKey wrapped with the namespace key
+}
+
+// ObjectMeta is the encryption metadata recorded alongside a CID.
+type ObjectMeta struct {
+	Cid           string `db:"cid"`
+	Namespace     string `db:"namespace"`
+	Algorithm     string `db:"algorithm"`
+	WrappedKey    string `db:"wrapped_key"` // base64
+	PlaintextSize int64  `db:"plaintext_size"`
+}
+
+type keyRow struct {
+	Key string `db:"wrapped_key"`
+}
+
+type masterKeyRow struct {
+	Key string `db:"key_hex"`
+}
+
+// Keyring manages per-namespace keys and per-object encryption metadata in
+// RQLite. Namespace keys are wrapped with the master key and cached in memory
+// once unwrapped.
+type Keyring struct {
+	db     DB
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	masterKey []byte
+	nsKeys    map[string][]byte
+}
+
+// NewKeyring creates a Keyring. masterKeyHex is a 32-byte hex-encoded key that
+// must be identical on every gateway. When empty, a cluster-wide master key
+// is generated once and stored in RQLite: objects on IPFS stay encrypted, but
+// anyone with database access can decrypt them.
+func NewKeyring(db DB, masterKeyHex string, logger *zap.Logger) (*Keyring, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	k := &Keyring{db: db, logger: logger, nsKeys: make(map[string][]byte)}
+	if masterKeyHex != "" {
+		key, err := hex.DecodeString(masterKeyHex)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("invalid encryption key: must be %d bytes hex-encoded", KeySize)
+		}
+		k.masterKey = key
+	} else {
+		logger.Warn("No storage encryption key configured; using a master key stored in RQLite")
+	}
+	return k, nil
+}
+
+// NewObjectKey generates a data key for a new object in namespace.
+func (k *Keyring) NewObjectKey(ctx context.Context, namespace string) (*ObjectKey, error) {
+	nsKey, err := k.namespaceKey(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := WrapKey(nsKey, dataKey, []byte(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return &ObjectKey{Namespace: namespace, DataKey: dataKey, WrappedKey: wrapped}, nil
+}
+
+// SaveObject records the encryption metadata for an uploaded object.
+func (k *Keyring) SaveObject(ctx context.Context, cid string, key *ObjectKey, plaintextSize int64) error {
+	_, err := k.db.Exec(ctx,
+		`INSERT INTO storage_object_keys (cid, namespace, algorithm, wrapped_key, plaintext_size) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(cid) DO NOTHING`,
+		cid, key.Namespace, StreamAlgorithm, base64.StdEncoding.EncodeToString(key.WrappedKey), plaintextSize)
+	if err != nil {
+		return fmt.Errorf("failed to save object key: %w", err)
+	}
+	return nil
+}
+
+// LookupObject returns the encryption metadata for cid, or nil if the object
+// was stored unencrypted.
+func (k *Keyring) LookupObject(ctx context.Context, cid string) (*ObjectMeta, error) {
+	var rows []ObjectMeta
+	if err := k.db.Query(ctx, &rows,
+		"SELECT cid, namespace, algorithm, wrapped_key, plaintext_size FROM storage_object_keys WHERE cid = ? LIMIT 1", cid); err != nil {
+		return nil, fmt.Errorf("failed to query object key: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// ObjectDataKey unwraps the data key described by meta.
+func (k *Keyring) ObjectDataKey(ctx context.Context, meta *ObjectMeta) ([]byte, error) {
+	if meta.Algorithm != StreamAlgorithm {
+		return nil, fmt.Errorf("unsupported encryption algorithm %q", meta.Algorithm)
+	}
+	nsKey, err := k.namespaceKey(ctx, meta.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(meta.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	return UnwrapKey(nsKey, wrapped, []byte(meta.Namespace))
+}
+
+// namespaceKey returns the namespace's key, creating it on first use.
+func (k *Keyring) namespaceKey(ctx context.Context, namespace string) ([]byte, error) {
+	k.mu.Lock()
+	if key, ok := k.nsKeys[namespace]; ok {
+		k.mu.Unlock()
+		return key, nil
+	}
+	k.mu.Unlock()
+
+	master, err := k.master(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := k.loadNamespaceKey(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if wrapped == nil {
+		// First object in this namespace. Concurrent gateways may race here;
+		// INSERT OR IGNORE keeps the first key and everyone re-reads it.
+		key, err := NewKey()
+		if err != nil {
+			return nil, err
+		}
+		w, err := WrapKey(master, key, []byte(namespace))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := k.db.Exec(ctx,
+			"INSERT OR IGNORE INTO storage_namespace_keys (namespace, wrapped_key) VALUES (?, ?)",
+			namespace, base64.StdEncoding.EncodeToString(w)); err != nil {
+			return nil, fmt.Errorf("failed to store namespace key: %w", err)
+		}
+		if wrapped, err = k.loadNamespaceKey(ctx, namespace); err != nil {
+			return nil, err
+		}
+		if wrapped == nil {
+			return nil, fmt.Errorf("namespace key not found after insert")
+		}
+	}
+
+	key, err := UnwrapKey(master, wrapped, []byte(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap namespace key (wrong master key?): %w", err)
+	}
+
+	k.mu.Lock()
+	k.nsKeys[namespace] = key
+	k.mu.Unlock()
+	return key, nil
+}
+
+func (k *Keyring) loadNamespaceKey(ctx context.Context, namespace string) ([]byte, error) {
+	var rows []keyRow
+	if err := k.db.Query(ctx, &rows,
+		"SELECT wrapped_key FROM storage_namespace_keys WHERE namespace = ? LIMIT 1", namespace); err != nil {
+		return nil, fmt.Errorf("failed to query namespace key: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(rows[0].Key)
+}
+
+// master returns the configured master key, or loads (creating if needed)
+// the cluster master key stored in RQLite.
+func (k *Keyring) master(ctx context.Context) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.masterKey != nil {
+		return k.masterKey, nil
+	}
+
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := k.db.Exec(ctx,
+		"INSERT OR IGNORE INTO storage_master_key (id, key_hex) VALUES (1, ?)", hex.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to store master key: %w", err)
+	}
+	var rows []masterKeyRow
+	if err := k.db.Query(ctx, &rows, "SELECT key_hex FROM storage_master_key WHERE id = 1"); err != nil {
+		return nil, fmt.Errorf("failed to query master key: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("master key not found after insert")
+	}
+	stored, err := hex.DecodeString(rows[0].Key)
+	if err != nil || len(stored) != KeySize {
+		return nil, fmt.Errorf("stored master key is invalid")
+	}
+	k.masterKey = stored
+	return stored, nil
+}
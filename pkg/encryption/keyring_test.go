@@ -0,0 +1,130 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB stores the keyring tables in memory
+type fakeDB struct {
+	mu      sync.Mutex
+	master  string
+	nsKeys  map[string]string
+	objects map[string]ObjectMeta
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{nsKeys: map[string]string{}, objects: map[string]ObjectMeta{}}
+}
+
+func (f *fakeDB) Query(ctx context.Context, dest any, query string, args ...any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch d := dest.(type) {
+	case *[]masterKeyRow:
+		if f.master != "" {
+			*d = append(*d, masterKeyRow{Key: f.master})
+		}
+	case *[]keyRow:
+		if k, ok := f.nsKeys[args[0].(string)]; ok {
+			*d = append(*d, keyRow{Key: k})
+		}
+	case *[]ObjectMeta:
+		if m, ok := f.objects[args[0].(string)]; ok {
+			*d = append(*d, m)
+		}
+	}
+	return nil
+}
+
+func (f *fakeDB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.Contains(query, "storage_master_key"):
+		if f.master == "" {
+			f.master = args[0].(string)
+		}
+	case strings.Contains(query, "storage_namespace_keys"):
+		ns := args[0].(string)
+		if _, ok := f.nsKeys[ns]; !ok {
+			f.nsKeys[ns] = args[1].(string)
+		}
+	case strings.Contains(query, "storage_object_keys"):
+		cid := args[0].(string)
+		if _, ok := f.objects[cid]; !ok {
+			f.objects[cid] = ObjectMeta{Cid: cid, Namespace: args[1].(string), Algorithm: args[2].(string), WrappedKey: args[3].(string), PlaintextSize: args[4].(int64)}
+		}
+	}
+	return nil, nil
+}
+
+func TestKeyringObjectKeys(t *testing.T) {
+	db := newFakeDB()
+	ctx := context.Background()
+	k, err := NewKeyring(db, "", nil)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	objKey, err := k.NewObjectKey(ctx, "ns-a")
+	if err != nil {
+		t.Fatalf("NewObjectKey: %v", err)
+	}
+	if err := k.SaveObject(ctx, "bafy1", objKey, 42); err != nil {
+		t.Fatalf("SaveObject: %v", err)
+	}
+	if db.master == "" || db.nsKeys["ns-a"] == "" {
+		t.Fatal("expected master and namespace keys to be persisted")
+	}
+
+	// Another gateway sharing the database can decrypt the object
+	k2, _ := NewKeyring(db, "", nil)
+	meta, err := k2.LookupObject(ctx, "bafy1")
+	if err != nil || meta == nil {
+		t.Fatalf("LookupObject: %v %v", meta, err)
+	}
+	if meta.Namespace != "ns-a" || meta.PlaintextSize != 42 || meta.Algorithm != StreamAlgorithm {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+	dataKey, err := k2.ObjectDataKey(ctx, meta)
+	if err != nil {
+		t.Fatalf("ObjectDataKey: %v", err)
+	}
+	if !bytes.Equal(dataKey, objKey.DataKey) {
+		t.Error("unwrapped data key does not match")
+	}
+
+	if meta, _ := k.LookupObject(ctx, "bafy-plain"); meta != nil {
+		t.Error("expected no metadata for an unencrypted object")
+	}
+}
+
+func TestKeyringConfiguredMasterKey(t *testing.T) {
+	if _, err := NewKeyring(newFakeDB(), "abcd", nil); err == nil {
+		t.Error("expected invalid key error")
+	}
+
+	db := newFakeDB()
+	ctx := context.Background()
+	k, err := NewKeyring(db, strings.Repeat("ab", 32), nil)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	objKey, _ := k.NewObjectKey(ctx, "ns-a")
+	_ = k.SaveObject(ctx, "bafy1", objKey, 1)
+	if db.master != "" {
+		t.Error("configured master key must not be stored")
+	}
+
+	// A gateway with a different master key cannot unwrap the namespace key
+	other, _ := NewKeyring(db, strings.Repeat("cd", 32), nil)
+	meta, _ := other.LookupObject(ctx, "bafy1")
+	if _, err := other.ObjectDataKey(ctx, meta); err == nil {
+		t.Error("expected unwrap with the wrong master key to fail")
+	}
+}
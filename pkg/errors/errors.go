@@ -340,6 +340,30 @@ func NewRateLimitError(limit, retryAfter int) *RateLimitError {
 	}
 }
 
+// QuotaExceededError represents a namespace exceeding a hard usage quota.
+type QuotaExceededError struct {
+	*BaseError
+	Metric     string
+	Used       float64
+	Limit      float64
+	RetryAfter int // seconds until the quota period resets
+}
+
+// NewQuotaExceededError creates a new quota exceeded error.
+func NewQuotaExceededError(metric string, used, limit float64, retryAfter int) *QuotaExceededError {
+	return &QuotaExceededError{
+		BaseError: &BaseError{
+			code:    CodeResourceExhausted,
+			message: fmt.Sprintf("%s quota exceeded", metric),
+			stack:   captureStack(1),
+		},
+		Metric:     metric,
+		Used:       used,
+		Limit:      limit,
+		RetryAfter: retryAfter,
+	}
+}
+
 // Wrap wraps an error with additional context.
 // If the error is already one of our custom types, it preserves the type
 // and adds the cause chain. Otherwise, it creates an InternalError.
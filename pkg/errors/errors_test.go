@@ -248,6 +248,25 @@ func TestRateLimitError(t *testing.T) {
 	}
 }
 
+func TestQuotaExceededError(t *testing.T) {
+	err := NewQuotaExceededError("db_rows", 1500, 1000, 3600)
+
+	if err.Metric != "db_rows" {
+		t.Errorf("Expected metric 'db_rows', got %q", err.Metric)
+	}
+	if err.Code() != CodeResourceExhausted {
+		t.Errorf("Expected code %q, got %q", CodeResourceExhausted, err.Code())
+	}
+	if StatusCode(err) != 429 {
+		t.Errorf("Expected status 429, got %d", StatusCode(err))
+	}
+
+	httpErr := ToHTTPError(err, "")
+	if httpErr.Details["limit"] != "1000" || httpErr.Details["used"] != "1500" {
+		t.Errorf("Unexpected details: %v", httpErr.Details)
+	}
+}
+
 func TestWrap(t *testing.T) {
 	t.Run("wrap standard error", func(t *testing.T) {
 		original := errors.New("original error")
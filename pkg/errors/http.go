@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 )
 
 // HTTPError represents an HTTP error response.
@@ -168,6 +169,7 @@ func ToHTTPError(err error, traceID string) *HTTPError {
 		conflictErr    *ConflictError
 		timeoutErr     *TimeoutError
 		rateLimitErr   *RateLimitError
+		quotaErr       *QuotaExceededError
 		serviceErr     *ServiceError
 		internalErr    *InternalError
 	)
@@ -210,8 +212,18 @@ func ToHTTPError(err error, traceID string) *HTTPError {
 			httpErr.Details["duration"] = timeoutErr.Duration
 		}
 	case errors.As(err, &rateLimitErr):
+		if rateLimitErr.Limit > 0 {
+			httpErr.Details["limit"] = strconv.Itoa(rateLimitErr.Limit)
+		}
 		if rateLimitErr.RetryAfter > 0 {
-			httpErr.Details["retry_after"] = string(rune(rateLimitErr.RetryAfter))
+			httpErr.Details["retry_after"] = strconv.Itoa(rateLimitErr.RetryAfter)
+		}
+	case errors.As(err, &quotaErr):
+		httpErr.Details["metric"] = quotaErr.Metric
+		httpErr.Details["used"] = strconv.FormatFloat(quotaErr.Used, 'f', -1, 64)
+		httpErr.Details["limit"] = strconv.FormatFloat(quotaErr.Limit, 'f', -1, 64)
+		if quotaErr.RetryAfter > 0 {
+			httpErr.Details["retry_after"] = strconv.Itoa(quotaErr.RetryAfter)
 		}
 	case errors.As(err, &serviceErr):
 		if serviceErr.Service != "" {
@@ -234,7 +246,12 @@ func WriteHTTPError(w http.ResponseWriter, err error, traceID string) {
 	// Add retry-after header for rate limit errors
 	var rateLimitErr *RateLimitError
 	if errors.As(err, &rateLimitErr) && rateLimitErr.RetryAfter > 0 {
-		w.Header().Set("Retry-After", string(rune(rateLimitErr.RetryAfter)))
+		w.Header().Set("Retry-After", strconv.Itoa(rateLimitErr.RetryAfter))
+	}
+
+	var quotaErr *QuotaExceededError
+	if errors.As(err, &quotaErr) && quotaErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(quotaErr.RetryAfter))
 	}
 
 	// Add WWW-Authenticate header for unauthorized errors
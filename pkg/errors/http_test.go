@@ -303,8 +303,9 @@ func TestWriteHTTPError(t *testing.T) {
 			t.Errorf("Expected status 429, got %d", w.Code)
 		}
 
-		// Note: The retry-after header implementation may need adjustment
-		// as we're converting int to rune which may not be the desired behavior
+		if got := w.Header().Get("Retry-After"); got != "60" {
+			t.Errorf("Expected Retry-After 60, got %q", got)
+		}
 	})
 
 	t.Run("not found error", func(t *testing.T) {
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// eip1271MagicValue is bytes4(keccak256("isValidSignature(bytes32,bytes)")),
+// which is both the function selector and the value a contract returns for a
+// valid signature.
+var eip1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+
+// EIP1271Verifier checks smart-contract wallet signatures (Safe and other
+// account-abstraction wallets) by calling isValidSignature over JSON-RPC.
+type EIP1271Verifier struct {
+	rpcURL string
+	client *http.Client
+}
+
+// NewEIP1271Verifier creates a verifier for the given JSON-RPC endpoint.
+func NewEIP1271Verifier(rpcURL string, timeout time.Duration) *EIP1271Verifier {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &EIP1271Verifier{
+		rpcURL: strings.TrimSpace(rpcURL),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	Result string `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// IsValidSignature reports whether the contract at address accepts sig for
+// hash. Calls that revert (e.g. an EOA or a contract without EIP-1271
+// support) report false without an error.
+func (v *EIP1271Verifier) IsValidSignature(ctx context.Context, address string, hash []byte, sig []byte) (bool, error) {
+	if !common.IsHexAddress(address) {
+		return false, fmt.Errorf("invalid contract address")
+	}
+	if len(hash) != 32 {
+		return false, fmt.Errorf("hash must be 32 bytes")
+	}
+
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "eth_call",
+		Params: []any{
+			map[string]string{
+				"to":   common.HexToAddress(address).Hex(),
+				"data": "0x" + hex.EncodeToString(encodeIsValidSignature(hash, sig)),
+			},
+			"latest",
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("eip1271 rpc call failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("eip1271 rpc returned status %d", resp.StatusCode)
+	}
+
+	var out rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, fmt.Errorf("invalid eip1271 rpc response: %w", err)
+	}
+	if out.Error != nil {
+		// Reverts surface as JSON-RPC errors; treat them as a rejected signature
+		return false, nil
+	}
+
+	result, err := hex.DecodeString(strings.TrimPrefix(out.Result, "0x"))
+	if err != nil || len(result) < 4 {
+		return false, nil
+	}
+	return bytes.Equal(result[:4], eip1271MagicValue), nil
+}
+
+// encodeIsValidSignature ABI-encodes isValidSignature(bytes32 hash, bytes signature).
+func encodeIsValidSignature(hash, sig []byte) []byte {
+	padded := (len(sig) + 31) / 32 * 32
+	data := make([]byte, 0, 4+32*3+padded)
+	data = append(data, eip1271MagicValue...)
+	data = append(data, common.LeftPadBytes(hash, 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(64).Bytes(), 32)...) // offset of the bytes argument
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(sig))).Bytes(), 32)...)
+	data = append(data, common.RightPadBytes(sig, padded)...)
+	return data
+}
@@ -24,11 +24,14 @@ import (
 
 // Service handles authentication business logic
 type Service struct {
-	logger      *logging.ColoredLogger
-	orm         client.NetworkClient
-	signingKey  *rsa.PrivateKey
-	keyID       string
-	defaultNS   string
+	logger     *logging.ColoredLogger
+	orm        client.NetworkClient
+	signingKey *rsa.PrivateKey
+	keyID      string
+	defaultNS  string
+	siwe       SIWEConfig
+	eip1271    *EIP1271Verifier
+	nonces     NonceDB // consumes SIWE nonces; see SetNonceDB
 }
 
 func NewService(logger *logging.ColoredLogger, orm client.NetworkClient, signingKeyPEM string, defaultNS string) (*Service, error) {
@@ -60,12 +63,13 @@ func NewService(logger *logging.ColoredLogger, orm client.NetworkClient, signing
 
 // CreateNonce generates a new nonce and stores it in the database
 func (s *Service) CreateNonce(ctx context.Context, wallet, purpose, namespace string) (string, error) {
-	// Generate a URL-safe random nonce (32 bytes)
+	// Generate a random alphanumeric nonce (32 bytes, hex) so it can be
+	// embedded in EIP-4361 messages
 	buf := make([]byte, 32)
 	if _, err := rand.Read(buf); err != nil {
 		return "", fmt.Errorf("failed to generate nonce: %w", err)
 	}
-	nonce := base64.RawURLEncoding.EncodeToString(buf)
+	nonce := hex.EncodeToString(buf)
 
 	// Use internal context to bypass authentication for system operations
 	internalCtx := client.WithInternalAuth(ctx)
@@ -109,7 +113,7 @@ func (s *Service) VerifySignature(ctx context.Context, wallet, nonce, signature,
 
 	switch chainType {
 	case "ETH":
-		return s.verifyEthSignature(wallet, nonce, signature)
+		return s.verifyEthSignature(ctx, wallet, nonce, signature)
 	case "SOL":
 		return s.verifySolSignature(wallet, nonce, signature)
 	default:
@@ -117,8 +121,14 @@ func (s *Service) VerifySignature(ctx context.Context, wallet, nonce, signature,
 	}
 }
 
-func (s *Service) verifyEthSignature(wallet, nonce, signature string) (bool, error) {
-	msg := []byte(nonce)
+func (s *Service) verifyEthSignature(ctx context.Context, wallet, nonce, signature string) (bool, error) {
+	return s.verifyEthMessage(ctx, wallet, []byte(nonce), signature)
+}
+
+// verifyEthMessage checks a personal_sign (EIP-191) signature over msg. When
+// the signature does not recover to wallet and an RPC endpoint is configured,
+// wallet is treated as a smart-contract wallet and checked via EIP-1271.
+func (s *Service) verifyEthMessage(ctx context.Context, wallet string, msg []byte, signature string) (bool, error) {
 	prefix := []byte("\x19Ethereum Signed Message:\n" + strconv.Itoa(len(msg)))
 	hash := ethcrypto.Keccak256(prefix, msg)
 
@@ -127,24 +137,33 @@ func (s *Service) verifyEthSignature(wallet, nonce, signature string) (bool, err
 		sigHex = sigHex[2:]
 	}
 	sig, err := hex.DecodeString(sigHex)
-	if err != nil || len(sig) != 65 {
+	if err != nil || len(sig) == 0 {
 		return false, fmt.Errorf("invalid signature format")
 	}
 
-	if sig[64] >= 27 {
-		sig[64] -= 27
+	if len(sig) == 65 {
+		ecdsaSig := append([]byte(nil), sig...)
+		if ecdsaSig[64] >= 27 {
+			ecdsaSig[64] -= 27
+		}
+		if pub, err := ethcrypto.SigToPub(hash, ecdsaSig); err == nil {
+			addr := ethcrypto.PubkeyToAddress(*pub).Hex()
+			want := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(wallet, "0x"), "0X"))
+			got := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(addr, "0x"), "0X"))
+			if got == want {
+				return true, nil
+			}
+		} else if s.eip1271 == nil {
+			return false, fmt.Errorf("signature recovery failed: %w", err)
+		}
+	} else if s.eip1271 == nil {
+		return false, fmt.Errorf("invalid signature format")
 	}
 
-	pub, err := ethcrypto.SigToPub(hash, sig)
-	if err != nil {
-		return false, fmt.Errorf("signature recovery failed: %w", err)
+	if s.eip1271 == nil {
+		return false, nil
 	}
-
-	addr := ethcrypto.PubkeyToAddress(*pub).Hex()
-	want := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(wallet, "0x"), "0X"))
-	got := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(addr, "0x"), "0X"))
-
-	return got == want, nil
+	return s.eip1271.IsValidSignature(ctx, wallet, hash, sig)
 }
 
 func (s *Service) verifySolSignature(wallet, nonce, signature string) (bool, error) {
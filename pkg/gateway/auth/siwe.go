@@ -0,0 +1,422 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SIWE (EIP-4361) message constants
+const (
+	siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+	siweVersion      = "1"
+
+	// DefaultSIWEClockSkew is the tolerance applied to issued-at, expiration
+	// and not-before checks.
+	DefaultSIWEClockSkew = time.Minute
+)
+
+// SIWEConfig controls Sign-In with Ethereum validation.
+type SIWEConfig struct {
+	Domains    []string      // Accepted message domains; required to sign in with SIWE
+	ChainIDs   []int64       // Accepted chain IDs; empty accepts any chain
+	RPCURL     string        // JSON-RPC endpoint used for EIP-1271 contract wallet checks; empty disables them
+	RPCTimeout time.Duration // Timeout for EIP-1271 calls (default: 10s)
+	ClockSkew  time.Duration // Tolerance for message timestamps (default: 1m)
+}
+
+// SIWEMessage is a parsed EIP-4361 message.
+type SIWEMessage struct {
+	Scheme         string
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseSIWEMessage parses msg according to the EIP-4361 grammar.
+func ParseSIWEMessage(msg string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return nil, fmt.Errorf("siwe: message too short")
+	}
+
+	m := &SIWEMessage{}
+
+	// Header: [scheme "://"] domain " wants you to sign in with your Ethereum account:"
+	header := lines[0]
+	if !strings.HasSuffix(header, siweHeaderSuffix) {
+		return nil, fmt.Errorf("siwe: invalid header")
+	}
+	m.Domain = strings.TrimSuffix(header, siweHeaderSuffix)
+	if i := strings.Index(m.Domain, "://"); i >= 0 {
+		m.Scheme, m.Domain = m.Domain[:i], m.Domain[i+3:]
+	}
+	if m.Domain == "" || strings.ContainsAny(m.Domain, " /") {
+		return nil, fmt.Errorf("siwe: invalid domain %q", m.Domain)
+	}
+
+	// Address must be EIP-55 checksummed
+	m.Address = lines[1]
+	if !common.IsHexAddress(m.Address) || common.HexToAddress(m.Address).Hex() != m.Address {
+		return nil, fmt.Errorf("siwe: address must be an EIP-55 checksummed address")
+	}
+	if lines[2] != "" {
+		return nil, fmt.Errorf("siwe: expected empty line after address")
+	}
+
+	// Optional statement, surrounded by empty lines
+	i := 3
+	switch {
+	case lines[i] == "":
+		i++
+	case strings.HasPrefix(lines[i], "URI: "):
+		// Tolerate messages that omit the blank line when there is no statement
+	default:
+		m.Statement = lines[i]
+		i++
+		if i >= len(lines) || lines[i] != "" {
+			return nil, fmt.Errorf("siwe: expected empty line after statement")
+		}
+		i++
+	}
+
+	// Fields appear in a fixed order; the trailing ones are optional
+	type field struct {
+		name     string
+		required bool
+		set      func(string) error
+	}
+	parseTime := func(dst **time.Time) func(string) error {
+		return func(v string) error {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return err
+			}
+			*dst = &t
+			return nil
+		}
+	}
+	fields := []field{
+		{"URI", true, func(v string) error {
+			u, err := url.Parse(v)
+			if err != nil || u.Scheme == "" {
+				return fmt.Errorf("must be an absolute URI")
+			}
+			m.URI = v
+			return nil
+		}},
+		{"Version", true, func(v string) error {
+			if v != siweVersion {
+				return fmt.Errorf("unsupported version %q", v)
+			}
+			m.Version = v
+			return nil
+		}},
+		{"Chain ID", true, func(v string) error {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("must be a positive integer")
+			}
+			m.ChainID = id
+			return nil
+		}},
+		{"Nonce", true, func(v string) error {
+			if len(v) < 8 || !isAlphanumeric(v) {
+				return fmt.Errorf("must be at least 8 alphanumeric characters")
+			}
+			m.Nonce = v
+			return nil
+		}},
+		{"Issued At", true, func(v string) error {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return err
+			}
+			m.IssuedAt = t
+			return nil
+		}},
+		{"Expiration Time", false, parseTime(&m.ExpirationTime)},
+		{"Not Before", false, parseTime(&m.NotBefore)},
+		{"Request ID", false, func(v string) error {
+			m.RequestID = v
+			return nil
+		}},
+	}
+
+	for _, f := range fields {
+		prefix := f.name + ": "
+		if i < len(lines) && strings.HasPrefix(lines[i], prefix) {
+			if err := f.set(strings.TrimPrefix(lines[i], prefix)); err != nil {
+				return nil, fmt.Errorf("siwe: invalid %s: %v", f.name, err)
+			}
+			i++
+		} else if f.required {
+			return nil, fmt.Errorf("siwe: missing %s", f.name)
+		}
+	}
+
+	if i < len(lines) && lines[i] == "Resources:" {
+		i++
+		for i < len(lines) && strings.HasPrefix(lines[i], "- ") {
+			m.Resources = append(m.Resources, strings.TrimPrefix(lines[i], "- "))
+			i++
+		}
+	}
+
+	// Allow a single trailing newline, nothing else
+	for ; i < len(lines); i++ {
+		if lines[i] != "" {
+			return nil, fmt.Errorf("siwe: unexpected line %q", lines[i])
+		}
+	}
+
+	return m, nil
+}
+
+// String renders the message in EIP-4361 format. This is the exact text the
+// wallet signs.
+func (m *SIWEMessage) String() string {
+	var sb strings.Builder
+	if m.Scheme != "" {
+		sb.WriteString(m.Scheme + "://")
+	}
+	sb.WriteString(m.Domain + siweHeaderSuffix + "\n")
+	sb.WriteString(m.Address + "\n\n")
+	if m.Statement != "" {
+		sb.WriteString(m.Statement + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString("URI: " + m.URI + "\n")
+	version := m.Version
+	if version == "" {
+		version = siweVersion
+	}
+	sb.WriteString("Version: " + version + "\n")
+	sb.WriteString("Chain ID: " + strconv.FormatInt(m.ChainID, 10) + "\n")
+	sb.WriteString("Nonce: " + m.Nonce + "\n")
+	sb.WriteString("Issued At: " + m.IssuedAt.UTC().Format(time.RFC3339Nano))
+	if m.ExpirationTime != nil {
+		sb.WriteString("\nExpiration Time: " + m.ExpirationTime.UTC().Format(time.RFC3339Nano))
+	}
+	if m.NotBefore != nil {
+		sb.WriteString("\nNot Before: " + m.NotBefore.UTC().Format(time.RFC3339Nano))
+	}
+	if m.RequestID != "" {
+		sb.WriteString("\nRequest ID: " + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		sb.WriteString("\nResources:")
+		for _, r := range m.Resources {
+			sb.WriteString("\n- " + r)
+		}
+	}
+	return sb.String()
+}
+
+// Validate checks the message against the configured domains and chain IDs
+// and its validity window at now. Both the domain and the host of the URI
+// must be configured domains.
+func (m *SIWEMessage) Validate(cfg SIWEConfig, now time.Time) error {
+	if len(cfg.Domains) == 0 {
+		return fmt.Errorf("siwe: no domains configured")
+	}
+	if !containsFold(cfg.Domains, m.Domain) {
+		return fmt.Errorf("siwe: domain %q is not accepted", m.Domain)
+	}
+	if err := checkSIWEURI(cfg.Domains, m.URI); err != nil {
+		return err
+	}
+
+	if len(cfg.ChainIDs) > 0 {
+		ok := false
+		for _, id := range cfg.ChainIDs {
+			if id == m.ChainID {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("siwe: chain ID %d is not accepted", m.ChainID)
+		}
+	}
+
+	skew := cfg.ClockSkew
+	if skew <= 0 {
+		skew = DefaultSIWEClockSkew
+	}
+	if m.IssuedAt.After(now.Add(skew)) {
+		return fmt.Errorf("siwe: message issued in the future")
+	}
+	if m.ExpirationTime != nil && !now.Before(m.ExpirationTime.Add(skew)) {
+		return fmt.Errorf("siwe: message expired")
+	}
+	if m.NotBefore != nil && now.Add(skew).Before(*m.NotBefore) {
+		return fmt.Errorf("siwe: message not yet valid")
+	}
+	return nil
+}
+
+// SetSIWEConfig configures Sign-In with Ethereum validation and EIP-1271
+// contract wallet verification.
+func (s *Service) SetSIWEConfig(cfg SIWEConfig) {
+	s.siwe = cfg
+	s.eip1271 = nil
+	if strings.TrimSpace(cfg.RPCURL) != "" {
+		s.eip1271 = NewEIP1271Verifier(cfg.RPCURL, cfg.RPCTimeout)
+	}
+}
+
+// NonceDB runs the conditional update that consumes a SIWE nonce. The network
+// client's Query does not report the rows a write changed, so this is a direct
+// database client such as rqlite.Client.
+type NonceDB interface {
+	Exec(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// SetNonceDB sets the database SIWE nonces are consumed in. VerifySIWE
+// refuses every message without one.
+func (s *Service) SetNonceDB(db NonceDB) {
+	s.nonces = db
+}
+
+// VerifySIWE verifies a signed EIP-4361 message. The message must carry a
+// nonce issued by CreateNonce for its address in namespace that has not been
+// used or expired; on success the nonce is consumed.
+func (s *Service) VerifySIWE(ctx context.Context, message, signature, namespace string) (*SIWEMessage, error) {
+	m, err := ParseSIWEMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Validate(s.siwe, time.Now()); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.verifyEthMessage(ctx, m.Address, []byte(message), signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("siwe: signature does not match address")
+	}
+
+	if err := s.consumeNonce(ctx, m.Address, m.Nonce, namespace); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// consumeNonce marks an outstanding nonce as used. It fails if the nonce was
+// never issued for wallet in namespace, has expired, or was already used.
+func (s *Service) consumeNonce(ctx context.Context, wallet, nonce, namespace string) error {
+	if s.orm == nil || s.nonces == nil {
+		return fmt.Errorf("client not initialized")
+	}
+	if namespace == "" {
+		namespace = s.defaultNS
+	}
+	nsID, err := s.ResolveNamespaceID(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to resolve namespace ID: %w", err)
+	}
+
+	walletLower := strings.ToLower(strings.TrimSpace(wallet))
+
+	// A single conditional update, so that concurrent verifications of the
+	// same message cannot both consume the nonce
+	res, err := s.nonces.Exec(ctx,
+		"UPDATE nonces SET used_at = datetime('now') WHERE namespace_id = ? AND wallet = ? AND nonce = ? AND used_at IS NULL AND expires_at > datetime('now')",
+		nsID, walletLower, nonce,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to consume nonce: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to consume nonce: %w", err)
+	} else if n != 1 {
+		return fmt.Errorf("siwe: nonce is unknown, expired or already used")
+	}
+	return nil
+}
+
+// checkSIWEURI requires uri to be an absolute URI on one of domains.
+func checkSIWEURI(domains []string, uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("siwe: URI %q is not an absolute URI", uri)
+	}
+	if !containsFold(domains, u.Host) && !containsFold(domains, u.Hostname()) {
+		return fmt.Errorf("siwe: URI %q is not on an accepted domain", uri)
+	}
+	return nil
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(strings.TrimSpace(s), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSIWEMessage prepares an EIP-4361 message for a nonce issued by
+// CreateNonce. The domain is the first configured domain, the chain ID
+// defaults to the first configured chain (or mainnet), and the URI to
+// https://<domain>. The message expires together with the nonce.
+func (s *Service) NewSIWEMessage(address, nonce, uri, statement string, chainID int64) (*SIWEMessage, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("siwe: invalid address")
+	}
+	if len(s.siwe.Domains) == 0 {
+		return nil, fmt.Errorf("siwe: no domains configured")
+	}
+	domain := strings.TrimSpace(s.siwe.Domains[0])
+	if chainID <= 0 {
+		chainID = 1
+		if len(s.siwe.ChainIDs) > 0 {
+			chainID = s.siwe.ChainIDs[0]
+		}
+	}
+	if uri == "" {
+		uri = "https://" + domain
+	} else if err := checkSIWEURI(s.siwe.Domains, uri); err != nil {
+		return nil, err
+	}
+	issuedAt := time.Now().UTC().Truncate(time.Second)
+	expires := issuedAt.Add(5 * time.Minute)
+	return &SIWEMessage{
+		Domain:         domain,
+		Address:        common.HexToAddress(address).Hex(),
+		Statement:      statement,
+		URI:            uri,
+		Version:        siweVersion,
+		ChainID:        chainID,
+		Nonce:          nonce,
+		IssuedAt:       issuedAt,
+		ExpirationTime: &expires,
+	}, nil
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// nonceDB reports one row changed only the first time a nonce is consumed
+type nonceDB struct {
+	used map[string]bool
+}
+
+func (m *nonceDB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	key := fmt.Sprint(args...)
+	if m.used[key] {
+		return driver.RowsAffected(0), nil
+	}
+	m.used[key] = true
+	return driver.RowsAffected(1), nil
+}
+
+func createNonceTestService(t *testing.T) *Service {
+	s := createTestService(t)
+	s.SetNonceDB(&nonceDB{used: map[string]bool{}})
+	return s
+}
+
+func personalSign(t *testing.T, msg string) (string, string) {
+	t.Helper()
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	hash := ethcrypto.Keccak256([]byte("\x19Ethereum Signed Message:\n"+strconv.Itoa(len(msg))), []byte(msg))
+	sig, err := ethcrypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	sig[64] += 27
+	return ethcrypto.PubkeyToAddress(key.PublicKey).Hex(), "0x" + hex.EncodeToString(sig)
+}
+
+func testSIWEMessage(address string) *SIWEMessage {
+	issued := time.Now().UTC().Truncate(time.Second)
+	exp := issued.Add(5 * time.Minute)
+	return &SIWEMessage{
+		Domain:         "app.example.com",
+		Address:        address,
+		Statement:      "Sign in to DeBros",
+		URI:            "https://app.example.com/login",
+		Version:        "1",
+		ChainID:        1,
+		Nonce:          "abcdef0123456789",
+		IssuedAt:       issued,
+		ExpirationTime: &exp,
+	}
+}
+
+func TestParseSIWEMessage(t *testing.T) {
+	addr := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed").Hex()
+	m := testSIWEMessage(addr)
+	m.RequestID = "req-1"
+	m.Resources = []string{"ipfs://bafy", "https://example.com/tos"}
+
+	parsed, err := ParseSIWEMessage(m.String())
+	if err != nil {
+		t.Fatalf("ParseSIWEMessage: %v", err)
+	}
+	if parsed.String() != m.String() {
+		t.Errorf("round trip mismatch:\n%s\n---\n%s", parsed.String(), m.String())
+	}
+	if parsed.Domain != "app.example.com" || parsed.ChainID != 1 || parsed.Nonce != m.Nonce || len(parsed.Resources) != 2 {
+		t.Errorf("unexpected parse result: %+v", parsed)
+	}
+
+	// No statement: two blank lines between address and URI
+	m.Statement = ""
+	m.Scheme = "https"
+	parsed, err = ParseSIWEMessage(m.String())
+	if err != nil {
+		t.Fatalf("ParseSIWEMessage without statement: %v", err)
+	}
+	if parsed.Statement != "" || parsed.Scheme != "https" || parsed.URI != m.URI {
+		t.Errorf("unexpected parse result: %+v", parsed)
+	}
+}
+
+func TestParseSIWEMessage_Invalid(t *testing.T) {
+	addr := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed").Hex()
+	valid := testSIWEMessage(addr).String()
+
+	tests := map[string]string{
+		"bad header":       strings.Replace(valid, "wants you to sign in", "asks you to log in", 1),
+		"not checksummed":  strings.Replace(valid, addr, strings.ToLower(addr), 1),
+		"bad version":      strings.Replace(valid, "Version: 1", "Version: 2", 1),
+		"bad chain id":     strings.Replace(valid, "Chain ID: 1", "Chain ID: mainnet", 1),
+		"short nonce":      strings.Replace(valid, "abcdef0123456789", "abc", 1),
+		"non-alnum nonce":  strings.Replace(valid, "abcdef0123456789", "abcdef-123456789", 1),
+		"missing nonce":    strings.Replace(valid, "Nonce: abcdef0123456789\n", "", 1),
+		"bad issued at":    strings.Replace(valid, "Issued At: ", "Issued At: yesterday", 1),
+		"trailing garbage": valid + "\nextra",
+	}
+	for name, msg := range tests {
+		if _, err := ParseSIWEMessage(msg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSIWEMessageValidate(t *testing.T) {
+	now := time.Now()
+	m := testSIWEMessage(common.HexToAddress("0x01").Hex())
+
+	cfg := SIWEConfig{Domains: []string{"app.example.com"}, ChainIDs: []int64{1, 10}}
+	if err := m.Validate(cfg, now); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if err := m.Validate(SIWEConfig{}, now); err == nil {
+		t.Error("expected failure without configured domains")
+	}
+	if err := m.Validate(SIWEConfig{Domains: []string{"evil.example.com"}}, now); err == nil {
+		t.Error("expected domain mismatch")
+	}
+	if err := m.Validate(SIWEConfig{Domains: []string{"app.example.com"}, ChainIDs: []int64{137}}, now); err == nil {
+		t.Error("expected chain ID mismatch")
+	}
+	if err := m.Validate(cfg, now.Add(10*time.Minute)); err == nil {
+		t.Error("expected expired message")
+	}
+	if err := m.Validate(cfg, now.Add(-10*time.Minute)); err == nil {
+		t.Error("expected issued-at in the future")
+	}
+
+	for _, uri := range []string{"https://evil.example.com/login", "/login", "app.example.com"} {
+		other := *m
+		other.URI = uri
+		if err := other.Validate(cfg, now); err == nil {
+			t.Errorf("expected URI %q to be refused", uri)
+		}
+	}
+
+	nbf := now.Add(5 * time.Minute)
+	m.NotBefore = &nbf
+	if err := m.Validate(cfg, now); err == nil {
+		t.Error("expected not-before violation")
+	}
+}
+
+func TestVerifySIWE_EOA(t *testing.T) {
+	s := createNonceTestService(t)
+	s.SetSIWEConfig(SIWEConfig{Domains: []string{"app.example.com"}})
+
+	key, _ := ethcrypto.GenerateKey()
+	addr := ethcrypto.PubkeyToAddress(key.PublicKey).Hex()
+	msg := testSIWEMessage(addr).String()
+	hash := ethcrypto.Keccak256([]byte("\x19Ethereum Signed Message:\n"+strconv.Itoa(len(msg))), []byte(msg))
+	sig, _ := ethcrypto.Sign(hash, key)
+	sigHex := "0x" + hex.EncodeToString(sig)
+
+	got, err := s.VerifySIWE(context.Background(), msg, sigHex, "test-ns")
+	if err != nil {
+		t.Fatalf("VerifySIWE: %v", err)
+	}
+	if got.Address != addr {
+		t.Errorf("address = %s, want %s", got.Address, addr)
+	}
+
+	// Signature from another key
+	_, otherSig := personalSign(t, msg)
+	if _, err := s.VerifySIWE(context.Background(), msg, otherSig, "test-ns"); err == nil {
+		t.Error("expected signature mismatch")
+	}
+
+	// Replaying the message finds its nonce already used
+	if _, err := s.VerifySIWE(context.Background(), msg, sigHex, "test-ns"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("expected nonce error, got %v", err)
+	}
+}
+
+func TestVerifySIWE_EIP1271(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000c0de5").Hex()
+	validSig := []byte("safe-owner-signatures")
+
+	// Local stand-in for an Ethereum JSON-RPC node hosting the contract wallet
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		var call struct {
+			To   string `json:"to"`
+			Data string `json:"data"`
+		}
+		_ = json.Unmarshal(req.Params[0], &call)
+		data, _ := hex.DecodeString(strings.TrimPrefix(call.Data, "0x"))
+
+		result := make([]byte, 32)
+		if req.Method == "eth_call" && strings.EqualFold(call.To, contract) &&
+			len(data) >= 4+32*3 && hex.EncodeToString(data[:4]) == "1626ba7e" &&
+			strings.Contains(string(data[4+32*3:]), string(validSig)) {
+			copy(result, eip1271MagicValue)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": "0x" + hex.EncodeToString(result)})
+	}))
+	defer rpc.Close()
+
+	s := createNonceTestService(t)
+	s.SetSIWEConfig(SIWEConfig{Domains: []string{"app.example.com"}, RPCURL: rpc.URL})
+
+	msg := testSIWEMessage(contract).String()
+	if _, err := s.VerifySIWE(context.Background(), msg, "0x"+hex.EncodeToString(validSig), "test-ns"); err != nil {
+		t.Fatalf("VerifySIWE with contract wallet: %v", err)
+	}
+	if _, err := s.VerifySIWE(context.Background(), msg, "0x"+hex.EncodeToString([]byte("forged")), "test-ns"); err == nil {
+		t.Error("expected contract wallet to reject signature")
+	}
+
+	// Without an RPC endpoint contract wallets cannot sign in
+	s.SetSIWEConfig(SIWEConfig{Domains: []string{"app.example.com"}})
+	if _, err := s.VerifySIWE(context.Background(), msg, "0x"+hex.EncodeToString(validSig), "test-ns"); err == nil {
+		t.Error("expected failure without RPC endpoint")
+	}
+}
+
+func TestNewSIWEMessage(t *testing.T) {
+	s := createTestService(t)
+	s.SetSIWEConfig(SIWEConfig{Domains: []string{"app.example.com"}, ChainIDs: []int64{8453}})
+
+	m, err := s.NewSIWEMessage("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "abcdef0123456789", "", "", 0)
+	if err != nil {
+		t.Fatalf("NewSIWEMessage: %v", err)
+	}
+	if m.Domain != "app.example.com" || m.ChainID != 8453 || m.URI != "https://app.example.com" {
+		t.Errorf("unexpected message: %+v", m)
+	}
+	if _, err := ParseSIWEMessage(m.String()); err != nil {
+		t.Errorf("generated message does not parse: %v", err)
+	}
+	if err := m.Validate(s.siwe, time.Now()); err != nil {
+		t.Errorf("generated message does not validate: %v", err)
+	}
+	if _, err := s.NewSIWEMessage("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "abcdef0123456789", "https://evil.example.com", "", 0); err == nil {
+		t.Error("expected a URI on another domain to be refused")
+	}
+
+	s.SetSIWEConfig(SIWEConfig{})
+	if _, err := s.NewSIWEMessage("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "abcdef0123456789", "", "", 0); err == nil {
+		t.Error("expected failure without configured domains")
+	}
+}
@@ -1,6 +1,10 @@
 package gateway
 
-import "time"
+import (
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+)
 
 // Config holds configuration for the gateway server
 type Config struct {
@@ -28,4 +32,47 @@ type Config struct {
 	IPFSTimeout           time.Duration // Timeout for IPFS operations (default: 60s)
 	IPFSReplicationFactor int           // Replication factor for pins (default: 3)
 	IPFSEnableEncryption  bool          // Enable client-side encryption before upload (default: true, discovered from node configs)
+	IPFSEncryptionKey     string        // Hex-encoded 32-byte master key wrapping namespace keys; must match on every gateway. If empty, a key stored in RQLite is used
+	IPFSMaxObjectSize     int64         // Largest object accepted by uploads, in bytes (default: 5 GiB)
+	IPFSUploadDir         string        // Directory for staging resumable chunked uploads (default: $TMPDIR/orama-uploads)
+	IPFSUploadSessionTTL  time.Duration // How long an idle chunked upload is kept (default: 24h)
+	IPFSMaxUploadSessions int           // Open chunked uploads allowed per namespace (default: 16)
+	IPFSMaxUploadBytes    int64         // Bytes a namespace may stage across its chunked uploads (default: 20 GiB)
+	IPFSPinMaxAttempts    int           // Attempts before a queued pin is marked failed (default: 10)
+	IPFSReconcileInterval time.Duration // How often expected pins are checked against the cluster (default: 15m; < 0 disables)
+	IPFSLifecycleInterval time.Duration // How often upload TTLs and lifecycle rules are applied (default: 1h; < 0 disables)
+	IPFSPresignKey        string        // Hex-encoded 32-byte key signing presigned storage URLs; must match on every gateway. If empty, a key stored in RQLite is used
+
+	// Durable pub/sub topic defaults; topics may override them via /v1/pubsub/durable
+	PubSubRetention   time.Duration // How long durable topic messages are kept (default: 24h)
+	PubSubMaxMessages int           // Newest messages kept per durable topic (default: 10000)
+
+	// Pub/sub publish limits; topics may override them via /v1/pubsub/policies
+	PubSubMaxPayload  int // Largest message data in bytes (default and maximum: just under 1 MiB)
+	PubSubPublishRate int // Messages per second a WebSocket connection may publish to a topic (default: unlimited)
+
+	PubSubEnvelopeKey string // Hex-encoded 32-byte key signing pub/sub message envelopes; must match on every gateway. If empty, a key stored in RQLite is used
+
+	// CORS defaults; namespaces can override them via /v1/namespaces/{ns}/cors
+	CORS CORSConfig
+
+	// Request log buffering, sampling and retention
+	RequestLog RequestLogConfig
+
+	// CIDRs (or single IPs) of reverse proxies whose X-Forwarded-For,
+	// X-Real-IP and X-Forwarded-Proto headers are believed. Requests from
+	// anywhere else are attributed to their connection address.
+	TrustedProxies []string
+
+	// Rate limiting (per client IP, API key and wallet; counters shared via Olric)
+	RateLimit RateLimitConfig
+
+	// Per-namespace usage metering and quotas
+	Metering MeteringConfig
+
+	// Sign-In with Ethereum (EIP-4361) and EIP-1271 contract wallet verification
+	SIWE auth.SIWEConfig
+
+	// Push notifications via Expo
+	Push PushConfig
 }
@@ -1,12 +1,16 @@
 package gateway
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/url"
 	"strconv"
 	"strings"
 
+	pubsubhandlers "github.com/DeBrosOfficial/network/pkg/gateway/handlers/pubsub"
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"github.com/multiformats/go-multiaddr"
 )
 
@@ -85,6 +89,121 @@ func (c *Config) ValidateConfig() []error {
 		}
 	}
 
+	// Validate CORS defaults
+	for i, o := range c.CORS.AllowedOrigins {
+		if err := validateCORSOrigin(o); err != nil {
+			errs = append(errs, fmt.Errorf("gateway.cors.allowed_origins[%d]: %v", i, err))
+		} else if c.CORS.AllowCredentials && strings.TrimSpace(o) == "*" {
+			errs = append(errs, fmt.Errorf("gateway.cors.allowed_origins[%d]: \"*\" cannot be combined with allow_credentials", i))
+		}
+	}
+
+	// Validate rate limit policies
+	if c.RateLimit.Enabled {
+		for class, p := range c.RateLimit.Policies {
+			path := fmt.Sprintf("gateway.rate_limit.policies.%s", class)
+			if p.Requests < 0 {
+				errs = append(errs, fmt.Errorf("%s.requests: must be >= 0; got %d", path, p.Requests))
+			}
+			if p.Requests > 0 && p.Window <= 0 {
+				errs = append(errs, fmt.Errorf("%s.window: must be a positive duration when requests > 0", path))
+			}
+		}
+	}
+	if _, err := httputil.ParseTrustedProxies(c.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("gateway.trusted_proxies: %v", err))
+	}
+
+	// Validate default quotas
+	for metric, q := range c.Metering.Quotas {
+		path := fmt.Sprintf("gateway.metering.quotas.%s", metric)
+		if !metering.IsMetric(metric) {
+			errs = append(errs, fmt.Errorf("%s: unknown metric; expected one of %s", path, strings.Join(metering.Metrics, ", ")))
+			continue
+		}
+		if q.Soft < 0 || q.Hard < 0 {
+			errs = append(errs, fmt.Errorf("%s: limits must be >= 0", path))
+		}
+		if q.Soft > 0 && q.Hard > 0 && q.Soft > q.Hard {
+			errs = append(errs, fmt.Errorf("%s.soft: must not exceed hard limit (%g > %g)", path, q.Soft, q.Hard))
+		}
+	}
+
+	// Validate storage encryption key
+	if c.IPFSEncryptionKey != "" {
+		if key, err := hex.DecodeString(c.IPFSEncryptionKey); err != nil || len(key) != 32 {
+			errs = append(errs, fmt.Errorf("gateway.ipfs_encryption_key: must be 32 bytes hex-encoded (64 hex characters)"))
+		}
+	}
+	if c.IPFSPresignKey != "" {
+		if key, err := hex.DecodeString(c.IPFSPresignKey); err != nil || len(key) != 32 {
+			errs = append(errs, fmt.Errorf("gateway.ipfs_presign_key: must be 32 bytes hex-encoded (64 hex characters)"))
+		}
+	}
+
+	// Validate storage upload limits
+	if c.IPFSMaxObjectSize < 0 {
+		errs = append(errs, fmt.Errorf("gateway.ipfs_max_object_size: must be >= 0 (0 uses the default)"))
+	}
+	if c.IPFSUploadSessionTTL < 0 {
+		errs = append(errs, fmt.Errorf("gateway.ipfs_upload_session_ttl: must be >= 0"))
+	}
+	if c.IPFSMaxUploadSessions < 0 {
+		errs = append(errs, fmt.Errorf("gateway.ipfs_max_upload_sessions: must be >= 0 (0 uses the default)"))
+	}
+	if c.IPFSMaxUploadBytes < 0 {
+		errs = append(errs, fmt.Errorf("gateway.ipfs_max_upload_bytes: must be >= 0 (0 uses the default)"))
+	}
+	if c.IPFSPinMaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("gateway.ipfs_pin_max_attempts: must be >= 0 (0 uses the default)"))
+	}
+
+	// Validate durable pub/sub topic defaults
+	if c.PubSubRetention < 0 {
+		errs = append(errs, fmt.Errorf("gateway.pubsub_retention: must be >= 0 (0 uses the default)"))
+	}
+	if c.PubSubMaxMessages < 0 {
+		errs = append(errs, fmt.Errorf("gateway.pubsub_max_messages: must be >= 0 (0 uses the default)"))
+	}
+	if c.PubSubMaxPayload < 0 || c.PubSubMaxPayload > pubsubhandlers.DefaultMaxPayload {
+		errs = append(errs, fmt.Errorf("gateway.pubsub_max_payload: must be between 0 and %d bytes (0 uses the default)", pubsubhandlers.DefaultMaxPayload))
+	}
+	if c.PubSubPublishRate < 0 {
+		errs = append(errs, fmt.Errorf("gateway.pubsub_publish_rate: must be >= 0 (0 is unlimited)"))
+	}
+	if c.PubSubEnvelopeKey != "" {
+		if key, err := hex.DecodeString(c.PubSubEnvelopeKey); err != nil || len(key) != 32 {
+			errs = append(errs, fmt.Errorf("gateway.pubsub_envelope_key: must be 32 bytes hex-encoded (64 hex characters)"))
+		}
+	}
+
+	// Validate SIWE settings
+	for i, d := range c.SIWE.Domains {
+		if d = strings.TrimSpace(d); d == "" || strings.ContainsAny(d, " /") {
+			errs = append(errs, fmt.Errorf("gateway.siwe.domains[%d]: must be a host (optionally with port); got %q", i, d))
+		}
+	}
+	for i, id := range c.SIWE.ChainIDs {
+		if id <= 0 {
+			errs = append(errs, fmt.Errorf("gateway.siwe.chain_ids[%d]: must be > 0; got %d", i, id))
+		}
+	}
+	if c.SIWE.RPCURL != "" {
+		if u, err := url.Parse(c.SIWE.RPCURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("gateway.siwe.rpc_url: must be an http(s) URL; got %q", c.SIWE.RPCURL))
+		}
+	}
+
+	// Validate push notification settings
+	if c.Push.ExpoURL != "" {
+		if u, err := url.Parse(c.Push.ExpoURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("gateway.push.expo_url: must be an http(s) URL; got %q", c.Push.ExpoURL))
+		}
+	}
+	if c.Push.ReceiptDelay < 0 {
+		errs = append(errs, fmt.Errorf("gateway.push.receipt_delay: must be >= 0 (0 uses the default)"))
+	}
+
 	return errs
 }
 
@@ -14,6 +14,24 @@ const (
 	CtxKeyNamespaceOverride = ctxkeys.NamespaceOverride
 )
 
+// ctxKeyRequestLog carries a *requestLogInfo from loggingMiddleware to auth,
+// so the request log can record the identity resolved further down the chain.
+const ctxKeyRequestLog = ctxkeys.ContextKey("request_log_info")
+
+// requestLogInfo is filled in by authMiddleware once the caller is identified.
+type requestLogInfo struct {
+	APIKey    string
+	Namespace string
+}
+
+// setRequestLogIdentity records the caller's API key and namespace for the request log.
+func setRequestLogIdentity(ctx context.Context, apiKey, namespace string) {
+	if info, ok := ctx.Value(ctxKeyRequestLog).(*requestLogInfo); ok && info != nil {
+		info.APIKey = apiKey
+		info.Namespace = namespace
+	}
+}
+
 // withInternalAuth creates a context for internal gateway operations that bypass authentication.
 // This is used when the gateway needs to make internal calls to services without auth checks.
 func (g *Gateway) withInternalAuth(ctx context.Context) context.Context {
@@ -0,0 +1,355 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Default CORS settings applied when neither the gateway config nor a namespace overrides them
+var (
+	defaultCORSAllowedMethods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS"}
+	defaultCORSAllowedHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "If-None-Match", "If-Range", "Range", "Upload-Offset"}
+	defaultCORSExposedHeaders = []string{"X-Request-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Quota-Warning", "Upload-Offset", "Location", "ETag", "Content-Range", "Accept-Ranges", "Content-Disposition"}
+)
+
+const (
+	defaultCORSMaxAge         = 10 * time.Minute
+	defaultCORSPolicyCacheTTL = 30 * time.Second
+)
+
+// CORSConfig holds the gateway-wide CORS defaults. Namespaces may store their
+// own policy (see CORSPolicy), which takes precedence for authenticated requests.
+type CORSConfig struct {
+	AllowedOrigins   []string      // Origins allowed for every namespace (default: ["*"])
+	AllowedHeaders   []string      // Request headers browsers may send
+	ExposedHeaders   []string      // Response headers browsers may read
+	AllowCredentials bool          // Allow cookies/Authorization on cross-origin requests
+	MaxAge           time.Duration // Preflight cache duration (default: 10m)
+	PolicyCacheTTL   time.Duration // How long namespace policies are cached (default: 30s)
+}
+
+// CORSPolicy is a namespace-scoped CORS policy stored in namespace_cors_policies.
+type CORSPolicy struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	ExposedHeaders   []string `json:"exposed_headers,omitempty"`
+	MaxAgeSeconds    int      `json:"max_age_seconds,omitempty"`
+}
+
+// allowsOrigin reports whether origin matches one of the allowed patterns.
+// Patterns are "*", an exact origin, or a wildcard subdomain such as "https://*.example.com".
+func allowsOrigin(patterns []string, origin string) bool {
+	origin = strings.ToLower(strings.TrimSpace(origin))
+	if origin == "" {
+		return false
+	}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case p == "*":
+			return true
+		case p == origin:
+			return true
+		case strings.Contains(p, "://*."):
+			scheme, host, _ := strings.Cut(p, "://*.")
+			if strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateCORSOrigin checks that an origin pattern is "*" or scheme://host[:port].
+func validateCORSOrigin(o string) error {
+	o = strings.TrimSpace(o)
+	if o == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(o, "://*.", "://wildcard.", 1))
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %v", o, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid origin %q: scheme must be http or https", o)
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid origin %q: expected scheme://host[:port]", o)
+	}
+	return nil
+}
+
+// corsPolicyStore caches namespace CORS policies loaded from the database.
+type corsPolicyStore struct {
+	mu       sync.RWMutex
+	policies map[string]CORSPolicy
+	loadedAt time.Time
+	ttl      time.Duration
+	load     func(ctx context.Context) (map[string]CORSPolicy, error)
+	logger   *logging.ColoredLogger
+}
+
+func newCORSPolicyStore(ttl time.Duration, load func(ctx context.Context) (map[string]CORSPolicy, error), logger *logging.ColoredLogger) *corsPolicyStore {
+	if ttl <= 0 {
+		ttl = defaultCORSPolicyCacheTTL
+	}
+	return &corsPolicyStore{ttl: ttl, load: load, logger: logger}
+}
+
+// snapshot returns the cached policies, reloading them once the TTL has passed.
+// On load failure the stale snapshot is kept until the next TTL.
+func (s *corsPolicyStore) snapshot(ctx context.Context) map[string]CORSPolicy {
+	s.mu.RLock()
+	if time.Since(s.loadedAt) < s.ttl {
+		p := s.policies
+		s.mu.RUnlock()
+		return p
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.loadedAt) < s.ttl {
+		return s.policies
+	}
+	s.loadedAt = time.Now()
+	if s.load == nil {
+		return s.policies
+	}
+	loadCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	policies, err := s.load(loadCtx)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.ComponentWarn(logging.ComponentGeneral, "failed to load namespace CORS policies", zap.Error(err))
+		}
+		return s.policies
+	}
+	s.policies = policies
+	return s.policies
+}
+
+// get returns the policy for a namespace, if one is stored.
+func (s *corsPolicyStore) get(ctx context.Context, ns string) (CORSPolicy, bool) {
+	p, ok := s.snapshot(ctx)[ns]
+	return p, ok
+}
+
+// invalidate forces the next lookup to reload from the database.
+func (s *corsPolicyStore) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// loadCORSPolicies reads all namespace CORS policies from the database.
+func (g *Gateway) loadCORSPolicies(ctx context.Context) (map[string]CORSPolicy, error) {
+	if g.client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	db := g.client.Database()
+	res, err := db.Query(client.WithInternalAuth(ctx),
+		"SELECT namespace, allowed_origins, allow_credentials, exposed_headers, max_age_seconds FROM namespace_cors_policies")
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]CORSPolicy)
+	if res == nil {
+		return policies, nil
+	}
+	for _, row := range res.Rows {
+		if len(row) < 5 {
+			continue
+		}
+		ns := strings.TrimSpace(fmt.Sprint(row[0]))
+		if ns == "" {
+			continue
+		}
+		p := CORSPolicy{
+			AllowCredentials: rowBool(row[2]),
+			MaxAgeSeconds:    rowInt(row[4]),
+		}
+		if s, ok := row[1].(string); ok && s != "" {
+			_ = json.Unmarshal([]byte(s), &p.AllowedOrigins)
+		}
+		if s, ok := row[3].(string); ok && s != "" {
+			_ = json.Unmarshal([]byte(s), &p.ExposedHeaders)
+		}
+		policies[ns] = p
+	}
+	return policies, nil
+}
+
+// corsDefaults returns the effective gateway-wide CORS configuration.
+func (g *Gateway) corsDefaults() CORSConfig {
+	var c CORSConfig
+	if g.cfg != nil {
+		c = g.cfg.CORS
+	}
+	if len(c.AllowedOrigins) == 0 {
+		c.AllowedOrigins = []string{"*"}
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = defaultCORSAllowedHeaders
+	}
+	if len(c.ExposedHeaders) == 0 {
+		c.ExposedHeaders = defaultCORSExposedHeaders
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = defaultCORSMaxAge
+	}
+	return c
+}
+
+// setCORSOrigin writes the Allow-Origin/Credentials pair. A wildcard is only
+// emitted when credentials are not allowed, as browsers reject "*" with credentials.
+func setCORSOrigin(h http.Header, origin string, wildcard, credentials bool) {
+	h.Add("Vary", "Origin")
+	if wildcard && !credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	} else {
+		h.Del("Access-Control-Allow-Credentials")
+	}
+}
+
+// corsMiddleware answers preflight requests and applies the gateway-wide CORS
+// policy. The namespace is not known yet at this point, so a preflight is
+// accepted if the origin is allowed globally or by any namespace policy, but
+// only the global defaults can grant credentials on it: one namespace's
+// policy must not vouch for requests to another. namespaceCORSMiddleware
+// then narrows the actual response once auth has run.
+func (g *Gateway) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defaults := g.corsDefaults()
+		origin := r.Header.Get("Origin")
+		h := w.Header()
+
+		globalMatch := allowsOrigin(defaults.AllowedOrigins, origin)
+		wildcard := globalMatch && allowsOrigin(defaults.AllowedOrigins, "*")
+		credentials := globalMatch && defaults.AllowCredentials
+		maxAge := defaults.MaxAge
+
+		if r.Method == http.MethodOptions {
+			if origin != "" && g.corsPolicies != nil {
+				for _, p := range g.corsPolicies.snapshot(r.Context()) {
+					if !allowsOrigin(p.AllowedOrigins, origin) {
+						continue
+					}
+					if !globalMatch {
+						globalMatch, wildcard = true, false
+					}
+					if p.MaxAgeSeconds > 0 && time.Duration(p.MaxAgeSeconds)*time.Second < maxAge {
+						maxAge = time.Duration(p.MaxAgeSeconds) * time.Second
+					}
+				}
+			}
+			if origin != "" && !globalMatch {
+				writeError(w, http.StatusForbidden, "origin not allowed")
+				return
+			}
+			if origin != "" {
+				setCORSOrigin(h, origin, wildcard, credentials)
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", strings.Join(defaultCORSAllowedMethods, ", "))
+			h.Set("Access-Control-Allow-Headers", strings.Join(defaults.AllowedHeaders, ", "))
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if origin != "" && globalMatch {
+			setCORSOrigin(h, origin, wildcard, credentials)
+			h.Set("Access-Control-Expose-Headers", strings.Join(defaults.ExposedHeaders, ", "))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// namespaceCORSMiddleware applies the stored CORS policy of the namespace
+// resolved by auth, replacing the gateway-wide headers set by corsMiddleware.
+func (g *Gateway) namespaceCORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || g.corsPolicies == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ns, ok := r.Context().Value(CtxKeyNamespaceOverride).(string)
+		if !ok || strings.TrimSpace(ns) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy, ok := g.corsPolicies.get(r.Context(), strings.TrimSpace(ns))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		if allowsOrigin(policy.AllowedOrigins, origin) {
+			setCORSOrigin(h, origin, allowsOrigin(policy.AllowedOrigins, "*"), policy.AllowCredentials)
+			exposed := g.corsDefaults().ExposedHeaders
+			if len(policy.ExposedHeaders) > 0 {
+				exposed = append(append([]string{}, exposed...), policy.ExposedHeaders...)
+			}
+			h.Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+		} else {
+			h.Del("Access-Control-Allow-Origin")
+			h.Del("Access-Control-Allow-Credentials")
+			h.Del("Access-Control-Expose-Headers")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rowBool converts a database value to bool.
+func rowBool(v interface{}) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case int64:
+		return t != 0
+	case float64:
+		return t != 0
+	case string:
+		b, _ := strconv.ParseBool(t)
+		return b || t == "1"
+	}
+	return false
+}
+
+// rowInt converts a database value to int.
+func rowInt(v interface{}) int {
+	switch t := v.(type) {
+	case int64:
+		return int(t)
+	case int:
+		return t
+	case float64:
+		return int(t)
+	case string:
+		n, _ := strconv.Atoi(t)
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowsOrigin(t *testing.T) {
+	patterns := []string{"https://app.example.com", "https://*.example.org"}
+	cases := map[string]bool{
+		"https://app.example.com": true,
+		"https://APP.example.com": true,
+		"http://app.example.com":  false,
+		"https://a.b.example.org": true,
+		"https://example.org":     false,
+		"https://evil.com":        false,
+		"":                        false,
+	}
+	for origin, want := range cases {
+		if got := allowsOrigin(patterns, origin); got != want {
+			t.Errorf("allowsOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+	if !allowsOrigin([]string{"*"}, "https://anything.dev") {
+		t.Error("wildcard should allow any origin")
+	}
+}
+
+func TestValidateCORSOrigin(t *testing.T) {
+	valid := []string{"*", "https://app.example.com", "http://localhost:3000", "https://*.example.com"}
+	for _, o := range valid {
+		if err := validateCORSOrigin(o); err != nil {
+			t.Errorf("expected %q to be valid: %v", o, err)
+		}
+	}
+	invalid := []string{"app.example.com", "ftp://example.com", "https://example.com/path", "https://"}
+	for _, o := range invalid {
+		if err := validateCORSOrigin(o); err == nil {
+			t.Errorf("expected %q to be invalid", o)
+		}
+	}
+}
+
+func newCORSTestGateway(policies map[string]CORSPolicy) *Gateway {
+	gw := &Gateway{cfg: &Config{CORS: CORSConfig{AllowedOrigins: []string{"https://global.example.com"}}}}
+	gw.corsPolicies = newCORSPolicyStore(0, func(ctx context.Context) (map[string]CORSPolicy, error) {
+		return policies, nil
+	}, nil)
+	return gw
+}
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	gw := newCORSTestGateway(map[string]CORSPolicy{
+		"tenant": {AllowedOrigins: []string{"https://tenant.app"}, AllowCredentials: true, MaxAgeSeconds: 120},
+	})
+	h := gw.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("preflight should not reach handler")
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/v1/storage/upload", nil)
+	req.Header.Set("Origin", "https://tenant.app")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://tenant.app" {
+		t.Errorf("unexpected Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("expected a namespace policy not to grant credentials on preflight, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "120" {
+		t.Errorf("expected max age 120, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/v1/storage/upload", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for unknown origin, got %d", w.Code)
+	}
+}
+
+func TestNamespaceCORSMiddleware(t *testing.T) {
+	gw := newCORSTestGateway(map[string]CORSPolicy{
+		"tenant": {AllowedOrigins: []string{"https://tenant.app"}},
+	})
+	h := gw.corsMiddleware(gw.namespaceCORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	// Globally allowed origin is stripped when the namespace policy does not list it
+	req := httptest.NewRequest(http.MethodGet, "/v1/storage/get/Qm", nil)
+	req.Header.Set("Origin", "https://global.example.com")
+	req = req.WithContext(context.WithValue(req.Context(), CtxKeyNamespaceOverride, "tenant"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Allow-Origin, got %q", got)
+	}
+
+	// Namespace origin is allowed and X-Request-ID is exposed
+	req = httptest.NewRequest(http.MethodGet, "/v1/storage/get/Qm", nil)
+	req.Header.Set("Origin", "https://tenant.app")
+	req = req.WithContext(context.WithValue(req.Context(), CtxKeyNamespaceOverride, "tenant"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://tenant.app" {
+		t.Errorf("unexpected Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got == "" {
+		t.Error("expected exposed headers")
+	}
+}
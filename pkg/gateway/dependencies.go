@@ -16,10 +16,12 @@ import (
 
 	"github.com/DeBrosOfficial/network/pkg/client"
 	"github.com/DeBrosOfficial/network/pkg/config"
+	"github.com/DeBrosOfficial/network/pkg/encryption"
 	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
 	serverlesshandlers "github.com/DeBrosOfficial/network/pkg/gateway/handlers/serverless"
 	"github.com/DeBrosOfficial/network/pkg/ipfs"
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"github.com/DeBrosOfficial/network/pkg/olric"
 	"github.com/DeBrosOfficial/network/pkg/pubsub"
 	"github.com/DeBrosOfficial/network/pkg/rqlite"
@@ -55,15 +57,22 @@ type Dependencies struct {
 	// IPFS storage client
 	IPFSClient ipfs.IPFSClient
 
+	// Envelope encryption keys for storage uploads (nil when encryption is disabled or RQLite is unavailable)
+	StorageKeyring *encryption.Keyring
+
 	// Serverless function engine components
 	ServerlessEngine   *serverless.Engine
 	ServerlessRegistry *serverless.Registry
 	ServerlessInvoker  *serverless.Invoker
 	ServerlessWSMgr    *serverless.WSManager
 	ServerlessHandlers *serverlesshandlers.ServerlessHandlers
+	ServerlessHost     *hostfunctions.HostFunctions
 
 	// Authentication service
 	AuthService *auth.Service
+
+	// Usage meter (nil when metering is disabled or RQLite is unavailable)
+	Meter *metering.Meter
 }
 
 // NewDependencies creates and initializes all gateway dependencies based on the provided configuration.
@@ -75,6 +84,8 @@ func NewDependencies(logger *logging.ColoredLogger, cfg *Config) (*Dependencies,
 	// Create and connect network client
 	logger.ComponentInfo(logging.ComponentGeneral, "Building client config...")
 	cliCfg := client.DefaultClientConfig(cfg.ClientNamespace)
+	// The gateway is the mesh's entry point; it must never route pubsub through itself
+	cliCfg.PubSubTransport = client.PubSubTransportLibp2p
 	if len(cfg.BootstrapPeers) > 0 {
 		cliCfg.BootstrapPeers = cfg.BootstrapPeers
 	}
@@ -104,6 +115,9 @@ func NewDependencies(logger *logging.ColoredLogger, cfg *Config) (*Dependencies,
 		logger.ComponentWarn(logging.ComponentGeneral, "RQLite initialization failed", zap.Error(err))
 	}
 
+	// Initialize usage metering (requires RQLite)
+	initializeMetering(logger, cfg, deps)
+
 	// Initialize Olric cache client (with retry and background reconnection)
 	initializeOlric(logger, cfg, deps, c)
 
@@ -153,6 +167,31 @@ func initializeRQLite(logger *logging.ColoredLogger, cfg *Config, deps *Dependen
 	return nil
 }
 
+// initializeMetering starts the usage meter and reports RQLite HTTP gateway
+// statements and rows to it.
+func initializeMetering(logger *logging.ColoredLogger, cfg *Config, deps *Dependencies) {
+	if cfg.Metering.Disabled || deps.ORMClient == nil {
+		return
+	}
+	deps.Meter = metering.NewMeter(deps.ORMClient, metering.Config{
+		FlushInterval: cfg.Metering.FlushInterval,
+		CacheTTL:      cfg.Metering.CacheTTL,
+		Quotas:        cfg.Metering.Quotas,
+	}, logger.Logger)
+	deps.Meter.Start()
+
+	if deps.ORMHTTP != nil {
+		deps.ORMHTTP.OnUsage = func(ctx context.Context, statements, rows int64) {
+			metering.Record(ctx, metering.DBStatements, float64(statements))
+			metering.Record(ctx, metering.DBRows, float64(rows))
+		}
+	}
+
+	logger.ComponentInfo(logging.ComponentGeneral, "Usage metering enabled",
+		zap.Int("default_quotas", len(cfg.Metering.Quotas)),
+	)
+}
+
 // initializeOlric sets up the Olric distributed cache client with retry and background reconnection
 func initializeOlric(logger *logging.ColoredLogger, cfg *Config, deps *Dependencies, networkClient client.NetworkClient) {
 	logger.ComponentInfo(logging.ComponentGeneral, "Initializing Olric cache client...")
@@ -324,6 +363,18 @@ func initializeIPFS(logger *logging.ColoredLogger, cfg *Config, deps *Dependenci
 	cfg.IPFSAPIURL = ipfsAPIURL
 	cfg.IPFSReplicationFactor = ipfsReplicationFactor
 	cfg.IPFSEnableEncryption = ipfsEnableEncryption
+
+	// Encryption keys live in RQLite next to the object metadata
+	if deps.ORMClient != nil {
+		keyring, err := encryption.NewKeyring(deps.ORMClient, cfg.IPFSEncryptionKey, logger.Logger)
+		if err != nil {
+			logger.ComponentWarn(logging.ComponentGeneral, "failed to initialize storage keyring; encrypted uploads disabled", zap.Error(err))
+		} else {
+			deps.StorageKeyring = keyring
+		}
+	} else if ipfsEnableEncryption {
+		logger.ComponentWarn(logging.ComponentGeneral, "RQLite unavailable; encrypted uploads disabled")
+	}
 }
 
 // initializeServerless sets up the serverless function engine and related components
@@ -388,11 +439,16 @@ func initializeServerless(logger *logging.ColoredLogger, cfg *Config, deps *Depe
 	engineCfg.ModuleCacheSize = 100
 
 	// Create WASM engine
-	engine, err := serverless.NewEngine(engineCfg, registry, hostFuncs, logger.Logger, serverless.WithInvocationLogger(registry))
+	engineOpts := []serverless.EngineOption{serverless.WithInvocationLogger(registry)}
+	if deps.Meter != nil {
+		engineOpts = append(engineOpts, serverless.WithUsageRecorder(deps.Meter))
+	}
+	engine, err := serverless.NewEngine(engineCfg, registry, hostFuncs, logger.Logger, engineOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to initialize serverless engine: %w", err)
 	}
 	deps.ServerlessEngine = engine
+	deps.ServerlessHost = hostFuncs
 
 	// Create invoker
 	deps.ServerlessInvoker = serverless.NewInvoker(engine, registry, hostFuncs, logger.Logger)
@@ -416,6 +472,10 @@ func initializeServerless(logger *logging.ColoredLogger, cfg *Config, deps *Depe
 	if err != nil {
 		return fmt.Errorf("failed to initialize auth service: %w", err)
 	}
+	authService.SetSIWEConfig(cfg.SIWE)
+	if deps.ORMClient != nil {
+		authService.SetNonceDB(deps.ORMClient)
+	}
 	deps.AuthService = authService
 
 	logger.ComponentInfo(logging.ComponentGeneral, "Serverless function engine ready",
@@ -8,6 +8,7 @@ package gateway
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"sync"
 	"time"
 
@@ -18,15 +19,16 @@ import (
 	pubsubhandlers "github.com/DeBrosOfficial/network/pkg/gateway/handlers/pubsub"
 	serverlesshandlers "github.com/DeBrosOfficial/network/pkg/gateway/handlers/serverless"
 	"github.com/DeBrosOfficial/network/pkg/gateway/handlers/storage"
+	"github.com/DeBrosOfficial/network/pkg/httputil"
 	"github.com/DeBrosOfficial/network/pkg/ipfs"
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"github.com/DeBrosOfficial/network/pkg/olric"
 	"github.com/DeBrosOfficial/network/pkg/rqlite"
 	"github.com/DeBrosOfficial/network/pkg/serverless"
 	"go.uber.org/zap"
 )
 
-
 type Gateway struct {
 	logger     *logging.ColoredLogger
 	cfg        *Config
@@ -40,8 +42,8 @@ type Gateway struct {
 	ormHTTP   *rqlite.HTTPGateway
 
 	// Olric cache client
-	olricClient *olric.Client
-	olricMu     sync.RWMutex
+	olricClient   *olric.Client
+	olricMu       sync.RWMutex
 	cacheHandlers *cache.CacheHandlers
 
 	// IPFS storage client
@@ -65,6 +67,26 @@ type Gateway struct {
 	// Authentication service
 	authService  *auth.Service
 	authHandlers *authhandlers.Handlers
+
+	// Proxies whose forwarding headers are believed; see getClientIP
+	trustedProxies httputil.TrustedProxies
+
+	// Request throttling (nil when rate limiting is disabled)
+	rateLimiter *rateLimiter
+
+	// Cached per-namespace CORS policies
+	corsPolicies *corsPolicyStore
+
+	// Buffered, batched request log persistence (nil when disabled)
+	requestLogs *requestLogWriter
+
+	// Per-namespace usage metering and quotas (nil when disabled)
+	meter *metering.Meter
+
+	// Push notifications (nil without RQLite to store device tokens)
+	push         *PushNotificationService
+	pushReceipts *pushReceiptChecker
+	pushBridge   *pushBridge
 }
 
 // localSubscriber represents a WebSocket subscriber for local message delivery
@@ -142,22 +164,91 @@ func New(logger *logging.ColoredLogger, cfg *Config) (*Gateway, error) {
 		serverlessWSMgr:    deps.ServerlessWSMgr,
 		serverlessHandlers: deps.ServerlessHandlers,
 		authService:        deps.AuthService,
+		meter:              deps.Meter,
 		localSubscribers:   make(map[string][]*localSubscriber),
 		presenceMembers:    make(map[string][]PresenceMember),
 	}
 
+	gw.corsPolicies = newCORSPolicyStore(cfg.CORS.PolicyCacheTTL, gw.loadCORSPolicies, logger)
+
+	if deps.Client != nil && !cfg.RequestLog.Disabled {
+		gw.requestLogs = newRequestLogWriter(cfg.RequestLog, func() requestLogDB {
+			return gw.client.Database()
+		}, logger)
+	}
+
+	trusted, err := httputil.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.ComponentWarn(logging.ComponentGeneral, "ignoring invalid trusted proxies", zap.Error(err))
+	}
+	gw.trustedProxies = trusted
+
+	if cfg.RateLimit.Enabled {
+		gw.rateLimiter = newRateLimiter(cfg.RateLimit, gw.getOlricClient, logger)
+	}
+
 	// Initialize handler instances
-	gw.pubsubHandlers = pubsubhandlers.NewPubSubHandlers(deps.Client, logger)
+	pubsubCfg := pubsubhandlers.Config{
+		Olric:       gw.getOlricClient,
+		Retention:   cfg.PubSubRetention,
+		MaxMessages: cfg.PubSubMaxMessages,
+		MaxPayload:  cfg.PubSubMaxPayload,
+		PublishRate: cfg.PubSubPublishRate,
+	}
+	if cfg.PubSubEnvelopeKey != "" {
+		// Validated as 32 bytes of hex by Config.ValidateConfig
+		pubsubCfg.EnvelopeKey, _ = hex.DecodeString(cfg.PubSubEnvelopeKey)
+	}
+	if deps.ORMClient != nil {
+		pubsubCfg.DB = deps.ORMClient
+
+		gw.push = NewPushNotificationService(logger.Logger, cfg.Push)
+		gw.pushReceipts = newPushReceiptChecker(gw.push, cfg.Push.ReceiptDelay, gw.forgetPushToken, logger)
+		gw.pushBridge = newPushBridge(gw)
+		pubsubCfg.OnPublish = gw.pushBridge.enqueue
+	}
+	gw.pubsubHandlers = pubsubhandlers.NewPubSubHandlers(deps.Client, logger, pubsubCfg)
 
 	if deps.OlricClient != nil {
 		gw.cacheHandlers = cache.NewCacheHandlers(logger, deps.OlricClient)
 	}
 
 	if deps.IPFSClient != nil {
-		gw.storageHandlers = storage.New(deps.IPFSClient, logger, storage.Config{
+		storageCfg := storage.Config{
 			IPFSReplicationFactor: cfg.IPFSReplicationFactor,
 			IPFSAPIURL:            cfg.IPFSAPIURL,
-		})
+			EnableEncryption:      cfg.IPFSEnableEncryption,
+			MaxObjectSize:         cfg.IPFSMaxObjectSize,
+			UploadDir:             cfg.IPFSUploadDir,
+			UploadSessionTTL:      cfg.IPFSUploadSessionTTL,
+			MaxUploadSessions:     cfg.IPFSMaxUploadSessions,
+			MaxUploadBytes:        cfg.IPFSMaxUploadBytes,
+			PinMaxAttempts:        cfg.IPFSPinMaxAttempts,
+			PinReconcileInterval:  cfg.IPFSReconcileInterval,
+			LifecycleInterval:     cfg.IPFSLifecycleInterval,
+			TrustedProxies:        gw.trustedProxies,
+		}
+		if deps.StorageKeyring != nil {
+			storageCfg.Keyring = deps.StorageKeyring
+		}
+		if deps.ORMClient != nil {
+			storageCfg.DB = deps.ORMClient
+		}
+		if deps.Meter != nil {
+			storageCfg.Meter = deps.Meter
+		}
+		if cfg.DomainName != "" {
+			storageCfg.ReservedDomains = []string{cfg.DomainName}
+		}
+		if cfg.IPFSPresignKey != "" {
+			// Validated as 32 bytes of hex by Config.ValidateConfig
+			storageCfg.PresignKey, _ = hex.DecodeString(cfg.IPFSPresignKey)
+		}
+		gw.storageHandlers = storage.New(deps.IPFSClient, logger, storageCfg)
+		// Objects written by functions are encrypted, tracked and expired like uploads
+		if deps.ServerlessHost != nil {
+			deps.ServerlessHost.SetObjectStore(gw.storageHandlers)
+		}
 	}
 
 	if deps.AuthService != nil {
@@ -245,4 +336,3 @@ func (g *Gateway) startOlricReconnectLoop(cfg olric.Config) {
 		}
 	}()
 }
-
@@ -13,7 +13,10 @@ import (
 //
 // POST /v1/auth/challenge
 // Request body: ChallengeRequest
-// Response: { "wallet", "namespace", "nonce", "purpose", "expires_at" }
+// Response: { "wallet", "namespace", "nonce", "purpose", "expires_at", "siwe_message"? }
+//
+// For Ethereum wallets the response also carries a ready-to-sign EIP-4361
+// message that can be passed back to /v1/auth/verify as "message".
 func (h *Handlers) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
 	if h.authService == nil {
 		writeError(w, http.StatusServiceUnavailable, "auth service not initialized")
@@ -40,13 +43,17 @@ func (h *Handlers) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"wallet":     req.Wallet,
 		"namespace":  req.Namespace,
 		"nonce":      nonce,
 		"purpose":    req.Purpose,
 		"expires_at": time.Now().Add(5 * time.Minute).UTC().Format(time.RFC3339Nano),
-	})
+	}
+	if msg, err := h.authService.NewSIWEMessage(req.Wallet, nonce, req.URI, req.Statement, req.ChainID); err == nil {
+		resp["siwe_message"] = msg.String()
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // writeJSON writes JSON with status code
@@ -5,6 +5,9 @@ type ChallengeRequest struct {
 	Wallet    string `json:"wallet"`
 	Purpose   string `json:"purpose"`
 	Namespace string `json:"namespace"`
+	ChainID   int64  `json:"chain_id,omitempty"`  // SIWE chain ID (defaults to the first configured chain)
+	URI       string `json:"uri,omitempty"`       // SIWE URI (defaults to https://<domain>)
+	Statement string `json:"statement,omitempty"` // SIWE statement shown by the wallet
 }
 
 // VerifyRequest is the request body for signature verification
@@ -14,6 +17,7 @@ type VerifyRequest struct {
 	Signature string `json:"signature"`
 	Namespace string `json:"namespace"`
 	ChainType string `json:"chain_type"`
+	Message   string `json:"message,omitempty"` // Signed EIP-4361 message; wallet and nonce are taken from it
 }
 
 // APIKeyRequest is the request body for API key generation
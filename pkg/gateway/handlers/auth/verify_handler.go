@@ -14,6 +14,10 @@ import (
 // POST /v1/auth/verify
 // Request body: VerifyRequest
 // Response: { "access_token", "token_type", "expires_in", "refresh_token", "subject", "namespace", "api_key", "nonce", "signature_verified" }
+//
+// When "message" is set the request is a Sign-In with Ethereum (EIP-4361)
+// login: the message's domain, chain ID, timestamps and nonce are validated
+// and the signature may come from an EOA or an EIP-1271 contract wallet.
 func (h *Handlers) VerifyHandler(w http.ResponseWriter, r *http.Request) {
 	if h.authService == nil {
 		writeError(w, http.StatusServiceUnavailable, "auth service not initialized")
@@ -29,21 +33,40 @@ func (h *Handlers) VerifyHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid json body")
 		return
 	}
-	if strings.TrimSpace(req.Wallet) == "" || strings.TrimSpace(req.Nonce) == "" || strings.TrimSpace(req.Signature) == "" {
-		writeError(w, http.StatusBadRequest, "wallet, nonce and signature are required")
-		return
-	}
 
 	ctx := r.Context()
-	verified, err := h.authService.VerifySignature(ctx, req.Wallet, req.Nonce, req.Signature, req.ChainType)
-	if err != nil || !verified {
-		writeError(w, http.StatusUnauthorized, "signature verification failed")
-		return
-	}
+	if strings.TrimSpace(req.Message) != "" {
+		if strings.TrimSpace(req.Signature) == "" {
+			writeError(w, http.StatusBadRequest, "message and signature are required")
+			return
+		}
+		msg, err := h.authService.VerifySIWE(ctx, req.Message, req.Signature, req.Namespace)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if req.Wallet != "" && !strings.EqualFold(req.Wallet, msg.Address) {
+			writeError(w, http.StatusUnauthorized, "wallet does not match message address")
+			return
+		}
+		req.Wallet = msg.Address
+		req.Nonce = msg.Nonce
+	} else {
+		if strings.TrimSpace(req.Wallet) == "" || strings.TrimSpace(req.Nonce) == "" || strings.TrimSpace(req.Signature) == "" {
+			writeError(w, http.StatusBadRequest, "wallet, nonce and signature are required")
+			return
+		}
+
+		verified, err := h.authService.VerifySignature(ctx, req.Wallet, req.Nonce, req.Signature, req.ChainType)
+		if err != nil || !verified {
+			writeError(w, http.StatusUnauthorized, "signature verification failed")
+			return
+		}
 
-	// Mark nonce used
-	nsID, _ := h.resolveNamespace(ctx, req.Namespace)
-	h.markNonceUsed(ctx, nsID, strings.ToLower(req.Wallet), req.Nonce)
+		// Mark nonce used
+		nsID, _ := h.resolveNamespace(ctx, req.Namespace)
+		h.markNonceUsed(ctx, nsID, strings.ToLower(req.Wallet), req.Nonce)
+	}
 
 	token, refresh, expUnix, err := h.authService.IssueTokens(ctx, req.Wallet, req.Namespace)
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	olriclib "github.com/olric-data/olric"
 )
 
@@ -76,6 +77,7 @@ func (h *CacheHandlers) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "key not found")
 		return
 	}
+	metering.Record(r.Context(), metering.CacheOps, 1)
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status": "ok",
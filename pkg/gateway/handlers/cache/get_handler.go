@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	olriclib "github.com/olric-data/olric"
 	"go.uber.org/zap"
 )
@@ -88,6 +89,8 @@ func (h *CacheHandlers) GetHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to decode value: %v", err))
 		return
 	}
+	metering.Record(r.Context(), metering.CacheOps, 1)
+	metering.Record(r.Context(), metering.CacheBytes, float64(valueSize(value)))
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"key":   req.Key,
@@ -155,10 +158,12 @@ func (h *CacheHandlers) MultiGetHandler(w http.ResponseWriter, r *http.Request)
 
 	// Get all keys and collect results
 	var results []map[string]any
+	var ops, bytesOut int
 	for _, key := range req.Keys {
 		if strings.TrimSpace(key) == "" {
 			continue // Skip empty keys
 		}
+		ops++
 
 		gr, err := dm.Get(ctx, key)
 		if err != nil {
@@ -178,11 +183,14 @@ func (h *CacheHandlers) MultiGetHandler(w http.ResponseWriter, r *http.Request)
 			continue
 		}
 
+		bytesOut += valueSize(value)
 		results = append(results, map[string]any{
 			"key":   key,
 			"value": value,
 		})
 	}
+	metering.Record(r.Context(), metering.CacheOps, float64(ops))
+	metering.Record(r.Context(), metering.CacheBytes, float64(bytesOut))
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"results": results,
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	olriclib "github.com/olric-data/olric"
 )
 
@@ -78,6 +79,7 @@ func (h *CacheHandlers) ScanHandler(w http.ResponseWriter, r *http.Request) {
 	for iterator.Next() {
 		keys = append(keys, iterator.Key())
 	}
+	metering.Record(r.Context(), metering.CacheOps, 1)
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"keys":  keys,
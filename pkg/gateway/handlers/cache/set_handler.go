@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/metering"
 )
 
 // SetHandler handles cache PUT/SET requests for storing a key-value pair in a distributed map.
@@ -93,6 +95,8 @@ func (h *CacheHandlers) SetHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to put key: %v", err))
 		return
 	}
+	metering.Record(r.Context(), metering.CacheOps, 1)
+	metering.Record(r.Context(), metering.CacheBytes, float64(valueSize(valueToStore)))
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status": "ok",
@@ -94,3 +94,21 @@ func decodeValueFromOlric(gr *olriclib.GetResponse) (any, error) {
 
 	return value, nil
 }
+
+// valueSize estimates the stored size of a cache value in bytes for usage metering.
+func valueSize(v any) int {
+	switch val := v.(type) {
+	case nil:
+		return 0
+	case []byte:
+		return len(val)
+	case string:
+		return len(val)
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return 0
+		}
+		return len(b)
+	}
+}
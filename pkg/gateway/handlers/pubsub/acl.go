@@ -0,0 +1,683 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"go.uber.org/zap"
+)
+
+const (
+	aclPublish   = "publish"
+	aclSubscribe = "subscribe"
+
+	// adminRole names the role allowed to change a namespace's ACLs, roles
+	// and policies. Until it is defined, only wallets owning the namespace
+	// may.
+	adminRole = "admin"
+
+	// aclCacheTTL bounds how long a gateway may enforce rules changed on
+	// another gateway.
+	aclCacheTTL = 30 * time.Second
+
+	maxRoleName      = 64
+	maxACLPrincipals = 1000
+)
+
+var errTopicForbidden = errors.New("forbidden: not allowed on this topic")
+
+// aclRuleRow is a row of pubsub_topic_acls.
+type aclRuleRow struct {
+	Topic     string `db:"topic"`
+	Publish   string `db:"publish"`
+	Subscribe string `db:"subscribe"`
+	UpdatedAt string `db:"updated_at"`
+	UpdatedBy string `db:"updated_by"`
+}
+
+func (r *aclRuleRow) toACL() TopicACL {
+	a := TopicACL{Topic: r.Topic, UpdatedBy: r.UpdatedBy}
+	a.Publish = decodePrincipals(r.Publish)
+	a.Subscribe = decodePrincipals(r.Subscribe)
+	a.UpdatedAt, _ = time.Parse(time.RFC3339, r.UpdatedAt)
+	return a
+}
+
+// roleRow is a row of pubsub_roles.
+type roleRow struct {
+	Role      string `db:"role"`
+	Members   string `db:"members"`
+	UpdatedAt string `db:"updated_at"`
+	UpdatedBy string `db:"updated_by"`
+}
+
+func (r *roleRow) toRole() PubSubRole {
+	role := PubSubRole{Role: r.Role, Members: decodePrincipals(r.Members), UpdatedBy: r.UpdatedBy}
+	if role.Members == nil {
+		role.Members = []string{}
+	}
+	role.UpdatedAt, _ = time.Parse(time.RFC3339, r.UpdatedAt)
+	return role
+}
+
+// encodePrincipals stores a rule list; nil (anyone) is stored as an empty
+// string.
+func encodePrincipals(list []string) string {
+	if list == nil {
+		return ""
+	}
+	b, _ := json.Marshal(list)
+	return string(b)
+}
+
+func decodePrincipals(s string) []string {
+	if s == "" {
+		return nil
+	}
+	list := []string{}
+	_ = json.Unmarshal([]byte(s), &list)
+	return list
+}
+
+// validPrincipal reports whether s names who a rule applies to: "*", or
+// wallet:, apikey:, scope: or role: followed by a value. Role members cannot
+// be roles themselves.
+func validPrincipal(s string, inRole bool) bool {
+	if s == "*" {
+		return true
+	}
+	kind, v, ok := strings.Cut(s, ":")
+	if !ok || v == "" {
+		return false
+	}
+	switch kind {
+	case "wallet", "apikey", "scope":
+		return true
+	case "role":
+		return !inRole
+	}
+	return false
+}
+
+func validatePrincipals(field string, list []string, inRole bool) error {
+	if len(list) > maxACLPrincipals {
+		return fmt.Errorf("%s: at most %d principals", field, maxACLPrincipals)
+	}
+	for _, pr := range list {
+		if !validPrincipal(pr, inRole) {
+			if inRole {
+				return fmt.Errorf("%s: invalid principal %q: expected *, wallet:, apikey: or scope:", field, pr)
+			}
+			return fmt.Errorf("%s: invalid principal %q: expected *, wallet:, apikey:, scope: or role:", field, pr)
+		}
+	}
+	return nil
+}
+
+// aclCaller is who a publish or subscribe is checked for.
+type aclCaller struct {
+	wallet string // wallet of a JWT
+	apiKey string // API key fingerprint, as in publisherOf
+	rawKey string
+	scopes []string
+}
+
+// callerOf returns the identity of the request that ctx belongs to.
+func callerOf(ctx context.Context) aclCaller {
+	var c aclCaller
+	if v := ctx.Value(ctxkeys.JWT); v != nil {
+		if claims, ok := v.(*auth.JWTClaims); ok && claims != nil {
+			// API key subjects (ak_<random>:<namespace>) are not wallets
+			sub := strings.TrimSpace(claims.Sub)
+			if sub != "" && !strings.HasPrefix(strings.ToLower(sub), "ak_") && !strings.Contains(sub, ":") {
+				c.wallet = sub
+			}
+		}
+	}
+	if v, ok := ctx.Value(ctxkeys.APIKey).(string); ok && v != "" {
+		sum := sha256.Sum256([]byte(v))
+		c.rawKey = v
+		c.apiKey = "apikey:" + hex.EncodeToString(sum[:6])
+	}
+	return c
+}
+
+// namespaceACL holds the rules and roles of a namespace.
+type namespaceACL struct {
+	rules      []TopicACL
+	roles      map[string][]string
+	usesScopes bool
+}
+
+type cachedACL struct {
+	acl     *namespaceACL
+	expires time.Time
+}
+
+type cachedScopes struct {
+	scopes  []string
+	expires time.Time
+}
+
+// ruleFor returns the rule governing topic: a rule for the topic itself, or
+// else the matching pattern with the most literal segments.
+func (a *namespaceACL) ruleFor(topic string) *TopicACL {
+	var best *TopicACL
+	bestScore := -1
+	for i := range a.rules {
+		r := &a.rules[i]
+		if r.Topic == topic {
+			return r
+		}
+		if !isPattern(r.Topic) || !matchTopic(r.Topic, topic) {
+			continue
+		}
+		if score := patternSpecificity(r.Topic); score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best
+}
+
+// patternSpecificity ranks wildcard patterns matching the same topic: more
+// segments, and more literal ones, rank higher.
+func patternSpecificity(pattern string) int {
+	score := 0
+	for _, seg := range strings.Split(pattern, ".") {
+		score += 2
+		if seg == "*" || seg == ">" {
+			score--
+		}
+	}
+	return score
+}
+
+// allows reports whether any of principals matches c; nil allows anyone.
+func (a *namespaceACL) allows(principals []string, c aclCaller) bool {
+	if principals == nil {
+		return true
+	}
+	for _, pr := range principals {
+		if a.matches(pr, c, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *namespaceACL) matches(pr string, c aclCaller, roles bool) bool {
+	if pr == "*" {
+		return true
+	}
+	kind, v, _ := strings.Cut(pr, ":")
+	switch kind {
+	case "wallet":
+		return c.wallet != "" && strings.EqualFold(v, c.wallet)
+	case "apikey":
+		return c.apiKey != "" && pr == c.apiKey
+	case "scope":
+		return slices.Contains(c.scopes, v)
+	case "role":
+		if roles {
+			for _, m := range a.roles[v] {
+				if a.matches(m, c, false) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// namespaceACL returns the cached rules and roles of ns. When they cannot be
+// reloaded the previous ones stay in force.
+func (p *PubSubHandlers) namespaceACL(ctx context.Context, ns string) (*namespaceACL, error) {
+	if p.config.DB == nil {
+		return &namespaceACL{}, nil
+	}
+	p.aclMu.Lock()
+	c, ok := p.acls[ns]
+	p.aclMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.acl, nil
+	}
+
+	acl, err := p.loadACL(ctx, ns)
+	if err != nil {
+		if ok {
+			p.logger.ComponentWarn("gateway", "pubsub: failed to reload topic ACLs, keeping previous rules",
+				zap.String("namespace", ns),
+				zap.Error(err))
+			return c.acl, nil
+		}
+		return nil, err
+	}
+	p.aclMu.Lock()
+	p.acls[ns] = cachedACL{acl: acl, expires: time.Now().Add(aclCacheTTL)}
+	p.aclMu.Unlock()
+	return acl, nil
+}
+
+func (p *PubSubHandlers) loadACL(ctx context.Context, ns string) (*namespaceACL, error) {
+	var rules []aclRuleRow
+	if err := p.config.DB.Query(ctx, &rules,
+		"SELECT topic, publish, subscribe, updated_at, updated_by FROM pubsub_topic_acls WHERE namespace = ? ORDER BY topic",
+		ns); err != nil {
+		return nil, err
+	}
+	var roles []roleRow
+	if err := p.config.DB.Query(ctx, &roles,
+		"SELECT role, members, updated_at, updated_by FROM pubsub_roles WHERE namespace = ? ORDER BY role",
+		ns); err != nil {
+		return nil, err
+	}
+
+	acl := &namespaceACL{roles: make(map[string][]string, len(roles))}
+	uses := func(list []string) {
+		for _, pr := range list {
+			if strings.HasPrefix(pr, "scope:") {
+				acl.usesScopes = true
+			}
+		}
+	}
+	for i := range rules {
+		r := rules[i].toACL()
+		uses(r.Publish)
+		uses(r.Subscribe)
+		acl.rules = append(acl.rules, r)
+	}
+	for i := range roles {
+		r := roles[i].toRole()
+		uses(r.Members)
+		acl.roles[r.Role] = r.Members
+	}
+	return acl, nil
+}
+
+// forgetACL drops the cached rules of ns after they change.
+func (p *PubSubHandlers) forgetACL(ns string) {
+	p.aclMu.Lock()
+	delete(p.acls, ns)
+	p.aclMu.Unlock()
+}
+
+// scopesOf returns the scopes of an API key, stored comma-separated or as a
+// JSON array in api_keys.scopes.
+func (p *PubSubHandlers) scopesOf(ctx context.Context, c aclCaller) []string {
+	if c.rawKey == "" || p.config.DB == nil {
+		return nil
+	}
+	p.aclMu.Lock()
+	cs, ok := p.scopes[c.apiKey]
+	p.aclMu.Unlock()
+	if ok && time.Now().Before(cs.expires) {
+		return cs.scopes
+	}
+
+	var rows []struct {
+		Scopes string `db:"scopes"`
+	}
+	if err := p.config.DB.Query(ctx, &rows, "SELECT COALESCE(scopes, '') AS scopes FROM api_keys WHERE key = ? LIMIT 1", c.rawKey); err != nil {
+		p.logger.ComponentWarn("gateway", "pubsub: failed to read API key scopes", zap.Error(err))
+		return cs.scopes
+	}
+	var scopes []string
+	if len(rows) > 0 {
+		raw := strings.TrimSpace(rows[0].Scopes)
+		if strings.HasPrefix(raw, "[") {
+			_ = json.Unmarshal([]byte(raw), &scopes)
+		} else {
+			for _, s := range strings.Split(raw, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					scopes = append(scopes, s)
+				}
+			}
+		}
+	}
+	p.aclMu.Lock()
+	p.scopes[c.apiKey] = cachedScopes{scopes: scopes, expires: time.Now().Add(aclCacheTTL)}
+	p.aclMu.Unlock()
+	return scopes
+}
+
+// authorize returns errTopicForbidden unless the caller of ctx may perform
+// action (aclPublish or aclSubscribe) on topic. Topics without a rule are
+// open to every member of the namespace.
+func (p *PubSubHandlers) authorize(ctx context.Context, ns, topic, action string) error {
+	acl, err := p.namespaceACL(ctx, ns)
+	if err != nil {
+		return err
+	}
+	rule := acl.ruleFor(topic)
+	if rule == nil {
+		return nil
+	}
+	principals := rule.Subscribe
+	if action == aclPublish {
+		principals = rule.Publish
+	}
+	c := callerOf(ctx)
+	if acl.usesScopes {
+		c.scopes = p.scopesOf(ctx, c)
+	}
+	if !acl.allows(principals, c) {
+		return errTopicForbidden
+	}
+	return nil
+}
+
+// WalletMaySubscribe reports whether wallet may subscribe to topic in ns,
+// judged by the wallet alone. Push notifications use it to honor topic ACLs
+// both when a device registers and when a message is delivered to it.
+func (p *PubSubHandlers) WalletMaySubscribe(ctx context.Context, ns, topic, wallet string) (bool, error) {
+	ctx = context.WithValue(ctx, ctxkeys.JWT, &auth.JWTClaims{Sub: wallet})
+	ctx = context.WithValue(ctx, ctxkeys.APIKey, "")
+	err := p.authorize(ctx, ns, topic, aclSubscribe)
+	if errors.Is(err, errTopicForbidden) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// allowHTTP authorizes an HTTP request, writing the error response when it
+// is refused.
+func (p *PubSubHandlers) allowHTTP(w http.ResponseWriter, r *http.Request, ns, topic, action string) bool {
+	err := p.authorize(r.Context(), ns, topic, action)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errTopicForbidden):
+		writeError(w, http.StatusForbidden, fmt.Sprintf("forbidden: not allowed to %s to this topic", action))
+	default:
+		p.logger.ComponentError("gateway", "pubsub: failed to load topic ACLs", zap.Error(err))
+		writeError(w, http.StatusServiceUnavailable, "failed to check topic permissions")
+	}
+	return false
+}
+
+// canManageACL reports whether the caller may change the rules, roles and
+// policies of ns: a member of the admin role or, until that role is
+// defined, a wallet owning the namespace. API keys are shared with apps, so
+// they need the admin role.
+func (p *PubSubHandlers) canManageACL(ctx context.Context, ns string, acl *namespaceACL) (bool, error) {
+	c := callerOf(ctx)
+	if _, ok := acl.roles[adminRole]; !ok {
+		if c.wallet == "" {
+			return false, nil
+		}
+		return p.ownsNamespace(ctx, ns, c.wallet)
+	}
+	if acl.usesScopes {
+		c.scopes = p.scopesOf(ctx, c)
+	}
+	return acl.allows([]string{"role:" + adminRole}, c), nil
+}
+
+// ownsNamespace reports whether wallet is recorded as an owner of ns.
+func (p *PubSubHandlers) ownsNamespace(ctx context.Context, ns, wallet string) (bool, error) {
+	var rows []struct {
+		One int `db:"one"`
+	}
+	err := p.config.DB.Query(ctx, &rows,
+		`SELECT 1 AS one FROM namespace_ownership o JOIN namespaces n ON n.id = o.namespace_id
+		 WHERE n.name = ? AND o.owner_type = 'wallet' AND LOWER(o.owner_id) = LOWER(?) LIMIT 1`,
+		ns, wallet)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+// loadACLForManagement loads the current rules of ns (bypassing the cache)
+// and checks that the caller may change them, writing the error response
+// otherwise.
+func (p *PubSubHandlers) loadACLForManagement(w http.ResponseWriter, r *http.Request, ns string) (*namespaceACL, bool) {
+	acl, err := p.loadACL(r.Context(), ns)
+	if err != nil {
+		p.logger.ComponentError("gateway", "failed to load topic ACLs", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to load topic ACLs")
+		return nil, false
+	}
+	if r.Method == http.MethodGet {
+		return acl, true
+	}
+	allowed, err := p.canManageACL(r.Context(), ns, acl)
+	if err != nil {
+		p.logger.ComponentError("gateway", "failed to check namespace ownership", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to check permissions")
+		return nil, false
+	}
+	if !allowed {
+		if _, ok := acl.roles[adminRole]; ok {
+			writeError(w, http.StatusForbidden, "forbidden: requires the admin role")
+		} else {
+			writeError(w, http.StatusForbidden, "forbidden: requires a wallet owning the namespace until the admin role is defined")
+		}
+		return nil, false
+	}
+	return acl, true
+}
+
+// ACLHandler handles /v1/pubsub/acl:
+//
+//	GET                             lists the namespace's rules
+//	PUT {topic,publish,subscribe}   sets the rule of a topic or pattern
+//	DELETE ?topic=                  removes a rule
+//
+// publish and subscribe list the principals allowed to do so; an omitted
+// list allows anyone and an empty one nobody.
+func (p *PubSubHandlers) ACLHandler(w http.ResponseWriter, r *http.Request) {
+	if p.config.DB == nil {
+		writeError(w, http.StatusServiceUnavailable, "topic ACLs not available")
+		return
+	}
+	ns := resolveNamespaceFromRequest(r)
+	if ns == "" {
+		writeError(w, http.StatusForbidden, "namespace not resolved")
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		acl, ok := p.loadACLForManagement(w, r, ns)
+		if !ok {
+			return
+		}
+		rules := acl.rules
+		if rules == nil {
+			rules = []TopicACL{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"rules": rules, "count": len(rules)})
+
+	case http.MethodPut:
+		var req TopicACLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Topic == "" {
+			writeError(w, http.StatusBadRequest, "invalid body: expected {topic,publish,subscribe}")
+			return
+		}
+		if !validTopic(req.Topic) {
+			writeError(w, http.StatusBadRequest, "invalid topic")
+			return
+		}
+		if err := validatePrincipals("publish", req.Publish, false); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := validatePrincipals("subscribe", req.Subscribe, false); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, ok := p.loadACLForManagement(w, r, ns); !ok {
+			return
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		if _, err := p.config.DB.Exec(ctx,
+			`INSERT INTO pubsub_topic_acls (namespace, topic, publish, subscribe, updated_at, updated_by)
+			 VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(namespace, topic) DO UPDATE SET
+			   publish = excluded.publish,
+			   subscribe = excluded.subscribe,
+			   updated_at = excluded.updated_at,
+			   updated_by = excluded.updated_by`,
+			ns, req.Topic, encodePrincipals(req.Publish), encodePrincipals(req.Subscribe), now, publisherOf(ctx)); err != nil {
+			p.logger.ComponentError("gateway", "failed to save topic ACL", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to save topic ACL")
+			return
+		}
+		p.forgetACL(ns)
+		row := aclRuleRow{Topic: req.Topic, Publish: encodePrincipals(req.Publish), Subscribe: encodePrincipals(req.Subscribe), UpdatedAt: now, UpdatedBy: publisherOf(ctx)}
+		writeJSON(w, http.StatusOK, row.toACL())
+
+	case http.MethodDelete:
+		topic := r.URL.Query().Get("topic")
+		if topic == "" {
+			writeError(w, http.StatusBadRequest, "missing 'topic'")
+			return
+		}
+		if _, ok := p.loadACLForManagement(w, r, ns); !ok {
+			return
+		}
+		res, err := p.config.DB.Exec(ctx, "DELETE FROM pubsub_topic_acls WHERE namespace = ? AND topic = ?", ns, topic)
+		if err != nil {
+			p.logger.ComponentError("gateway", "failed to delete topic ACL", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to delete topic ACL")
+			return
+		}
+		p.forgetACL(ns)
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeError(w, http.StatusNotFound, "topic has no ACL")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "topic": topic})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// RolesHandler handles /v1/pubsub/roles:
+//
+//	GET                  lists the namespace's roles
+//	PUT {role,members}   defines a role that ACL rules can refer to as role:<name>
+//	DELETE ?role=        removes a role
+//
+// Only namespace-owning wallets may change ACLs and roles until the admin
+// role exists, and only its members afterwards.
+func (p *PubSubHandlers) RolesHandler(w http.ResponseWriter, r *http.Request) {
+	if p.config.DB == nil {
+		writeError(w, http.StatusServiceUnavailable, "topic ACLs not available")
+		return
+	}
+	ns := resolveNamespaceFromRequest(r)
+	if ns == "" {
+		writeError(w, http.StatusForbidden, "namespace not resolved")
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var rows []roleRow
+		if err := p.config.DB.Query(ctx, &rows,
+			"SELECT role, members, updated_at, updated_by FROM pubsub_roles WHERE namespace = ? ORDER BY role",
+			ns); err != nil {
+			p.logger.ComponentError("gateway", "failed to list pubsub roles", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to list roles")
+			return
+		}
+		roles := make([]PubSubRole, 0, len(rows))
+		for i := range rows {
+			roles = append(roles, rows[i].toRole())
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"roles": roles, "count": len(roles)})
+
+	case http.MethodPut:
+		var req PubSubRoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+			writeError(w, http.StatusBadRequest, "invalid body: expected {role,members}")
+			return
+		}
+		if len(req.Role) > maxRoleName || strings.ContainsAny(req.Role, ": ") {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid role: at most %d characters, without ':' or spaces", maxRoleName))
+			return
+		}
+		if req.Members == nil {
+			req.Members = []string{}
+		}
+		if err := validatePrincipals("members", req.Members, true); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		acl, ok := p.loadACLForManagement(w, r, ns)
+		if !ok {
+			return
+		}
+		if req.Role == adminRole {
+			// Refuse to lock the caller out of managing the namespace
+			next := &namespaceACL{roles: map[string][]string{adminRole: req.Members}, usesScopes: acl.usesScopes}
+			for _, m := range req.Members {
+				if strings.HasPrefix(m, "scope:") {
+					next.usesScopes = true
+				}
+			}
+			if ok, _ := p.canManageACL(ctx, ns, next); !ok {
+				writeError(w, http.StatusBadRequest, "the admin role must include you")
+				return
+			}
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		if _, err := p.config.DB.Exec(ctx,
+			`INSERT INTO pubsub_roles (namespace, role, members, updated_at, updated_by)
+			 VALUES (?, ?, ?, ?, ?)
+			 ON CONFLICT(namespace, role) DO UPDATE SET
+			   members = excluded.members,
+			   updated_at = excluded.updated_at,
+			   updated_by = excluded.updated_by`,
+			ns, req.Role, encodePrincipals(req.Members), now, publisherOf(ctx)); err != nil {
+			p.logger.ComponentError("gateway", "failed to save pubsub role", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to save role")
+			return
+		}
+		p.forgetACL(ns)
+		row := roleRow{Role: req.Role, Members: encodePrincipals(req.Members), UpdatedAt: now, UpdatedBy: publisherOf(ctx)}
+		writeJSON(w, http.StatusOK, row.toRole())
+
+	case http.MethodDelete:
+		role := r.URL.Query().Get("role")
+		if role == "" {
+			writeError(w, http.StatusBadRequest, "missing 'role'")
+			return
+		}
+		if _, ok := p.loadACLForManagement(w, r, ns); !ok {
+			return
+		}
+		res, err := p.config.DB.Exec(ctx, "DELETE FROM pubsub_roles WHERE namespace = ? AND role = ?", ns, role)
+		if err != nil {
+			p.logger.ComponentError("gateway", "failed to delete pubsub role", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to delete role")
+			return
+		}
+		p.forgetACL(ns)
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeError(w, http.StatusNotFound, "role not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "role": role})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
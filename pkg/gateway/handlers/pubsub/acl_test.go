@@ -0,0 +1,105 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+)
+
+// asWallet authenticates r as wallet.
+func asWallet(r *http.Request, wallet string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), ctxkeys.JWT, &auth.JWTClaims{Sub: wallet}))
+}
+
+// ownNamespace records wallet as an owner of the test namespace.
+func ownNamespace(t *testing.T, db *sql.DB, wallet string) {
+	t.Helper()
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS namespaces (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE)",
+		"CREATE TABLE IF NOT EXISTS namespace_ownership (namespace_id INTEGER, owner_type TEXT, owner_id TEXT)",
+		"INSERT OR IGNORE INTO namespaces (name) VALUES ('ns')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to set up namespace ownership: %v", err)
+		}
+	}
+	if _, err := db.Exec("INSERT INTO namespace_ownership (namespace_id, owner_type, owner_id) SELECT id, 'wallet', ? FROM namespaces WHERE name = 'ns'", wallet); err != nil {
+		t.Fatalf("Failed to record owner: %v", err)
+	}
+}
+
+func putACL(t *testing.T, h http.HandlerFunc, wallet string, body any) int {
+	t.Helper()
+	b, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	h(w, asWallet(nsRequest(http.MethodPut, "/", b), wallet))
+	return w.Code
+}
+
+func TestTopicACL_Enforcement(t *testing.T) {
+	p, db := newHistoryTestHandlers(t)
+	if _, err := db.Exec("CREATE TABLE api_keys (key TEXT, scopes TEXT)"); err != nil {
+		t.Fatalf("Failed to create api_keys: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO api_keys (key, scopes) VALUES ('ak_bot', 'pubsub:publish, storage')"); err != nil {
+		t.Fatalf("Failed to insert API key: %v", err)
+	}
+
+	ownNamespace(t, db, "0xAlice")
+	if code := putACL(t, p.ACLHandler, "0xbob", TopicACLRequest{Topic: "chat", Publish: []string{}}); code != http.StatusForbidden {
+		t.Fatalf("Expected a member not owning the namespace to be refused before the admin role exists, got %d", code)
+	}
+	if code := putACL(t, p.RolesHandler, "0xalice", PubSubRoleRequest{Role: adminRole, Members: []string{"wallet:0xbob"}}); code != http.StatusBadRequest {
+		t.Fatalf("Expected an admin role without the caller to be refused, got %d", code)
+	}
+	if code := putACL(t, p.RolesHandler, "0xalice", PubSubRoleRequest{Role: adminRole, Members: []string{"wallet:0xALICE"}}); code != http.StatusOK {
+		t.Fatalf("Expected the admin role to be created, got %d", code)
+	}
+	if code := putACL(t, p.ACLHandler, "0xbob", TopicACLRequest{Topic: "announce.>", Publish: []string{}}); code != http.StatusForbidden {
+		t.Fatalf("Expected a non-admin to be refused, got %d", code)
+	}
+	for _, rule := range []TopicACLRequest{
+		{Topic: "announce.>", Publish: []string{"role:admin", "scope:pubsub:publish"}},
+		{Topic: "announce.secret", Subscribe: []string{}},
+	} {
+		if code := putACL(t, p.ACLHandler, "0xalice", rule); code != http.StatusOK {
+			t.Fatalf("Expected rule %s to be saved, got %d", rule.Topic, code)
+		}
+	}
+
+	wallet := func(w string) context.Context {
+		return context.WithValue(context.Background(), ctxkeys.JWT, &auth.JWTClaims{Sub: w})
+	}
+	bot := context.WithValue(context.Background(), ctxkeys.APIKey, "ak_bot")
+	cases := []struct {
+		ctx           context.Context
+		topic, action string
+		allowed       bool
+	}{
+		{wallet("0xalice"), "announce.news", aclPublish, true},
+		{wallet("0xbob"), "announce.news", aclPublish, false},
+		{bot, "announce.news", aclPublish, true},
+		{wallet("0xbob"), "announce.news", aclSubscribe, true},
+		{wallet("0xalice"), "announce.secret", aclSubscribe, false},
+		{wallet("0xbob"), "chat", aclPublish, true},
+	}
+	for _, c := range cases {
+		err := p.authorize(c.ctx, "ns", c.topic, c.action)
+		if c.allowed && err != nil || !c.allowed && !errors.Is(err, errTopicForbidden) {
+			t.Errorf("authorize(%s %s) = %v, want allowed=%v", c.action, c.topic, err, c.allowed)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	p.HistoryHandler(w, asWallet(nsRequest(http.MethodGet, "/v1/pubsub/history?topic=announce.secret", nil), "0xbob"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected reading a restricted topic's history to be refused, got %d", w.Code)
+	}
+}
@@ -0,0 +1,386 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/olric"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
+	olriclib "github.com/olric-data/olric"
+	"go.uber.org/zap"
+)
+
+const (
+	// envelopeFrameMagic prefixes the libp2p payloads of messages published
+	// through a gateway. It is followed by the big-endian length of a JSON
+	// envelope header, the header, its HMAC-SHA256 and then the message data.
+	envelopeFrameMagic = pubsub.ReservedPayloadPrefix + "msg\x00"
+	maxEnvelopeHeader  = 4096
+	envelopeKeySize    = 32
+
+	// envelopeKeyRetry is how long a gateway signs with its own key after
+	// failing to load the cluster key before trying again.
+	envelopeKeyRetry = 30 * time.Second
+
+	// durableFrameMagic prefixed durable topic messages before envelopes
+	// existed: the big-endian sequence number followed by the data. Its
+	// unsigned sequence number is ignored.
+	durableFrameMagic = pubsub.ReservedPayloadPrefix + "seq\x00"
+
+	// recentMessageIDs is how many message IDs a subscriber remembers to drop
+	// a message delivered both locally and through the mesh, or published again
+	// with the same idempotency key.
+	recentMessageIDs = 512
+
+	// idempotencyDMap is the Olric DMap holding the idempotency keys of all
+	// gateways; keys are remembered for idempotencyTTL.
+	idempotencyDMap    = "pubsub_idempotency"
+	idempotencyTTL     = 24 * time.Hour
+	idempotencyTimeout = 2 * time.Second
+	maxIdempotencyKey  = 256
+
+	// idempotencySweep is how often expired keys are dropped from the local
+	// store used without Olric.
+	idempotencySweep = time.Minute
+)
+
+// topicMessage is a message delivered to subscribers. Seq is 0 for topics
+// without a log; ID is empty for payloads published around the gateways.
+type topicMessage struct {
+	ID   string
+	Seq  int64
+	Data []byte
+	Time time.Time
+	From string // publisher identity attested by the gateway
+}
+
+// envelopeHeader is the JSON header of an envelope frame.
+type envelopeHeader struct {
+	ID   string `json:"id"`
+	Seq  int64  `json:"seq,omitempty"`
+	Time int64  `json:"ts"` // Unix milliseconds
+	From string `json:"from,omitempty"`
+}
+
+// meshPayload returns the libp2p payload for m, published to topic in ns.
+func (p *PubSubHandlers) meshPayload(ns, topic string, m topicMessage) []byte {
+	if m.ID == "" && m.Seq == 0 {
+		return m.Data
+	}
+	header, _ := json.Marshal(envelopeHeader{ID: m.ID, Seq: m.Seq, Time: m.Time.UnixMilli(), From: m.From})
+	b := make([]byte, 0, len(envelopeFrameMagic)+4+len(header)+sha256.Size+len(m.Data))
+	b = append(b, envelopeFrameMagic...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(header)))
+	b = append(b, header...)
+	b = append(b, envelopeMAC(p.envelopeKey(), ns, topic, b, m.Data)...)
+	return append(b, m.Data...)
+}
+
+// decodeMeshMessage unwraps a libp2p payload received on topic in ns.
+// Payloads that are not framed are delivered as they are. The ID, sequence
+// number and publisher of an envelope are only trusted when it was signed by
+// a gateway holding the envelope key; any libp2p peer can publish a frame.
+func (p *PubSubHandlers) decodeMeshMessage(ns, topic string, b []byte) topicMessage {
+	if n := len(envelopeFrameMagic); len(b) >= n+4 && string(b[:n]) == envelopeFrameMagic {
+		size := int(binary.BigEndian.Uint32(b[n : n+4]))
+		end := n + 4 + size
+		if size <= maxEnvelopeHeader && len(b) >= end+sha256.Size {
+			mac, data := b[end:end+sha256.Size], b[end+sha256.Size:]
+			var h envelopeHeader
+			if hmac.Equal(mac, envelopeMAC(p.envelopeKey(), ns, topic, b[:end], data)) && json.Unmarshal(b[n+4:end], &h) == nil {
+				return topicMessage{ID: h.ID, Seq: h.Seq, Data: data, Time: time.UnixMilli(h.Time), From: h.From}
+			}
+			p.logger.ComponentWarn("gateway", "pubsub: dropping metadata of unverified envelope",
+				zap.String("namespace", ns),
+				zap.String("topic", topic))
+			return topicMessage{Data: data, Time: time.Now()}
+		}
+	}
+	if n := len(durableFrameMagic); len(b) >= n+8 && string(b[:n]) == durableFrameMagic {
+		return topicMessage{Data: b[n+8:], Time: time.Now()}
+	}
+	return topicMessage{Data: b, Time: time.Now()}
+}
+
+// envelopeMAC signs an envelope header for one topic, so a signed envelope
+// cannot be replayed on another topic.
+func envelopeMAC(key []byte, ns, topic string, header, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ns + "\x00" + topic + "\x00"))
+	mac.Write(header)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+type envelopeKeyRow struct {
+	Key string `db:"key_hex"`
+}
+
+// envelopeKey returns the configured envelope key, or loads (creating if
+// needed) the cluster key stored in RQLite. Without a database, or while it
+// is unavailable, this gateway's own key is used and envelopes from other
+// gateways are not trusted.
+func (p *PubSubHandlers) envelopeKey() []byte {
+	if len(p.config.EnvelopeKey) > 0 {
+		return p.config.EnvelopeKey
+	}
+	p.envelopeMu.Lock()
+	defer p.envelopeMu.Unlock()
+	if p.envelopeKeyCache != nil {
+		return p.envelopeKeyCache
+	}
+	if p.config.DB == nil || time.Since(p.envelopeKeyTried) < envelopeKeyRetry {
+		return p.localEnvelopeKey
+	}
+	p.envelopeKeyTried = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	key, err := p.loadEnvelopeKey(ctx)
+	if err != nil {
+		p.logger.ComponentWarn("gateway", "pubsub: cluster envelope key unavailable; other gateways' envelopes are not trusted",
+			zap.Error(err))
+		return p.localEnvelopeKey
+	}
+	p.envelopeKeyCache = key
+	return key
+}
+
+func (p *PubSubHandlers) loadEnvelopeKey(ctx context.Context) ([]byte, error) {
+	if _, err := p.config.DB.Exec(ctx,
+		"INSERT OR IGNORE INTO pubsub_envelope_key (id, key_hex) VALUES (1, ?)", hex.EncodeToString(newEnvelopeKey())); err != nil {
+		return nil, fmt.Errorf("failed to store envelope key: %w", err)
+	}
+	var rows []envelopeKeyRow
+	if err := p.config.DB.Query(ctx, &rows, "SELECT key_hex FROM pubsub_envelope_key WHERE id = 1"); err != nil {
+		return nil, fmt.Errorf("failed to query envelope key: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("envelope key not found after insert")
+	}
+	key, err := hex.DecodeString(rows[0].Key)
+	if err != nil || len(key) != envelopeKeySize {
+		return nil, fmt.Errorf("stored envelope key is invalid")
+	}
+	return key, nil
+}
+
+func newEnvelopeKey() []byte {
+	key := make([]byte, envelopeKeySize)
+	_, _ = rand.Read(key)
+	return key
+}
+
+// newMessageID returns a random message ID.
+func newMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// idempotentMessageID derives the message ID of a publish with an idempotency
+// key, so every retry carries the same ID whichever gateway it reaches.
+func idempotentMessageID(ns, topic, key string) string {
+	sum := sha256.Sum256([]byte(ns + presenceKeySep + topic + presenceKeySep + key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// publisherOf returns who is publishing: the wallet from a JWT, or a
+// fingerprint of the API key. The raw API key is never sent to subscribers.
+func publisherOf(ctx context.Context) string {
+	if v := ctx.Value(ctxkeys.JWT); v != nil {
+		if claims, ok := v.(*auth.JWTClaims); ok && claims != nil && claims.Sub != "" {
+			return claims.Sub
+		}
+	}
+	if v, ok := ctx.Value(ctxkeys.APIKey).(string); ok && v != "" {
+		sum := sha256.Sum256([]byte(v))
+		return "apikey:" + hex.EncodeToString(sum[:6])
+	}
+	return ""
+}
+
+// recentIDs remembers the last recentMessageIDs message IDs.
+type recentIDs struct {
+	ring []string
+	next int
+	set  map[string]struct{}
+}
+
+// seen reports whether id was seen before and remembers it otherwise.
+func (r *recentIDs) seen(id string) bool {
+	if r.set == nil {
+		r.ring = make([]string, recentMessageIDs)
+		r.set = make(map[string]struct{}, recentMessageIDs)
+	}
+	if _, ok := r.set[id]; ok {
+		return true
+	}
+	delete(r.set, r.ring[r.next])
+	r.ring[r.next] = id
+	r.next = (r.next + 1) % len(r.ring)
+	r.set[id] = struct{}{}
+	return false
+}
+
+// publishRecord is what a publish retried with the same idempotency key gets
+// back instead of publishing again.
+type publishRecord struct {
+	ID   string `json:"id"`
+	Seq  int64  `json:"seq,omitempty"`
+	Time int64  `json:"ts"` // Unix milliseconds
+}
+
+// idempotencyStore remembers the publishes made with an idempotency key.
+type idempotencyStore interface {
+	// claim stores rec under key unless the key is taken, in which case the
+	// record stored first is returned
+	claim(ctx context.Context, key string, rec publishRecord) (*publishRecord, error)
+	// update replaces the record of a claimed key
+	update(ctx context.Context, key string, rec publishRecord) error
+	// release forgets key so the publish can be retried
+	release(ctx context.Context, key string) error
+}
+
+// olricIdempotencyStore shares idempotency keys between gateways.
+type olricIdempotencyStore struct {
+	client *olric.Client
+}
+
+func (s *olricIdempotencyStore) claim(ctx context.Context, key string, rec publishRecord) (*publishRecord, error) {
+	dm, err := s.client.GetClient().NewDMap(idempotencyDMap)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	err = dm.Put(ctx, key, b, olriclib.NX(), olriclib.EX(idempotencyTTL))
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, olriclib.ErrKeyFound) {
+		return nil, err
+	}
+	resp, err := dm.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = resp.Byte(); err != nil {
+		return nil, err
+	}
+	var prev publishRecord
+	if err := json.Unmarshal(b, &prev); err != nil {
+		return nil, err
+	}
+	return &prev, nil
+}
+
+func (s *olricIdempotencyStore) update(ctx context.Context, key string, rec publishRecord) error {
+	dm, err := s.client.GetClient().NewDMap(idempotencyDMap)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return dm.Put(ctx, key, b, olriclib.EX(idempotencyTTL))
+}
+
+func (s *olricIdempotencyStore) release(ctx context.Context, key string) error {
+	dm, err := s.client.GetClient().NewDMap(idempotencyDMap)
+	if err != nil {
+		return err
+	}
+	_, err = dm.Delete(ctx, key)
+	return err
+}
+
+// memIdempotencyStore keeps idempotency keys on this gateway only; it is used
+// while Olric is unavailable.
+type memIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]memIdempotencyEntry
+	swept   time.Time
+}
+
+type memIdempotencyEntry struct {
+	rec     publishRecord
+	expires time.Time
+}
+
+func newMemIdempotencyStore() *memIdempotencyStore {
+	return &memIdempotencyStore{entries: make(map[string]memIdempotencyEntry)}
+}
+
+func (s *memIdempotencyStore) claim(_ context.Context, key string, rec publishRecord) (*publishRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) >= idempotencySweep {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		prev := e.rec
+		return &prev, nil
+	}
+	s.entries[key] = memIdempotencyEntry{rec: rec, expires: now.Add(idempotencyTTL)}
+	return nil, nil
+}
+
+func (s *memIdempotencyStore) update(_ context.Context, key string, rec publishRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.rec = rec
+		s.entries[key] = e
+	}
+	return nil
+}
+
+func (s *memIdempotencyStore) release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// claimPublish claims an idempotency key, preferring the store shared through
+// Olric. It returns the store holding the claim and, if the key was already
+// used, the record of the first publish.
+func (p *PubSubHandlers) claimPublish(ctx context.Context, key string, rec publishRecord) (idempotencyStore, *publishRecord, error) {
+	if p.config.Olric != nil {
+		if c := p.config.Olric(); c != nil {
+			store := &olricIdempotencyStore{client: c}
+			cctx, cancel := context.WithTimeout(ctx, idempotencyTimeout)
+			prev, err := store.claim(cctx, key, rec)
+			cancel()
+			if err == nil {
+				return store, prev, nil
+			}
+			// Fall back to this gateway's keys rather than failing the publish
+			p.logger.ComponentWarn("gateway", "pubsub: idempotency store unavailable, using local keys",
+				zap.Error(err))
+		}
+	}
+	prev, err := p.idempotency.claim(ctx, key, rec)
+	return p.idempotency, prev, err
+}
@@ -0,0 +1,462 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DB is the subset of the RQLite client used for durable topic logs.
+type DB interface {
+	Query(ctx context.Context, dest any, query string, args ...any) error
+	Exec(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+const (
+	// DefaultRetention is how long durable topic messages are kept when
+	// neither the topic nor the gateway configures it.
+	DefaultRetention = 24 * time.Hour
+	maxRetention     = 30 * 24 * time.Hour
+
+	// DefaultMaxMessages is how many messages a durable topic keeps when
+	// neither the topic nor the gateway configures it.
+	DefaultMaxMessages = 10000
+	maxMaxMessages     = 1000000
+
+	// historyPageSize is the default (and replay) page size; maxHistoryPage caps
+	// GET /v1/pubsub/history.
+	historyPageSize = 100
+	maxHistoryPage  = 1000
+
+	// durableCacheTTL bounds how long a gateway may miss a topic being made
+	// durable (or not) on another gateway.
+	durableCacheTTL = 30 * time.Second
+
+	pruneInterval = time.Minute
+	pruneTimeout  = 30 * time.Second
+)
+
+// durableTopicRow is a row of pubsub_durable_topics.
+type durableTopicRow struct {
+	Namespace   string `db:"namespace"`
+	Topic       string `db:"topic"`
+	Retention   int64  `db:"retention"`
+	MaxMessages int    `db:"max_messages"`
+	CreatedAt   string `db:"created_at"`
+	UpdatedAt   string `db:"updated_at"`
+}
+
+func (r *durableTopicRow) toTopic() DurableTopic {
+	t := DurableTopic{Topic: r.Topic, Retention: r.Retention, MaxMessages: r.MaxMessages}
+	t.CreatedAt, _ = time.Parse(time.RFC3339, r.CreatedAt)
+	t.UpdatedAt, _ = time.Parse(time.RFC3339, r.UpdatedAt)
+	return t
+}
+
+type cachedDurable struct {
+	row     *durableTopicRow // nil if the topic is not durable
+	expires time.Time
+}
+
+// durableTopic returns the durable settings of namespace's topic, or nil if
+// the topic keeps no log.
+func (p *PubSubHandlers) durableTopic(ctx context.Context, namespace, topic string) (*durableTopicRow, error) {
+	if p.config.DB == nil {
+		return nil, nil
+	}
+	key := namespace + "." + topic
+	p.durableMu.Lock()
+	c, ok := p.durable[key]
+	p.durableMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.row, nil
+	}
+
+	var rows []durableTopicRow
+	if err := p.config.DB.Query(ctx, &rows,
+		"SELECT namespace, topic, retention, max_messages, created_at, updated_at FROM pubsub_durable_topics WHERE namespace = ? AND topic = ? LIMIT 1",
+		namespace, topic); err != nil {
+		return nil, err
+	}
+	var row *durableTopicRow
+	if len(rows) > 0 {
+		row = &rows[0]
+	}
+	p.durableMu.Lock()
+	p.durable[key] = cachedDurable{row: row, expires: time.Now().Add(durableCacheTTL)}
+	p.durableMu.Unlock()
+	return row, nil
+}
+
+// forgetDurable drops the cached settings of a topic after they change.
+func (p *PubSubHandlers) forgetDurable(namespace, topic string) {
+	p.durableMu.Lock()
+	delete(p.durable, namespace+"."+topic)
+	p.durableMu.Unlock()
+}
+
+// stamp prepares a message for publishing: it gets an ID (a random one when
+// id is empty) and the publisher identity of ctx. On durable topics the
+// message is appended to the topic log first and carries the assigned
+// sequence number.
+func (p *PubSubHandlers) stamp(ctx context.Context, namespace, topic, id string, data []byte) (topicMessage, error) {
+	if id == "" {
+		id = newMessageID()
+	}
+	m := topicMessage{ID: id, Data: data, Time: time.Now(), From: publisherOf(ctx)}
+	dt, err := p.durableTopic(ctx, namespace, topic)
+	if err != nil {
+		return m, fmt.Errorf("failed to look up topic: %w", err)
+	}
+	if dt == nil {
+		return m, nil
+	}
+	res, err := p.config.DB.Exec(ctx,
+		"INSERT INTO pubsub_messages (namespace, topic, data, created_at, message_id, publisher) VALUES (?, ?, ?, ?, ?, ?)",
+		namespace, topic, base64.StdEncoding.EncodeToString(data), m.Time.UTC().Format(time.RFC3339), m.ID, m.From)
+	if err != nil {
+		return m, fmt.Errorf("failed to store message: %w", err)
+	}
+	if m.Seq, err = res.LastInsertId(); err != nil {
+		return m, fmt.Errorf("failed to read message sequence: %w", err)
+	}
+	return m, nil
+}
+
+// storedMessageRow is a row of pubsub_messages.
+type storedMessageRow struct {
+	Seq       int64  `db:"seq"`
+	Data      string `db:"data"`
+	CreatedAt string `db:"created_at"`
+	MessageID string `db:"message_id"`
+	Publisher string `db:"publisher"`
+}
+
+// readHistory returns up to limit messages of a topic with a sequence number
+// above since, oldest first.
+func (p *PubSubHandlers) readHistory(ctx context.Context, namespace, topic string, since int64, limit int) ([]topicMessage, error) {
+	var rows []storedMessageRow
+	if err := p.config.DB.Query(ctx, &rows,
+		"SELECT seq, data, created_at, message_id, publisher FROM pubsub_messages WHERE namespace = ? AND topic = ? AND seq > ? ORDER BY seq ASC LIMIT ?",
+		namespace, topic, since, limit); err != nil {
+		return nil, err
+	}
+	msgs := make([]topicMessage, 0, len(rows))
+	for _, r := range rows {
+		data, err := base64.StdEncoding.DecodeString(r.Data)
+		if err != nil {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339, r.CreatedAt)
+		msgs = append(msgs, topicMessage{ID: r.MessageID, Seq: r.Seq, Data: data, Time: t, From: r.Publisher})
+	}
+	return msgs, nil
+}
+
+// historyMessage converts m to its JSON representation.
+func historyMessage(topic string, m topicMessage) HistoryMessage {
+	return HistoryMessage{
+		Seq:       m.Seq,
+		MessageID: m.ID,
+		Topic:     topic,
+		Data:      base64.StdEncoding.EncodeToString(m.Data),
+		Timestamp: m.Time.UnixMilli(),
+		From:      m.From,
+	}
+}
+
+// DurableTopicsHandler handles /v1/pubsub/durable:
+//
+//	GET                 lists the namespace's durable topics
+//	PUT {topic,...}     makes a topic durable or changes its retention
+//	DELETE ?topic=      stops logging a topic and deletes its history
+func (p *PubSubHandlers) DurableTopicsHandler(w http.ResponseWriter, r *http.Request) {
+	if p.config.DB == nil {
+		writeError(w, http.StatusServiceUnavailable, "durable topics not available")
+		return
+	}
+	ns := resolveNamespaceFromRequest(r)
+	if ns == "" {
+		writeError(w, http.StatusForbidden, "namespace not resolved")
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var rows []durableTopicRow
+		if err := p.config.DB.Query(ctx, &rows,
+			"SELECT namespace, topic, retention, max_messages, created_at, updated_at FROM pubsub_durable_topics WHERE namespace = ? ORDER BY topic",
+			ns); err != nil {
+			p.logger.ComponentError("gateway", "failed to list durable topics", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to list durable topics")
+			return
+		}
+		topics := make([]DurableTopic, 0, len(rows))
+		for i := range rows {
+			topics = append(topics, rows[i].toTopic())
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"topics": topics, "count": len(topics)})
+
+	case http.MethodPut:
+		var req DurableTopicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Topic == "" {
+			writeError(w, http.StatusBadRequest, "invalid body: expected {topic,retention,max_messages}")
+			return
+		}
+		if req.Retention < 0 || req.Retention > int64(maxRetention/time.Second) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("retention must be between 1 and %d seconds", int64(maxRetention/time.Second)))
+			return
+		}
+		if req.MaxMessages < 0 || req.MaxMessages > maxMaxMessages {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("max_messages must be between 1 and %d", maxMaxMessages))
+			return
+		}
+		retention, maxMessages := req.Retention, req.MaxMessages
+		if retention == 0 {
+			retention = int64(p.retention() / time.Second)
+		}
+		if maxMessages == 0 {
+			maxMessages = p.maxMessages()
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		if _, err := p.config.DB.Exec(ctx,
+			`INSERT INTO pubsub_durable_topics (namespace, topic, retention, max_messages, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(namespace, topic) DO UPDATE SET
+			   retention = excluded.retention,
+			   max_messages = excluded.max_messages,
+			   updated_at = excluded.updated_at`,
+			ns, req.Topic, retention, maxMessages, now, now); err != nil {
+			p.logger.ComponentError("gateway", "failed to save durable topic", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to save durable topic")
+			return
+		}
+		p.forgetDurable(ns, req.Topic)
+		dt, err := p.durableTopic(ctx, ns, req.Topic)
+		if err != nil || dt == nil {
+			writeError(w, http.StatusInternalServerError, "failed to load durable topic")
+			return
+		}
+		writeJSON(w, http.StatusOK, dt.toTopic())
+
+	case http.MethodDelete:
+		topic := r.URL.Query().Get("topic")
+		if topic == "" {
+			writeError(w, http.StatusBadRequest, "missing 'topic'")
+			return
+		}
+		res, err := p.config.DB.Exec(ctx, "DELETE FROM pubsub_durable_topics WHERE namespace = ? AND topic = ?", ns, topic)
+		if err != nil {
+			p.logger.ComponentError("gateway", "failed to delete durable topic", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to delete durable topic")
+			return
+		}
+		p.forgetDurable(ns, topic)
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeError(w, http.StatusNotFound, "topic is not durable")
+			return
+		}
+		if _, err := p.config.DB.Exec(ctx, "DELETE FROM pubsub_messages WHERE namespace = ? AND topic = ?", ns, topic); err != nil {
+			p.logger.ComponentWarn("gateway", "failed to delete topic history", zap.Error(err), zap.String("topic", topic))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "topic": topic})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// HistoryHandler handles GET /v1/pubsub/history?topic=&since=&limit=.
+// It returns a durable topic's messages with a sequence number above since,
+// oldest first. Pass next_since back as since to fetch the following page.
+func (p *PubSubHandlers) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if p.config.DB == nil {
+		writeError(w, http.StatusServiceUnavailable, "durable topics not available")
+		return
+	}
+	ns := resolveNamespaceFromRequest(r)
+	if ns == "" {
+		writeError(w, http.StatusForbidden, "namespace not resolved")
+		return
+	}
+
+	q := r.URL.Query()
+	topic := q.Get("topic")
+	if topic == "" {
+		writeError(w, http.StatusBadRequest, "missing 'topic'")
+		return
+	}
+	if !p.allowHTTP(w, r, ns, topic, aclSubscribe) {
+		return
+	}
+	since, err := parseSince(q.Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := historyPageSize
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxHistoryPage {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxHistoryPage))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	dt, err := p.durableTopic(ctx, ns, topic)
+	if err != nil {
+		p.logger.ComponentError("gateway", "failed to look up durable topic", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to look up topic")
+		return
+	}
+	if dt == nil {
+		writeError(w, http.StatusNotFound, "topic is not durable")
+		return
+	}
+
+	// Fetch one extra message to tell whether another page follows
+	msgs, err := p.readHistory(ctx, ns, topic, max(since, 0), limit+1)
+	if err != nil {
+		p.logger.ComponentError("gateway", "failed to read topic history", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to read topic history")
+		return
+	}
+	resp := HistoryResponse{Topic: topic, Messages: []HistoryMessage{}, NextSince: max(since, 0)}
+	if len(msgs) > limit {
+		msgs, resp.HasMore = msgs[:limit], true
+	}
+	for _, m := range msgs {
+		resp.Messages = append(resp.Messages, historyMessage(topic, m))
+		resp.NextSince = m.Seq
+	}
+	resp.Count = len(resp.Messages)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseSince parses a since parameter; empty means no replay (-1).
+func parseSince(v string) (int64, error) {
+	if v == "" {
+		return -1, nil
+	}
+	since, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || since < 0 {
+		return 0, fmt.Errorf("since must be a non-negative sequence number")
+	}
+	return since, nil
+}
+
+// retention returns the default retention of durable topics.
+func (p *PubSubHandlers) retention() time.Duration {
+	if p.config.Retention > 0 {
+		return min(p.config.Retention, maxRetention)
+	}
+	return DefaultRetention
+}
+
+// maxMessages returns the default message cap of durable topics.
+func (p *PubSubHandlers) maxMessages() int {
+	if p.config.MaxMessages > 0 {
+		return min(p.config.MaxMessages, maxMaxMessages)
+	}
+	return DefaultMaxMessages
+}
+
+// historyPruner deletes durable topic messages past their topic's retention
+// or message cap. Deletes are idempotent, so every gateway may run it.
+type historyPruner struct {
+	p *PubSubHandlers
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newHistoryPruner(p *PubSubHandlers) *historyPruner {
+	ctx, cancel := context.WithCancel(context.Background())
+	hp := &historyPruner{p: p, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	go hp.run()
+	return hp
+}
+
+// Close stops the pruner.
+func (hp *historyPruner) Close() {
+	if hp == nil {
+		return
+	}
+	hp.once.Do(func() {
+		hp.cancel()
+		<-hp.done
+	})
+}
+
+func (hp *historyPruner) run() {
+	defer close(hp.done)
+	t := time.NewTicker(pruneInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-hp.ctx.Done():
+			return
+		case <-t.C:
+			hp.prune(time.Now())
+		}
+	}
+}
+
+// prune applies every durable topic's retention and message cap, and drops
+// the history of topics that are no longer durable.
+func (hp *historyPruner) prune(now time.Time) {
+	ctx, cancel := context.WithTimeout(hp.ctx, pruneTimeout)
+	defer cancel()
+	p := hp.p
+
+	var topics []durableTopicRow
+	if err := p.config.DB.Query(ctx, &topics,
+		"SELECT namespace, topic, retention, max_messages, created_at, updated_at FROM pubsub_durable_topics"); err != nil {
+		p.logger.ComponentWarn("gateway", "pubsub history: failed to list durable topics", zap.Error(err))
+		return
+	}
+	for _, t := range topics {
+		if ctx.Err() != nil {
+			return
+		}
+		cutoff := now.Add(-time.Duration(t.Retention) * time.Second).UTC().Format(time.RFC3339)
+		if _, err := p.config.DB.Exec(ctx,
+			"DELETE FROM pubsub_messages WHERE namespace = ? AND topic = ? AND created_at < ?",
+			t.Namespace, t.Topic, cutoff); err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub history: failed to apply retention",
+				zap.Error(err), zap.String("namespace", t.Namespace), zap.String("topic", t.Topic))
+			continue
+		}
+		// Keep the newest max_messages; the subquery is NULL for shorter logs
+		if _, err := p.config.DB.Exec(ctx,
+			`DELETE FROM pubsub_messages WHERE namespace = ? AND topic = ? AND seq < (
+			   SELECT seq FROM pubsub_messages WHERE namespace = ? AND topic = ? ORDER BY seq DESC LIMIT 1 OFFSET ?)`,
+			t.Namespace, t.Topic, t.Namespace, t.Topic, t.MaxMessages-1); err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub history: failed to apply message cap",
+				zap.Error(err), zap.String("namespace", t.Namespace), zap.String("topic", t.Topic))
+		}
+	}
+
+	// Messages published while another gateway still had the topic cached as durable
+	if _, err := p.config.DB.Exec(ctx,
+		`DELETE FROM pubsub_messages WHERE NOT EXISTS (
+		   SELECT 1 FROM pubsub_durable_topics d WHERE d.namespace = pubsub_messages.namespace AND d.topic = pubsub_messages.topic)`); err != nil {
+		p.logger.ComponentWarn("gateway", "pubsub history: failed to drop orphaned messages", zap.Error(err))
+	}
+}
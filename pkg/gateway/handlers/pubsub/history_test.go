@@ -0,0 +1,264 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/rqlite"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newHistoryTestHandlers returns handlers backed by an in-memory SQLite
+// database with the pubsub history migrations applied.
+func newHistoryTestHandlers(t *testing.T) (*PubSubHandlers, *sql.DB) {
+	t.Helper()
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("Failed to create schema_migrations: %v", err)
+	}
+	for _, name := range []string{"015_pubsub_history.sql", "016_pubsub_envelope.sql", "017_pubsub_acl.sql", "018_pubsub_topic_policies.sql", "020_pubsub_envelope_key.sql"} {
+		migration, err := os.ReadFile(filepath.Join("..", "..", "..", "..", "migrations", name))
+		if err != nil {
+			t.Fatalf("Failed to read migration: %v", err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("Failed to apply migration %s: %v", name, err)
+		}
+	}
+
+	p := NewPubSubHandlers(nil, logger, Config{DB: rqlite.NewClient(db)})
+	t.Cleanup(p.Close)
+	return p, db
+}
+
+func nsRequest(method, target string, body []byte) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	return r.WithContext(context.WithValue(r.Context(), ctxkeys.NamespaceOverride, "ns"))
+}
+
+func makeDurable(t *testing.T, p *PubSubHandlers, req DurableTopicRequest) DurableTopic {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	p.DurableTopicsHandler(w, nsRequest(http.MethodPut, "/v1/pubsub/durable", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var dt DurableTopic
+	if err := json.Unmarshal(w.Body.Bytes(), &dt); err != nil {
+		t.Fatalf("Failed to decode durable topic: %v", err)
+	}
+	return dt
+}
+
+func history(t *testing.T, p *PubSubHandlers, query string) HistoryResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	p.HistoryHandler(w, nsRequest(http.MethodGet, "/v1/pubsub/history?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp HistoryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	return resp
+}
+
+func TestDurableTopic_HistoryPaging(t *testing.T) {
+	p, _ := newHistoryTestHandlers(t)
+	ctx := context.Background()
+
+	if m, err := p.stamp(ctx, "ns", "chat", "", []byte("before")); err != nil || m.Seq != 0 {
+		t.Fatalf("Expected no sequence on a plain topic, got %d, %v", m.Seq, err)
+	}
+	if dt := makeDurable(t, p, DurableTopicRequest{Topic: "chat"}); dt.Retention != int64(DefaultRetention/time.Second) || dt.MaxMessages != DefaultMaxMessages {
+		t.Fatalf("Expected gateway defaults, got %+v", dt)
+	}
+
+	var last int64
+	for _, msg := range []string{"one", "two", "three"} {
+		m, err := p.stamp(ctx, "ns", "chat", "", []byte(msg))
+		if err != nil {
+			t.Fatalf("Failed to stamp message: %v", err)
+		}
+		if m.Seq <= last {
+			t.Fatalf("Expected increasing sequence numbers, got %d after %d", m.Seq, last)
+		}
+		last = m.Seq
+	}
+
+	page := history(t, p, "topic=chat&limit=2")
+	if page.Count != 2 || !page.HasMore || page.Messages[0].Data != "b25l" || page.Messages[0].MessageID == "" {
+		t.Fatalf("Unexpected first page: %+v", page)
+	}
+	page = history(t, p, "topic=chat&limit=2&since="+strconv.FormatInt(page.NextSince, 10))
+	if page.Count != 1 || page.HasMore || page.NextSince != last {
+		t.Fatalf("Unexpected second page: %+v", page)
+	}
+
+	w := httptest.NewRecorder()
+	p.HistoryHandler(w, nsRequest(http.MethodGet, "/v1/pubsub/history?topic=other", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a plain topic, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDurableTopic_Prune(t *testing.T) {
+	p, db := newHistoryTestHandlers(t)
+	ctx := context.Background()
+	makeDurable(t, p, DurableTopicRequest{Topic: "capped", MaxMessages: 2})
+	makeDurable(t, p, DurableTopicRequest{Topic: "expiring", Retention: 60})
+
+	for range 5 {
+		if _, err := p.stamp(ctx, "ns", "capped", "", []byte("x")); err != nil {
+			t.Fatalf("Failed to stamp message: %v", err)
+		}
+	}
+	if _, err := p.stamp(ctx, "ns", "expiring", "", []byte("x")); err != nil {
+		t.Fatalf("Failed to stamp message: %v", err)
+	}
+	// A message left behind by a topic that is no longer durable
+	if _, err := db.Exec("INSERT INTO pubsub_messages (namespace, topic, data, created_at) VALUES ('ns', 'gone', 'eA==', ?)",
+		time.Now().UTC().Format(time.RFC3339)); err != nil {
+		t.Fatalf("Failed to insert orphan: %v", err)
+	}
+
+	hp := &historyPruner{p: p, ctx: context.Background()}
+	hp.prune(time.Now().Add(2 * time.Minute))
+
+	count := func(topic string) int {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM pubsub_messages WHERE topic = ?", topic).Scan(&n); err != nil {
+			t.Fatalf("Failed to count messages: %v", err)
+		}
+		return n
+	}
+	if n := count("capped"); n != 2 {
+		t.Errorf("Expected the newest 2 capped messages to be kept, got %d", n)
+	}
+	if n := count("expiring"); n != 0 {
+		t.Errorf("Expected expired messages to be deleted, got %d", n)
+	}
+	if n := count("gone"); n != 0 {
+		t.Errorf("Expected orphaned messages to be deleted, got %d", n)
+	}
+}
+
+func TestMeshFrame(t *testing.T) {
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	key := bytes.Repeat([]byte{1}, envelopeKeySize)
+	p := NewPubSubHandlers(nil, logger, Config{EnvelopeKey: key})
+
+	sent := topicMessage{ID: "abc", Seq: 1 << 40, Data: []byte("hello"), Time: time.UnixMilli(1700000000000), From: "0xwallet"}
+	frame := p.meshPayload("ns", "chat", sent)
+	m := p.decodeMeshMessage("ns", "chat", frame)
+	if m.ID != sent.ID || m.Seq != sent.Seq || string(m.Data) != "hello" || !m.Time.Equal(sent.Time) || m.From != sent.From {
+		t.Fatalf("Expected framed message to round-trip, got %+v", m)
+	}
+
+	// Frames signed with another key, or replayed on another topic, keep
+	// only their data
+	forger := NewPubSubHandlers(nil, logger, Config{EnvelopeKey: bytes.Repeat([]byte{2}, envelopeKeySize)})
+	for name, m := range map[string]topicMessage{
+		"forged":   p.decodeMeshMessage("ns", "chat", forger.meshPayload("ns", "chat", sent)),
+		"replayed": p.decodeMeshMessage("ns", "admin", frame),
+	} {
+		if m.ID != "" || m.Seq != 0 || m.From != "" || string(m.Data) != "hello" {
+			t.Fatalf("%s: expected unverified metadata to be dropped, got %+v", name, m)
+		}
+	}
+
+	legacy := append(append([]byte(durableFrameMagic), 0, 0, 0, 0, 0, 0, 0, 7), "old"...)
+	if m := p.decodeMeshMessage("ns", "chat", legacy); m.Seq != 0 || string(m.Data) != "old" {
+		t.Fatalf("Expected the unsigned sequence number to be ignored, got %d %q", m.Seq, m.Data)
+	}
+	if m := p.decodeMeshMessage("ns", "chat", []byte("plain")); m.Seq != 0 || string(m.Data) != "plain" {
+		t.Fatalf("Expected plain payload to pass through, got %d %q", m.Seq, m.Data)
+	}
+}
+
+func TestEnvelopeKey_SharedThroughDB(t *testing.T) {
+	p, db := newHistoryTestHandlers(t)
+	other := NewPubSubHandlers(nil, p.logger, Config{DB: rqlite.NewClient(db)})
+	t.Cleanup(other.Close)
+
+	frame := p.meshPayload("ns", "chat", topicMessage{ID: "abc", Data: []byte("hello"), Time: time.Now()})
+	if m := other.decodeMeshMessage("ns", "chat", frame); m.ID != "abc" {
+		t.Fatalf("Expected gateways sharing a database to verify each other's envelopes, got %+v", m)
+	}
+}
+
+func TestTopicStream_OutOfOrderSequences(t *testing.T) {
+	p, _ := newHistoryTestHandlers(t)
+	makeDurable(t, p, DurableTopicRequest{Topic: "chat"})
+	ctx := context.Background()
+	var stored []topicMessage
+	for _, data := range []string{"a", "b", "c", "d"} {
+		m, err := p.stamp(ctx, "ns", "chat", "", []byte(data))
+		if err != nil {
+			t.Fatalf("Failed to store message: %v", err)
+		}
+		stored = append(stored, m)
+	}
+
+	sub := &localSubscriber{msgChan: make(chan topicMessage, 1), lagged: make(chan struct{}, 1)}
+	s := &topicStream{p: p, ns: "ns", topic: "chat", sub: sub, readSeq: stored[0].Seq}
+	var sent []string
+	emit := func(m topicMessage) error {
+		sent = append(sent, string(m.Data))
+		return nil
+	}
+	// c overtakes b, which still arrives; d never arrives live
+	for _, m := range []topicMessage{stored[2], stored[1], stored[2]} {
+		if err := s.deliver(ctx, m, emit); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+	if strings.Join(sent, "") != "cb" {
+		t.Fatalf("Expected the late message to be sent once, got %v", sent)
+	}
+	sub.lagged <- struct{}{}
+	if err := s.deliver(ctx, stored[1], emit); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if strings.Join(sent, "") != "cbd" || s.readSeq != stored[3].Seq || len(s.sent) != 0 {
+		t.Fatalf("Expected the catch-up to add only the missing message, got %v (read up to %d)", sent, s.readSeq)
+	}
+}
+
+func TestLocalSubscriber_SignalsLag(t *testing.T) {
+	sub := &localSubscriber{msgChan: make(chan topicMessage, 1), lagged: make(chan struct{}, 1)}
+	sub.deliver(topicMessage{Data: []byte("a")})
+	if sub.deliver(topicMessage{Data: []byte("b")}) || len(sub.lagged) != 0 {
+		t.Fatal("Expected plain messages to be dropped silently")
+	}
+	if sub.deliver(topicMessage{Seq: 7}) || len(sub.lagged) != 1 {
+		t.Fatal("Expected a dropped durable message to signal lag")
+	}
+}
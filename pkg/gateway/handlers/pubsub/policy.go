@@ -0,0 +1,416 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxPayload is the largest message data accepted when neither the
+	// topic nor the gateway sets a smaller limit. GossipSub drops messages
+	// over 1 MiB, so room is left for the envelope.
+	DefaultMaxPayload = 1<<20 - 16<<10
+
+	// maxFrameSize bounds a WebSocket frame or publish body. Anything between
+	// the payload limit and this gets a structured error; larger input ends
+	// the request.
+	maxFrameSize = 2 << 20
+
+	maxPublishRate = 10000
+
+	// policyCacheTTL bounds how long a gateway may enforce a policy changed
+	// on another gateway.
+	policyCacheTTL = 30 * time.Second
+)
+
+// Codes of publishes refused by a topic policy
+const (
+	rejectPayloadTooLarge = "payload_too_large"
+	rejectInvalidJSON     = "invalid_json"
+	rejectSchema          = "schema_violation"
+	rejectRateLimited     = "rate_limited"
+)
+
+var errPolicyUnavailable = errors.New("failed to check topic policy")
+
+// publishRejection is a publish refused by a topic policy. It goes back to
+// the publisher as a PublishError, or an error frame on WebSockets.
+type publishRejection struct {
+	Code       string
+	Message    string
+	Details    []string
+	RetryAfter time.Duration
+}
+
+func (e *publishRejection) Error() string { return e.Message }
+
+func (e *publishRejection) status() int {
+	switch e.Code {
+	case rejectPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case rejectRateLimited:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// retryAfter returns RetryAfter in whole seconds, rounded up.
+func (e *publishRejection) retryAfter() int {
+	if e.RetryAfter <= 0 {
+		return 0
+	}
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// topicPolicyRow is a row of pubsub_topic_policies.
+type topicPolicyRow struct {
+	Topic       string `db:"topic"`
+	Schema      string `db:"schema"`
+	MaxPayload  int    `db:"max_payload"`
+	PublishRate int    `db:"publish_rate"`
+	UpdatedAt   string `db:"updated_at"`
+	UpdatedBy   string `db:"updated_by"`
+}
+
+func (r *topicPolicyRow) toPolicy() TopicPolicy {
+	t := TopicPolicy{Topic: r.Topic, MaxPayload: r.MaxPayload, PublishRate: r.PublishRate, UpdatedBy: r.UpdatedBy}
+	if r.Schema != "" {
+		t.Schema = json.RawMessage(r.Schema)
+	}
+	t.UpdatedAt, _ = time.Parse(time.RFC3339, r.UpdatedAt)
+	return t
+}
+
+// compiledPolicy is a topic policy with its schema ready for validation.
+type compiledPolicy struct {
+	TopicPolicy
+	schema *jsonSchema
+}
+
+// namespacePolicies holds the topic policies of a namespace.
+type namespacePolicies struct {
+	policies []compiledPolicy
+}
+
+type cachedPolicies struct {
+	policies *namespacePolicies
+	expires  time.Time
+}
+
+// policyFor returns the policy governing topic, chosen like ACL rules: a
+// policy for the topic itself, or else the most specific matching pattern.
+func (n *namespacePolicies) policyFor(topic string) *compiledPolicy {
+	var best *compiledPolicy
+	bestScore := -1
+	for i := range n.policies {
+		pol := &n.policies[i]
+		if pol.Topic == topic {
+			return pol
+		}
+		if !isPattern(pol.Topic) || !matchTopic(pol.Topic, topic) {
+			continue
+		}
+		if score := patternSpecificity(pol.Topic); score > bestScore {
+			best, bestScore = pol, score
+		}
+	}
+	return best
+}
+
+// namespacePolicies returns the cached topic policies of ns. When they cannot
+// be reloaded the previous ones stay in force.
+func (p *PubSubHandlers) namespacePolicies(ctx context.Context, ns string) (*namespacePolicies, error) {
+	if p.config.DB == nil {
+		return &namespacePolicies{}, nil
+	}
+	p.policyMu.Lock()
+	c, ok := p.policies[ns]
+	p.policyMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.policies, nil
+	}
+
+	var rows []topicPolicyRow
+	if err := p.config.DB.Query(ctx, &rows,
+		"SELECT topic, schema, max_payload, publish_rate, updated_at, updated_by FROM pubsub_topic_policies WHERE namespace = ? ORDER BY topic",
+		ns); err != nil {
+		if ok {
+			p.logger.ComponentWarn("gateway", "pubsub: failed to reload topic policies, keeping previous ones",
+				zap.String("namespace", ns),
+				zap.Error(err))
+			return c.policies, nil
+		}
+		return nil, err
+	}
+	policies := &namespacePolicies{}
+	for i := range rows {
+		pol := compiledPolicy{TopicPolicy: rows[i].toPolicy()}
+		if len(pol.Schema) > 0 {
+			schema, err := compileSchema(pol.Schema)
+			if err != nil {
+				// Schemas are checked when set; do not block the topic over one
+				p.logger.ComponentWarn("gateway", "pubsub: ignoring invalid topic schema",
+					zap.String("namespace", ns),
+					zap.String("topic", pol.Topic),
+					zap.Error(err))
+			}
+			pol.schema = schema
+		}
+		policies.policies = append(policies.policies, pol)
+	}
+	p.policyMu.Lock()
+	p.policies[ns] = cachedPolicies{policies: policies, expires: time.Now().Add(policyCacheTTL)}
+	p.policyMu.Unlock()
+	return policies, nil
+}
+
+// forgetPolicies drops the cached policies of ns after they change.
+func (p *PubSubHandlers) forgetPolicies(ns string) {
+	p.policyMu.Lock()
+	delete(p.policies, ns)
+	p.policyMu.Unlock()
+}
+
+// maxPayload returns the gateway's default payload limit.
+func (p *PubSubHandlers) maxPayload() int {
+	if p.config.MaxPayload > 0 {
+		return min(p.config.MaxPayload, DefaultMaxPayload)
+	}
+	return DefaultMaxPayload
+}
+
+// publishLimiter enforces publish rates for one connection. Only the
+// connection's reader uses it.
+type publishLimiter struct {
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from topic's bucket, which refills at rate per second
+// up to a burst of one second's worth. Otherwise it returns how long until a
+// token is available.
+func (l *publishLimiter) allow(topic string, rate int, now time.Time) (bool, time.Duration) {
+	if l.buckets == nil {
+		l.buckets = make(map[string]*rateBucket)
+	}
+	b, ok := l.buckets[topic]
+	if !ok {
+		if len(l.buckets) >= maxSessionTopics {
+			clear(l.buckets)
+		}
+		b = &rateBucket{tokens: float64(rate), last: now}
+		l.buckets[topic] = b
+	}
+	b.tokens = min(float64(rate), b.tokens+now.Sub(b.last).Seconds()*float64(rate))
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / float64(rate) * float64(time.Second))
+}
+
+// admitPublish checks a publish against the topic's policy: the connection's
+// publish rate (limiter is nil for HTTP publishes), the payload size and the
+// schema. A refusal is a *publishRejection; errPolicyUnavailable means the
+// policies could not be loaded.
+func (p *PubSubHandlers) admitPublish(ctx context.Context, ns, topic string, data []byte, limiter *publishLimiter) error {
+	policies, err := p.namespacePolicies(ctx, ns)
+	if err != nil {
+		p.logger.ComponentError("gateway", "pubsub: failed to load topic policies",
+			zap.String("namespace", ns),
+			zap.Error(err))
+		return errPolicyUnavailable
+	}
+	rate, limit := p.config.PublishRate, p.maxPayload()
+	var schema *jsonSchema
+	if pol := policies.policyFor(topic); pol != nil {
+		if pol.PublishRate > 0 {
+			rate = pol.PublishRate
+		}
+		if pol.MaxPayload > 0 {
+			// a topic may tighten the gateway's limit but never raise it
+			limit = min(pol.MaxPayload, limit)
+		}
+		schema = pol.schema
+	}
+
+	if limiter != nil && rate > 0 {
+		if ok, wait := limiter.allow(topic, rate, time.Now()); !ok {
+			return &publishRejection{
+				Code:       rejectRateLimited,
+				Message:    fmt.Sprintf("publish rate of %d messages per second exceeded", rate),
+				RetryAfter: wait,
+			}
+		}
+	}
+	if len(data) > limit {
+		return &publishRejection{
+			Code:    rejectPayloadTooLarge,
+			Message: fmt.Sprintf("message data is %d bytes; the topic allows %d", len(data), limit),
+		}
+	}
+	if schema != nil {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return &publishRejection{Code: rejectInvalidJSON, Message: "message data is not valid JSON; the topic has a schema"}
+		}
+		if violations := schema.validate(v); len(violations) > 0 {
+			return &publishRejection{Code: rejectSchema, Message: "message does not match the topic schema", Details: violations}
+		}
+	}
+	return nil
+}
+
+// writePublishError writes the response to a publish admitPublish refused.
+func writePublishError(w http.ResponseWriter, err error) {
+	var rej *publishRejection
+	if !errors.As(err, &rej) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if s := rej.retryAfter(); s > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(s))
+	}
+	writeJSON(w, rej.status(), PublishError{Error: rej.Message, Code: rej.Code, Details: rej.Details, RetryAfter: rej.retryAfter()})
+}
+
+// errorFrame converts an error into an error frame; publish refusals carry
+// their code and details.
+func errorFrame(id string, err error) wsFrame {
+	var rej *publishRejection
+	if !errors.As(err, &rej) {
+		return wsFrame{Type: frameError, ID: id, Error: err.Error()}
+	}
+	return wsFrame{Type: frameError, ID: id, Error: rej.Message, Code: rej.Code, Details: rej.Details, RetryAfter: rej.retryAfter()}
+}
+
+// PoliciesHandler handles /v1/pubsub/policies:
+//
+//	GET                                              lists the namespace's policies
+//	PUT {topic,schema,max_payload,publish_rate}      sets the policy of a topic or pattern
+//	DELETE ?topic=                                   removes a policy
+//
+// Like ACLs, policies may only be changed by wallets owning the namespace, or
+// by the admin role once it exists.
+func (p *PubSubHandlers) PoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	if p.config.DB == nil {
+		writeError(w, http.StatusServiceUnavailable, "topic policies not available")
+		return
+	}
+	ns := resolveNamespaceFromRequest(r)
+	if ns == "" {
+		writeError(w, http.StatusForbidden, "namespace not resolved")
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var rows []topicPolicyRow
+		if err := p.config.DB.Query(ctx, &rows,
+			"SELECT topic, schema, max_payload, publish_rate, updated_at, updated_by FROM pubsub_topic_policies WHERE namespace = ? ORDER BY topic",
+			ns); err != nil {
+			p.logger.ComponentError("gateway", "failed to list topic policies", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to list topic policies")
+			return
+		}
+		policies := make([]TopicPolicy, 0, len(rows))
+		for i := range rows {
+			policies = append(policies, rows[i].toPolicy())
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"policies": policies, "count": len(policies)})
+
+	case http.MethodPut:
+		var req TopicPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Topic == "" {
+			writeError(w, http.StatusBadRequest, "invalid body: expected {topic,schema,max_payload,publish_rate}")
+			return
+		}
+		if !validTopic(req.Topic) {
+			writeError(w, http.StatusBadRequest, "invalid topic")
+			return
+		}
+		if req.MaxPayload < 0 || req.MaxPayload > p.maxPayload() {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("max_payload must be between 0 and %d bytes", p.maxPayload()))
+			return
+		}
+		if req.PublishRate < 0 || req.PublishRate > maxPublishRate {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("publish_rate must be between 0 and %d", maxPublishRate))
+			return
+		}
+		schema := ""
+		if len(req.Schema) > 0 && !bytes.Equal(bytes.TrimSpace(req.Schema), []byte("null")) {
+			if _, err := compileSchema(req.Schema); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid schema: "+err.Error())
+				return
+			}
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, req.Schema); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid schema: not valid JSON")
+				return
+			}
+			schema = buf.String()
+		}
+		if _, ok := p.loadACLForManagement(w, r, ns); !ok {
+			return
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		if _, err := p.config.DB.Exec(ctx,
+			`INSERT INTO pubsub_topic_policies (namespace, topic, schema, max_payload, publish_rate, updated_at, updated_by)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(namespace, topic) DO UPDATE SET
+			   schema = excluded.schema,
+			   max_payload = excluded.max_payload,
+			   publish_rate = excluded.publish_rate,
+			   updated_at = excluded.updated_at,
+			   updated_by = excluded.updated_by`,
+			ns, req.Topic, schema, req.MaxPayload, req.PublishRate, now, publisherOf(ctx)); err != nil {
+			p.logger.ComponentError("gateway", "failed to save topic policy", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to save topic policy")
+			return
+		}
+		p.forgetPolicies(ns)
+		row := topicPolicyRow{Topic: req.Topic, Schema: schema, MaxPayload: req.MaxPayload, PublishRate: req.PublishRate, UpdatedAt: now, UpdatedBy: publisherOf(ctx)}
+		writeJSON(w, http.StatusOK, row.toPolicy())
+
+	case http.MethodDelete:
+		topic := r.URL.Query().Get("topic")
+		if topic == "" {
+			writeError(w, http.StatusBadRequest, "missing 'topic'")
+			return
+		}
+		if _, ok := p.loadACLForManagement(w, r, ns); !ok {
+			return
+		}
+		res, err := p.config.DB.Exec(ctx, "DELETE FROM pubsub_topic_policies WHERE namespace = ? AND topic = ?", ns, topic)
+		if err != nil {
+			p.logger.ComponentError("gateway", "failed to delete topic policy", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to delete topic policy")
+			return
+		}
+		p.forgetPolicies(ns)
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeError(w, http.StatusNotFound, "topic has no policy")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "topic": topic})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
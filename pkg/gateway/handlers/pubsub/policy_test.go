@@ -0,0 +1,120 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+)
+
+func TestTopicPolicy_RejectsWithStructuredErrors(t *testing.T) {
+	p, db := newHistoryTestHandlers(t)
+	ownNamespace(t, db, "0xalice")
+	p.client = &memClient{ps: &memPubSub{handlers: map[string][]client.MessageHandler{}}}
+
+	schema := `{"type":"object","required":["id","amount"],"properties":{"id":{"type":"string"},"amount":{"type":"number","minimum":0}}}`
+	if code := putACL(t, p.PoliciesHandler, "0xalice", map[string]any{"topic": "orders.>", "schema": json.RawMessage(schema), "max_payload": 64}); code != http.StatusOK {
+		t.Fatalf("Expected the policy to be saved, got %d", code)
+	}
+	if code := putACL(t, p.PoliciesHandler, "0xalice", map[string]any{"topic": "bad", "schema": json.RawMessage(`{"type":"thing"}`)}); code != http.StatusBadRequest {
+		t.Fatalf("Expected an invalid schema to be refused, got %d", code)
+	}
+
+	cases := []struct {
+		data    string
+		status  int
+		code    string
+		details string
+	}{
+		{`{"id":"o-1","amount":5}`, http.StatusOK, "", ""},
+		{`not json`, http.StatusUnprocessableEntity, rejectInvalidJSON, ""},
+		{`{"id":"o-1","amount":-1}`, http.StatusUnprocessableEntity, rejectSchema, "/amount: must be >= 0"},
+		{`{"amount":"5"}`, http.StatusUnprocessableEntity, rejectSchema, `/: missing required property "id"`},
+		{`{"id":"` + strings.Repeat("x", 64) + `","amount":1}`, http.StatusRequestEntityTooLarge, rejectPayloadTooLarge, ""},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(PublishRequest{Topic: "orders.eu", DataB64: base64.StdEncoding.EncodeToString([]byte(c.data))})
+		w := httptest.NewRecorder()
+		p.PublishHandler(w, nsRequest(http.MethodPost, "/v1/pubsub/publish", body))
+		if w.Code != c.status {
+			t.Fatalf("Publishing %s: expected status %d, got %d: %s", c.data, c.status, w.Code, w.Body.String())
+		}
+		if c.code == "" {
+			continue
+		}
+		var resp PublishError
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode publish error: %v", err)
+		}
+		if resp.Code != c.code || c.details != "" && !strings.Contains(strings.Join(resp.Details, "\n"), c.details) {
+			t.Errorf("Publishing %s: expected code %s with %q, got %+v", c.data, c.code, c.details, resp)
+		}
+	}
+
+	// Other topics keep accepting any bytes
+	if err := p.admitPublish(context.Background(), "ns", "chat", []byte("hi"), nil); err != nil {
+		t.Fatalf("Expected a topic without a policy to accept any data, got %v", err)
+	}
+}
+
+func TestTopicPolicy_CannotRaiseGatewayMaxPayload(t *testing.T) {
+	p, db := newHistoryTestHandlers(t)
+	ownNamespace(t, db, "0xalice")
+	if code := putACL(t, p.PoliciesHandler, "0xalice", TopicPolicyRequest{Topic: "big", MaxPayload: 64}); code != http.StatusOK {
+		t.Fatalf("Expected the policy to be saved, got %d", code)
+	}
+
+	// The operator lowers the gateway limit below the stored policy
+	p.config.MaxPayload = 16
+	if code := putACL(t, p.PoliciesHandler, "0xalice", TopicPolicyRequest{Topic: "big", MaxPayload: 64}); code != http.StatusBadRequest {
+		t.Fatalf("Expected max_payload above the gateway limit to be refused, got %d", code)
+	}
+	var rejection *publishRejection
+	err := p.admitPublish(context.Background(), "ns", "big", []byte(strings.Repeat("x", 32)), nil)
+	if !errors.As(err, &rejection) || rejection.Code != rejectPayloadTooLarge {
+		t.Fatalf("Expected the gateway limit to apply over the topic policy, got %v", err)
+	}
+}
+
+func TestPublishLimiter(t *testing.T) {
+	p, db := newHistoryTestHandlers(t)
+	ownNamespace(t, db, "0xalice")
+	if code := putACL(t, p.PoliciesHandler, "0xalice", TopicPolicyRequest{Topic: "ticks", PublishRate: 2}); code != http.StatusOK {
+		t.Fatalf("Expected the policy to be saved, got %d", code)
+	}
+
+	var limiter publishLimiter
+	for i := 0; i < 2; i++ {
+		if err := p.admitPublish(context.Background(), "ns", "ticks", []byte("x"), &limiter); err != nil {
+			t.Fatalf("Expected publish %d within the burst, got %v", i, err)
+		}
+	}
+	err := p.admitPublish(context.Background(), "ns", "ticks", []byte("x"), &limiter)
+	var rej *publishRejection
+	if !errors.As(err, &rej) || rej.Code != rejectRateLimited || rej.retryAfter() != 1 {
+		t.Fatalf("Expected the third publish to be rate limited, got %v", err)
+	}
+	if err := p.admitPublish(context.Background(), "ns", "ticks", []byte("x"), nil); err != nil {
+		t.Fatalf("Expected HTTP publishes to skip the connection rate, got %v", err)
+	}
+
+	now := time.Now()
+	var l publishLimiter
+	l.allow("t", 10, now)
+	for i := 0; i < 9; i++ {
+		l.allow("t", 10, now)
+	}
+	if ok, wait := l.allow("t", 10, now); ok || wait != 100*time.Millisecond {
+		t.Fatalf("Expected an empty bucket to wait 100ms, got ok=%v wait=%v", ok, wait)
+	}
+	if ok, _ := l.allow("t", 10, now.Add(100*time.Millisecond)); !ok {
+		t.Fatal("Expected the bucket to refill")
+	}
+}
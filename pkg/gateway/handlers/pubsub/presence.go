@@ -0,0 +1,458 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/olric"
+	olriclib "github.com/olric-data/olric"
+	"go.uber.org/zap"
+)
+
+const (
+	// presenceDMap is the Olric DMap holding the presence entries of all gateways
+	presenceDMap = "pubsub_presence"
+
+	// presenceHeartbeat is how often a gateway refreshes its connections'
+	// entries; an entry not refreshed within presenceTTL is reaped by any
+	// gateway, so members of a gateway that died disappear.
+	presenceHeartbeat = 10 * time.Second
+	presenceTTL       = 30 * time.Second
+
+	// presenceStoreTimeout bounds each presence store call.
+	presenceStoreTimeout = 2 * time.Second
+
+	// presenceKeySep separates the parts of a presence key.
+	presenceKeySep = "\x1f"
+)
+
+var errPresenceUnavailable = errors.New("presence store unavailable")
+
+// presenceEntry is one connection's presence in a topic.
+type presenceEntry struct {
+	MemberID  string                 `json:"member_id"`
+	ConnID    string                 `json:"conn_id"`
+	JoinedAt  int64                  `json:"joined_at"` // Unix seconds
+	Meta      map[string]interface{} `json:"meta,omitempty"`
+	UpdatedAt int64                  `json:"updated_at"` // Unix milliseconds of the last metadata change
+	ExpiresAt int64                  `json:"expires_at"` // Unix milliseconds
+}
+
+// presenceStore keeps presence entries shared by all gateways.
+type presenceStore interface {
+	put(ctx context.Context, key string, e presenceEntry) error
+	// remove deletes key and reports whether this call removed it
+	remove(ctx context.Context, key string) (bool, error)
+	// scan returns the entries whose key starts with prefix
+	scan(ctx context.Context, prefix string) (map[string]presenceEntry, error)
+}
+
+// presencePrefix returns the key prefix of a topic's entries.
+func presencePrefix(ns, topic string) string {
+	return ns + presenceKeySep + topic + presenceKeySep
+}
+
+// presenceKey returns the key of one connection's entry.
+func presenceKey(ns, topic, memberID, connID string) string {
+	return presencePrefix(ns, topic) + memberID + presenceKeySep + connID
+}
+
+// parsePresenceKey splits a key into namespace and topic.
+func parsePresenceKey(key string) (ns, topic string, ok bool) {
+	parts := strings.Split(key, presenceKeySep)
+	if len(parts) != 4 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// olricPresenceStore keeps entries in an Olric DMap. The client is looked up
+// on every call so a late Olric connection is picked up without a restart.
+type olricPresenceStore struct {
+	client func() *olric.Client
+}
+
+func (s *olricPresenceStore) dmap() (olriclib.DMap, error) {
+	c := s.client()
+	if c == nil {
+		return nil, errPresenceUnavailable
+	}
+	return c.GetClient().NewDMap(presenceDMap)
+}
+
+func (s *olricPresenceStore) put(ctx context.Context, key string, e presenceEntry) error {
+	dm, err := s.dmap()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// Olric expiry only collects entries left behind when every gateway is gone
+	return dm.Put(ctx, key, b, olriclib.EX(4*presenceTTL))
+}
+
+func (s *olricPresenceStore) remove(ctx context.Context, key string) (bool, error) {
+	dm, err := s.dmap()
+	if err != nil {
+		return false, err
+	}
+	n, err := dm.Delete(ctx, key)
+	return n > 0, err
+}
+
+func (s *olricPresenceStore) scan(ctx context.Context, prefix string) (map[string]presenceEntry, error) {
+	dm, err := s.dmap()
+	if err != nil {
+		return nil, err
+	}
+	it, err := dm.Scan(ctx, olriclib.Match("^"+regexp.QuoteMeta(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	entries := make(map[string]presenceEntry)
+	for it.Next() {
+		key := it.Key()
+		resp, err := dm.Get(ctx, key)
+		if err != nil {
+			continue // expired or removed since the scan
+		}
+		b, err := resp.Byte()
+		if err != nil {
+			continue
+		}
+		var e presenceEntry
+		if json.Unmarshal(b, &e) == nil {
+			entries[key] = e
+		}
+	}
+	return entries, nil
+}
+
+// localPresence returns the connection entries of this gateway, keyed like
+// the shared store.
+func (p *PubSubHandlers) localPresence() map[string]presenceEntry {
+	p.presenceMu.RLock()
+	defer p.presenceMu.RUnlock()
+	entries := make(map[string]presenceEntry)
+	for topicKey, members := range p.presenceMembers {
+		ns, topic, _ := strings.Cut(topicKey, ".")
+		for _, m := range members {
+			entries[presenceKey(ns, topic, m.MemberID, m.ConnID)] = m.entry()
+		}
+	}
+	return entries
+}
+
+// entry converts a local member to a shared presence entry.
+func (m PresenceMember) entry() presenceEntry {
+	return presenceEntry{
+		MemberID:  m.MemberID,
+		ConnID:    m.ConnID,
+		JoinedAt:  m.JoinedAt,
+		Meta:      m.Meta,
+		UpdatedAt: m.updatedAt,
+		ExpiresAt: time.Now().Add(presenceTTL).UnixMilli(),
+	}
+}
+
+// presenceMembersOf returns the members present in a topic across the
+// cluster, one per member ID. This gateway's own connections are always
+// included, so it degrades to local presence without the shared store.
+func (p *PubSubHandlers) presenceMembersOf(ctx context.Context, ns, topic string) []PresenceMember {
+	prefix := presencePrefix(ns, topic)
+	entries := make(map[string]presenceEntry)
+	if p.presence != nil {
+		ctx, cancel := context.WithTimeout(ctx, presenceStoreTimeout)
+		defer cancel()
+		shared, err := p.presence.scan(ctx, prefix)
+		if err != nil && !errors.Is(err, errPresenceUnavailable) {
+			p.logger.ComponentWarn("gateway", "pubsub presence: shared store unavailable, showing local members",
+				zap.Error(err))
+		}
+		for k, e := range shared {
+			entries[k] = e
+		}
+	}
+	for k, e := range p.localPresence() {
+		if strings.HasPrefix(k, prefix) {
+			entries[k] = e
+		}
+	}
+	return mergePresence(entries, time.Now())
+}
+
+// mergePresence folds live connection entries into one member per member ID:
+// the earliest join time, the most recently updated metadata and the number
+// of connections.
+func mergePresence(entries map[string]presenceEntry, now time.Time) []PresenceMember {
+	byID := make(map[string]*PresenceMember)
+	for _, e := range entries {
+		if e.ExpiresAt < now.UnixMilli() {
+			continue
+		}
+		m := byID[e.MemberID]
+		if m == nil {
+			m = &PresenceMember{MemberID: e.MemberID, JoinedAt: e.JoinedAt}
+			byID[e.MemberID] = m
+		}
+		m.Connections++
+		m.JoinedAt = min(m.JoinedAt, e.JoinedAt)
+		if m.Connections == 1 || e.UpdatedAt > m.updatedAt {
+			m.Meta = e.Meta
+			m.updatedAt = e.UpdatedAt
+		}
+	}
+	members := make([]PresenceMember, 0, len(byID))
+	for _, m := range byID {
+		members = append(members, *m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].JoinedAt != members[j].JoinedAt {
+			return members[i].JoinedAt < members[j].JoinedAt
+		}
+		return members[i].MemberID < members[j].MemberID
+	})
+	return members
+}
+
+// PresentMembers returns the IDs of the members present in a topic across
+// the cluster.
+func (p *PubSubHandlers) PresentMembers(ctx context.Context, ns, topic string) []string {
+	members := p.presenceMembersOf(ctx, ns, topic)
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.MemberID
+	}
+	return ids
+}
+
+// memberPresent reports whether memberID has a live connection in the topic.
+func (p *PubSubHandlers) memberPresent(ctx context.Context, ns, topic, memberID string) bool {
+	for _, m := range p.presenceMembersOf(ctx, ns, topic) {
+		if m.MemberID == memberID {
+			return true
+		}
+	}
+	return false
+}
+
+// joinPresence registers a connection's member in a topic. The join event is
+// broadcast only for the member's first connection in the cluster.
+func (p *PubSubHandlers) joinPresence(ns, topic string, m PresenceMember) {
+	ctx := context.Background()
+	present := p.memberPresent(ctx, ns, topic, m.MemberID)
+
+	m.updatedAt = time.Now().UnixMilli()
+	topicKey := ns + "." + topic
+	p.presenceMu.Lock()
+	p.presenceMembers[topicKey] = append(p.presenceMembers[topicKey], m)
+	p.presenceMu.Unlock()
+	p.storePresence(ctx, ns, topic, m)
+
+	if !present {
+		p.broadcastPresenceEvent(ns, topic, "presence.join", m.MemberID, m.Meta, m.JoinedAt)
+	}
+	p.logger.ComponentInfo("gateway", "pubsub ws: member joined presence",
+		zap.String("topic", topic),
+		zap.String("member_id", m.MemberID))
+}
+
+// updatePresence replaces the metadata of a connection's member and
+// broadcasts a presence.update event.
+func (p *PubSubHandlers) updatePresence(ns, topic, connID string, meta map[string]interface{}) bool {
+	topicKey := ns + "." + topic
+	ctx := context.Background()
+	memberID := ""
+	p.presenceMu.RLock()
+	for _, m := range p.presenceMembers[topicKey] {
+		if m.ConnID == connID {
+			memberID = m.MemberID
+			break
+		}
+	}
+	p.presenceMu.RUnlock()
+	if memberID == "" {
+		return false
+	}
+
+	// Stamp the change after the member's metadata on every connection, so
+	// it wins the merge even within the same millisecond
+	stamp := time.Now().UnixMilli()
+	for _, m := range p.presenceMembersOf(ctx, ns, topic) {
+		if m.MemberID == memberID {
+			stamp = max(stamp, m.updatedAt+1)
+		}
+	}
+
+	var member PresenceMember
+	found := false
+	p.presenceMu.Lock()
+	for i, m := range p.presenceMembers[topicKey] {
+		if m.ConnID == connID {
+			p.presenceMembers[topicKey][i].Meta = meta
+			p.presenceMembers[topicKey][i].updatedAt = stamp
+			member, found = p.presenceMembers[topicKey][i], true
+			break
+		}
+	}
+	p.presenceMu.Unlock()
+	if !found {
+		return false
+	}
+
+	p.storePresence(ctx, ns, topic, member)
+	p.broadcastPresenceEvent(ns, topic, "presence.update", member.MemberID, meta, time.Now().Unix())
+	return true
+}
+
+// leavePresence unregisters a connection's member. The leave event is
+// broadcast only when it was the member's last connection in the cluster.
+func (p *PubSubHandlers) leavePresence(ns, topic, connID string) {
+	topicKey := ns + "." + topic
+	var member PresenceMember
+	found := false
+	p.presenceMu.Lock()
+	members := p.presenceMembers[topicKey]
+	for i, m := range members {
+		if m.ConnID == connID {
+			member, found = m, true
+			p.presenceMembers[topicKey] = append(members[:i], members[i+1:]...)
+			break
+		}
+	}
+	if len(p.presenceMembers[topicKey]) == 0 {
+		delete(p.presenceMembers, topicKey)
+	}
+	p.presenceMu.Unlock()
+	if !found {
+		return
+	}
+
+	ctx := context.Background()
+	if p.presence != nil {
+		ctx, cancel := context.WithTimeout(ctx, presenceStoreTimeout)
+		_, err := p.presence.remove(ctx, presenceKey(ns, topic, member.MemberID, connID))
+		cancel()
+		if err != nil && !errors.Is(err, errPresenceUnavailable) {
+			p.logger.ComponentWarn("gateway", "pubsub presence: failed to remove entry", zap.Error(err))
+		}
+	}
+	if !p.memberPresent(ctx, ns, topic, member.MemberID) {
+		p.broadcastPresenceEvent(ns, topic, "presence.leave", member.MemberID, nil, time.Now().Unix())
+	}
+	p.logger.ComponentInfo("gateway", "pubsub ws: member left presence",
+		zap.String("topic", topic),
+		zap.String("member_id", member.MemberID))
+}
+
+// storePresence writes a connection's entry to the shared store.
+func (p *PubSubHandlers) storePresence(ctx context.Context, ns, topic string, m PresenceMember) {
+	if p.presence == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, presenceStoreTimeout)
+	defer cancel()
+	if err := p.presence.put(ctx, presenceKey(ns, topic, m.MemberID, m.ConnID), m.entry()); err != nil && !errors.Is(err, errPresenceUnavailable) {
+		p.logger.ComponentWarn("gateway", "pubsub presence: failed to store entry", zap.Error(err))
+	}
+}
+
+// presenceKeeper refreshes this gateway's presence entries and reaps the
+// entries of connections whose gateway stopped refreshing them.
+type presenceKeeper struct {
+	p *PubSubHandlers
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newPresenceKeeper(p *PubSubHandlers) *presenceKeeper {
+	ctx, cancel := context.WithCancel(context.Background())
+	k := &presenceKeeper{p: p, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	go k.run()
+	return k
+}
+
+// Close stops the keeper.
+func (k *presenceKeeper) Close() {
+	if k == nil {
+		return
+	}
+	k.once.Do(func() {
+		k.cancel()
+		<-k.done
+	})
+}
+
+func (k *presenceKeeper) run() {
+	defer close(k.done)
+	t := time.NewTicker(presenceHeartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-k.ctx.Done():
+			return
+		case <-t.C:
+			k.heartbeat()
+			k.reap(time.Now())
+		}
+	}
+}
+
+// heartbeat extends the expiry of this gateway's entries.
+func (k *presenceKeeper) heartbeat() {
+	for key, e := range k.p.localPresence() {
+		ctx, cancel := context.WithTimeout(k.ctx, presenceStoreTimeout)
+		err := k.p.presence.put(ctx, key, e)
+		cancel()
+		if err != nil {
+			if !errors.Is(err, errPresenceUnavailable) {
+				k.p.logger.ComponentWarn("gateway", "pubsub presence: heartbeat failed", zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+// reap removes expired entries. Whichever gateway removes an entry announces
+// the member's leave if it has no other live connection.
+func (k *presenceKeeper) reap(now time.Time) {
+	ctx, cancel := context.WithTimeout(k.ctx, presenceHeartbeat)
+	defer cancel()
+	entries, err := k.p.presence.scan(ctx, "")
+	if err != nil {
+		return
+	}
+	for key, e := range entries {
+		if e.ExpiresAt >= now.UnixMilli() {
+			continue
+		}
+		ns, topic, ok := parsePresenceKey(key)
+		if !ok {
+			continue
+		}
+		removed, err := k.p.presence.remove(ctx, key)
+		if err != nil || !removed {
+			continue
+		}
+		if !k.p.memberPresent(ctx, ns, topic, e.MemberID) {
+			k.p.broadcastPresenceEvent(ns, topic, "presence.leave", e.MemberID, nil, now.Unix())
+		}
+		k.p.logger.ComponentInfo("gateway", "pubsub presence: reaped stale member",
+			zap.String("namespace", ns),
+			zap.String("topic", topic),
+			zap.String("member_id", e.MemberID))
+	}
+}
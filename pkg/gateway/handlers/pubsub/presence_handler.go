@@ -1,11 +1,11 @@
 package pubsub
 
 import (
-	"fmt"
 	"net/http"
 )
 
-// PresenceHandler handles GET /v1/pubsub/presence?topic=mytopic
+// PresenceHandler handles GET /v1/pubsub/presence?topic=mytopic. Members are
+// listed once per member ID across every gateway in the cluster.
 func (p *PubSubHandlers) PresenceHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -23,22 +23,11 @@ func (p *PubSubHandlers) PresenceHandler(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "missing 'topic'")
 		return
 	}
-
-	topicKey := fmt.Sprintf("%s.%s", ns, topic)
-
-	p.presenceMu.RLock()
-	members, ok := p.presenceMembers[topicKey]
-	p.presenceMu.RUnlock()
-
-	if !ok {
-		writeJSON(w, http.StatusOK, map[string]any{
-			"topic":   topic,
-			"members": []PresenceMember{},
-			"count":   0,
-		})
+	if !p.allowHTTP(w, r, ns, topic, aclSubscribe) {
 		return
 	}
 
+	members := p.presenceMembersOf(r.Context(), ns, topic)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"topic":   topic,
 		"members": members,
@@ -0,0 +1,137 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
+)
+
+// memPresenceStore is an in-process stand-in for the Olric presence DMap.
+type memPresenceStore struct {
+	mu      sync.Mutex
+	entries map[string]presenceEntry
+}
+
+func (s *memPresenceStore) put(_ context.Context, key string, e presenceEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = e
+	return nil
+}
+
+func (s *memPresenceStore) remove(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.entries[key]
+	delete(s.entries, key)
+	return ok, nil
+}
+
+func (s *memPresenceStore) scan(_ context.Context, prefix string) (map[string]presenceEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]presenceEntry)
+	for k, e := range s.entries {
+		if strings.HasPrefix(k, prefix) {
+			out[k] = e
+		}
+	}
+	return out, nil
+}
+
+// newPresenceGateways returns two gateways sharing a presence store and mesh,
+// and the presence events published on topic.
+func newPresenceGateways(t *testing.T, topic string) (a, b *PubSubHandlers, store *memPresenceStore, events func() []string) {
+	t.Helper()
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	mesh := &memPubSub{handlers: map[string][]client.MessageHandler{}}
+	store = &memPresenceStore{entries: map[string]presenceEntry{}}
+	a = NewPubSubHandlers(&memClient{ps: mesh}, logger, Config{})
+	b = NewPubSubHandlers(&memClient{ps: mesh}, logger, Config{})
+	a.presence, b.presence = store, store
+
+	var mu sync.Mutex
+	var seen []string
+	ctx := pubsub.WithNamespace(context.Background(), "ns")
+	_ = mesh.Subscribe(ctx, topic, func(_ string, data []byte) error {
+		var ev map[string]any
+		if json.Unmarshal(data, &ev) == nil {
+			mu.Lock()
+			seen = append(seen, ev["type"].(string)+":"+ev["member_id"].(string))
+			mu.Unlock()
+		}
+		return nil
+	})
+	return a, b, store, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+}
+
+func TestPresence_ClusterWideDedup(t *testing.T) {
+	a, b, _, events := newPresenceGateways(t, "room")
+	now := time.Now().Unix()
+
+	a.joinPresence("ns", "room", PresenceMember{MemberID: "alice", ConnID: "a1", JoinedAt: now})
+	b.joinPresence("ns", "room", PresenceMember{MemberID: "alice", ConnID: "b1", JoinedAt: now + 5})
+	b.joinPresence("ns", "room", PresenceMember{MemberID: "bob", ConnID: "b2", JoinedAt: now + 1})
+
+	members := a.presenceMembersOf(context.Background(), "ns", "room")
+	if len(members) != 2 || members[0].MemberID != "alice" || members[0].Connections != 2 || members[0].JoinedAt != now {
+		t.Fatalf("Expected alice (2 connections) and bob from either gateway, got %+v", members)
+	}
+	if got := events(); len(got) != 2 || got[0] != "presence.join:alice" || got[1] != "presence.join:bob" {
+		t.Fatalf("Expected one join per member, got %v", got)
+	}
+
+	if !b.updatePresence("ns", "room", "b1", map[string]interface{}{"status": "away"}) {
+		t.Fatal("Expected metadata update to find the connection")
+	}
+	members = a.presenceMembersOf(context.Background(), "ns", "room")
+	if members[0].Meta["status"] != "away" {
+		t.Errorf("Expected updated metadata to be visible on the other gateway, got %+v", members[0])
+	}
+
+	b.leavePresence("ns", "room", "b1")
+	if got := events(); got[len(got)-1] == "presence.leave:alice" {
+		t.Errorf("Expected no leave while alice is still connected elsewhere, got %v", got)
+	}
+	a.leavePresence("ns", "room", "a1")
+	if got := events(); got[len(got)-1] != "presence.leave:alice" {
+		t.Errorf("Expected a leave after alice's last connection, got %v", got)
+	}
+}
+
+func TestPresence_ReapsDeadGateway(t *testing.T) {
+	a, b, store, events := newPresenceGateways(t, "room")
+	b.joinPresence("ns", "room", PresenceMember{MemberID: "carol", ConnID: "b1", JoinedAt: time.Now().Unix()})
+
+	// Gateway b dies: its entry is no longer refreshed
+	key := presenceKey("ns", "room", "carol", "b1")
+	b.presenceMembers = map[string][]PresenceMember{}
+	e := store.entries[key]
+	e.ExpiresAt = time.Now().Add(-time.Second).UnixMilli()
+	store.entries[key] = e
+
+	if members := a.presenceMembersOf(context.Background(), "ns", "room"); len(members) != 0 {
+		t.Fatalf("Expected expired members to be hidden, got %+v", members)
+	}
+	(&presenceKeeper{p: a, ctx: context.Background()}).reap(time.Now())
+	if _, ok := store.entries[key]; ok {
+		t.Error("Expected the stale entry to be reaped")
+	}
+	if got := events(); got[len(got)-1] != "presence.leave:carol" {
+		t.Errorf("Expected a leave event for the reaped member, got %v", got)
+	}
+}
@@ -4,15 +4,26 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"github.com/DeBrosOfficial/network/pkg/pubsub"
 	"go.uber.org/zap"
 )
 
-// PublishHandler handles POST /v1/pubsub/publish {topic, data_base64}
+// PublishHandler handles POST /v1/pubsub/publish {topic, data_base64}.
+//
+// By default the message is delivered to local subscribers and the response
+// returns before it is sent to the libp2p mesh. With ?ack=true the response
+// waits for the mesh publish and reports local deliveries and topic peers.
+// An Idempotency-Key header (or idempotency_key) makes retries of the same
+// publish deliver once; a retry gets the first publish back as a duplicate.
+// Publishes the topic policy refuses get a PublishError.
 func (p *PubSubHandlers) PublishHandler(w http.ResponseWriter, r *http.Request) {
 	if p.client == nil {
 		writeError(w, http.StatusServiceUnavailable, "client not initialized")
@@ -27,16 +38,102 @@ func (p *PubSubHandlers) PublishHandler(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusForbidden, "namespace not resolved")
 		return
 	}
+	ack := false
+	if v := r.URL.Query().Get("ack"); v != "" {
+		var err error
+		if ack, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid ack: expected true or false")
+			return
+		}
+	}
 	var body PublishRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxFrameSize)
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Topic == "" || body.DataB64 == "" {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writePublishError(w, &publishRejection{
+				Code:    rejectPayloadTooLarge,
+				Message: fmt.Sprintf("request body larger than %d bytes", maxFrameSize),
+			})
+			return
+		}
 		writeError(w, http.StatusBadRequest, "invalid body: expected {topic,data_base64}")
 		return
 	}
+	if isPattern(body.Topic) {
+		writeError(w, http.StatusBadRequest, "cannot publish to a wildcard topic")
+		return
+	}
+	if !validTopic(body.Topic) {
+		writeError(w, http.StatusBadRequest, "invalid topic")
+		return
+	}
+	if !p.allowHTTP(w, r, ns, body.Topic, aclPublish) {
+		return
+	}
 	data, err := base64.StdEncoding.DecodeString(body.DataB64)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid base64 data")
 		return
 	}
+	// HTTP publishes are rate limited by the gateway, not per connection
+	if err := p.admitPublish(r.Context(), ns, body.Topic, data, nil); err != nil {
+		writePublishError(w, err)
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		key = body.IdempotencyKey
+	}
+	if len(key) > maxIdempotencyKey {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("idempotency key longer than %d bytes", maxIdempotencyKey))
+		return
+	}
+
+	// Retries with the same idempotency key get the first publish back
+	var (
+		id    string
+		store idempotencyStore
+	)
+	if key != "" {
+		id = idempotentMessageID(ns, body.Topic, key)
+		var prev *publishRecord
+		store, prev, err = p.claimPublish(r.Context(), id, publishRecord{ID: id, Time: time.Now().UnixMilli()})
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "failed to check idempotency key")
+			return
+		}
+		if prev != nil {
+			writeJSON(w, http.StatusOK, PublishResponse{Status: "ok", ID: prev.ID, Timestamp: prev.Time, Seq: prev.Seq, Duplicate: true})
+			return
+		}
+	}
+
+	// Durable topics store the message before it is delivered anywhere
+	msg, err := p.stamp(r.Context(), ns, body.Topic, id, data)
+	if err != nil {
+		p.logger.ComponentError("gateway", "pubsub publish: failed to store message",
+			zap.String("topic", body.Topic),
+			zap.Error(err))
+		if store != nil {
+			_ = store.release(r.Context(), id)
+		}
+		writeError(w, http.StatusServiceUnavailable, "failed to store message")
+		return
+	}
+	if store != nil {
+		if err := store.update(r.Context(), id, publishRecord{ID: msg.ID, Seq: msg.Seq, Time: msg.Time.UnixMilli()}); err != nil {
+			p.logger.ComponentDebug("gateway", "pubsub publish: failed to record idempotency key",
+				zap.String("topic", body.Topic),
+				zap.Error(err))
+		}
+	}
+
+	metering.Record(r.Context(), metering.PubSubMessages, 1)
+	p.published(ns, body.Topic, msg)
+
+	// Let wildcard subscriptions pick up a new topic before delivery
+	p.noteTopic(ns, body.Topic, true)
 
 	// Check for local websocket subscribers FIRST and deliver directly
 	p.mu.RLock()
@@ -46,13 +143,12 @@ func (p *PubSubHandlers) PublishHandler(w http.ResponseWriter, r *http.Request)
 	localDeliveryCount := 0
 	if len(localSubs) > 0 {
 		for _, sub := range localSubs {
-			select {
-			case sub.msgChan <- data:
+			if sub.deliver(msg) {
 				localDeliveryCount++
 				p.logger.ComponentDebug("gateway", "delivered to local subscriber",
 					zap.String("topic", body.Topic))
-			default:
-				// Drop if buffer full
+			} else {
+				// Dropped; durable subscribers catch up from the topic log
 				p.logger.ComponentWarn("gateway", "local subscriber buffer full, dropping message",
 					zap.String("topic", body.Topic))
 			}
@@ -62,18 +158,44 @@ func (p *PubSubHandlers) PublishHandler(w http.ResponseWriter, r *http.Request)
 	p.logger.ComponentInfo("gateway", "pubsub publish: processing message",
 		zap.String("topic", body.Topic),
 		zap.String("namespace", ns),
+		zap.String("id", msg.ID),
 		zap.Int("data_len", len(data)),
 		zap.Int("local_subscribers", len(localSubs)),
 		zap.Int("local_delivered", localDeliveryCount))
 
+	resp := PublishResponse{Status: "ok", ID: msg.ID, Timestamp: msg.Time.UnixMilli(), Seq: msg.Seq}
+
+	if ack {
+		publishCtx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		peers, err := p.publishMesh(publishCtx, ns, body.Topic, msg)
+		if err != nil {
+			p.logger.ComponentWarn("gateway", "libp2p publish failed",
+				zap.String("topic", body.Topic),
+				zap.Error(err))
+			// Let a retry publish again; subscribers drop the copy by ID
+			if store != nil {
+				_ = store.release(r.Context(), id)
+			}
+			writeError(w, http.StatusBadGateway, "failed to publish message to the network")
+			return
+		}
+		resp.Acked = true
+		resp.LocalDelivered = &localDeliveryCount
+		if peers >= 0 {
+			resp.MeshPeers = &peers
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
 	// Publish to libp2p asynchronously for cross-node delivery
 	// This prevents blocking the HTTP response if libp2p network is slow
 	go func() {
 		publishCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		ctx := pubsub.WithNamespace(client.WithInternalAuth(publishCtx), ns)
-		if err := p.client.PubSub().Publish(ctx, body.Topic, data); err != nil {
+		if _, err := p.publishMesh(publishCtx, ns, body.Topic, msg); err != nil {
 			p.logger.ComponentWarn("gateway", "async libp2p publish failed",
 				zap.String("topic", body.Topic),
 				zap.Error(err))
@@ -85,7 +207,38 @@ func (p *PubSubHandlers) PublishHandler(w http.ResponseWriter, r *http.Request)
 
 	// Return immediately after local delivery
 	// Local WebSocket subscribers already received the message
-	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// published reports an accepted message to Config.OnPublish.
+func (p *PubSubHandlers) published(ns, topic string, m topicMessage) {
+	if p.config.OnPublish == nil {
+		return
+	}
+	p.config.OnPublish(PublishedMessage{
+		Namespace: ns,
+		Topic:     topic,
+		ID:        m.ID,
+		Seq:       m.Seq,
+		Data:      m.Data,
+		From:      m.From,
+		Time:      m.Time,
+	})
+}
+
+// publishMesh publishes m to the libp2p mesh and returns the number of peers
+// in the topic, or -1 if the node cannot report it.
+func (p *PubSubHandlers) publishMesh(ctx context.Context, ns, topic string, m topicMessage) (int, error) {
+	ctx = pubsub.WithNamespace(client.WithInternalAuth(ctx), ns)
+	ps := p.client.PubSub()
+	if reporter, ok := ps.(client.PubSubPublishReporter); ok {
+		res, err := reporter.PublishWithResult(ctx, topic, p.meshPayload(ns, topic, m))
+		if err != nil {
+			return -1, err
+		}
+		return res.Peers, nil
+	}
+	return -1, ps.Publish(ctx, topic, p.meshPayload(ns, topic, m))
 }
 
 // TopicsHandler lists topics within the caller's namespace
@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
+)
+
+func publish(t *testing.T, p *PubSubHandlers, target, key string) PublishResponse {
+	t.Helper()
+	r := nsRequest(http.MethodPost, target, []byte(`{"topic":"orders","data_base64":"aGk="}`))
+	r = r.WithContext(context.WithValue(r.Context(), ctxkeys.JWT, &auth.JWTClaims{Sub: "0xwallet"}))
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	p.PublishHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp PublishResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode publish response: %v", err)
+	}
+	return resp
+}
+
+func TestPublish_AckAndIdempotency(t *testing.T) {
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	mesh := &memPubSub{handlers: map[string][]client.MessageHandler{}}
+	p := NewPubSubHandlers(&memClient{ps: mesh}, logger, Config{})
+
+	local := &localSubscriber{msgChan: make(chan topicMessage, 4), namespace: "ns"}
+	p.addLocalSubscriber("ns.orders", local)
+	var remote []topicMessage
+	_ = mesh.Subscribe(pubsub.WithNamespace(context.Background(), "ns"), "orders", func(_ string, data []byte) error {
+		remote = append(remote, p.decodeMeshMessage("ns", "orders", data))
+		return nil
+	})
+
+	first := publish(t, p, "/v1/pubsub/publish?ack=true", "order-1")
+	if !first.Acked || first.Duplicate || first.ID == "" || *first.LocalDelivered != 1 || *first.MeshPeers != 1 {
+		t.Fatalf("Expected an acknowledged publish reaching one local subscriber and one peer, got %+v", first)
+	}
+	if len(remote) != 1 || remote[0].ID != first.ID || remote[0].From != "0xwallet" {
+		t.Fatalf("Expected the mesh to receive the envelope, got %+v", remote)
+	}
+
+	retry := publish(t, p, "/v1/pubsub/publish?ack=true", "order-1")
+	if !retry.Duplicate || retry.ID != first.ID || retry.Timestamp != first.Timestamp {
+		t.Fatalf("Expected the retry to return the first publish, got %+v", retry)
+	}
+	if len(local.msgChan) != 1 || len(remote) != 1 {
+		t.Errorf("Expected the retry not to be delivered again, got %d local and %d remote", len(local.msgChan), len(remote))
+	}
+
+	if other := publish(t, p, "/v1/pubsub/publish", "order-2"); other.Duplicate || other.ID == first.ID || other.Acked {
+		t.Errorf("Expected a new key to publish a new message, got %+v", other)
+	}
+}
+
+func TestTopicStream_DropsDuplicateIDs(t *testing.T) {
+	s := &topicStream{readSeq: -1}
+	var sent []string
+	emit := func(m topicMessage) error {
+		sent = append(sent, string(m.Data))
+		return nil
+	}
+	for _, m := range []topicMessage{{ID: "a", Data: []byte("1")}, {ID: "a", Data: []byte("1")}, {Data: []byte("2")}, {Data: []byte("2")}} {
+		_ = s.send(m, emit)
+	}
+	if len(sent) != 3 {
+		t.Errorf("Expected the repeated ID to be dropped and anonymous payloads kept, got %v", sent)
+	}
+}
+
+func TestPublish_RefusesReservedTopics(t *testing.T) {
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	p := NewPubSubHandlers(&memClient{ps: &memPubSub{handlers: map[string][]client.MessageHandler{}}}, logger, Config{})
+
+	w := httptest.NewRecorder()
+	p.PublishHandler(w, nsRequest(http.MethodPost, "/v1/pubsub/publish", []byte(`{"topic":"_orama.topics","data_base64":"aGk="}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected publishing to a reserved topic to be refused, got %d", w.Code)
+	}
+
+	// Single-topic streams, including WebSocket publishing, are refused as well
+	for name, handler := range map[string]http.HandlerFunc{"ws": p.WebsocketHandler, "sse": p.SSEHandler} {
+		w := httptest.NewRecorder()
+		handler(w, nsRequest(http.MethodGet, "/v1/pubsub/"+name+"?topic=_orama.topics", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s on a reserved topic to be refused, got %d", name, w.Code)
+		}
+	}
+}
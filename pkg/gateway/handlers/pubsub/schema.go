@@ -0,0 +1,601 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxSchemaSize caps a registered schema document.
+	maxSchemaSize = 64 << 10
+	// maxSchemaErrors caps the violations reported for one message.
+	maxSchemaErrors = 10
+	// maxSchemaDepth stops $ref cycles that never descend into the message.
+	maxSchemaDepth = 128
+)
+
+// jsonSchema is a compiled JSON Schema. It implements a subset of the draft
+// 2020-12 validation keywords: type, enum, const, properties,
+// patternProperties, required, additionalProperties, items, minItems,
+// maxItems, uniqueItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, minProperties,
+// maxProperties, allOf, anyOf, oneOf and not, plus $ref to "#",
+// "#/$defs/<name>" or "#/definitions/<name>". Annotations such as title,
+// description and format are accepted and not checked. Any other keyword is
+// refused when the schema is compiled, so that a schema never silently
+// accepts messages it was meant to reject.
+type jsonSchema struct {
+	reject bool // the false schema
+	ref    string
+	target *jsonSchema // resolved ref
+
+	types    []string
+	enum     []any
+	hasConst bool
+	constant any
+
+	properties map[string]*jsonSchema
+	patterns   []patternSchema
+	required   []string
+	additional *jsonSchema // nil allows any other property
+	minProps   int
+	maxProps   int
+
+	items       *jsonSchema
+	minItems    int
+	maxItems    int
+	uniqueItems bool
+
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+
+	minimum    *float64
+	maximum    *float64
+	exclMin    *float64
+	exclMax    *float64
+	multipleOf *float64
+
+	allOf []*jsonSchema
+	anyOf []*jsonSchema
+	oneOf []*jsonSchema
+	not   *jsonSchema
+}
+
+// patternSchema applies to the properties whose name matches re.
+type patternSchema struct {
+	re     *regexp.Regexp
+	schema *jsonSchema
+}
+
+// schemaKeywords lists the keywords compileSchema understands: true for
+// those it enforces, false for annotations that are accepted unchecked.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "patternProperties": true, "required": true, "additionalProperties": true,
+	"minProperties": true, "maxProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true, "$ref": true,
+
+	"$schema": false, "$comment": false, "title": false, "description": false, "default": false,
+	"examples": false, "deprecated": false, "readOnly": false, "writeOnly": false, "format": false,
+	"contentEncoding": false, "contentMediaType": false,
+}
+
+// schemaCompiler resolves $refs once the whole document is compiled.
+type schemaCompiler struct {
+	defs map[string]*jsonSchema
+	refs []*jsonSchema
+}
+
+// compileSchema parses and compiles a JSON Schema document.
+func compileSchema(raw []byte) (*jsonSchema, error) {
+	if len(raw) > maxSchemaSize {
+		return nil, fmt.Errorf("schema larger than %d bytes", maxSchemaSize)
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, errors.New("schema is not valid JSON")
+	}
+	c := &schemaCompiler{defs: make(map[string]*jsonSchema)}
+	if obj, ok := doc.(map[string]any); ok {
+		for _, kw := range []string{"$defs", "definitions"} {
+			v, ok := obj[kw]
+			if !ok {
+				continue
+			}
+			defs, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("/%s: expected an object", kw)
+			}
+			for name, d := range defs {
+				s, err := c.compile(d, "/"+kw+"/"+name)
+				if err != nil {
+					return nil, err
+				}
+				c.defs["#/"+kw+"/"+name] = s
+			}
+		}
+	}
+	root, err := c.compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	c.defs["#"] = root
+	for _, s := range c.refs {
+		if s.target = c.defs[s.ref]; s.target == nil {
+			return nil, fmt.Errorf("unresolvable $ref %q", s.ref)
+		}
+	}
+	return root, nil
+}
+
+func (c *schemaCompiler) compile(v any, at string) (*jsonSchema, error) {
+	s := &jsonSchema{minProps: -1, maxProps: -1, minItems: -1, maxItems: -1, minLength: -1, maxLength: -1}
+	switch v := v.(type) {
+	case bool:
+		s.reject = !v
+		return s, nil
+	case map[string]any:
+		return s, c.keywords(s, v, at)
+	}
+	return nil, fmt.Errorf("%s: a schema must be an object or a boolean", pointer(at))
+}
+
+func (c *schemaCompiler) keywords(s *jsonSchema, obj map[string]any, at string) error {
+	bad := func(kw, want string) error {
+		return fmt.Errorf("%s: expected %s", pointer(at+"/"+kw), want)
+	}
+	for kw := range obj {
+		if _, known := schemaKeywords[kw]; known {
+			continue
+		}
+		// $defs are compiled by compileSchema, and only resolve at the root
+		if at == "" && (kw == "$defs" || kw == "definitions" || kw == "$id") {
+			continue
+		}
+		return fmt.Errorf("%s: unsupported keyword", pointer(at+"/"+kw))
+	}
+	sub := func(kw string, v any) (*jsonSchema, error) {
+		return c.compile(v, at+"/"+kw)
+	}
+	count := func(kw string, dst *int) error {
+		if v, ok := obj[kw]; ok {
+			n, ok := v.(float64)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return bad(kw, "a non-negative integer")
+			}
+			*dst = int(min(n, math.MaxInt32))
+		}
+		return nil
+	}
+	number := func(kw string, dst **float64) error {
+		if v, ok := obj[kw]; ok {
+			n, ok := v.(float64)
+			if !ok {
+				return bad(kw, "a number")
+			}
+			*dst = &n
+		}
+		return nil
+	}
+	list := func(kw string) ([]*jsonSchema, error) {
+		v, ok := obj[kw]
+		if !ok {
+			return nil, nil
+		}
+		arr, ok := v.([]any)
+		if !ok || len(arr) == 0 {
+			return nil, bad(kw, "a non-empty array of schemas")
+		}
+		out := make([]*jsonSchema, len(arr))
+		for i, e := range arr {
+			var err error
+			if out[i], err = c.compile(e, at+"/"+kw+"/"+strconv.Itoa(i)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+
+	if v, ok := obj["$ref"]; ok {
+		ref, ok := v.(string)
+		if !ok {
+			return bad("$ref", "a string")
+		}
+		s.ref = ref
+		c.refs = append(c.refs, s)
+	}
+
+	switch t := obj["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, e := range t {
+			name, ok := e.(string)
+			if !ok {
+				return bad("type", "a type name or an array of them")
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return bad("type", "a type name or an array of them")
+	}
+	for _, t := range s.types {
+		switch t {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return fmt.Errorf("%s: unknown type %q", pointer(at+"/type"), t)
+		}
+	}
+
+	if v, ok := obj["enum"]; ok {
+		arr, ok := v.([]any)
+		if !ok {
+			return bad("enum", "an array")
+		}
+		s.enum = arr
+		if s.enum == nil {
+			s.enum = []any{}
+		}
+	}
+	if v, ok := obj["const"]; ok {
+		s.hasConst, s.constant = true, v
+	}
+
+	if v, ok := obj["properties"]; ok {
+		props, ok := v.(map[string]any)
+		if !ok {
+			return bad("properties", "an object")
+		}
+		s.properties = make(map[string]*jsonSchema, len(props))
+		for name, p := range props {
+			ps, err := c.compile(p, at+"/properties/"+name)
+			if err != nil {
+				return err
+			}
+			s.properties[name] = ps
+		}
+	}
+	if v, ok := obj["patternProperties"]; ok {
+		props, ok := v.(map[string]any)
+		if !ok {
+			return bad("patternProperties", "an object")
+		}
+		for expr, p := range props {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("%s: invalid regular expression: %v", pointer(at+"/patternProperties/"+escapePointer(expr)), err)
+			}
+			ps, err := c.compile(p, at+"/patternProperties/"+escapePointer(expr))
+			if err != nil {
+				return err
+			}
+			s.patterns = append(s.patterns, patternSchema{re: re, schema: ps})
+		}
+	}
+	if v, ok := obj["required"]; ok {
+		arr, ok := v.([]any)
+		if !ok {
+			return bad("required", "an array of strings")
+		}
+		for _, e := range arr {
+			name, ok := e.(string)
+			if !ok {
+				return bad("required", "an array of strings")
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	if v, ok := obj["additionalProperties"]; ok {
+		var err error
+		if s.additional, err = sub("additionalProperties", v); err != nil {
+			return err
+		}
+	}
+	if v, ok := obj["items"]; ok {
+		var err error
+		if s.items, err = sub("items", v); err != nil {
+			return err
+		}
+	}
+	if v, ok := obj["uniqueItems"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			return bad("uniqueItems", "a boolean")
+		}
+		s.uniqueItems = b
+	}
+	if v, ok := obj["not"]; ok {
+		var err error
+		if s.not, err = sub("not", v); err != nil {
+			return err
+		}
+	}
+	if v, ok := obj["pattern"]; ok {
+		expr, ok := v.(string)
+		if !ok {
+			return bad("pattern", "a regular expression")
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("%s: invalid regular expression: %v", pointer(at+"/pattern"), err)
+		}
+		s.pattern = re
+	}
+
+	for kw, dst := range map[string]*int{
+		"minProperties": &s.minProps, "maxProperties": &s.maxProps,
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+	} {
+		if err := count(kw, dst); err != nil {
+			return err
+		}
+	}
+	for kw, dst := range map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclMin, "exclusiveMaximum": &s.exclMax,
+		"multipleOf": &s.multipleOf,
+	} {
+		if err := number(kw, dst); err != nil {
+			return err
+		}
+	}
+	if s.multipleOf != nil && *s.multipleOf <= 0 {
+		return bad("multipleOf", "a number greater than 0")
+	}
+
+	var err error
+	if s.allOf, err = list("allOf"); err != nil {
+		return err
+	}
+	if s.anyOf, err = list("anyOf"); err != nil {
+		return err
+	}
+	if s.oneOf, err = list("oneOf"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validate checks a decoded JSON value and returns the violations found, at
+// most maxSchemaErrors of them.
+func (s *jsonSchema) validate(v any) []string {
+	var errs []string
+	s.check(v, "", 0, &errs)
+	return errs
+}
+
+// check reports whether v is valid. Violations are appended to errs unless
+// it is nil, which anyOf, oneOf and not use to try a schema quietly.
+func (s *jsonSchema) check(v any, at string, depth int, errs *[]string) bool {
+	fail := func(format string, args ...any) {
+		if errs != nil && len(*errs) < maxSchemaErrors {
+			*errs = append(*errs, pointer(at)+": "+fmt.Sprintf(format, args...))
+		}
+	}
+	if depth > maxSchemaDepth {
+		fail("schema nests too deeply")
+		return false
+	}
+	if s.reject {
+		fail("not allowed")
+		return false
+	}
+	ok := true
+	if s.target != nil && !s.target.check(v, at, depth+1, errs) {
+		ok = false
+	}
+
+	if len(s.types) > 0 && !hasType(v, s.types) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(v))
+		// The other keywords describe a value of the expected type
+		return false
+	}
+	if s.enum != nil && !containsValue(s.enum, v) {
+		fail("must be one of the allowed values")
+		ok = false
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		fail("must equal the constant value")
+		ok = false
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, present := v[name]; !present {
+				fail("missing required property %q", name)
+				ok = false
+			}
+		}
+		if s.minProps >= 0 && len(v) < s.minProps {
+			fail("must have at least %d properties", s.minProps)
+			ok = false
+		}
+		if s.maxProps >= 0 && len(v) > s.maxProps {
+			fail("must have at most %d properties", s.maxProps)
+			ok = false
+		}
+		for name, pv := range v {
+			child := at + "/" + escapePointer(name)
+			ps, matched := s.properties[name]
+			if matched && !ps.check(pv, child, depth+1, errs) {
+				ok = false
+			}
+			for _, pp := range s.patterns {
+				if !pp.re.MatchString(name) {
+					continue
+				}
+				matched = true
+				if !pp.schema.check(pv, child, depth+1, errs) {
+					ok = false
+				}
+			}
+			if !matched && s.additional != nil && !s.additional.check(pv, child, depth+1, errs) {
+				ok = false
+			}
+		}
+	case []any:
+		if s.minItems >= 0 && len(v) < s.minItems {
+			fail("must have at least %d items", s.minItems)
+			ok = false
+		}
+		if s.maxItems >= 0 && len(v) > s.maxItems {
+			fail("must have at most %d items", s.maxItems)
+			ok = false
+		}
+		if s.uniqueItems {
+			for i := 1; i < len(v); i++ {
+				if containsValue(v[:i], v[i]) {
+					fail("items must be unique")
+					ok = false
+					break
+				}
+			}
+		}
+		if s.items != nil {
+			for i, e := range v {
+				if !s.items.check(e, at+"/"+strconv.Itoa(i), depth+1, errs) {
+					ok = false
+				}
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength >= 0 && n < s.minLength {
+			fail("must be at least %d characters", s.minLength)
+			ok = false
+		}
+		if s.maxLength >= 0 && n > s.maxLength {
+			fail("must be at most %d characters", s.maxLength)
+			ok = false
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %q", s.pattern.String())
+			ok = false
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+			ok = false
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+			ok = false
+		}
+		if s.exclMin != nil && v <= *s.exclMin {
+			fail("must be > %v", *s.exclMin)
+			ok = false
+		}
+		if s.exclMax != nil && v >= *s.exclMax {
+			fail("must be < %v", *s.exclMax)
+			ok = false
+		}
+		if s.multipleOf != nil {
+			if q := v / *s.multipleOf; q != math.Trunc(q) {
+				fail("must be a multiple of %v", *s.multipleOf)
+				ok = false
+			}
+		}
+	}
+
+	for _, sub := range s.allOf {
+		if !sub.check(v, at, depth+1, errs) {
+			ok = false
+		}
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if sub.check(v, at, depth+1, nil) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("must match at least one schema in anyOf")
+			ok = false
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if sub.check(v, at, depth+1, nil) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("must match exactly one schema in oneOf, matched %d", matched)
+			ok = false
+		}
+	}
+	if s.not != nil && s.not.check(v, at, depth+1, nil) {
+		fail("must not match the schema in not")
+		ok = false
+	}
+	return ok
+}
+
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+func hasType(v any, types []string) bool {
+	t := typeOf(v)
+	for _, want := range types {
+		if want == t || (want == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(list []any, v any) bool {
+	for _, e := range list {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointer formats a JSON Pointer for messages; the document itself is "/".
+func pointer(at string) string {
+	if at == "" {
+		return "/"
+	}
+	return at
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
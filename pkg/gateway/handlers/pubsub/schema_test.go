@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := compileSchema([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "Order",
+		"$defs": {"item": {"type": "object", "required": ["sku"], "properties": {"sku": {"type": "string", "pattern": "^[A-Z]{3}-[0-9]+$"}}}},
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"kind": {"enum": ["order", "refund"]},
+			"items": {"type": "array", "minItems": 1, "items": {"$ref": "#/$defs/item"}},
+			"qty": {"type": "integer", "exclusiveMinimum": 0},
+			"note": {"anyOf": [{"type": "null"}, {"type": "string", "maxLength": 5}]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	cases := []struct {
+		doc   string
+		valid bool
+	}{
+		{`{"kind":"order","items":[{"sku":"ABC-1"}],"qty":2,"note":null}`, true},
+		{`{"kind":"order","items":[{"sku":"ABC-1"}],"note":"short"}`, true},
+		{`{"kind":"other"}`, false},
+		{`{"items":[]}`, false},
+		{`{"items":[{"sku":"abc"}]}`, false},
+		{`{"qty":1.5}`, false},
+		{`{"qty":0}`, false},
+		{`{"note":"too long"}`, false},
+		{`{"extra":true}`, false},
+		{`[]`, false},
+	}
+	for _, c := range cases {
+		var v any
+		if err := json.Unmarshal([]byte(c.doc), &v); err != nil {
+			t.Fatalf("Bad test document %s: %v", c.doc, err)
+		}
+		if errs := schema.validate(v); (len(errs) == 0) != c.valid {
+			t.Errorf("validate(%s) = %v, want valid=%v", c.doc, errs, c.valid)
+		}
+	}
+
+	// Pattern properties are exempt from additionalProperties
+	extensible, err := compileSchema([]byte(`{"type":"object","additionalProperties":false,"properties":{"id":{"type":"string"}},"patternProperties":{"^x-":{"type":"integer"}}}`))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+	for doc, valid := range map[string]bool{`{"id":"a","x-a":1}`: true, `{"x-a":"1"}`: false, `{"y":1}`: false} {
+		var v any
+		_ = json.Unmarshal([]byte(doc), &v)
+		if errs := extensible.validate(v); (len(errs) == 0) != valid {
+			t.Errorf("validate(%s) = %v, want valid=%v", doc, errs, valid)
+		}
+	}
+
+	unsupported := []string{
+		`{"prefixItems":[{"type":"string"}]}`,
+		`{"if":{"type":"string"},"then":{"minLength":1}}`,
+		`{"dependentRequired":{"a":["b"]}}`,
+		`{"propertyNames":{"maxLength":3}}`,
+		`{"properties":{"tags":{"contains":{"const":"x"}}}}`,
+		`{"items":{"$defs":{}}}`,
+	}
+	for _, bad := range append(unsupported, `{"$ref":"#/$defs/missing"}`, `{"pattern":"("}`, `{"minLength":-1}`, `"string"`) {
+		if _, err := compileSchema([]byte(bad)); err == nil {
+			t.Errorf("Expected schema %s to be refused", bad)
+		}
+	}
+	// A $ref cycle that never descends into the document fails instead of looping
+	loop, err := compileSchema([]byte(`{"$ref":"#"}`))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+	if errs := loop.validate(1.0); len(errs) == 0 {
+		t.Error("Expected a $ref cycle to fail validation")
+	}
+}
@@ -0,0 +1,207 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
+	"go.uber.org/zap"
+)
+
+const (
+	// sseHeartbeat is how often a comment line is written to an SSE stream so
+	// proxies and load balancers do not close it while the topic is quiet.
+	sseHeartbeat = 15 * time.Second
+
+	// sseRetry is the reconnect delay suggested to EventSource clients.
+	sseRetry = 3 * time.Second
+
+	// sseWriteTimeout bounds a single write to a client that stopped reading.
+	sseWriteTimeout = 30 * time.Second
+)
+
+// SSEHandler streams a namespaced topic as Server-Sent Events for clients that
+// cannot hold a WebSocket. Each event's data is the JSON envelope the WebSocket
+// handler sends. Durable topic events carry their sequence number as the event
+// ID, so a reconnect with Last-Event-ID (or ?since=<seq>) first replays the
+// stored messages after it.
+func (p *PubSubHandlers) SSEHandler(w http.ResponseWriter, r *http.Request) {
+	if p.client == nil {
+		p.logger.ComponentWarn("gateway", "pubsub sse: client not initialized")
+		writeError(w, http.StatusServiceUnavailable, "client not initialized")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ns := resolveNamespaceFromRequest(r)
+	if ns == "" {
+		writeError(w, http.StatusForbidden, "namespace not resolved")
+		return
+	}
+
+	topic := r.URL.Query().Get("topic")
+	if topic == "" {
+		writeError(w, http.StatusBadRequest, "missing 'topic'")
+		return
+	}
+	if isPattern(topic) {
+		writeError(w, http.StatusBadRequest, "wildcard topics require the multi-topic WebSocket protocol")
+		return
+	}
+	if !validTopic(topic) {
+		writeError(w, http.StatusBadRequest, "invalid topic")
+		return
+	}
+	if !p.allowHTTP(w, r, ns, topic, aclSubscribe) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	// An explicit ?since= must name a durable topic. Last-Event-ID is sent by
+	// EventSource on every reconnect, so it is ignored where it cannot apply.
+	since, err := parseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	explicit := since >= 0
+	if !explicit {
+		if id := strings.TrimSpace(r.Header.Get("Last-Event-ID")); id != "" {
+			if seq, err := strconv.ParseInt(id, 10, 64); err == nil && seq >= 0 {
+				since = seq
+			}
+		}
+	}
+	if since >= 0 {
+		dt, err := p.durableTopic(r.Context(), ns, topic)
+		if err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub sse: durable topic lookup failed", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to look up topic")
+			return
+		}
+		if dt == nil {
+			if explicit {
+				writeError(w, http.StatusBadRequest, "topic is not durable; 'since' requires a durable topic")
+				return
+			}
+			since = -1
+		}
+	}
+
+	// Register before any replay so no message falls between history and
+	// live delivery
+	msgs := make(chan topicMessage, 128)
+	localSub := &localSubscriber{
+		msgChan:   msgs,
+		namespace: ns,
+		lagged:    make(chan struct{}, 1),
+	}
+	topicKey := fmt.Sprintf("%s.%s", ns, topic)
+	subscriberCount := p.addLocalSubscriber(topicKey, localSub)
+	p.logger.ComponentInfo("gateway", "pubsub sse: registered local subscriber",
+		zap.String("topic", topic),
+		zap.String("namespace", ns),
+		zap.Int("total_subscribers", subscriberCount))
+	defer func() {
+		remainingCount := p.removeLocalSubscriber(topicKey, localSub)
+		p.logger.ComponentInfo("gateway", "pubsub sse: unregistered local subscriber",
+			zap.String("topic", topic),
+			zap.Int("remaining_subscribers", remainingCount))
+	}()
+
+	ctx := client.WithInternalAuth(r.Context())
+	ctx = pubsub.WithNamespace(ctx, ns)
+
+	done := make(chan struct{})
+	defer close(done)
+	go p.libp2pSubscriber(ctx, topic, localSub, done)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // nginx would otherwise buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	stream := &sseStream{w: w, flusher: flusher, rc: http.NewResponseController(w)}
+	if err := stream.write([]byte(fmt.Sprintf("retry: %d\n\n", sseRetry.Milliseconds()))); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	topicStream := &topicStream{p: p, ns: ns, topic: topic, sub: localSub, readSeq: since}
+	emit := func(m topicMessage) error { return stream.event(topic, m) }
+	if err := topicStream.catchUp(ctx, emit); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-localSub.lagged:
+			if err := topicStream.catchUp(ctx, emit); err != nil {
+				return
+			}
+		case m := <-localSub.msgChan:
+			if err := topicStream.deliver(ctx, m, emit); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := stream.write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sseStream writes Server-Sent Events, flushing after each one.
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	rc      *http.ResponseController
+	buf     bytes.Buffer
+}
+
+// event writes m as a message event.
+func (s *sseStream) event(topic string, m topicMessage) error {
+	data, err := json.Marshal(messageEnvelope(topic, m))
+	if err != nil {
+		return err
+	}
+	s.buf.Reset()
+	if m.Seq > 0 {
+		fmt.Fprintf(&s.buf, "id: %d\n", m.Seq)
+	}
+	s.buf.WriteString("data: ")
+	s.buf.Write(data)
+	s.buf.WriteString("\n\n")
+	return s.write(s.buf.Bytes())
+}
+
+// write sends b and flushes it to the client.
+func (s *sseStream) write(b []byte) error {
+	// Not every writer supports deadlines; the write then blocks until the
+	// connection breaks
+	_ = s.rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
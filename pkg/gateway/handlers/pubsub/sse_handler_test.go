@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+)
+
+// readSSEEvent reads the next event, skipping comments and the retry hint.
+func readSSEEvent(t *testing.T, r *bufio.Reader) (id string, envelope map[string]interface{}) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &envelope); err != nil {
+				t.Fatalf("Failed to decode event data: %v", err)
+			}
+		case line == "" && envelope != nil:
+			return id, envelope
+		}
+	}
+}
+
+func TestSSE_StreamsAndResumes(t *testing.T) {
+	p, _ := newHistoryTestHandlers(t)
+	p.client = &memClient{ps: &memPubSub{handlers: map[string][]client.MessageHandler{}}}
+	makeDurable(t, p, DurableTopicRequest{Topic: "orders"})
+
+	first := publish(t, p, "/v1/pubsub/publish", "")
+	second := publish(t, p, "/v1/pubsub/publish", "")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.SSEHandler(w, r.WithContext(context.WithValue(r.Context(), ctxkeys.NamespaceOverride, "ns")))
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?topic=chat&since=0")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 'since' on a topic without a log to be rejected, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?topic=orders", nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(first.Seq, 10))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+	body := bufio.NewReader(resp.Body)
+
+	// The reconnect resumes after the last event it saw
+	id, env := readSSEEvent(t, body)
+	if id != strconv.FormatInt(second.Seq, 10) || env["message_id"] != second.ID || env["topic"] != "orders" || env["data"] != "aGk=" {
+		t.Fatalf("Expected the replay to start after Last-Event-ID, got id %q %+v", id, env)
+	}
+
+	// The subscriber is registered before the replay, so this arrives live
+	live := publish(t, p, "/v1/pubsub/publish", "")
+	id, env = readSSEEvent(t, body)
+	if id != strconv.FormatInt(live.Seq, 10) || env["message_id"] != live.ID || env["from"] != "0xwallet" {
+		t.Fatalf("Expected the live message once, got id %q %+v", id, env)
+	}
+}
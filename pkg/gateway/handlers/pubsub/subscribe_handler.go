@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"github.com/DeBrosOfficial/network/pkg/pubsub"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -16,7 +17,9 @@ import (
 
 // WebsocketHandler upgrades to WS, subscribes to a namespaced topic, and
 // forwards received PubSub messages to the client. Messages sent by the client
-// are published to the same namespaced topic.
+// are published to the same namespaced topic. On durable topics ?since=<seq>
+// first replays the stored messages after seq. Without a topic parameter the
+// connection speaks the multi-topic protocol instead (see serveSession).
 func (p *PubSubHandlers) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
 	if p.client == nil {
 		p.logger.ComponentWarn("gateway", "pubsub ws: client not initialized")
@@ -39,8 +42,18 @@ func (p *PubSubHandlers) WebsocketHandler(w http.ResponseWriter, r *http.Request
 
 	topic := r.URL.Query().Get("topic")
 	if topic == "" {
-		p.logger.ComponentWarn("gateway", "pubsub ws: missing topic")
-		writeError(w, http.StatusBadRequest, "missing 'topic'")
+		p.serveSession(w, r, ns)
+		return
+	}
+	if isPattern(topic) {
+		writeError(w, http.StatusBadRequest, "wildcard topics require the multi-topic protocol (omit 'topic')")
+		return
+	}
+	if !validTopic(topic) {
+		writeError(w, http.StatusBadRequest, "invalid topic")
+		return
+	}
+	if !p.allowHTTP(w, r, ns, topic, aclSubscribe) {
 		return
 	}
 
@@ -59,6 +72,24 @@ func (p *PubSubHandlers) WebsocketHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	since, err := parseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if since >= 0 {
+		dt, err := p.durableTopic(r.Context(), ns, topic)
+		if err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub ws: durable topic lookup failed", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to look up topic")
+			return
+		}
+		if dt == nil {
+			writeError(w, http.StatusBadRequest, "topic is not durable; 'since' requires a durable topic")
+			return
+		}
+	}
+
 	conn, err := wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		p.logger.ComponentWarn("gateway", "pubsub ws: upgrade failed")
@@ -67,39 +98,27 @@ func (p *PubSubHandlers) WebsocketHandler(w http.ResponseWriter, r *http.Request
 	defer conn.Close()
 
 	// Channel to deliver PubSub messages to WS writer
-	msgs := make(chan []byte, 128)
+	msgs := make(chan topicMessage, 128)
 
-	// Register as local subscriber for direct message delivery
+	// Register as local subscriber for direct message delivery. This happens
+	// before any replay so no message falls between history and live delivery.
 	localSub := &localSubscriber{
 		msgChan:   msgs,
 		namespace: ns,
+		lagged:    make(chan struct{}, 1),
 	}
 	topicKey := fmt.Sprintf("%s.%s", ns, topic)
-
-	p.mu.Lock()
-	p.localSubscribers[topicKey] = append(p.localSubscribers[topicKey], localSub)
-	subscriberCount := len(p.localSubscribers[topicKey])
-	p.mu.Unlock()
+	subscriberCount := p.addLocalSubscriber(topicKey, localSub)
 
 	connID := uuid.New().String()
 	if enablePresence {
-		member := PresenceMember{
+		// Broadcasts a join event unless the member is already connected elsewhere
+		p.joinPresence(ns, topic, PresenceMember{
 			MemberID: memberID,
 			JoinedAt: time.Now().Unix(),
 			Meta:     memberMeta,
 			ConnID:   connID,
-		}
-
-		p.presenceMu.Lock()
-		p.presenceMembers[topicKey] = append(p.presenceMembers[topicKey], member)
-		p.presenceMu.Unlock()
-
-		// Broadcast join event (will be received via PubSub by others AND via local delivery)
-		p.broadcastPresenceEvent(ns, topic, "presence.join", memberID, memberMeta, member.JoinedAt)
-
-		p.logger.ComponentInfo("gateway", "pubsub ws: member joined presence",
-			zap.String("topic", topic),
-			zap.String("member_id", memberID))
+		})
 	}
 
 	p.logger.ComponentInfo("gateway", "pubsub ws: registered local subscriber",
@@ -109,40 +128,10 @@ func (p *PubSubHandlers) WebsocketHandler(w http.ResponseWriter, r *http.Request
 
 	// Unregister on close
 	defer func() {
-		p.mu.Lock()
-		subs := p.localSubscribers[topicKey]
-		for i, sub := range subs {
-			if sub == localSub {
-				p.localSubscribers[topicKey] = append(subs[:i], subs[i+1:]...)
-				break
-			}
-		}
-		remainingCount := len(p.localSubscribers[topicKey])
-		if remainingCount == 0 {
-			delete(p.localSubscribers, topicKey)
-		}
-		p.mu.Unlock()
+		remainingCount := p.removeLocalSubscriber(topicKey, localSub)
 
 		if enablePresence {
-			p.presenceMu.Lock()
-			members := p.presenceMembers[topicKey]
-			for i, m := range members {
-				if m.ConnID == connID {
-					p.presenceMembers[topicKey] = append(members[:i], members[i+1:]...)
-					break
-				}
-			}
-			if len(p.presenceMembers[topicKey]) == 0 {
-				delete(p.presenceMembers, topicKey)
-			}
-			p.presenceMu.Unlock()
-
-			// Broadcast leave event
-			p.broadcastPresenceEvent(ns, topic, "presence.leave", memberID, nil, time.Now().Unix())
-
-			p.logger.ComponentInfo("gateway", "pubsub ws: member left presence",
-				zap.String("topic", topic),
-				zap.String("member_id", memberID))
+			p.leavePresence(ns, topic, connID)
 		}
 
 		p.logger.ComponentInfo("gateway", "pubsub ws: unregistered local subscriber",
@@ -158,17 +147,24 @@ func (p *PubSubHandlers) WebsocketHandler(w http.ResponseWriter, r *http.Request
 	// Writer loop - START THIS FIRST before libp2p subscription
 	done := make(chan struct{})
 	wsClient := newWSClient(conn, topic, p.logger)
-	go p.writerLoop(ctx, wsClient, msgs, done)
+	go p.writerLoop(ctx, wsClient, localSub, ns, since, done)
 
 	// Subscribe to libp2p for cross-node messages (in background, non-blocking)
-	go p.libp2pSubscriber(ctx, topic, msgs, done)
+	go p.libp2pSubscriber(ctx, topic, localSub, done)
 
 	// Reader loop: treat any client message as publish to the same topic
-	p.readerLoop(ctx, wsClient, topic, done)
+	presenceConn := ""
+	if enablePresence {
+		presenceConn = connID
+	}
+	p.readerLoop(ctx, wsClient, ns, topic, presenceConn, done)
 }
 
-// writerLoop handles writing messages from the msgs channel to the WebSocket client
-func (p *PubSubHandlers) writerLoop(ctx context.Context, wsClient *wsClient, msgs chan []byte, done chan struct{}) {
+// writerLoop handles writing messages from the subscriber's channel to the
+// WebSocket client. Durable topic messages are sent at most once: a
+// replay from since (if >= 0) comes first, duplicates are skipped, and
+// messages dropped for a slow client are re-read from the topic log.
+func (p *PubSubHandlers) writerLoop(ctx context.Context, wsClient *wsClient, sub *localSubscriber, ns string, since int64, done chan struct{}) {
 	p.logger.ComponentInfo("gateway", "pubsub ws: writer goroutine started",
 		zap.String("topic", wsClient.topic))
 	defer p.logger.ComponentInfo("gateway", "pubsub ws: writer goroutine exiting",
@@ -177,9 +173,21 @@ func (p *PubSubHandlers) writerLoop(ctx context.Context, wsClient *wsClient, msg
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	stream := &topicStream{p: p, ns: ns, topic: wsClient.topic, sub: sub, readSeq: since}
+	if err := stream.catchUp(ctx, wsClient.writeMessage); err != nil {
+		close(done)
+		return
+	}
+
 	for {
 		select {
-		case b, ok := <-msgs:
+		case <-sub.lagged:
+			if err := stream.catchUp(ctx, wsClient.writeMessage); err != nil {
+				close(done)
+				return
+			}
+
+		case m, ok := <-sub.msgChan:
 			if !ok {
 				p.logger.ComponentWarn("gateway", "pubsub ws: message channel closed",
 					zap.String("topic", wsClient.topic))
@@ -188,7 +196,7 @@ func (p *PubSubHandlers) writerLoop(ctx context.Context, wsClient *wsClient, msg
 				return
 			}
 
-			if err := wsClient.writeMessage(b); err != nil {
+			if err := stream.deliver(ctx, m, wsClient.writeMessage); err != nil {
 				close(done)
 				return
 			}
@@ -204,28 +212,132 @@ func (p *PubSubHandlers) writerLoop(ctx context.Context, wsClient *wsClient, msg
 	}
 }
 
-// libp2pSubscriber handles subscribing to libp2p pubsub for cross-node messages
-func (p *PubSubHandlers) libp2pSubscriber(ctx context.Context, topic string, msgs chan []byte, done chan struct{}) {
-	h := func(_ string, data []byte) error {
-		p.logger.ComponentInfo("gateway", "pubsub ws: received message from libp2p",
-			zap.String("topic", topic),
-			zap.Int("data_len", len(data)))
+// topicStream sends one subscriber's messages. It skips duplicates (a
+// message may arrive both locally and over libp2p, or be published again
+// with the same idempotency key). On durable topics it replays history from
+// readSeq and re-reads messages dropped for a slow client.
+//
+// Sequence numbers are assigned by whichever gateway stores a message, so
+// live messages published through different gateways may arrive out of
+// order. Every message up to readSeq has been sent; above it, the sequence
+// numbers already sent live are remembered, so a late message is still sent
+// and the next read of the topic log fills in any that never arrived.
+type topicStream struct {
+	p       *PubSubHandlers
+	ns      string
+	topic   string
+	sub     *localSubscriber
+	readSeq int64 // every durable message up to it was sent; -1 before the first
+	sent    map[int64]struct{}
+	recent  recentIDs
+}
 
-		select {
-		case msgs <- data:
-			p.logger.ComponentInfo("gateway", "pubsub ws: forwarded to client",
-				zap.String("topic", topic),
-				zap.String("source", "libp2p"))
+// send emits m unless it was already sent.
+func (s *topicStream) send(m topicMessage, emit func(topicMessage) error) error {
+	if m.ID != "" && s.recent.seen(m.ID) {
+		return nil
+	}
+	if m.Seq > 0 {
+		if s.readSeq < 0 {
+			// A live-only subscriber's log starts at its first durable message
+			s.readSeq = m.Seq - 1
+		}
+		if m.Seq <= s.readSeq {
+			return nil
+		}
+		if _, ok := s.sent[m.Seq]; ok {
+			return nil
+		}
+		if s.sent == nil {
+			s.sent = make(map[int64]struct{})
+		}
+		s.sent[m.Seq] = struct{}{}
+	}
+	return emit(m)
+}
+
+// catchUp emits the stored messages after readSeq that were not sent yet,
+// then advances readSeq past them.
+func (s *topicStream) catchUp(ctx context.Context, emit func(topicMessage) error) error {
+	if s.p.config.DB == nil {
+		s.sent = nil
+		return nil
+	}
+	if s.readSeq < 0 {
+		return nil
+	}
+	for {
+		page, err := s.p.readHistory(ctx, s.ns, s.topic, s.readSeq, historyPageSize)
+		if err != nil {
+			s.p.logger.ComponentWarn("gateway", "pubsub ws: failed to read topic history",
+				zap.String("topic", s.topic),
+				zap.Error(err))
 			return nil
-		default:
-			// Drop if client is slow to avoid blocking network
-			p.logger.ComponentWarn("gateway", "pubsub ws: client slow, dropping message",
-				zap.String("topic", topic))
+		}
+		for _, m := range page {
+			if err := s.send(m, emit); err != nil {
+				return err
+			}
+		}
+		if len(page) > 0 {
+			s.readSeq = page[len(page)-1].Seq
+			for seq := range s.sent {
+				if seq <= s.readSeq {
+					delete(s.sent, seq)
+				}
+			}
+		}
+		if len(page) < historyPageSize {
 			return nil
 		}
 	}
+}
+
+// deliver emits a live message, first catching up if a message before it
+// was dropped. Once many live messages were sent since the log was last
+// read, it is read again so that messages lost out of order are filled in.
+func (s *topicStream) deliver(ctx context.Context, m topicMessage, emit func(topicMessage) error) error {
+	select {
+	case <-s.sub.lagged:
+		if err := s.catchUp(ctx, emit); err != nil {
+			return err
+		}
+	default:
+	}
+	if err := s.send(m, emit); err != nil {
+		return err
+	}
+	if len(s.sent) >= recentMessageIDs {
+		return s.catchUp(ctx, emit)
+	}
+	return nil
+}
+
+// run replays history and then emits live messages until ctx is done or
+// emit fails.
+func (s *topicStream) run(ctx context.Context, emit func(topicMessage) error) error {
+	if err := s.catchUp(ctx, emit); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.sub.lagged:
+			if err := s.catchUp(ctx, emit); err != nil {
+				return err
+			}
+		case m := <-s.sub.msgChan:
+			if err := s.deliver(ctx, m, emit); err != nil {
+				return err
+			}
+		}
+	}
+}
 
-	if err := p.client.PubSub().Subscribe(ctx, topic, h); err != nil {
+// libp2pSubscriber handles subscribing to libp2p pubsub for cross-node messages
+func (p *PubSubHandlers) libp2pSubscriber(ctx context.Context, topic string, sub *localSubscriber, done chan struct{}) {
+	if err := p.client.PubSub().Subscribe(ctx, topic, p.meshHandler(topic, sub)); err != nil {
 		p.logger.ComponentWarn("gateway", "pubsub ws: libp2p subscribe failed (will use local-only)",
 			zap.String("topic", topic),
 			zap.Error(err))
@@ -241,8 +353,33 @@ func (p *PubSubHandlers) libp2pSubscriber(ctx context.Context, topic string, msg
 		zap.String("topic", topic))
 }
 
-// readerLoop handles reading messages from the WebSocket client and publishing them
-func (p *PubSubHandlers) readerLoop(ctx context.Context, wsClient *wsClient, topic string, done chan struct{}) {
+// meshHandler returns the libp2p handler that forwards topic messages to sub.
+func (p *PubSubHandlers) meshHandler(topic string, sub *localSubscriber) func(string, []byte) error {
+	return func(_ string, data []byte) error {
+		p.logger.ComponentInfo("gateway", "pubsub ws: received message from libp2p",
+			zap.String("topic", topic),
+			zap.Int("data_len", len(data)))
+
+		if sub.deliver(p.decodeMeshMessage(sub.namespace, topic, data)) {
+			p.logger.ComponentInfo("gateway", "pubsub ws: forwarded to client",
+				zap.String("topic", topic),
+				zap.String("source", "libp2p"))
+			return nil
+		}
+		// Drop if client is slow to avoid blocking network
+		p.logger.ComponentWarn("gateway", "pubsub ws: client slow, dropping message",
+			zap.String("topic", topic))
+		return nil
+	}
+}
+
+// readerLoop handles reading messages from the WebSocket client and publishing them.
+// Publishes refused by the topic policy get a JSON error frame with a code
+// (see PublishError); other failures get a "publish_error" text frame.
+// With presence enabled (connID set), {"type":"presence.update","meta":{...}}
+// frames replace the member's metadata instead of being published.
+func (p *PubSubHandlers) readerLoop(ctx context.Context, wsClient *wsClient, ns, topic, connID string, done chan struct{}) {
+	var limiter publishLimiter
 	for {
 		mt, data, err := wsClient.readMessage()
 		if err != nil {
@@ -260,12 +397,45 @@ func (p *PubSubHandlers) readerLoop(ctx context.Context, wsClient *wsClient, top
 				p.logger.ComponentInfo("gateway", "pubsub ws: filtering out heartbeat ping")
 				continue
 			}
+			if msgType, ok := msg["type"].(string); ok && msgType == "presence.update" && connID != "" {
+				meta, _ := msg["meta"].(map[string]interface{})
+				p.updatePresence(ns, topic, connID, meta)
+				continue
+			}
 		}
 
-		if err := p.client.PubSub().Publish(ctx, topic, data); err != nil {
+		if err := p.authorize(ctx, ns, topic, aclPublish); err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub ws: publish refused",
+				zap.String("topic", topic),
+				zap.Error(err))
+			_ = wsClient.writeText([]byte("publish_error"))
+			continue
+		}
+		// Refusals by the topic policy are reported as error frames
+		if err := p.admitPublish(ctx, ns, topic, data, &limiter); err != nil {
+			f := errorFrame("", err)
+			f.Topic = topic
+			if frame, jerr := json.Marshal(f); jerr == nil {
+				_ = wsClient.writeText(frame)
+			}
+			continue
+		}
+		stamped, err := p.stamp(ctx, ns, topic, "", data)
+		if err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub ws: failed to store message",
+				zap.String("topic", topic),
+				zap.Error(err))
+			_ = wsClient.writeText([]byte("publish_error"))
+			continue
+		}
+		p.noteTopic(ns, topic, true)
+		if err := p.client.PubSub().Publish(ctx, topic, p.meshPayload(ns, topic, stamped)); err != nil {
 			// Best-effort notify client
-			_ = wsClient.conn.WriteMessage(websocket.TextMessage, []byte("publish_error"))
+			_ = wsClient.writeText([]byte("publish_error"))
+			continue
 		}
+		metering.Record(ctx, metering.PubSubMessages, 1)
+		p.published(ns, topic, stamped)
 	}
 	<-done
 }
@@ -301,10 +471,7 @@ func (p *PubSubHandlers) broadcastPresenceEventExcluding(ns, topic, eventType, m
 		// Skip the excluded connection if specified
 		// Note: We don't have direct access to connID in localSubscriber, so we use a different approach
 		// The excluded client already received its own event directly, so this is best-effort
-		select {
-		case sub.msgChan <- eventData:
-		default:
-			// Channel full, skip (client will see it via PubSub if they're still subscribed)
-		}
+		// Channel full, skip (client will see it via PubSub if they're still subscribed)
+		sub.deliver(topicMessage{Data: eventData, Time: time.Now()})
 	}
 }
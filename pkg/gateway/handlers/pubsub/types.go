@@ -1,54 +1,319 @@
 package pubsub
 
 import (
+	"encoding/json"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/DeBrosOfficial/network/pkg/client"
 	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/olric"
 )
 
+// Config holds configuration for pubsub handlers
+type Config struct {
+	// Olric returns the cache client used to share presence across gateways;
+	// presence is per gateway when it is nil or returns nil
+	Olric func() *olric.Client
+	// DB stores the logs of durable topics; durable topics are unavailable without it
+	DB DB
+	// Retention is how long durable topic messages are kept unless the topic
+	// sets its own (default: 24h)
+	Retention time.Duration
+	// MaxMessages is how many messages a durable topic keeps unless the topic
+	// sets its own (default: 10000)
+	MaxMessages int
+	// MaxPayload caps message data in bytes unless the topic sets its own
+	// (default and upper bound: DefaultMaxPayload)
+	MaxPayload int
+	// PublishRate caps the messages per second a connection may publish to
+	// a topic unless the topic sets its own (default: unlimited)
+	PublishRate int
+	// OnPublish is called with every message published through this gateway,
+	// once it is accepted. It must not block.
+	OnPublish func(PublishedMessage)
+	// EnvelopeKey signs the envelopes carrying message IDs, sequence numbers
+	// and publishers between gateways; it must match on every gateway. If
+	// empty, a key stored in DB is used.
+	EnvelopeKey []byte
+}
+
+// PublishedMessage is a message published through this gateway
+type PublishedMessage struct {
+	Namespace string
+	Topic     string
+	ID        string
+	Seq       int64 // 0 unless the topic is durable
+	Data      []byte
+	From      string // publisher identity attested by the gateway
+	Time      time.Time
+}
+
 // PubSubHandlers handles all pubsub-related HTTP and WebSocket endpoints
 type PubSubHandlers struct {
 	client client.NetworkClient
 	logger *logging.ColoredLogger
+	config Config
 
 	// Local pub/sub bypass for same-gateway subscribers
 	localSubscribers map[string][]*localSubscriber // topic+namespace -> subscribers
-	presenceMembers  map[string][]PresenceMember   // topicKey -> members
+	presenceMembers  map[string][]PresenceMember   // topicKey -> this gateway's connections
 	mu               sync.RWMutex
 	presenceMu       sync.RWMutex
+
+	// Presence shared with other gateways, kept fresh by presenceKeeper
+	presence       presenceStore
+	presenceKeeper *presenceKeeper
+
+	// Durable topic settings cached by topicKey
+	durable   map[string]cachedDurable
+	durableMu sync.Mutex
+	pruner    *historyPruner
+
+	// Topics seen per namespace, and multi-topic sessions with wildcard
+	// subscriptions per namespace, for resolving wildcards
+	knownTopics     map[string]map[string]*knownTopic
+	patternSessions map[string]map[*wsSession]struct{}
+	topicsMu        sync.Mutex
+
+	// Idempotency keys of publishes while Olric is unavailable
+	idempotency *memIdempotencyStore
+
+	// Topic ACLs cached by namespace, and API key scopes by key fingerprint
+	acls   map[string]cachedACL
+	scopes map[string]cachedScopes
+	aclMu  sync.Mutex
+
+	// Topic policies (schemas and limits) cached by namespace
+	policies map[string]cachedPolicies
+	policyMu sync.Mutex
+
+	// Cluster envelope key loaded from DB, and this gateway's own key used
+	// until it is available
+	envelopeKeyCache []byte
+	envelopeKeyTried time.Time
+	localEnvelopeKey []byte
+	envelopeMu       sync.Mutex
 }
 
 // NewPubSubHandlers creates a new PubSubHandlers instance
-func NewPubSubHandlers(client client.NetworkClient, logger *logging.ColoredLogger) *PubSubHandlers {
-	return &PubSubHandlers{
+func NewPubSubHandlers(client client.NetworkClient, logger *logging.ColoredLogger, config Config) *PubSubHandlers {
+	p := &PubSubHandlers{
 		client:           client,
 		logger:           logger,
+		config:           config,
 		localSubscribers: make(map[string][]*localSubscriber),
 		presenceMembers:  make(map[string][]PresenceMember),
+		durable:          make(map[string]cachedDurable),
+		knownTopics:      make(map[string]map[string]*knownTopic),
+		patternSessions:  make(map[string]map[*wsSession]struct{}),
+		idempotency:      newMemIdempotencyStore(),
+		acls:             make(map[string]cachedACL),
+		scopes:           make(map[string]cachedScopes),
+		policies:         make(map[string]cachedPolicies),
+		localEnvelopeKey: newEnvelopeKey(),
+	}
+	if config.DB != nil {
+		p.pruner = newHistoryPruner(p)
+	}
+	if config.Olric != nil {
+		p.presence = &olricPresenceStore{client: config.Olric}
+		p.presenceKeeper = newPresenceKeeper(p)
 	}
+	return p
+}
+
+// Close stops background work of the handlers.
+func (p *PubSubHandlers) Close() {
+	p.pruner.Close()
+	p.presenceKeeper.Close()
 }
 
 // localSubscriber represents a local websocket subscriber on this gateway node
 type localSubscriber struct {
-	msgChan   chan []byte
+	msgChan   chan topicMessage
 	namespace string
+	// lagged is signalled when a durable topic message is dropped because the
+	// subscriber is slow; the writer then catches up from the topic log
+	lagged chan struct{}
+}
+
+// deliver queues m without blocking and reports whether it was queued.
+func (s *localSubscriber) deliver(m topicMessage) bool {
+	select {
+	case s.msgChan <- m:
+		return true
+	default:
+		if m.Seq > 0 && s.lagged != nil {
+			select {
+			case s.lagged <- struct{}{}:
+			default:
+			}
+		}
+		return false
+	}
 }
 
 // PresenceMember represents a member in a topic's presence list
 type PresenceMember struct {
-	MemberID string                 `json:"member_id"`
-	JoinedAt int64                  `json:"joined_at"` // Unix timestamp
-	Meta     map[string]interface{} `json:"meta,omitempty"`
-	ConnID   string                 `json:"-"` // Internal: for tracking which connection
+	MemberID    string                 `json:"member_id"`
+	JoinedAt    int64                  `json:"joined_at"` // Unix timestamp
+	Meta        map[string]interface{} `json:"meta,omitempty"`
+	Connections int                    `json:"connections,omitempty"` // Open connections across the cluster
+	ConnID      string                 `json:"-"`                     // Internal: for tracking which connection
+	updatedAt   int64                  // Unix milliseconds of the last metadata change
 }
 
 // PublishRequest represents the request body for publishing a message
 type PublishRequest struct {
 	Topic   string `json:"topic"`
 	DataB64 string `json:"data_base64"`
+	// IdempotencyKey makes retries of the same publish deliver once; the
+	// Idempotency-Key header takes precedence
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// PublishResponse is the response of POST /v1/pubsub/publish
+type PublishResponse struct {
+	Status    string `json:"status"`
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+	Seq       int64  `json:"seq,omitempty"`
+	// Duplicate is set when the idempotency key was used before; nothing was
+	// published and the first publish is described
+	Duplicate bool `json:"duplicate,omitempty"`
+	// Set with ?ack=true once the message was delivered locally and sent to
+	// the mesh; MeshPeers is omitted when the node cannot report it
+	Acked          bool `json:"acked,omitempty"`
+	LocalDelivered *int `json:"local_delivered,omitempty"`
+	MeshPeers      *int `json:"mesh_peers,omitempty"`
+}
+
+// DurableTopic describes a topic whose messages are kept for history and replay
+type DurableTopic struct {
+	Topic       string    `json:"topic"`
+	Retention   int64     `json:"retention"` // seconds
+	MaxMessages int       `json:"max_messages"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DurableTopicRequest is the body of PUT /v1/pubsub/durable. Zero values use
+// the gateway defaults.
+type DurableTopicRequest struct {
+	Topic       string `json:"topic"`
+	Retention   int64  `json:"retention,omitempty"` // seconds
+	MaxMessages int    `json:"max_messages,omitempty"`
+}
+
+// TopicACL restricts who may publish or subscribe to a topic, or to the
+// topics a wildcard pattern matches. Principals are "*", "wallet:<address>",
+// "apikey:<fingerprint>", "scope:<api key scope>" or "role:<role>"; a nil list
+// allows anyone.
+type TopicACL struct {
+	Topic     string    `json:"topic"`
+	Publish   []string  `json:"publish"`
+	Subscribe []string  `json:"subscribe"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+}
+
+// TopicACLRequest is the body of PUT /v1/pubsub/acl. An omitted list allows
+// anyone and an empty one nobody.
+type TopicACLRequest struct {
+	Topic     string   `json:"topic"`
+	Publish   []string `json:"publish"`
+	Subscribe []string `json:"subscribe"`
+}
+
+// PubSubRole is a named set of principals that topic ACLs can refer to
+type PubSubRole struct {
+	Role      string    `json:"role"`
+	Members   []string  `json:"members"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+}
+
+// PubSubRoleRequest is the body of PUT /v1/pubsub/roles
+type PubSubRoleRequest struct {
+	Role    string   `json:"role"`
+	Members []string `json:"members"`
+}
+
+// TopicPolicy constrains the messages published to a topic, or to the topics
+// a wildcard pattern matches. Zero limits use the gateway defaults.
+type TopicPolicy struct {
+	Topic       string          `json:"topic"`
+	Schema      json.RawMessage `json:"schema,omitempty"`       // JSON Schema the message data must satisfy
+	MaxPayload  int             `json:"max_payload,omitempty"`  // bytes
+	PublishRate int             `json:"publish_rate,omitempty"` // messages per second per connection
+	UpdatedAt   time.Time       `json:"updated_at"`
+	UpdatedBy   string          `json:"updated_by,omitempty"`
+}
+
+// TopicPolicyRequest is the body of PUT /v1/pubsub/policies
+type TopicPolicyRequest struct {
+	Topic       string          `json:"topic"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	MaxPayload  int             `json:"max_payload,omitempty"`
+	PublishRate int             `json:"publish_rate,omitempty"`
+}
+
+// PublishError is the response to a publish refused by a topic policy
+type PublishError struct {
+	Error      string   `json:"error"`
+	Code       string   `json:"code"`                  // payload_too_large, invalid_json, schema_violation or rate_limited
+	Details    []string `json:"details,omitempty"`     // schema violations, as "<JSON pointer>: <problem>"
+	RetryAfter int      `json:"retry_after,omitempty"` // seconds, when rate limited
+}
+
+// HistoryMessage is a stored message of a durable topic
+type HistoryMessage struct {
+	Seq       int64  `json:"seq"`
+	MessageID string `json:"message_id,omitempty"`
+	Topic     string `json:"topic"`
+	Data      string `json:"data"`      // base64
+	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+	From      string `json:"from,omitempty"`
+}
+
+// HistoryResponse is the response of GET /v1/pubsub/history
+type HistoryResponse struct {
+	Topic     string           `json:"topic"`
+	Messages  []HistoryMessage `json:"messages"`
+	Count     int              `json:"count"`
+	NextSince int64            `json:"next_since"`
+	HasMore   bool             `json:"has_more"`
+}
+
+// addLocalSubscriber registers sub for topicKey and returns the number of
+// local subscribers of the topic.
+func (p *PubSubHandlers) addLocalSubscriber(topicKey string, sub *localSubscriber) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.localSubscribers[topicKey] = append(p.localSubscribers[topicKey], sub)
+	return len(p.localSubscribers[topicKey])
+}
+
+// removeLocalSubscriber unregisters sub and returns the number of remaining
+// local subscribers of the topic.
+func (p *PubSubHandlers) removeLocalSubscriber(topicKey string, sub *localSubscriber) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subs := p.localSubscribers[topicKey]
+	for i, s := range subs {
+		if s == sub {
+			p.localSubscribers[topicKey] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	remaining := len(p.localSubscribers[topicKey])
+	if remaining == 0 {
+		delete(p.localSubscribers, topicKey)
+	}
+	return remaining
 }
 
 // getLocalSubscribers returns local subscribers for a given topic and namespace
@@ -0,0 +1,207 @@
+package pubsub
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
+	"go.uber.org/zap"
+)
+
+const (
+	// topicAnnounceTopic carries the names of topics published through each
+	// gateway, so gateways can resolve wildcards against remote topics.
+	topicAnnounceTopic = pubsub.ReservedTopicPrefix + "topics"
+
+	// topicAnnounceInterval is how often a topic in use is announced again,
+	// so gateways that started watching since learn about it.
+	topicAnnounceInterval = time.Minute
+
+	// topicIndexTTL is how long a topic stays known after it was last seen.
+	topicIndexTTL = 30 * time.Minute
+
+	maxTopicLength = 256
+)
+
+// knownTopic tracks a topic seen in a namespace.
+type knownTopic struct {
+	seen      time.Time
+	announced time.Time
+}
+
+// isPattern reports whether topic contains wildcard segments.
+func isPattern(topic string) bool {
+	for _, seg := range strings.Split(topic, ".") {
+		if seg == "*" || seg == ">" {
+			return true
+		}
+	}
+	return false
+}
+
+// validTopic reports whether topic (or pattern) is acceptable. A ">" segment
+// may only end a pattern, and topics reserved for gateways are refused.
+func validTopic(topic string) bool {
+	if topic == "" || len(topic) > maxTopicLength || pubsub.IsReservedTopic(topic) {
+		return false
+	}
+	segs := strings.Split(topic, ".")
+	for i, seg := range segs {
+		if seg == "" || (seg == ">" && i != len(segs)-1) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTopic reports whether topic matches pattern. Segments are separated by
+// dots; "*" matches exactly one segment and a final ">" matches one or more.
+func matchTopic(pattern, topic string) bool {
+	ps, ts := strings.Split(pattern, "."), strings.Split(topic, ".")
+	for i, seg := range ps {
+		if seg == ">" && i == len(ps)-1 {
+			return len(ts) > i
+		}
+		if i >= len(ts) || (seg != "*" && seg != ts[i]) {
+			return false
+		}
+	}
+	return len(ps) == len(ts)
+}
+
+// matchTopics returns the known topics of ns that match pattern: topics seen
+// on this gateway or announced by others, and topics the node subscribes to.
+func (p *PubSubHandlers) matchTopics(ctx context.Context, ns, pattern string) []string {
+	set := map[string]bool{}
+	now := time.Now()
+
+	p.topicsMu.Lock()
+	for topic, kt := range p.knownTopics[ns] {
+		if now.Sub(kt.seen) > topicIndexTTL {
+			delete(p.knownTopics[ns], topic)
+			continue
+		}
+		if matchTopic(pattern, topic) {
+			set[topic] = true
+		}
+	}
+	p.topicsMu.Unlock()
+
+	if p.client != nil {
+		subscribed, err := p.client.PubSub().ListTopics(ctx)
+		if err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub ws: failed to list topics", zap.Error(err))
+		}
+		for _, topic := range subscribed {
+			if validTopic(topic) && matchTopic(pattern, topic) {
+				set[topic] = true
+			}
+		}
+	}
+
+	topics := make([]string, 0, len(set))
+	for topic := range set {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// noteTopic records that topic is in use in ns and attaches it to matching
+// wildcard subscriptions on this gateway. Topics published through this
+// gateway are announced so other gateways can resolve their wildcards too.
+func (p *PubSubHandlers) noteTopic(ns, topic string, published bool) {
+	if !validTopic(topic) || isPattern(topic) {
+		return
+	}
+	now := time.Now()
+
+	p.topicsMu.Lock()
+	topics := p.knownTopics[ns]
+	if topics == nil {
+		topics = make(map[string]*knownTopic)
+		p.knownTopics[ns] = topics
+	}
+	kt := topics[topic]
+	isNew := kt == nil
+	if isNew {
+		kt = &knownTopic{}
+		topics[topic] = kt
+	}
+	kt.seen = now
+	announce := published && now.Sub(kt.announced) >= topicAnnounceInterval
+	if announce {
+		kt.announced = now
+	}
+	sessions := make([]*wsSession, 0, len(p.patternSessions[ns]))
+	for s := range p.patternSessions[ns] {
+		sessions = append(sessions, s)
+	}
+	p.topicsMu.Unlock()
+
+	if isNew {
+		for _, s := range sessions {
+			s.topicAppeared(topic)
+		}
+	}
+	if announce && p.client != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			ctx = pubsub.WithNamespace(client.WithInternalAuth(ctx), ns)
+			if err := p.client.PubSub().Publish(ctx, topicAnnounceTopic, []byte(topic)); err != nil {
+				p.logger.ComponentDebug("gateway", "pubsub: topic announcement failed",
+					zap.String("topic", topic),
+					zap.Error(err))
+			}
+		}()
+	}
+}
+
+// watchTopics registers a session with wildcard subscriptions. The first
+// session of a namespace subscribes to topic announcements.
+func (p *PubSubHandlers) watchTopics(s *wsSession) {
+	p.topicsMu.Lock()
+	sessions := p.patternSessions[s.ns]
+	if sessions == nil {
+		sessions = make(map[*wsSession]struct{})
+		p.patternSessions[s.ns] = sessions
+	}
+	sessions[s] = struct{}{}
+	first := len(sessions) == 1
+	p.topicsMu.Unlock()
+
+	if first && p.client != nil {
+		ns := s.ns
+		ctx := pubsub.WithNamespace(client.WithInternalAuth(context.Background()), ns)
+		h := func(_ string, data []byte) error {
+			p.noteTopic(ns, string(data), false)
+			return nil
+		}
+		if err := p.client.PubSub().Subscribe(ctx, topicAnnounceTopic, h); err != nil {
+			p.logger.ComponentWarn("gateway", "pubsub ws: failed to watch topic announcements",
+				zap.String("namespace", ns),
+				zap.Error(err))
+		}
+	}
+}
+
+// unwatchTopics reverses watchTopics.
+func (p *PubSubHandlers) unwatchTopics(s *wsSession) {
+	p.topicsMu.Lock()
+	sessions := p.patternSessions[s.ns]
+	delete(sessions, s)
+	last := len(sessions) == 0
+	if last {
+		delete(p.patternSessions, s.ns)
+	}
+	p.topicsMu.Unlock()
+
+	if last && p.client != nil {
+		ctx := pubsub.WithNamespace(client.WithInternalAuth(context.Background()), s.ns)
+		_ = p.client.PubSub().Unsubscribe(ctx, topicAnnounceTopic)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/DeBrosOfficial/network/pkg/logging"
@@ -23,10 +24,13 @@ type wsClient struct {
 	conn   *websocket.Conn
 	topic  string
 	logger *logging.ColoredLogger
+	// writeMu serializes data frames from the writer and reader loops
+	writeMu sync.Mutex
 }
 
 // newWSClient creates a new WebSocket client wrapper
 func newWSClient(conn *websocket.Conn, topic string, logger *logging.ColoredLogger) *wsClient {
+	conn.SetReadLimit(maxFrameSize)
 	return &wsClient{
 		conn:   conn,
 		topic:  topic,
@@ -35,19 +39,12 @@ func newWSClient(conn *websocket.Conn, topic string, logger *logging.ColoredLogg
 }
 
 // writeMessage sends a message to the WebSocket client with proper envelope formatting
-func (c *wsClient) writeMessage(data []byte) error {
+func (c *wsClient) writeMessage(m topicMessage) error {
 	c.logger.ComponentInfo("gateway", "pubsub ws: sending message to client",
 		zap.String("topic", c.topic),
-		zap.Int("data_len", len(data)))
+		zap.Int("data_len", len(m.Data)))
 
-	// Format message as JSON envelope with data (base64 encoded), timestamp, and topic
-	// This matches the SDK's Message interface: {data: string, timestamp: number, topic: string}
-	envelope := map[string]interface{}{
-		"data":      base64.StdEncoding.EncodeToString(data),
-		"timestamp": time.Now().UnixMilli(),
-		"topic":     c.topic,
-	}
-	envelopeJSON, err := json.Marshal(envelope)
+	envelopeJSON, err := json.Marshal(messageEnvelope(c.topic, m))
 	if err != nil {
 		c.logger.ComponentWarn("gateway", "pubsub ws: failed to marshal envelope",
 			zap.String("topic", c.topic),
@@ -59,8 +56,7 @@ func (c *wsClient) writeMessage(data []byte) error {
 		zap.String("topic", c.topic),
 		zap.Int("envelope_len", len(envelopeJSON)))
 
-	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
-	if err := c.conn.WriteMessage(websocket.TextMessage, envelopeJSON); err != nil {
+	if err := c.writeText(envelopeJSON); err != nil {
 		c.logger.ComponentWarn("gateway", "pubsub ws: failed to write to websocket",
 			zap.String("topic", c.topic),
 			zap.Error(err))
@@ -72,6 +68,40 @@ func (c *wsClient) writeMessage(data []byte) error {
 	return nil
 }
 
+// messageEnvelope formats a message for single-topic subscribers as a JSON
+// envelope with data (base64 encoded), timestamp, and topic. This matches the
+// SDK's Message interface: {data: string, timestamp: number, topic: string}
+func messageEnvelope(topic string, m topicMessage) map[string]interface{} {
+	ts := m.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	envelope := map[string]interface{}{
+		"data":      base64.StdEncoding.EncodeToString(m.Data),
+		"timestamp": ts.UnixMilli(),
+		"topic":     topic,
+	}
+	// Durable topic messages carry their sequence number for ?since= resumes
+	if m.Seq > 0 {
+		envelope["seq"] = m.Seq
+	}
+	if m.ID != "" {
+		envelope["message_id"] = m.ID
+	}
+	if m.From != "" {
+		envelope["from"] = m.From
+	}
+	return envelope
+}
+
+// writeText sends a text frame
+func (c *wsClient) writeText(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
 // writeControl sends a WebSocket control message
 func (c *wsClient) writeControl(messageType int, data []byte, deadline time.Time) error {
 	return c.conn.WriteControl(messageType, data, deadline)
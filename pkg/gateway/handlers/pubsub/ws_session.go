@@ -0,0 +1,509 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/metering"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Frame types of the multi-topic WebSocket protocol. Clients send subscribe,
+// unsubscribe, publish, presence and ping frames; the gateway answers each
+// with an ack (or error) carrying the same id, and delivers messages in
+// message frames.
+const (
+	frameSubscribe   = "subscribe"
+	frameUnsubscribe = "unsubscribe"
+	framePublish     = "publish"
+	framePresence    = "presence"
+	framePing        = "ping"
+	frameAck         = "ack"
+	frameError       = "error"
+	frameMessage     = "message"
+	framePong        = "pong"
+)
+
+const (
+	// maxSessionSubscriptions caps the topics and patterns a connection subscribes to.
+	maxSessionSubscriptions = 100
+	// maxSessionTopics caps the concrete topics a connection receives, including
+	// all wildcard matches.
+	maxSessionTopics = 1000
+)
+
+var (
+	errTooManySubscriptions = errors.New("too many subscriptions on this connection")
+	errTooManyTopics        = errors.New("wildcard matches too many topics on this connection")
+)
+
+// wsFrame is a message of the multi-topic WebSocket protocol.
+type wsFrame struct {
+	Type      string   `json:"type"`
+	ID        string   `json:"id,omitempty"`
+	Topic     string   `json:"topic,omitempty"`
+	Data      string   `json:"data,omitempty"` // base64
+	Since     *int64   `json:"since,omitempty"`
+	Seq       int64    `json:"seq,omitempty"`
+	Timestamp int64    `json:"timestamp,omitempty"` // Unix milliseconds
+	Topics    []string `json:"topics,omitempty"`    // topics a subscribe matched
+	Error     string   `json:"error,omitempty"`
+
+	// Error frames of refused publishes carry a code (see PublishError)
+	Code       string   `json:"code,omitempty"`
+	Details    []string `json:"details,omitempty"`
+	RetryAfter int      `json:"retry_after,omitempty"` // seconds
+
+	// Presence frames join a subscribed topic as MemberID, or update Meta
+	MemberID string                 `json:"member_id,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+
+	// Message frames carry the envelope of the message
+	MessageID string `json:"message_id,omitempty"`
+	From      string `json:"from,omitempty"`
+}
+
+// wsSession is a WebSocket connection speaking the multi-topic protocol.
+type wsSession struct {
+	p    *PubSubHandlers
+	ns   string
+	conn *websocket.Conn
+
+	// ctx carries internal auth and the namespace; it ends with the connection
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	closed   bool
+	patterns map[string]int64         // subscribed topics and patterns -> since (-1 for none)
+	topics   map[string]*sessionTopic // concrete topic -> delivery
+	presence map[string]string        // topic -> presence connection ID
+	watching bool
+
+	// limiter enforces publish rates; only readLoop publishes
+	limiter publishLimiter
+}
+
+// sessionTopic delivers one concrete topic to a session.
+type sessionTopic struct {
+	sub      *localSubscriber
+	patterns map[string]bool // subscriptions that matched the topic
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// serveSession runs the multi-topic protocol on a WebSocket opened without a
+// topic parameter. One connection may subscribe to many topics and wildcard
+// patterns such as chat.room.* and publish to any topic.
+func (p *PubSubHandlers) serveSession(w http.ResponseWriter, r *http.Request, ns string) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		p.logger.ComponentWarn("gateway", "pubsub ws: upgrade failed")
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxFrameSize)
+
+	ctx, cancel := context.WithCancel(pubsub.WithNamespace(client.WithInternalAuth(r.Context()), ns))
+	s := &wsSession{
+		p:        p,
+		ns:       ns,
+		conn:     conn,
+		ctx:      ctx,
+		cancel:   cancel,
+		patterns: make(map[string]int64),
+		topics:   make(map[string]*sessionTopic),
+		presence: make(map[string]string),
+	}
+	defer s.close()
+
+	p.logger.ComponentInfo("gateway", "pubsub ws: multi-topic session opened",
+		zap.String("namespace", ns))
+
+	go s.keepalive()
+	s.readLoop()
+}
+
+// keepalive pings the client until the session ends.
+func (s *wsSession) keepalive() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(5*time.Second))
+		}
+	}
+}
+
+// write sends a frame; frames from all topics share the connection.
+func (s *wsSession) write(f wsFrame) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	return s.conn.WriteJSON(f)
+}
+
+func (s *wsSession) ack(f wsFrame) {
+	f.Type = frameAck
+	_ = s.write(f)
+}
+
+func (s *wsSession) fail(id string, err error) {
+	_ = s.write(errorFrame(id, err))
+}
+
+// readLoop handles client frames until the connection fails.
+func (s *wsSession) readLoop() {
+	for {
+		mt, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if mt != websocket.TextMessage && mt != websocket.BinaryMessage {
+			continue
+		}
+		var f wsFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			s.fail("", errors.New("invalid frame: expected JSON {type,...}"))
+			continue
+		}
+
+		switch f.Type {
+		case frameSubscribe:
+			topics, err := s.subscribe(f.Topic, f.Since)
+			if err != nil {
+				s.fail(f.ID, err)
+				continue
+			}
+			s.ack(wsFrame{ID: f.ID, Topic: f.Topic, Topics: topics})
+		case frameUnsubscribe:
+			if err := s.unsubscribe(f.Topic); err != nil {
+				s.fail(f.ID, err)
+				continue
+			}
+			s.ack(wsFrame{ID: f.ID, Topic: f.Topic})
+		case framePublish:
+			seq, err := s.publish(f.Topic, f.Data)
+			if err != nil {
+				s.fail(f.ID, err)
+				continue
+			}
+			s.ack(wsFrame{ID: f.ID, Topic: f.Topic, Seq: seq})
+		case framePresence:
+			if err := s.setPresence(f.Topic, f.MemberID, f.Meta); err != nil {
+				s.fail(f.ID, err)
+				continue
+			}
+			s.ack(wsFrame{ID: f.ID, Topic: f.Topic})
+		case framePing:
+			_ = s.write(wsFrame{Type: framePong, ID: f.ID})
+		default:
+			s.fail(f.ID, errors.New("unknown frame type: expected subscribe, unsubscribe, publish, presence or ping"))
+		}
+	}
+}
+
+// subscribe adds a topic or wildcard pattern and returns the concrete topics
+// it currently matches. since replays durable topics as with ?since=.
+func (s *wsSession) subscribe(pattern string, since *int64) ([]string, error) {
+	if !validTopic(pattern) {
+		return nil, errors.New("invalid topic")
+	}
+	from := int64(-1)
+	if since != nil {
+		if *since < 0 {
+			return nil, errors.New("since must be a non-negative sequence number")
+		}
+		from = *since
+	}
+	wildcard := isPattern(pattern)
+	if !wildcard && from >= 0 {
+		dt, err := s.p.durableTopic(s.ctx, s.ns, pattern)
+		if err != nil {
+			return nil, errors.New("failed to look up topic")
+		}
+		if dt == nil {
+			return nil, errors.New("topic is not durable; 'since' requires a durable topic")
+		}
+	}
+
+	topics := []string{pattern}
+	if wildcard {
+		// A pattern only delivers the matching topics the caller may read
+		topics = s.allowedTopics(s.p.matchTopics(s.ctx, s.ns, pattern))
+	} else if err := s.p.authorize(s.ctx, s.ns, pattern, aclSubscribe); err != nil {
+		return nil, aclError(err)
+	}
+
+	s.mu.Lock()
+	if _, ok := s.patterns[pattern]; !ok && len(s.patterns) >= maxSessionSubscriptions {
+		s.mu.Unlock()
+		return nil, errTooManySubscriptions
+	}
+	s.patterns[pattern] = from
+	var err error
+	for _, topic := range topics {
+		if err = s.attach(topic, pattern, from); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		s.removePattern(pattern)
+		s.mu.Unlock()
+		return nil, err
+	}
+	watch := wildcard && !s.watching
+	if watch {
+		s.watching = true
+	}
+	s.mu.Unlock()
+
+	if watch {
+		s.p.watchTopics(s)
+	}
+	s.p.logger.ComponentInfo("gateway", "pubsub ws: session subscribed",
+		zap.String("namespace", s.ns),
+		zap.String("topic", pattern),
+		zap.Int("matched_topics", len(topics)))
+	return topics, nil
+}
+
+// unsubscribe removes a topic or pattern previously subscribed to.
+func (s *wsSession) unsubscribe(pattern string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.patterns[pattern]; !ok {
+		return errors.New("not subscribed")
+	}
+	s.removePattern(pattern)
+	if connID, ok := s.presence[pattern]; ok {
+		delete(s.presence, pattern)
+		go s.p.leavePresence(s.ns, pattern, connID)
+	}
+	return nil
+}
+
+// setPresence joins a subscribed topic's presence as memberID, or updates
+// the metadata once joined.
+func (s *wsSession) setPresence(topic, memberID string, meta map[string]interface{}) error {
+	s.mu.Lock()
+	if _, ok := s.patterns[topic]; !ok || isPattern(topic) {
+		s.mu.Unlock()
+		return errors.New("presence requires a subscription to the topic")
+	}
+	if connID, ok := s.presence[topic]; ok {
+		s.mu.Unlock()
+		s.p.updatePresence(s.ns, topic, connID, meta)
+		return nil
+	}
+	if memberID == "" {
+		s.mu.Unlock()
+		return errors.New("missing 'member_id' for presence")
+	}
+	connID := uuid.New().String()
+	s.presence[topic] = connID
+	s.mu.Unlock()
+
+	s.p.joinPresence(s.ns, topic, PresenceMember{
+		MemberID: memberID,
+		JoinedAt: time.Now().Unix(),
+		Meta:     meta,
+		ConnID:   connID,
+	})
+	return nil
+}
+
+// removePattern drops pattern and the topics only it matched. Callers hold s.mu.
+func (s *wsSession) removePattern(pattern string) {
+	delete(s.patterns, pattern)
+	for topic, st := range s.topics {
+		delete(st.patterns, pattern)
+		if len(st.patterns) == 0 {
+			s.detach(topic, st)
+		}
+	}
+}
+
+// publish sends base64 data to topic and returns its sequence number on
+// durable topics.
+func (s *wsSession) publish(topic, dataB64 string) (int64, error) {
+	if !validTopic(topic) || isPattern(topic) {
+		return 0, errors.New("invalid topic: cannot publish to a wildcard")
+	}
+	if err := s.p.authorize(s.ctx, s.ns, topic, aclPublish); err != nil {
+		return 0, aclError(err)
+	}
+	data, err := base64.StdEncoding.DecodeString(dataB64)
+	if err != nil {
+		return 0, errors.New("invalid base64 data")
+	}
+	if err := s.p.admitPublish(s.ctx, s.ns, topic, data, &s.limiter); err != nil {
+		return 0, err
+	}
+	msg, err := s.p.stamp(s.ctx, s.ns, topic, "", data)
+	if err != nil {
+		s.p.logger.ComponentWarn("gateway", "pubsub ws: failed to store message",
+			zap.String("topic", topic),
+			zap.Error(err))
+		return 0, errors.New("failed to store message")
+	}
+	s.p.noteTopic(s.ns, topic, true)
+	if err := s.p.client.PubSub().Publish(s.ctx, topic, s.p.meshPayload(s.ns, topic, msg)); err != nil {
+		return 0, errors.New("publish failed")
+	}
+	metering.Record(s.ctx, metering.PubSubMessages, 1)
+	s.p.published(s.ns, topic, msg)
+	return msg.Seq, nil
+}
+
+// allowedTopics returns the topics the session may subscribe to.
+func (s *wsSession) allowedTopics(topics []string) []string {
+	allowed := topics[:0]
+	for _, topic := range topics {
+		if s.p.authorize(s.ctx, s.ns, topic, aclSubscribe) == nil {
+			allowed = append(allowed, topic)
+		}
+	}
+	return allowed
+}
+
+// aclError converts an authorization failure into a frame error.
+func aclError(err error) error {
+	if errors.Is(err, errTopicForbidden) {
+		return err
+	}
+	return errors.New("failed to check topic permissions")
+}
+
+// topicAppeared attaches a newly seen topic to matching wildcard patterns.
+func (s *wsSession) topicAppeared(topic string) {
+	if s.p.authorize(s.ctx, s.ns, topic, aclSubscribe) != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	for pattern, since := range s.patterns {
+		if isPattern(pattern) && matchTopic(pattern, topic) {
+			if err := s.attach(topic, pattern, since); err != nil {
+				s.p.logger.ComponentWarn("gateway", "pubsub ws: cannot attach wildcard topic",
+					zap.String("topic", topic),
+					zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// attach starts delivering topic to the session on behalf of pattern.
+// Callers hold s.mu.
+func (s *wsSession) attach(topic, pattern string, since int64) error {
+	if st, ok := s.topics[topic]; ok {
+		st.patterns[pattern] = true
+		return nil
+	}
+	if len(s.topics) >= maxSessionTopics {
+		return errTooManyTopics
+	}
+	// Wildcard replays only apply to the matched topics that are durable
+	if since >= 0 && isPattern(pattern) {
+		if dt, err := s.p.durableTopic(s.ctx, s.ns, topic); err != nil || dt == nil {
+			since = -1
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	st := &sessionTopic{
+		sub: &localSubscriber{
+			msgChan:   make(chan topicMessage, 128),
+			namespace: s.ns,
+			lagged:    make(chan struct{}, 1),
+		},
+		patterns: map[string]bool{pattern: true},
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	s.topics[topic] = st
+	s.p.addLocalSubscriber(s.ns+"."+topic, st.sub)
+
+	// Subscribe before returning so a publish that revealed the topic reaches it
+	if err := s.p.client.PubSub().Subscribe(ctx, topic, s.p.meshHandler(topic, st.sub)); err != nil {
+		s.p.logger.ComponentWarn("gateway", "pubsub ws: libp2p subscribe failed (will use local-only)",
+			zap.String("topic", topic),
+			zap.Error(err))
+	} else {
+		go func() {
+			<-st.done
+			_ = s.p.client.PubSub().Unsubscribe(s.ctx, topic)
+		}()
+	}
+
+	stream := &topicStream{p: s.p, ns: s.ns, topic: topic, sub: st.sub, readSeq: since}
+	go func() {
+		err := stream.run(ctx, func(m topicMessage) error {
+			return s.write(wsFrame{
+				Type:      frameMessage,
+				Topic:     topic,
+				Data:      base64.StdEncoding.EncodeToString(m.Data),
+				Seq:       m.Seq,
+				Timestamp: m.Time.UnixMilli(),
+				MessageID: m.ID,
+				From:      m.From,
+			})
+		})
+		if err != nil && ctx.Err() == nil {
+			// The connection is broken; end the whole session
+			s.cancel()
+			_ = s.conn.Close()
+		}
+	}()
+	return nil
+}
+
+// detach stops delivering topic. Callers hold s.mu.
+func (s *wsSession) detach(topic string, st *sessionTopic) {
+	st.cancel()
+	close(st.done)
+	s.p.removeLocalSubscriber(s.ns+"."+topic, st.sub)
+	delete(s.topics, topic)
+}
+
+// close releases every subscription of the session.
+func (s *wsSession) close() {
+	s.mu.Lock()
+	s.closed = true
+	for topic, st := range s.topics {
+		s.detach(topic, st)
+	}
+	watching := s.watching
+	presence := s.presence
+	s.presence = nil
+	s.mu.Unlock()
+
+	for topic, connID := range presence {
+		s.p.leavePresence(s.ns, topic, connID)
+	}
+
+	if watching {
+		s.p.unwatchTopics(s)
+	}
+	s.cancel()
+	s.p.logger.ComponentInfo("gateway", "pubsub ws: multi-topic session closed",
+		zap.String("namespace", s.ns))
+}
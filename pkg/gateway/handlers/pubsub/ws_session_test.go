@@ -0,0 +1,176 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
+	"github.com/gorilla/websocket"
+)
+
+// memPubSub is an in-process stand-in for the libp2p mesh. Like gossipsub it
+// delivers a node's own publishes to its subscriptions.
+type memPubSub struct {
+	mu       sync.Mutex
+	handlers map[string][]client.MessageHandler
+}
+
+func (m *memPubSub) key(ctx context.Context, topic string) string {
+	ns, _ := ctx.Value(pubsub.CtxKeyNamespaceOverride).(string)
+	return ns + "." + topic
+}
+
+func (m *memPubSub) Subscribe(ctx context.Context, topic string, h client.MessageHandler) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := m.key(ctx, topic)
+	m.handlers[k] = append(m.handlers[k], h)
+	return nil
+}
+
+func (m *memPubSub) Publish(ctx context.Context, topic string, data []byte) error {
+	m.mu.Lock()
+	handlers := append([]client.MessageHandler(nil), m.handlers[m.key(ctx, topic)]...)
+	m.mu.Unlock()
+	for _, h := range handlers {
+		_ = h(topic, data)
+	}
+	return nil
+}
+
+func (m *memPubSub) PublishWithResult(ctx context.Context, topic string, data []byte) (*client.PublishResult, error) {
+	m.mu.Lock()
+	peers := len(m.handlers[m.key(ctx, topic)])
+	m.mu.Unlock()
+	return &client.PublishResult{Peers: peers}, m.Publish(ctx, topic, data)
+}
+
+func (m *memPubSub) Unsubscribe(ctx context.Context, topic string) error { return nil }
+
+func (m *memPubSub) ListTopics(ctx context.Context) ([]string, error) { return nil, nil }
+
+type memClient struct {
+	client.NetworkClient
+	ps *memPubSub
+}
+
+func (c *memClient) PubSub() client.PubSubClient { return c.ps }
+
+func newSessionTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	p := NewPubSubHandlers(&memClient{ps: &memPubSub{handlers: map[string][]client.MessageHandler{}}}, logger, Config{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), ctxkeys.NamespaceOverride, "ns"))
+		p.WebsocketHandler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialSession(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// roundTrip sends f and returns the gateway's reply with the same id.
+func roundTrip(t *testing.T, conn *websocket.Conn, f wsFrame) wsFrame {
+	t.Helper()
+	if err := conn.WriteJSON(f); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+	for {
+		reply := readFrame(t, conn)
+		if reply.ID == f.ID && reply.Type != frameMessage {
+			return reply
+		}
+	}
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) wsFrame {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var f wsFrame
+	if err := conn.ReadJSON(&f); err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	return f
+}
+
+func TestSession_WildcardSubscription(t *testing.T) {
+	srv := newSessionTestServer(t)
+	sub := dialSession(t, srv)
+	pub := dialSession(t, srv)
+
+	for i, topic := range []string{"chat.room.*", "alerts"} {
+		if reply := roundTrip(t, sub, wsFrame{Type: frameSubscribe, ID: string(rune('a' + i)), Topic: topic}); reply.Type != frameAck {
+			t.Fatalf("Expected ack for %s, got %+v", topic, reply)
+		}
+	}
+
+	data := base64.StdEncoding.EncodeToString([]byte("hi"))
+	for i, topic := range []string{"chat.room.1", "chat.lobby", "alerts"} {
+		if reply := roundTrip(t, pub, wsFrame{Type: framePublish, ID: string(rune('a' + i)), Topic: topic, Data: data}); reply.Type != frameAck {
+			t.Fatalf("Expected ack for publish to %s, got %+v", topic, reply)
+		}
+	}
+
+	got := map[string]bool{}
+	for len(got) < 2 {
+		f := readFrame(t, sub)
+		if f.Type != frameMessage || f.Data != data {
+			t.Fatalf("Expected message frame, got %+v", f)
+		}
+		got[f.Topic] = true
+	}
+	if !got["chat.room.1"] || !got["alerts"] || got["chat.lobby"] {
+		t.Errorf("Expected messages from chat.room.1 and alerts only, got %v", got)
+	}
+
+	if reply := roundTrip(t, pub, wsFrame{Type: framePublish, ID: "x", Topic: "chat.*", Data: data}); reply.Type != frameError {
+		t.Errorf("Expected publishing to a wildcard to fail, got %+v", reply)
+	}
+	if reply := roundTrip(t, sub, wsFrame{Type: frameUnsubscribe, ID: "y", Topic: "nope"}); reply.Type != frameError {
+		t.Errorf("Expected unsubscribing an unknown topic to fail, got %+v", reply)
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"chat.room.*", "chat.room.1", true},
+		{"chat.room.*", "chat.room", false},
+		{"chat.room.*", "chat.room.1.typing", false},
+		{"chat.*.typing", "chat.room.typing", true},
+		{"chat.>", "chat.room.1.typing", true},
+		{"chat.>", "chat", false},
+		{"chat", "chat", true},
+	}
+	for _, c := range cases {
+		if got := matchTopic(c.pattern, c.topic); got != c.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", c.pattern, c.topic, got, c.want)
+		}
+	}
+	if validTopic("chat.>.x") || validTopic("a..b") || validTopic("_orama.topics") {
+		t.Error("Expected malformed and reserved topics to be rejected")
+	}
+}
@@ -0,0 +1,536 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Resumable chunked uploads.
+//
+//	POST   /v1/storage/uploads                 create a session
+//	GET    /v1/storage/uploads/{id}            session status (HEAD returns only Upload-Offset)
+//	PUT    /v1/storage/uploads/{id}            append a chunk at Upload-Offset
+//	POST   /v1/storage/uploads/{id}/complete   add the assembled object to IPFS
+//	DELETE /v1/storage/uploads/{id}            abort
+//
+// Chunks are staged on the gateway's local disk, so all requests for a session
+// must reach the gateway that created it. The current offset is the size of the
+// staged file, which keeps sessions consistent across gateway restarts.
+
+const (
+	// DefaultUploadSessionTTL is how long an idle chunked upload is kept.
+	DefaultUploadSessionTTL = 24 * time.Hour
+
+	// DefaultMaxUploadSessions is how many open chunked uploads a namespace may have.
+	DefaultMaxUploadSessions = 16
+
+	// DefaultMaxUploadBytes is how many bytes a namespace may stage across its
+	// chunked uploads (20 GiB).
+	DefaultMaxUploadBytes int64 = 20 << 30
+
+	// uploadOffsetHeader carries the byte offset of a chunk and the session's current offset.
+	uploadOffsetHeader = "Upload-Offset"
+)
+
+var errUploadNotFound = errors.New("upload not found")
+
+// uploadSession is the persisted state of a chunked upload.
+type uploadSession struct {
+	ID          string    `json:"upload_id"`
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size,omitempty"` // Declared total size; 0 if unknown
+	Pin         bool      `json:"pin"`
+	Public      bool      `json:"public"`
+	ContentType string    `json:"content_type,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	TTL         int64     `json:"ttl,omitempty"` // Object lifetime in seconds once completed; 0 keeps it
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// uploadStore keeps chunked upload sessions on local disk.
+type uploadStore struct {
+	dir string
+	ttl time.Duration
+
+	mu   sync.Mutex
+	busy map[string]bool // sessions with a request in flight
+
+	createMu sync.Mutex // serializes namespace limit checks with session creation
+}
+
+func newUploadStore(dir string, ttl time.Duration) *uploadStore {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "orama-uploads")
+	}
+	if ttl <= 0 {
+		ttl = DefaultUploadSessionTTL
+	}
+	return &uploadStore{dir: dir, ttl: ttl, busy: make(map[string]bool)}
+}
+
+func (s *uploadStore) dataPath(id string) string { return filepath.Join(s.dir, id+".part") }
+func (s *uploadStore) metaPath(id string) string { return filepath.Join(s.dir, id+".json") }
+
+// create persists a new session and an empty staging file.
+func (s *uploadStore) create(sess *uploadSession) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create upload dir: %w", err)
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	sess.ID = hex.EncodeToString(buf)
+	now := time.Now().UTC()
+	sess.CreatedAt = now
+	sess.ExpiresAt = now.Add(s.ttl)
+
+	f, err := os.OpenFile(s.dataPath(sess.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create staging file: %w", err)
+	}
+	f.Close()
+	return s.save(sess)
+}
+
+func (s *uploadStore) save(sess *uploadSession) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	tmp := s.metaPath(sess.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save upload session: %w", err)
+	}
+	return os.Rename(tmp, s.metaPath(sess.ID))
+}
+
+// load returns the session with id. Expired sessions are removed.
+func (s *uploadStore) load(id string) (*uploadSession, error) {
+	if len(id) != 32 || !isHex(id) {
+		return nil, errUploadNotFound
+	}
+	data, err := os.ReadFile(s.metaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sess uploadSession
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("corrupt upload session: %w", err)
+	}
+	if time.Now().After(sess.ExpiresAt) {
+		s.remove(id)
+		return nil, errUploadNotFound
+	}
+	return &sess, nil
+}
+
+// offset returns the number of bytes staged so far.
+func (s *uploadStore) offset(id string) (int64, error) {
+	fi, err := os.Stat(s.dataPath(id))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (s *uploadStore) remove(id string) {
+	_ = os.Remove(s.dataPath(id))
+	_ = os.Remove(s.metaPath(id))
+}
+
+// acquire marks a session busy so concurrent requests cannot interleave chunks.
+func (s *uploadStore) acquire(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy[id] {
+		return false
+	}
+	s.busy[id] = true
+	return true
+}
+
+func (s *uploadStore) release(id string) {
+	s.mu.Lock()
+	delete(s.busy, id)
+	s.mu.Unlock()
+}
+
+// usage returns how many open sessions namespace has, other than except, and
+// the bytes they hold: the declared size, or the bytes staged if larger.
+func (s *uploadStore) usage(namespace, except string) (sessions int, bytes int64) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, 0
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || id == except {
+			continue
+		}
+		sess, err := s.load(id)
+		if err != nil || sess.Namespace != namespace {
+			continue
+		}
+		staged, _ := s.offset(id)
+		sessions++
+		bytes += max(sess.Size, staged)
+	}
+	return sessions, bytes
+}
+
+// sweep removes expired sessions.
+func (s *uploadStore) sweep() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok {
+			_, _ = s.load(id) // removes the session if it expired
+		}
+	}
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// CreateUploadHandler handles POST /v1/storage/uploads.
+// It starts a resumable chunked upload for files too large to send in one request.
+func (h *Handlers) CreateUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if h.ipfsClient == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "IPFS storage not available")
+		return
+	}
+
+	if !httputil.CheckMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(r.Context())
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	var req StorageCreateUploadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
+			return
+		}
+	}
+	if req.Size < 0 {
+		httputil.WriteError(w, http.StatusBadRequest, "size must be >= 0")
+		return
+	}
+	if req.Size > h.maxObjectSize() {
+		h.writeStoreError(w, errObjectTooLarge)
+		return
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ttl, err := parseTTL(string(req.TTL))
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.uploads.sweep()
+
+	h.uploads.createMu.Lock()
+	defer h.uploads.createMu.Unlock()
+	sessions, staged := h.uploads.usage(namespace, "")
+	if sessions >= h.maxUploadSessions() {
+		httputil.WriteError(w, http.StatusTooManyRequests,
+			fmt.Sprintf("namespace already has %d open upload sessions; complete or abort one first", sessions))
+		return
+	}
+	if req.Size > h.maxUploadBytes()-staged {
+		httputil.WriteError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("upload exceeds the namespace's limit of %d bytes across open upload sessions", h.maxUploadBytes()))
+		return
+	}
+
+	sess := &uploadSession{
+		Namespace:   namespace,
+		Name:        req.Name,
+		Size:        req.Size,
+		Pin:         req.Pin == nil || *req.Pin,
+		Public:      req.Public,
+		ContentType: req.ContentType,
+		Tags:        tags,
+		TTL:         int64(ttl / time.Second),
+		CreatedBy:   callerIdentity(r.Context()),
+	}
+	if err := h.uploads.create(sess); err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to create upload session", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to create upload session")
+		return
+	}
+
+	w.Header().Set("Location", "/v1/storage/uploads/"+sess.ID)
+	w.Header().Set(uploadOffsetHeader, "0")
+	httputil.WriteJSON(w, http.StatusCreated, h.uploadStatus(sess, 0))
+}
+
+// UploadSessionHandler handles /v1/storage/uploads/{id}[/complete].
+func (h *Handlers) UploadSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if h.ipfsClient == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "IPFS storage not available")
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(r.Context())
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/storage/uploads/"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" {
+		httputil.WriteError(w, http.StatusBadRequest, "upload id required")
+		return
+	}
+
+	sess, err := h.uploads.load(id)
+	if err == nil && sess.Namespace != namespace {
+		err = errUploadNotFound
+	}
+	if errors.Is(err, errUploadNotFound) {
+		httputil.WriteError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to load upload session", zap.Error(err), zap.String("upload_id", id))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to load upload session")
+		return
+	}
+
+	switch {
+	case action == "complete":
+		if !httputil.CheckMethod(w, r, http.MethodPost) {
+			return
+		}
+		h.completeUpload(w, r, sess)
+	case action != "":
+		httputil.WriteError(w, http.StatusNotFound, "not found")
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		offset, err := h.uploads.offset(sess.ID)
+		if err != nil {
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to read upload state")
+			return
+		}
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		httputil.WriteJSON(w, http.StatusOK, h.uploadStatus(sess, offset))
+	case r.Method == http.MethodPut:
+		h.appendChunk(w, r, sess)
+	case r.Method == http.MethodDelete:
+		if !h.uploads.acquire(sess.ID) {
+			httputil.WriteError(w, http.StatusConflict, "upload has a request in progress")
+			return
+		}
+		defer h.uploads.release(sess.ID)
+		h.uploads.remove(sess.ID)
+		httputil.WriteJSON(w, http.StatusOK, map[string]any{"status": "aborted", "upload_id": sess.ID})
+	default:
+		httputil.CheckMethodOneOf(w, r, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// appendChunk writes the request body at the session's current offset.
+func (h *Handlers) appendChunk(w http.ResponseWriter, r *http.Request, sess *uploadSession) {
+	want, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil || want < 0 {
+		httputil.WriteError(w, http.StatusBadRequest, uploadOffsetHeader+" header required")
+		return
+	}
+
+	if !h.uploads.acquire(sess.ID) {
+		httputil.WriteError(w, http.StatusConflict, "upload has a request in progress")
+		return
+	}
+	defer h.uploads.release(sess.ID)
+
+	offset, err := h.uploads.offset(sess.ID)
+	if err != nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to read upload state")
+		return
+	}
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+	if want != offset {
+		httputil.WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":  fmt.Sprintf("offset mismatch: upload is at %d", offset),
+			"offset": offset,
+		})
+		return
+	}
+
+	limit := h.maxObjectSize() - offset
+	if sess.Size > 0 {
+		limit = sess.Size - offset
+	}
+	// The namespace's other sessions share its staging limit
+	_, others := h.uploads.usage(sess.Namespace, sess.ID)
+	overQuota := false
+	if room := h.maxUploadBytes() - others - offset; room < limit {
+		limit, overQuota = max(room, 0), true
+	}
+
+	f, err := os.OpenFile(h.uploads.dataPath(sess.ID), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to open staging file")
+		return
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r.Body, limit+1))
+	if n > limit {
+		// Reject the whole chunk so the client can retry with a correct one
+		_ = f.Truncate(offset)
+		f.Close()
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+		if overQuota {
+			httputil.WriteError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("chunk exceeds the namespace's limit of %d bytes across open upload sessions", h.maxUploadBytes()))
+		} else if sess.Size > 0 && limit < h.maxObjectSize()-offset {
+			httputil.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("chunk exceeds declared size of %d bytes", sess.Size))
+		} else {
+			h.writeStoreError(w, errObjectTooLarge)
+		}
+		return
+	}
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	// Bytes that made it to disk are kept so an interrupted chunk can be resumed
+	offset += n
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+	if copyErr != nil {
+		httputil.WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  fmt.Sprintf("chunk interrupted: %v", copyErr),
+			"offset": offset,
+		})
+		return
+	}
+
+	sess.ExpiresAt = time.Now().UTC().Add(h.uploads.ttl)
+	if err := h.uploads.save(sess); err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to extend upload session", zap.Error(err), zap.String("upload_id", sess.ID))
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, h.uploadStatus(sess, offset))
+}
+
+// completeUpload stores the staged file like a regular upload and removes the session.
+func (h *Handlers) completeUpload(w http.ResponseWriter, r *http.Request, sess *uploadSession) {
+	if !h.uploads.acquire(sess.ID) {
+		httputil.WriteError(w, http.StatusConflict, "upload has a request in progress")
+		return
+	}
+	defer h.uploads.release(sess.ID)
+
+	offset, err := h.uploads.offset(sess.ID)
+	if err != nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to read upload state")
+		return
+	}
+	if sess.Size > 0 && offset != sess.Size {
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+		httputil.WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":  fmt.Sprintf("upload incomplete: %d of %d bytes received", offset, sess.Size),
+			"offset": offset,
+		})
+		return
+	}
+
+	f, err := os.Open(h.uploads.dataPath(sess.ID))
+	if err != nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to open staging file")
+		return
+	}
+	defer f.Close()
+
+	ctx := r.Context()
+	response, err := h.storeObject(ctx, f, storeOptions{
+		Namespace:   sess.Namespace,
+		Name:        sess.Name,
+		ContentType: sess.ContentType,
+		Tags:        sess.Tags,
+		Public:      sess.Public,
+		Pin:         sess.Pin,
+		CreatedBy:   sess.CreatedBy,
+		TTL:         time.Duration(sess.TTL) * time.Second,
+	})
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	h.uploads.remove(sess.ID)
+
+	if sess.Pin {
+		h.enqueuePin(ctx, sess.Namespace, response.Cid, sess.Name, response.Size, 0)
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, response)
+}
+
+// maxUploadSessions returns how many open chunked uploads a namespace may have.
+func (h *Handlers) maxUploadSessions() int {
+	if h.config.MaxUploadSessions > 0 {
+		return h.config.MaxUploadSessions
+	}
+	return DefaultMaxUploadSessions
+}
+
+// maxUploadBytes returns how many bytes a namespace may stage across its
+// chunked uploads.
+func (h *Handlers) maxUploadBytes() int64 {
+	if h.config.MaxUploadBytes > 0 {
+		return h.config.MaxUploadBytes
+	}
+	return DefaultMaxUploadBytes
+}
+
+func (h *Handlers) uploadStatus(sess *uploadSession, offset int64) StorageUploadSessionResponse {
+	return StorageUploadSessionResponse{
+		UploadID:      sess.ID,
+		Name:          sess.Name,
+		Offset:        offset,
+		Size:          sess.Size,
+		MaxObjectSize: h.maxObjectSize(),
+		ExpiresAt:     sess.ExpiresAt,
+	}
+}
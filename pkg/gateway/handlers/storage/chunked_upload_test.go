@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+)
+
+func TestChunkedUpload_NamespaceLimits(t *testing.T) {
+	h := newPresignTestHandlers(t)
+	h.uploads = newUploadStore(t.TempDir(), 0)
+	h.config.MaxUploadSessions, h.config.MaxUploadBytes = 2, 10
+
+	call := func(handler http.HandlerFunc, method, target, namespace, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, namespace))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	create := func(namespace, body string) (int, string) {
+		w := call(h.CreateUploadHandler, http.MethodPost, "/v1/storage/uploads", namespace, body, nil)
+		var resp StorageUploadSessionResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.UploadID
+	}
+
+	// A declared size reserves its bytes
+	if code, _ := create("ns", `{"size":8}`); code != http.StatusCreated {
+		t.Fatalf("Expected the first session to be created, got %d", code)
+	}
+	if code, _ := create("ns", `{"size":4}`); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected a session over the byte limit to be refused, got %d", code)
+	}
+
+	// Sessions without a size are bounded as their chunks arrive
+	code, open := create("ns", `{}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected the second session to be created, got %d", code)
+	}
+	chunk := func(data string) int {
+		return call(h.UploadSessionHandler, http.MethodPut, "/v1/storage/uploads/"+open, "ns", data,
+			map[string]string{uploadOffsetHeader: "0"}).Code
+	}
+	if code := chunk("abc"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a chunk over the byte limit to be refused, got %d", code)
+	}
+	if code := chunk("ab"); code != http.StatusOK {
+		t.Errorf("Expected a chunk within the byte limit, got %d", code)
+	}
+
+	if code, _ := create("ns", `{}`); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a third session to be refused, got %d", code)
+	}
+	// Other namespaces have their own limits
+	if code, _ := create("other", `{"size":10}`); code != http.StatusCreated {
+		t.Errorf("Expected another namespace to create a session, got %d", code)
+	}
+}
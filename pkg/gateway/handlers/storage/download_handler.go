@@ -1,26 +1,44 @@
 package storage
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/DeBrosOfficial/network/pkg/encryption"
 	"github.com/DeBrosOfficial/network/pkg/httputil"
 	"github.com/DeBrosOfficial/network/pkg/logging"
 	"go.uber.org/zap"
 )
 
-// DownloadHandler handles GET /v1/storage/get/:cid.
+// rangeGetter is implemented by IPFS clients that can read part of an object.
+type rangeGetter interface {
+	GetRange(ctx context.Context, cid string, ipfsAPIURL string, offset, length int64) (io.ReadCloser, error)
+}
+
+// DownloadHandler handles GET and HEAD /v1/storage/get/:cid.
 // It retrieves content from IPFS by CID and streams it to the client.
-// The content is returned as an octet-stream with a content-disposition header.
+// Encrypted objects are decrypted transparently for their own namespace.
+//
+// CIDs are immutable, so the CID is the ETag and responses may be cached
+// forever. Single byte ranges are supported (with If-Range) when the object's
+// size is known from the namespace index or its encryption metadata. The
+// content type comes from the index or is detected from the name or content.
+// Passive types such as images, media, PDF and plain text are served inline;
+// everything else is an attachment unless ?disposition=inline is given.
 func (h *Handlers) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	if h.ipfsClient == nil {
 		httputil.WriteError(w, http.StatusServiceUnavailable, "IPFS storage not available")
 		return
 	}
 
-	if !httputil.CheckMethod(w, r, http.MethodGet) {
+	if !httputil.CheckMethodOneOf(w, r, http.MethodGet, http.MethodHead) {
 		return
 	}
 
@@ -33,6 +51,14 @@ func (h *Handlers) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get namespace from context
 	namespace := h.getNamespaceFromContext(r.Context())
+	if IsPresigned(r) {
+		grant, err := h.presignedGrant(r)
+		if err != nil {
+			h.writePresignError(w, err)
+			return
+		}
+		namespace = grant.Namespace
+	}
 	if namespace == "" {
 		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
 		return
@@ -45,7 +71,83 @@ func (h *Handlers) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	reader, err := h.ipfsClient.Get(ctx, path, ipfsAPIURL)
+
+	// Encrypted objects are only readable by the namespace that stored them
+	var meta *encryption.ObjectMeta
+	var dataKey []byte
+	if h.config.Keyring != nil {
+		var err error
+		meta, err = h.config.Keyring.LookupObject(ctx, path)
+		if err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to look up encryption metadata",
+				zap.Error(err), zap.String("cid", path))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to look up encryption metadata")
+			return
+		}
+		if meta != nil {
+			if meta.Namespace != namespace {
+				httputil.WriteError(w, http.StatusNotFound, fmt.Sprintf("content not found: %s", path))
+				return
+			}
+			if dataKey, err = h.config.Keyring.ObjectDataKey(ctx, meta); err != nil {
+				h.logger.ComponentError(logging.ComponentGeneral, "failed to unwrap object key",
+					zap.Error(err), zap.String("cid", path))
+				httputil.WriteError(w, http.StatusInternalServerError, "failed to decrypt content")
+				return
+			}
+		}
+	}
+
+	// The namespace index supplies the name, content type and size
+	var obj *objectRow
+	if h.config.DB != nil {
+		var err error
+		if obj, err = h.lookupObject(ctx, namespace, path); err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to look up storage object",
+				zap.Error(err), zap.String("cid", path))
+		}
+		if obj != nil {
+			h.touchObject(ctx, obj)
+		}
+	}
+
+	size := int64(-1)
+	switch {
+	case meta != nil:
+		size = meta.PlaintextSize
+	case obj != nil && obj.Size > 0:
+		size = obj.Size
+	}
+
+	etag := `"` + path + `"`
+	header := w.Header()
+	header.Set("ETag", etag)
+	if meta != nil {
+		header.Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var rng *byteRange
+	if size >= 0 {
+		header.Set("Accept-Ranges", "bytes")
+		if v := r.Header.Get("Range"); v != "" && ifRangeMatches(r.Header.Get("If-Range"), etag) {
+			var err error
+			rng, err = parseByteRange(v, size)
+			if errors.Is(err, errRangeNotSatisfiable) {
+				header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+				httputil.WriteError(w, http.StatusRequestedRangeNotSatisfiable, "range not satisfiable")
+				return
+			}
+			// Malformed and multi-part ranges are ignored and the whole object is served
+		}
+	}
+
+	body, err := h.openContent(ctx, path, ipfsAPIURL, dataKey, size, rng)
 	if err != nil {
 		h.logger.ComponentError(logging.ComponentGeneral, "failed to get content from IPFS",
 			zap.Error(err), zap.String("cid", path))
@@ -59,11 +161,45 @@ func (h *Handlers) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	defer reader.Close()
+	defer body.Close()
 
-	// Set headers for file download
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", path))
+	var reader io.Reader = body
+	var name, contentType string
+	if obj != nil {
+		name, contentType = obj.Name, obj.ContentType
+	}
+	if contentType == "" {
+		var head []byte
+		if rng == nil {
+			// Sniff the start of the content without consuming it
+			br := bufio.NewReaderSize(body, sniffLen)
+			head, _ = br.Peek(sniffLen)
+			reader = br
+		}
+		contentType = detectContentType("", name, head)
+	}
+
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if isActiveContent(contentType) {
+		// Never let stored HTML or SVG run scripts on the gateway's origin
+		header.Set("Content-Security-Policy", "sandbox")
+	}
+	header.Set("Content-Disposition", contentDisposition(r, contentType, name, path))
+
+	status := http.StatusOK
+	if rng != nil {
+		status = http.StatusPartialContent
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end, size))
+		header.Set("Content-Length", strconv.FormatInt(rng.length(), 10))
+	} else if size >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		return
+	}
 
 	// Stream content to client
 	if _, err := io.Copy(w, reader); err != nil {
@@ -71,6 +207,181 @@ func (h *Handlers) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// openContent returns the plaintext of cid, or of rng within it. Ranges are
+// read from IPFS directly when the client supports it; encrypted ranges start
+// at the segment covering rng.start.
+func (h *Handlers) openContent(ctx context.Context, cid, ipfsAPIURL string, dataKey []byte, size int64, rng *byteRange) (io.ReadCloser, error) {
+	ranged, canRange := h.ipfsClient.(rangeGetter)
+
+	if rng != nil && canRange {
+		if dataKey == nil {
+			return ranged.GetRange(ctx, cid, ipfsAPIURL, rng.start, rng.length())
+		}
+
+		hr, err := ranged.GetRange(ctx, cid, ipfsAPIURL, 0, encryption.HeaderSize)
+		if err != nil {
+			return nil, err
+		}
+		streamHeader := make([]byte, encryption.HeaderSize)
+		_, err = io.ReadFull(hr, streamHeader)
+		hr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream header: %w", err)
+		}
+
+		segment, offset, length, skip := encryption.SegmentSpan(rng.start, rng.length())
+		rc, err := ranged.GetRange(ctx, cid, ipfsAPIURL, offset, length)
+		if err != nil {
+			return nil, err
+		}
+		dec, err := encryption.NewSegmentDecryptingReader(rc, dataKey, streamHeader, segment, size)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return skipLimit(dec, rc, skip, rng.length())
+	}
+
+	rc, err := h.ipfsClient.Get(ctx, cid, ipfsAPIURL)
+	if err != nil {
+		return nil, err
+	}
+	var plain io.Reader = rc
+	if dataKey != nil {
+		dec, err := encryption.NewDecryptingReader(rc, dataKey)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		plain = dec
+	}
+	if rng == nil {
+		return readCloser{plain, rc}, nil
+	}
+	return skipLimit(plain, rc, rng.start, rng.length())
+}
+
+// skipLimit discards skip bytes of r and returns the next n bytes.
+func skipLimit(r io.Reader, c io.Closer, skip, n int64) (io.ReadCloser, error) {
+	if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to seek content: %w", err)
+	}
+	return readCloser{io.LimitReader(r, n), c}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange is an inclusive byte range.
+type byteRange struct {
+	start, end int64
+}
+
+func (b *byteRange) length() int64 { return b.end - b.start + 1 }
+
+// parseByteRange parses a single-range Range header against an object of size
+// bytes. It returns errRangeNotSatisfiable when the range lies outside the object.
+func parseByteRange(v string, size int64) (*byteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(v), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, fmt.Errorf("unsupported range %q", v)
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid range %q", v)
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid range %q", v)
+		}
+		if n == 0 || size == 0 {
+			return nil, errRangeNotSatisfiable
+		}
+		return &byteRange{start: max(size-n, 0), end: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, fmt.Errorf("invalid range %q", v)
+	}
+	if start >= size {
+		return nil, errRangeNotSatisfiable
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return nil, fmt.Errorf("invalid range %q", v)
+		}
+		end = min(end, size-1)
+	}
+	return &byteRange{start: start, end: end}, nil
+}
+
+// etagMatches reports whether an If-None-Match header matches etag (weak comparison).
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifRangeMatches reports whether a Range request should be honored. If-Range
+// requires a strong ETag match; dates never match since objects carry no
+// Last-Modified time.
+func ifRangeMatches(header, etag string) bool {
+	return header == "" || strings.TrimSpace(header) == etag
+}
+
+// isActiveContent reports whether browsers may execute content of this type.
+func isActiveContent(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "image/svg+xml", "text/xml", "application/xml",
+		"text/javascript", "application/javascript":
+		return true
+	}
+	return false
+}
+
+// contentDisposition serves passive content inline and everything else as an
+// attachment. ?disposition=inline|attachment and ?download=true override it.
+func contentDisposition(r *http.Request, contentType, name, cid string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	disposition := "attachment"
+	switch {
+	case isActiveContent(contentType):
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"), mediaType == "text/plain",
+		mediaType == "application/pdf", mediaType == "application/json":
+		disposition = "inline"
+	}
+	if v := r.URL.Query().Get("disposition"); v == "inline" || v == "attachment" {
+		disposition = v
+	}
+	if httputil.QueryParamBool(r, "download", false) {
+		disposition = "attachment"
+	}
+
+	if name == "" {
+		name = cid
+	}
+	if v := mime.FormatMediaType(disposition, map[string]string{"filename": name}); v != "" {
+		return v
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": cid})
+}
+
 // StatusHandler handles GET /v1/storage/status/:cid.
 // It retrieves the pin status of a CID from the IPFS cluster,
 // including replication information and peer distribution.
@@ -92,6 +403,31 @@ func (h *Handlers) StatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+
+	// Pins still in the queue are reported from the queue, not the cluster
+	if namespace := h.getNamespaceFromContext(ctx); h.pins != nil && namespace != "" {
+		job, err := h.lookupPinJob(ctx, namespace, path)
+		if err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to look up pin job",
+				zap.Error(err), zap.String("cid", path))
+		} else if job != nil && job.State != pinStatePinned {
+			response := StorageStatusResponse{
+				Cid:               job.Cid,
+				Name:              job.Name,
+				Status:            job.State,
+				ReplicationFactor: job.ReplicationFactor,
+				Peers:             []string{},
+				Error:             job.LastError,
+				Attempts:          job.Attempts,
+			}
+			if job.State == pinStateQueued {
+				response.NextAttemptAt = job.NextAttemptAt
+			}
+			httputil.WriteJSON(w, http.StatusOK, response)
+			return
+		}
+	}
+
 	status, err := h.ipfsClient.PinStatus(ctx, path)
 	if err != nil {
 		h.logger.ComponentError(logging.ComponentGeneral, "failed to get pin status",
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/encryption"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/ipfs"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+)
+
+// memIPFS serves objects from memory and supports ranged reads.
+type memIPFS struct {
+	objects map[string][]byte
+	ranged  int // number of GetRange calls
+}
+
+func (m *memIPFS) Add(ctx context.Context, r io.Reader, name string) (*ipfs.AddResponse, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *memIPFS) Pin(ctx context.Context, cid, name string, replicationFactor int) (*ipfs.PinResponse, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *memIPFS) PinStatus(ctx context.Context, cid string) (*ipfs.PinStatus, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *memIPFS) Unpin(ctx context.Context, cid string) error { return nil }
+
+func (m *memIPFS) Get(ctx context.Context, cid, ipfsAPIURL string) (io.ReadCloser, error) {
+	return m.GetRange(ctx, cid, ipfsAPIURL, 0, -1)
+}
+
+func (m *memIPFS) GetRange(ctx context.Context, cid, ipfsAPIURL string, offset, length int64) (io.ReadCloser, error) {
+	data, ok := m.objects[cid]
+	if !ok {
+		return nil, fmt.Errorf("content not found")
+	}
+	if offset > 0 || length >= 0 {
+		m.ranged++
+	}
+	data = data[min(offset, int64(len(data))):]
+	if length >= 0 {
+		data = data[:min(length, int64(len(data)))]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// fakeIndex answers object index lookups from a fixed set of rows.
+type fakeIndex struct {
+	rows map[string]objectRow
+}
+
+func (f *fakeIndex) Query(ctx context.Context, dest any, query string, args ...any) error {
+	if rows, ok := dest.(*[]objectRow); ok && len(args) == 2 {
+		if row, ok := f.rows[args[1].(string)]; ok && row.Namespace == args[0] {
+			*rows = append(*rows, row)
+		}
+	}
+	return nil
+}
+
+func (f *fakeIndex) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, nil
+}
+
+// fakeKeyring holds a single encrypted object.
+type fakeKeyring struct {
+	meta encryption.ObjectMeta
+	key  []byte
+}
+
+func (f *fakeKeyring) NewObjectKey(ctx context.Context, namespace string) (*encryption.ObjectKey, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeKeyring) SaveObject(ctx context.Context, cid string, key *encryption.ObjectKey, plaintextSize int64) error {
+	return nil
+}
+
+func (f *fakeKeyring) LookupObject(ctx context.Context, cid string) (*encryption.ObjectMeta, error) {
+	if cid != f.meta.Cid {
+		return nil, nil
+	}
+	meta := f.meta
+	return &meta, nil
+}
+
+func (f *fakeKeyring) ObjectDataKey(ctx context.Context, meta *encryption.ObjectMeta) ([]byte, error) {
+	return f.key, nil
+}
+
+func newDownloadTestHandlers(t *testing.T) (*Handlers, []byte, []byte) {
+	t.Helper()
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	plain := make([]byte, 200_000)
+	_, _ = rand.Read(plain)
+	video := []byte("0123456789abcdefghij")
+
+	key, _ := encryption.NewKey()
+	enc, _ := encryption.NewEncryptingReader(bytes.NewReader(plain), key)
+	ciphertext, _ := io.ReadAll(enc)
+
+	client := &memIPFS{objects: map[string][]byte{"QmSecret": ciphertext, "QmVideo": video}}
+	h := New(client, logger, Config{
+		DB: &fakeIndex{rows: map[string]objectRow{
+			"QmVideo": {Namespace: "ns", Cid: "QmVideo", Name: "clip.mp4", Size: int64(len(video)), ContentType: "video/mp4", Tags: "[]"},
+		}},
+		Keyring: &fakeKeyring{
+			meta: encryption.ObjectMeta{Cid: "QmSecret", Namespace: "ns", Algorithm: encryption.StreamAlgorithm, PlaintextSize: int64(len(plain))},
+			key:  key,
+		},
+	})
+	return h, plain, video
+}
+
+func download(h *Handlers, cid string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/storage/get/"+cid, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, "ns"))
+	w := httptest.NewRecorder()
+	h.DownloadHandler(w, req)
+	return w
+}
+
+func TestDownloadHandler_Range(t *testing.T) {
+	h, _, video := newDownloadTestHandlers(t)
+
+	w := download(h, "QmVideo", map[string]string{"Range": "bytes=5-9"})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusPartialContent, w.Code)
+	}
+	if w.Body.String() != string(video[5:10]) {
+		t.Errorf("Expected %q, got %q", video[5:10], w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 5-9/20" {
+		t.Errorf("Expected Content-Range 'bytes 5-9/20', got %s", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "video/mp4" {
+		t.Errorf("Expected Content-Type from index, got %s", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "inline; filename=clip.mp4" {
+		t.Errorf("Expected inline disposition with stored name, got %s", got)
+	}
+	if h.ipfsClient.(*memIPFS).ranged == 0 {
+		t.Error("Expected a ranged IPFS read")
+	}
+
+	// Suffix range
+	if w := download(h, "QmVideo", map[string]string{"Range": "bytes=-3"}); w.Body.String() != "hij" {
+		t.Errorf("Expected suffix 'hij', got %q", w.Body.String())
+	}
+
+	// Stale If-Range serves the whole object
+	w = download(h, "QmVideo", map[string]string{"Range": "bytes=0-1", "If-Range": `"QmOther"`})
+	if w.Code != http.StatusOK || w.Body.Len() != len(video) {
+		t.Errorf("Expected full response for stale If-Range, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+
+	// Out of range
+	w = download(h, "QmVideo", map[string]string{"Range": "bytes=20-"})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable || w.Header().Get("Content-Range") != "bytes */20" {
+		t.Errorf("Expected 416 with 'bytes */20', got %d %q", w.Code, w.Header().Get("Content-Range"))
+	}
+}
+
+func TestDownloadHandler_EncryptedRange(t *testing.T) {
+	h, plain, _ := newDownloadTestHandlers(t)
+
+	for _, rg := range [][2]int{{0, 99}, {65530, 65545}, {131072, 199999}, {150000, 150000}} {
+		w := download(h, "QmSecret", map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", rg[0], rg[1])})
+		if w.Code != http.StatusPartialContent {
+			t.Fatalf("range %v: expected status %d, got %d: %s", rg, http.StatusPartialContent, w.Code, w.Body.String())
+		}
+		if !bytes.Equal(w.Body.Bytes(), plain[rg[0]:rg[1]+1]) {
+			t.Errorf("range %v: decrypted bytes mismatch", rg)
+		}
+	}
+
+	w := download(h, "QmSecret", nil)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), plain) {
+		t.Errorf("Expected full decrypted object, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, max-age=31536000, immutable" {
+		t.Errorf("Expected private Cache-Control for encrypted content, got %s", got)
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		start, end int64
+		wantErr    bool
+	}{
+		{"bytes=0-0", 0, 0, false},
+		{"bytes=10-", 10, 99, false},
+		{"bytes=90-200", 90, 99, false},
+		{"bytes=-10", 90, 99, false},
+		{"bytes=-500", 0, 99, false},
+		{"bytes=100-", 0, 0, true},
+		{"bytes=5-1", 0, 0, true},
+		{"bytes=0-1,5-6", 0, 0, true},
+		{"items=0-1", 0, 0, true},
+	}
+	for _, tt := range tests {
+		rng, err := parseByteRange(tt.header, 100)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (rng.start != tt.start || rng.end != tt.end) {
+			t.Errorf("%s: got %d-%d, want %d-%d", tt.header, rng.start, rng.end, tt.start, tt.end)
+		}
+	}
+}
@@ -3,10 +3,15 @@ package storage
 import (
 	"context"
 	"io"
+	"sync"
+	"time"
 
+	"github.com/DeBrosOfficial/network/pkg/encryption"
 	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/httputil"
 	"github.com/DeBrosOfficial/network/pkg/ipfs"
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 )
 
 // IPFSClient defines the interface for interacting with IPFS.
@@ -19,12 +24,58 @@ type IPFSClient interface {
 	Unpin(ctx context.Context, cid string) error
 }
 
+// Keyring manages envelope encryption keys for stored objects.
+// This interface matches the encryption.Keyring implementation.
+type Keyring interface {
+	NewObjectKey(ctx context.Context, namespace string) (*encryption.ObjectKey, error)
+	SaveObject(ctx context.Context, cid string, key *encryption.ObjectKey, plaintextSize int64) error
+	LookupObject(ctx context.Context, cid string) (*encryption.ObjectMeta, error)
+	ObjectDataKey(ctx context.Context, meta *encryption.ObjectMeta) ([]byte, error)
+}
+
 // Config holds configuration values needed by storage handlers.
 type Config struct {
 	// IPFSReplicationFactor is the desired number of replicas for pinned content
 	IPFSReplicationFactor int
 	// IPFSAPIURL is the IPFS API endpoint URL
 	IPFSAPIURL string
+	// EnableEncryption encrypts uploads before they reach IPFS unless marked public
+	EnableEncryption bool
+	// Keyring provides namespace and object keys; required for encryption and
+	// for reading previously encrypted objects
+	Keyring Keyring
+	// MaxObjectSize is the largest accepted object in bytes (default: 5 GiB)
+	MaxObjectSize int64
+	// UploadDir stages chunked uploads on local disk (default: <tmp>/orama-uploads)
+	UploadDir string
+	// UploadSessionTTL is how long an idle chunked upload is kept (default: 24h)
+	UploadSessionTTL time.Duration
+	// MaxUploadSessions is how many open chunked uploads a namespace may have (default: 16)
+	MaxUploadSessions int
+	// MaxUploadBytes is how many bytes a namespace may stage across its
+	// chunked uploads (default: 20 GiB)
+	MaxUploadBytes int64
+	// DB stores the namespace object index; listing is unavailable without it
+	DB DB
+	// Meter receives pinned bytes for pins completed by the background queue
+	Meter metering.Recorder
+	// PinMaxAttempts is how often a queued pin is tried before it is marked failed (default: 10)
+	PinMaxAttempts int
+	// PinReconcileInterval is how often expected pins are checked against the
+	// cluster (default: 15m; < 0 disables)
+	PinReconcileInterval time.Duration
+	// LifecycleInterval is how often upload TTLs and lifecycle rules are
+	// applied (default: 1h; < 0 disables)
+	LifecycleInterval time.Duration
+	// ReservedDomains are the gateway's own domains; sites cannot claim them
+	// or their subdomains as custom domains
+	ReservedDomains []string
+	// PresignKey signs presigned URLs; without it a cluster key is generated
+	// and stored in RQLite
+	PresignKey []byte
+	// TrustedProxies are the reverse proxies whose X-Forwarded-Proto is
+	// believed when building presigned URLs
+	TrustedProxies httputil.TrustedProxies
 }
 
 // Handlers provides HTTP handlers for IPFS storage operations.
@@ -33,15 +84,35 @@ type Handlers struct {
 	ipfsClient IPFSClient
 	logger     *logging.ColoredLogger
 	config     Config
+	uploads    *uploadStore
+	pins       *pinQueue         // nil without a database
+	janitor    *lifecycleJanitor // nil without a database or when disabled
+
+	siteDomains siteDomainCache
+
+	presignMu       sync.Mutex
+	presignKeyCache []byte
 }
 
 // New creates a new storage handlers instance with the provided dependencies.
 func New(ipfsClient IPFSClient, logger *logging.ColoredLogger, config Config) *Handlers {
-	return &Handlers{
+	h := &Handlers{
 		ipfsClient: ipfsClient,
 		logger:     logger,
 		config:     config,
+		uploads:    newUploadStore(config.UploadDir, config.UploadSessionTTL),
 	}
+	if ipfsClient != nil && config.DB != nil {
+		h.pins = newPinQueue(h)
+		h.janitor = newLifecycleJanitor(h)
+	}
+	return h
+}
+
+// Close stops the background pin queue and lifecycle janitor.
+func (h *Handlers) Close() {
+	h.janitor.Close()
+	h.pins.Close()
 }
 
 // getNamespaceFromContext retrieves the namespace from the request context.
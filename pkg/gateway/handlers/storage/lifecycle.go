@@ -0,0 +1,428 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Lifecycle rule actions
+const (
+	lifecycleDelete = "delete"
+	lifecycleUnpin  = "unpin"
+
+	// lifecycleTTLRule is the rule reported for objects expired by an upload TTL.
+	lifecycleTTLRule = "ttl"
+)
+
+// Defaults and limits for lifecycle policies
+const (
+	DefaultLifecycleInterval = time.Hour
+
+	maxLifecycleRules  = 50
+	maxLifecycleRuleID = 64
+	lifecycleBatch     = 100 // objects expired per rule (and for TTLs) in one run
+	lifecycleTimeout   = 5 * time.Minute
+
+	minObjectTTL = time.Minute
+	maxObjectTTL = 10 * 365 * 24 * time.Hour
+)
+
+// UnmarshalJSON accepts a string or a number of seconds.
+func (t *StorageTTL) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*t = ""
+		return nil
+	}
+	if len(b) > 0 && b[0] != '"' {
+		*t = StorageTTL(b)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*t = StorageTTL(s)
+	return nil
+}
+
+// parseTTL parses an object TTL: seconds, a day count such as "7d", or a Go
+// duration such as "36h". An empty value means no expiry.
+func parseTTL(v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	var d time.Duration
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n > int64(maxObjectTTL/time.Second) {
+			return 0, fmt.Errorf("ttl exceeds %s", maxObjectTTL)
+		}
+		d = time.Duration(n) * time.Second
+	} else if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl %q", v)
+		}
+		if n > int(maxObjectTTL/(24*time.Hour)) {
+			return 0, fmt.Errorf("ttl exceeds %s", maxObjectTTL)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else if d, err = time.ParseDuration(v); err != nil {
+		return 0, fmt.Errorf("invalid ttl %q; use seconds, a duration such as 36h, or days such as 7d", v)
+	}
+	if d < minObjectTTL {
+		return 0, fmt.Errorf("ttl must be at least %s", minObjectTTL)
+	}
+	if d > maxObjectTTL {
+		return 0, fmt.Errorf("ttl exceeds %s", maxObjectTTL)
+	}
+	return d, nil
+}
+
+// normalizeLifecycleRules validates rules and fills in defaults.
+func normalizeLifecycleRules(rules []StorageLifecycleRule) ([]StorageLifecycleRule, error) {
+	if len(rules) > maxLifecycleRules {
+		return nil, fmt.Errorf("at most %d lifecycle rules are allowed", maxLifecycleRules)
+	}
+	out := make([]StorageLifecycleRule, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		rule.ID = strings.TrimSpace(rule.ID)
+		switch {
+		case rule.ID == "" || len(rule.ID) > maxLifecycleRuleID:
+			return nil, fmt.Errorf("rule id must be 1-%d characters", maxLifecycleRuleID)
+		case rule.ID == lifecycleTTLRule:
+			return nil, fmt.Errorf("rule id %q is reserved", lifecycleTTLRule)
+		case seen[rule.ID]:
+			return nil, fmt.Errorf("duplicate rule id %q", rule.ID)
+		case rule.ExpireAfterDays < 0 || rule.UnaccessedDays < 0:
+			return nil, fmt.Errorf("rule %q: day counts must not be negative", rule.ID)
+		case rule.ExpireAfterDays == 0 && rule.UnaccessedDays == 0:
+			return nil, fmt.Errorf("rule %q: set expire_after_days or unaccessed_days", rule.ID)
+		case len(rule.Tag) > maxTagLength:
+			return nil, fmt.Errorf("rule %q: tag exceeds %d characters", rule.ID, maxTagLength)
+		}
+		switch rule.Action {
+		case "":
+			rule.Action = lifecycleDelete
+		case lifecycleDelete, lifecycleUnpin:
+		default:
+			return nil, fmt.Errorf("rule %q: action must be %q or %q", rule.ID, lifecycleDelete, lifecycleUnpin)
+		}
+		seen[rule.ID] = true
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// lifecyclePolicyRow is a row of storage_lifecycle_policies.
+type lifecyclePolicyRow struct {
+	Namespace string `db:"namespace"`
+	Rules     string `db:"rules"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (p *lifecyclePolicyRow) toPolicy() StorageLifecyclePolicy {
+	policy := StorageLifecyclePolicy{Rules: []StorageLifecycleRule{}}
+	_ = json.Unmarshal([]byte(p.Rules), &policy.Rules)
+	policy.UpdatedAt = parseOptionalTime(p.UpdatedAt)
+	return policy
+}
+
+// lookupLifecyclePolicy returns namespace's rules; a namespace without a
+// policy has none.
+func (h *Handlers) lookupLifecyclePolicy(ctx context.Context, namespace string) (StorageLifecyclePolicy, error) {
+	var rows []lifecyclePolicyRow
+	if err := h.config.DB.Query(ctx, &rows,
+		"SELECT namespace, rules, updated_at FROM storage_lifecycle_policies WHERE namespace = ? LIMIT 1", namespace); err != nil {
+		return StorageLifecyclePolicy{}, err
+	}
+	if len(rows) == 0 {
+		return StorageLifecyclePolicy{Rules: []StorageLifecycleRule{}}, nil
+	}
+	return rows[0].toPolicy(), nil
+}
+
+// lifecycleMatch is an object selected for expiry.
+type lifecycleMatch struct {
+	Namespace string `db:"namespace"`
+	Cid       string `db:"cid"`
+	Name      string `db:"name"`
+}
+
+// notSiteRoot keeps lifecycle runs away from content a namespace serves as a
+// static site, which shares the namespace's pin reference.
+const notSiteRoot = "NOT EXISTS (SELECT 1 FROM storage_sites s WHERE s.namespace = storage_objects.namespace AND s.root_cid = storage_objects.cid)"
+
+// expiredByTTL returns objects whose upload TTL has passed, in namespace or in
+// every namespace if it is empty.
+func (h *Handlers) expiredByTTL(ctx context.Context, namespace string, now time.Time) ([]lifecycleMatch, error) {
+	where := []string{"expires_at != ''", "expires_at <= ?", notSiteRoot}
+	args := []any{now.UTC().Format(time.RFC3339)}
+	if namespace != "" {
+		where = append(where, "namespace = ?")
+		args = append(args, namespace)
+	}
+	var rows []lifecycleMatch
+	err := h.config.DB.Query(ctx, &rows,
+		"SELECT namespace, cid, name FROM storage_objects WHERE "+strings.Join(where, " AND ")+
+			fmt.Sprintf(" ORDER BY expires_at LIMIT %d", lifecycleBatch), args...)
+	return rows, err
+}
+
+// matchLifecycleRule returns namespace's objects that rule expires at now.
+func (h *Handlers) matchLifecycleRule(ctx context.Context, namespace string, rule StorageLifecycleRule, now time.Time) ([]lifecycleMatch, error) {
+	where := []string{"namespace = ?", notSiteRoot}
+	args := []any{namespace}
+	if rule.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM json_each(storage_objects.tags) WHERE json_each.value = ?)")
+		args = append(args, rule.Tag)
+	}
+	if rule.Prefix != "" {
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(rule.Prefix)+"%")
+	}
+	if rule.ExpireAfterDays > 0 {
+		where = append(where, "created_at <= ?")
+		args = append(args, now.AddDate(0, 0, -rule.ExpireAfterDays).UTC().Format(time.RFC3339))
+	}
+	if rule.UnaccessedDays > 0 {
+		where = append(where, "(CASE WHEN accessed_at != '' THEN accessed_at ELSE created_at END) <= ?")
+		args = append(args, now.AddDate(0, 0, -rule.UnaccessedDays).UTC().Format(time.RFC3339))
+	}
+	if rule.Action == lifecycleUnpin {
+		where = append(where, "pinned = TRUE")
+	}
+	var rows []lifecycleMatch
+	err := h.config.DB.Query(ctx, &rows,
+		"SELECT namespace, cid, name FROM storage_objects WHERE "+strings.Join(where, " AND ")+
+			fmt.Sprintf(" ORDER BY created_at LIMIT %d", lifecycleBatch), args...)
+	return rows, err
+}
+
+// evaluateLifecycle finds namespace's objects that are due to expire under its
+// upload TTLs and rules, and expires them unless dryRun. Each object is
+// reported once, for the first TTL or rule that matches it.
+func (h *Handlers) evaluateLifecycle(ctx context.Context, namespace string, rules []StorageLifecycleRule, now time.Time, dryRun bool) (*StorageLifecycleReport, error) {
+	report := &StorageLifecycleReport{DryRun: dryRun, Expired: []StorageExpiredObject{}}
+	seen := map[string]bool{}
+	add := func(matches []lifecycleMatch, rule, action string) {
+		if len(matches) == lifecycleBatch {
+			report.Truncated = true
+		}
+		for _, m := range matches {
+			if seen[m.Cid] {
+				continue
+			}
+			seen[m.Cid] = true
+			report.Expired = append(report.Expired, StorageExpiredObject{Cid: m.Cid, Name: m.Name, Rule: rule, Action: action})
+		}
+	}
+
+	matches, err := h.expiredByTTL(ctx, namespace, now)
+	if err != nil {
+		return nil, err
+	}
+	add(matches, lifecycleTTLRule, lifecycleDelete)
+	for _, rule := range rules {
+		if rule.Disabled {
+			continue
+		}
+		matches, err := h.matchLifecycleRule(ctx, namespace, rule, now)
+		if err != nil {
+			return nil, err
+		}
+		add(matches, rule.ID, rule.Action)
+	}
+	report.Count = len(report.Expired)
+
+	if !dryRun {
+		for _, obj := range report.Expired {
+			if err := h.expireObject(ctx, namespace, obj.Cid, obj.Action); err != nil {
+				h.logger.ComponentWarn(logging.ComponentGeneral, "failed to expire storage object",
+					zap.Error(err), zap.String("cid", obj.Cid), zap.String("namespace", namespace), zap.String("rule", obj.Rule))
+			}
+		}
+	}
+	return report, nil
+}
+
+// expireObject releases namespace's pin on cid and, for the delete action,
+// removes the object from the namespace index.
+func (h *Handlers) expireObject(ctx context.Context, namespace, cid, action string) error {
+	if _, _, err := h.releasePin(ctx, namespace, cid); err != nil {
+		return fmt.Errorf("failed to unpin: %w", err)
+	}
+	if action == lifecycleUnpin {
+		h.recordPin(ctx, namespace, cid, "", false, "")
+		return nil
+	}
+	_, err := h.config.DB.Exec(ctx, "DELETE FROM storage_objects WHERE namespace = ? AND cid = ?", namespace, cid)
+	return err
+}
+
+// PutObject stores content written outside the upload API, such as by
+// serverless functions, the way uploads are stored: encrypted unless
+// encryption is disabled, recorded in namespace's index and queued for
+// pinning. A positive ttl expires the object. It returns the CID.
+func (h *Handlers) PutObject(ctx context.Context, namespace string, data io.Reader, name string, ttl time.Duration, createdBy string) (string, error) {
+	if namespace == "" {
+		return "", fmt.Errorf("namespace required")
+	}
+	if ttl > maxObjectTTL {
+		return "", fmt.Errorf("ttl exceeds %s", maxObjectTTL)
+	}
+	pin := h.config.DB != nil
+	resp, err := h.storeObject(ctx, data, storeOptions{
+		Namespace: namespace,
+		Name:      name,
+		Pin:       pin,
+		CreatedBy: createdBy,
+		TTL:       ttl,
+	})
+	if err != nil {
+		return "", err
+	}
+	if pin {
+		h.enqueuePin(ctx, namespace, resp.Cid, name, resp.Size, 0)
+	}
+	return resp.Cid, nil
+}
+
+// GetObject returns the content of cid as read by namespace, decrypting it if
+// it was stored encrypted. Encrypted objects of other namespaces are not found.
+func (h *Handlers) GetObject(ctx context.Context, namespace, cid string) (io.ReadCloser, error) {
+	var dataKey []byte
+	size := int64(-1)
+	if h.config.Keyring != nil {
+		meta, err := h.config.Keyring.LookupObject(ctx, cid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up encryption metadata: %w", err)
+		}
+		if meta != nil {
+			if meta.Namespace != namespace {
+				return nil, fmt.Errorf("content not found: %s", cid)
+			}
+			if dataKey, err = h.config.Keyring.ObjectDataKey(ctx, meta); err != nil {
+				return nil, fmt.Errorf("failed to unwrap object key: %w", err)
+			}
+			size = meta.PlaintextSize
+		}
+	}
+	ipfsAPIURL := h.config.IPFSAPIURL
+	if ipfsAPIURL == "" {
+		ipfsAPIURL = "http://localhost:5001"
+	}
+	return h.openContent(ctx, cid, ipfsAPIURL, dataKey, size, nil)
+}
+
+// lifecycleJanitor periodically applies upload TTLs and lifecycle rules.
+// Expiry is idempotent, so several gateways may run it concurrently.
+type lifecycleJanitor struct {
+	h *Handlers
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newLifecycleJanitor starts the janitor, or returns nil if it is disabled.
+func newLifecycleJanitor(h *Handlers) *lifecycleJanitor {
+	every := h.lifecycleInterval()
+	if every <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &lifecycleJanitor{h: h, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	go j.run(every)
+	return j
+}
+
+// Close stops the janitor.
+func (j *lifecycleJanitor) Close() {
+	if j == nil {
+		return
+	}
+	j.once.Do(func() {
+		j.cancel()
+		<-j.done
+	})
+}
+
+func (j *lifecycleJanitor) run(every time.Duration) {
+	defer close(j.done)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-t.C:
+			j.sweep(time.Now())
+		}
+	}
+}
+
+// sweep expires objects whose TTL has passed and applies every namespace's
+// rules. It also forgets the nonces of expired presigned uploads.
+func (j *lifecycleJanitor) sweep(now time.Time) {
+	ctx, cancel := context.WithTimeout(j.ctx, lifecycleTimeout)
+	defer cancel()
+	h := j.h
+
+	if err := h.purgePresignedUploads(ctx, now); err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "lifecycle: failed to purge presigned uploads", zap.Error(err))
+	}
+
+	// TTLs first, across namespaces; a full batch is continued next run
+	matches, err := h.expiredByTTL(ctx, "", now)
+	if err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "lifecycle: failed to list expired objects", zap.Error(err))
+	}
+	for _, m := range matches {
+		if err := h.expireObject(ctx, m.Namespace, m.Cid, lifecycleDelete); err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "lifecycle: failed to expire object",
+				zap.Error(err), zap.String("cid", m.Cid), zap.String("namespace", m.Namespace))
+		}
+	}
+
+	var policies []lifecyclePolicyRow
+	if err := h.config.DB.Query(ctx, &policies,
+		"SELECT namespace, rules, updated_at FROM storage_lifecycle_policies WHERE rules != '[]'"); err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "lifecycle: failed to list policies", zap.Error(err))
+		return
+	}
+	for _, p := range policies {
+		if ctx.Err() != nil {
+			return
+		}
+		report, err := h.evaluateLifecycle(ctx, p.Namespace, p.toPolicy().Rules, now, false)
+		if err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "lifecycle: failed to apply rules",
+				zap.Error(err), zap.String("namespace", p.Namespace))
+			continue
+		}
+		if report.Count > 0 {
+			h.logger.ComponentInfo(logging.ComponentGeneral, "lifecycle: expired objects",
+				zap.String("namespace", p.Namespace), zap.Int("count", report.Count), zap.Bool("truncated", report.Truncated))
+		}
+	}
+}
+
+// lifecycleInterval returns the configured janitor interval.
+func (h *Handlers) lifecycleInterval() time.Duration {
+	if h.config.LifecycleInterval != 0 {
+		return h.config.LifecycleInterval
+	}
+	return DefaultLifecycleInterval
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// LifecycleHandler handles /v1/storage/lifecycle.
+// GET returns the namespace's lifecycle rules, PUT replaces them and DELETE
+// removes them. PUT with dry_run=true validates the rules and reports what
+// they would expire now, without saving them.
+func (h *Handlers) LifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	if h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "lifecycle policies not available")
+		return
+	}
+	if !httputil.CheckMethodOneOf(w, r, http.MethodGet, http.MethodPut, http.MethodDelete) {
+		return
+	}
+
+	ctx := r.Context()
+	namespace := h.getNamespaceFromContext(ctx)
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		policy, err := h.lookupLifecyclePolicy(ctx, namespace)
+		if err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to look up lifecycle policy", zap.Error(err))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to look up lifecycle policy")
+			return
+		}
+		httputil.WriteJSON(w, http.StatusOK, policy)
+
+	case http.MethodPut:
+		var req StorageLifecyclePolicy
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
+			return
+		}
+		rules, err := normalizeLifecycleRules(req.Rules)
+		if err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if httputil.QueryParamBool(r, "dry_run", false) {
+			h.writeLifecycleReport(w, r, namespace, rules, true)
+			return
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		rulesJSON, _ := json.Marshal(rules)
+		if _, err := h.config.DB.Exec(ctx,
+			`INSERT INTO storage_lifecycle_policies (namespace, rules, updated_at) VALUES (?, ?, ?)
+			 ON CONFLICT(namespace) DO UPDATE SET rules = excluded.rules, updated_at = excluded.updated_at`,
+			namespace, string(rulesJSON), now.Format(time.RFC3339)); err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to save lifecycle policy", zap.Error(err))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to save lifecycle policy")
+			return
+		}
+		httputil.WriteJSON(w, http.StatusOK, StorageLifecyclePolicy{Rules: rules, UpdatedAt: &now})
+
+	case http.MethodDelete:
+		if _, err := h.config.DB.Exec(ctx, "DELETE FROM storage_lifecycle_policies WHERE namespace = ?", namespace); err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to delete lifecycle policy", zap.Error(err))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to delete lifecycle policy")
+			return
+		}
+		httputil.WriteJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+	}
+}
+
+// LifecycleRunHandler handles POST /v1/storage/lifecycle/run.
+// It applies the namespace's upload TTLs and lifecycle rules immediately
+// instead of waiting for the janitor. With dry_run=true it only reports the
+// objects that would expire.
+func (h *Handlers) LifecycleRunHandler(w http.ResponseWriter, r *http.Request) {
+	if h.ipfsClient == nil || h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "lifecycle policies not available")
+		return
+	}
+	if !httputil.CheckMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(r.Context())
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	policy, err := h.lookupLifecyclePolicy(r.Context(), namespace)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to look up lifecycle policy", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to look up lifecycle policy")
+		return
+	}
+	h.writeLifecycleReport(w, r, namespace, policy.Rules, httputil.QueryParamBool(r, "dry_run", false))
+}
+
+func (h *Handlers) writeLifecycleReport(w http.ResponseWriter, r *http.Request, namespace string, rules []StorageLifecycleRule, dryRun bool) {
+	report, err := h.evaluateLifecycle(r.Context(), namespace, rules, time.Now(), dryRun)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to evaluate lifecycle rules", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to evaluate lifecycle rules")
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, report)
+}
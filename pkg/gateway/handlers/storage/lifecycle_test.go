@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/encryption"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+)
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, true},
+		{"3600", time.Hour, true},
+		{"36h", 36 * time.Hour, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"30s", 0, false},
+		{"-1h", 0, false},
+		{"soon", 0, false},
+		{"99999d", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTTL(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseTTL(%q) = %s, %v; want %s, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// backdate sets an object's timestamps relative to now.
+func backdate(t *testing.T, db *sql.DB, cid, column string, age time.Duration) {
+	t.Helper()
+	at := time.Now().UTC().Add(-age).Format(time.RFC3339)
+	if _, err := db.Exec("UPDATE storage_objects SET "+column+" = ? WHERE cid = ?", at, cid); err != nil {
+		t.Fatalf("Failed to backdate %s: %v", cid, err)
+	}
+}
+
+func objectExists(t *testing.T, h *Handlers, cid string) *objectRow {
+	t.Helper()
+	obj, err := h.lookupObject(context.Background(), "ns", cid)
+	if err != nil {
+		t.Fatalf("Failed to look up object: %v", err)
+	}
+	return obj
+}
+
+func TestLifecycle_TTLExpiry(t *testing.T) {
+	h, db := newQueueTestHandlers(t, &flakyCluster{pinned: map[string]bool{}}, recordedUsage{})
+	h.ipfsClient = &addCluster{flakyCluster{memIPFS: memIPFS{objects: map[string][]byte{}}, pinned: map[string]bool{}}}
+	ctx := context.Background()
+
+	put := func(data string, ttl time.Duration) string {
+		cid, err := h.PutObject(ctx, "ns", strings.NewReader(data), "function-data", ttl, "function:report")
+		if err != nil {
+			t.Fatalf("Failed to store object: %v", err)
+		}
+		return cid
+	}
+	shortLived, kept := put("short-lived", time.Hour), put("kept", 0)
+	if obj := objectExists(t, h, shortLived); obj == nil || obj.toObject().ExpiresAt == nil {
+		t.Fatalf("Expected an expiry to be recorded, got %+v", obj)
+	}
+
+	// Not yet due
+	report, err := h.evaluateLifecycle(ctx, "ns", nil, time.Now(), true)
+	if err != nil || report.Count != 0 {
+		t.Fatalf("Expected nothing to expire yet, got %+v, %v", report, err)
+	}
+
+	report, err = h.evaluateLifecycle(ctx, "ns", nil, time.Now().Add(2*time.Hour), true)
+	if err != nil {
+		t.Fatalf("Failed to evaluate lifecycle: %v", err)
+	}
+	if report.Count != 1 || report.Expired[0].Cid != shortLived || report.Expired[0].Rule != lifecycleTTLRule {
+		t.Fatalf("Expected the TTL object in the dry run, got %+v", report)
+	}
+	if objectExists(t, h, shortLived) == nil {
+		t.Fatal("Expected a dry run to leave the object in place")
+	}
+
+	backdate(t, db, shortLived, "expires_at", time.Minute)
+	(&lifecycleJanitor{h: h, ctx: ctx}).sweep(time.Now())
+	if objectExists(t, h, shortLived) != nil {
+		t.Error("Expected the expired object to be removed from the index")
+	}
+	if n, _ := h.countPinRefs(ctx, shortLived); n != 0 {
+		t.Errorf("Expected the expired object's pin to be released, got %d references", n)
+	}
+	if objectExists(t, h, kept) == nil {
+		t.Error("Expected objects without a TTL to be kept")
+	}
+}
+
+func TestPutObject_EncryptsLikeUploads(t *testing.T) {
+	h, _ := newQueueTestHandlers(t, &flakyCluster{pinned: map[string]bool{}}, recordedUsage{})
+	cluster := &addCluster{flakyCluster{memIPFS: memIPFS{objects: map[string][]byte{}}, pinned: map[string]bool{}}}
+	h.ipfsClient = cluster
+	keyring, err := encryption.NewKeyring(h.config.DB, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	h.config.EnableEncryption, h.config.Keyring = true, keyring
+	ctx := context.Background()
+
+	plain := "function secret"
+	cid, err := h.PutObject(ctx, "ns", strings.NewReader(plain), "function-data", 0, "function:report")
+	if err != nil {
+		t.Fatalf("Failed to store object: %v", err)
+	}
+	if bytes.Contains(cluster.objects[cid], []byte(plain)) {
+		t.Fatal("Expected the object to reach IPFS encrypted")
+	}
+	if obj := objectExists(t, h, cid); obj == nil || obj.Size != int64(len(plain)) {
+		t.Fatalf("Expected the object in the index with its plaintext size, got %+v", obj)
+	}
+
+	rc, err := h.GetObject(ctx, "ns", cid)
+	if err != nil {
+		t.Fatalf("Failed to read object: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != plain {
+		t.Errorf("Expected %q, got %q", plain, got)
+	}
+	if _, err := h.GetObject(ctx, "other", cid); err == nil {
+		t.Error("Expected another namespace not to read the object")
+	}
+}
+
+func TestLifecycle_Rules(t *testing.T) {
+	h, db := newQueueTestHandlers(t, &flakyCluster{pinned: map[string]bool{}}, recordedUsage{})
+	ctx := context.Background()
+
+	objects := []struct {
+		cid  string
+		tags []string
+		age  time.Duration
+	}{
+		{"QmOldTmp", []string{"tmp"}, 10 * 24 * time.Hour},
+		{"QmNewTmp", []string{"tmp"}, time.Hour},
+		{"QmStale", nil, 100 * 24 * time.Hour},
+		{"QmSiteRoot", []string{"tmp"}, 10 * 24 * time.Hour},
+	}
+	for _, obj := range objects {
+		if err := h.addPinRef(ctx, "ns", obj.cid); err != nil {
+			t.Fatalf("Failed to add pin reference: %v", err)
+		}
+		h.recordObject(ctx, "ns", &StorageUploadResponse{Cid: obj.cid, Name: obj.cid}, obj.tags, true, "")
+		backdate(t, db, obj.cid, "created_at", obj.age)
+	}
+	// A recent download keeps an old object from counting as unaccessed
+	backdate(t, db, "QmOldTmp", "accessed_at", time.Hour)
+	if _, err := db.Exec(`INSERT INTO storage_sites (namespace, name, root_cid, created_at, updated_at, deployed_at)
+		VALUES ('ns', 'blog', 'QmSiteRoot', '', '', '')`); err != nil {
+		t.Fatalf("Failed to create site: %v", err)
+	}
+
+	rules, err := normalizeLifecycleRules([]StorageLifecycleRule{
+		{ID: "tmp", Tag: "tmp", ExpireAfterDays: 7},
+		{ID: "cold", UnaccessedDays: 90, Action: lifecycleUnpin},
+		{ID: "off", ExpireAfterDays: 1, Disabled: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to normalize rules: %v", err)
+	}
+
+	report, err := h.evaluateLifecycle(ctx, "ns", rules, time.Now(), false)
+	if err != nil {
+		t.Fatalf("Failed to evaluate lifecycle: %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range report.Expired {
+		got[e.Cid] = e.Rule + "/" + e.Action
+	}
+	want := map[string]string{"QmOldTmp": "tmp/delete", "QmStale": "cold/unpin"}
+	if len(got) != len(want) || got["QmOldTmp"] != want["QmOldTmp"] || got["QmStale"] != want["QmStale"] {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+
+	if objectExists(t, h, "QmOldTmp") != nil {
+		t.Error("Expected the deleted object to leave the index")
+	}
+	if obj := objectExists(t, h, "QmStale"); obj == nil || obj.Pinned {
+		t.Errorf("Expected the unpinned object to stay indexed but unpinned, got %+v", obj)
+	}
+	if n, _ := h.countPinRefs(ctx, "QmSiteRoot"); n != 1 {
+		t.Error("Expected site content to be left alone")
+	}
+
+	// Unpinned objects no longer match an unpin rule
+	report, err = h.evaluateLifecycle(ctx, "ns", rules, time.Now(), true)
+	if err != nil || report.Count != 0 {
+		t.Errorf("Expected nothing left to expire, got %+v, %v", report, err)
+	}
+}
+
+func TestLifecycleHandler_Validation(t *testing.T) {
+	h, _ := newQueueTestHandlers(t, &flakyCluster{pinned: map[string]bool{}}, recordedUsage{})
+
+	put := func(body string, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/v1/storage/lifecycle"+query, bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, "ns"))
+		w := httptest.NewRecorder()
+		h.LifecycleHandler(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"rules":[{"id":"a"}]}`,
+		`{"rules":[{"id":"a","expire_after_days":1,"action":"archive"}]}`,
+		`{"rules":[{"id":"a","expire_after_days":1},{"id":"a","unaccessed_days":1}]}`,
+		`{"rules":[{"id":"ttl","expire_after_days":1}]}`,
+	} {
+		if w := put(body, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", body, w.Code)
+		}
+	}
+
+	// A dry run validates without saving
+	if w := put(`{"rules":[{"id":"tmp","tag":"tmp","expire_after_days":7}]}`, "?dry_run=true"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if policy, _ := h.lookupLifecyclePolicy(context.Background(), "ns"); len(policy.Rules) != 0 {
+		t.Fatalf("Expected a dry run not to save rules, got %+v", policy)
+	}
+
+	w := put(`{"rules":[{"id":"tmp","tag":"tmp","expire_after_days":7}]}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var saved StorageLifecyclePolicy
+	if err := json.Unmarshal(w.Body.Bytes(), &saved); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(saved.Rules) != 1 || saved.Rules[0].Action != lifecycleDelete || saved.UpdatedAt == nil {
+		t.Errorf("Expected the rule to be saved with the default action, got %+v", saved)
+	}
+}
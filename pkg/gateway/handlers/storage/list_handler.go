@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// listSortColumns maps the sort query parameter to columns of storage_objects.
+var listSortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"name":       "name",
+	"size":       "size",
+}
+
+// ListObjectsHandler handles GET /v1/storage/objects.
+// It lists the objects recorded for the caller's namespace. Supported filters:
+// prefix (name prefix), q (name substring), tag (repeatable; all must match),
+// content_type ("image/" matches a whole family), pinned and created_by.
+// Results are paginated with limit/offset and ordered by sort/order.
+func (h *Handlers) ListObjectsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "object index not available")
+		return
+	}
+
+	if !httputil.CheckMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(r.Context())
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	q := r.URL.Query()
+	where := []string{"namespace = ?"}
+	args := []any{namespace}
+
+	if v := q.Get("prefix"); v != "" {
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(v)+"%")
+	}
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(v)+"%")
+	}
+	for _, tag := range q["tag"] {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		where = append(where, "EXISTS (SELECT 1 FROM json_each(storage_objects.tags) WHERE json_each.value = ?)")
+		args = append(args, tag)
+	}
+	if v := strings.TrimSpace(q.Get("content_type")); v != "" {
+		if strings.HasSuffix(v, "/") {
+			where = append(where, `content_type LIKE ? ESCAPE '\'`)
+			args = append(args, escapeLike(v)+"%")
+		} else {
+			where = append(where, "content_type = ?")
+			args = append(args, v)
+		}
+	}
+	if q.Has("pinned") {
+		where = append(where, "pinned = ?")
+		args = append(args, httputil.QueryParamBool(r, "pinned", true))
+	}
+	if v := strings.TrimSpace(q.Get("created_by")); v != "" {
+		where = append(where, "created_by = ?")
+		args = append(args, v)
+	}
+
+	sortCol := "created_at"
+	if v := q.Get("sort"); v != "" {
+		if sortCol = listSortColumns[v]; sortCol == "" {
+			httputil.WriteError(w, http.StatusBadRequest, "invalid sort; expected created_at, updated_at, name or size")
+			return
+		}
+	}
+	order := "DESC"
+	switch strings.ToLower(q.Get("order")) {
+	case "", "desc":
+	case "asc":
+		order = "ASC"
+	default:
+		httputil.WriteError(w, http.StatusBadRequest, "invalid order; expected asc or desc")
+		return
+	}
+
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httputil.WriteError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = min(n, 1000)
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httputil.WriteError(w, http.StatusBadRequest, "invalid offset")
+			return
+		}
+		offset = n
+	}
+
+	ctx := r.Context()
+	cond := strings.Join(where, " AND ")
+
+	var totals []struct {
+		Total int64 `db:"total"`
+	}
+	if err := h.config.DB.Query(ctx, &totals, "SELECT COUNT(*) AS total FROM storage_objects WHERE "+cond, args...); err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to count storage objects", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to list objects")
+		return
+	}
+
+	var rows []objectRow
+	if err := h.config.DB.Query(ctx, &rows,
+		"SELECT "+objectColumns+" FROM storage_objects WHERE "+cond+
+			" ORDER BY "+sortCol+" "+order+", cid "+order+" LIMIT ? OFFSET ?",
+		append(args, limit, offset)...); err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to list storage objects", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to list objects")
+		return
+	}
+
+	response := StorageObjectListResponse{
+		Objects: make([]StorageObject, 0, len(rows)),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	for i := range rows {
+		response.Objects = append(response.Objects, rows[i].toObject())
+	}
+	response.Count = len(response.Objects)
+	if len(totals) > 0 {
+		response.Total = totals[0].Total
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, response)
+}
+
+// ObjectHandler handles GET and PUT /v1/storage/objects/:cid.
+// GET returns the object's index entry; PUT replaces its name and/or tags.
+func (h *Handlers) ObjectHandler(w http.ResponseWriter, r *http.Request) {
+	if h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "object index not available")
+		return
+	}
+
+	if !httputil.CheckMethodOneOf(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+
+	cid := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/storage/objects/"), "/")
+	if cid == "" {
+		httputil.WriteError(w, http.StatusBadRequest, "cid required")
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(r.Context())
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	ctx := r.Context()
+
+	if r.Method == http.MethodPut {
+		var req StorageObjectUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
+			return
+		}
+
+		sets := []string{"updated_at = ?"}
+		args := []any{time.Now().UTC().Format(time.RFC3339)}
+		if req.Name != nil {
+			sets = append(sets, "name = ?")
+			args = append(args, strings.TrimSpace(*req.Name))
+		}
+		if req.Tags != nil {
+			tags, err := normalizeTags(*req.Tags)
+			if err != nil {
+				httputil.WriteError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			tagsJSON, _ := json.Marshal(tags)
+			sets = append(sets, "tags = ?")
+			args = append(args, string(tagsJSON))
+		}
+
+		res, err := h.config.DB.Exec(ctx,
+			"UPDATE storage_objects SET "+strings.Join(sets, ", ")+" WHERE namespace = ? AND cid = ?",
+			append(args, namespace, cid)...)
+		if err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to update storage object",
+				zap.Error(err), zap.String("cid", cid))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to update object")
+			return
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			httputil.WriteError(w, http.StatusNotFound, fmt.Sprintf("object not found: %s", cid))
+			return
+		}
+	}
+
+	row, err := h.lookupObject(ctx, namespace, cid)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to look up storage object",
+			zap.Error(err), zap.String("cid", cid))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to look up object")
+		return
+	}
+	if row == nil {
+		httputil.WriteError(w, http.StatusNotFound, fmt.Sprintf("object not found: %s", cid))
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, row.toObject())
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
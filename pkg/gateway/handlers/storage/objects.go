@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// DB is the subset of rqlite.Client used for the namespace object index.
+type DB interface {
+	Query(ctx context.Context, dest any, query string, args ...any) error
+	Exec(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+const (
+	// maxTags and maxTagLength bound the tags attached to one object.
+	maxTags      = 32
+	maxTagLength = 64
+
+	// sniffLen is how much of an upload is inspected to detect its content type.
+	sniffLen = 512
+
+	objectColumns = "namespace, cid, name, size, content_type, tags, encrypted, pinned, created_by, created_at, updated_at, expires_at, accessed_at"
+
+	// accessTouchInterval limits how often a download refreshes accessed_at.
+	accessTouchInterval = 24 * time.Hour
+)
+
+// objectRow is a row of the storage_objects table.
+type objectRow struct {
+	Namespace   string `db:"namespace"`
+	Cid         string `db:"cid"`
+	Name        string `db:"name"`
+	Size        int64  `db:"size"`
+	ContentType string `db:"content_type"`
+	Tags        string `db:"tags"`
+	Encrypted   bool   `db:"encrypted"`
+	Pinned      bool   `db:"pinned"`
+	CreatedBy   string `db:"created_by"`
+	CreatedAt   string `db:"created_at"`
+	UpdatedAt   string `db:"updated_at"`
+	ExpiresAt   string `db:"expires_at"`
+	AccessedAt  string `db:"accessed_at"`
+}
+
+func (o *objectRow) toObject() StorageObject {
+	obj := StorageObject{
+		Cid:         o.Cid,
+		Name:        o.Name,
+		Size:        o.Size,
+		ContentType: o.ContentType,
+		Tags:        []string{},
+		Encrypted:   o.Encrypted,
+		Pinned:      o.Pinned,
+		CreatedBy:   o.CreatedBy,
+	}
+	_ = json.Unmarshal([]byte(o.Tags), &obj.Tags)
+	obj.CreatedAt, _ = time.Parse(time.RFC3339, o.CreatedAt)
+	obj.UpdatedAt, _ = time.Parse(time.RFC3339, o.UpdatedAt)
+	obj.ExpiresAt = parseOptionalTime(o.ExpiresAt)
+	obj.AccessedAt = parseOptionalTime(o.AccessedAt)
+	return obj
+}
+
+// parseOptionalTime parses an RFC3339 column; an empty value means unset.
+func parseOptionalTime(v string) *time.Time {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// recordObject upserts an uploaded object into the namespace index. The index
+// is advisory, so failures are logged rather than failing the upload.
+func (h *Handlers) recordObject(ctx context.Context, namespace string, resp *StorageUploadResponse, tags []string, pinned bool, createdBy string) {
+	if h.config.DB == nil {
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	tagsJSON, _ := json.Marshal(tags)
+	expiresAt := ""
+	if resp.ExpiresAt != nil {
+		expiresAt = resp.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	_, err := h.config.DB.Exec(ctx,
+		`INSERT INTO storage_objects (`+objectColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '')
+		 ON CONFLICT(namespace, cid) DO UPDATE SET
+		   name = CASE WHEN excluded.name != '' THEN excluded.name ELSE storage_objects.name END,
+		   size = excluded.size,
+		   content_type = excluded.content_type,
+		   tags = CASE WHEN excluded.tags != '[]' THEN excluded.tags ELSE storage_objects.tags END,
+		   encrypted = excluded.encrypted,
+		   pinned = storage_objects.pinned OR excluded.pinned,
+		   updated_at = excluded.updated_at,
+		   expires_at = excluded.expires_at`,
+		namespace, resp.Cid, resp.Name, resp.Size, resp.ContentType, string(tagsJSON), resp.Encrypted, pinned, createdBy, now, now, expiresAt)
+	if err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to record storage object",
+			zap.Error(err), zap.String("cid", resp.Cid), zap.String("namespace", namespace))
+	}
+}
+
+// recordPin marks cid as pinned (or unpinned) in the namespace index, adding
+// it if the namespace pinned content it did not upload.
+func (h *Handlers) recordPin(ctx context.Context, namespace, cid, name string, pinned bool, createdBy string) {
+	if h.config.DB == nil {
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var err error
+	if pinned {
+		_, err = h.config.DB.Exec(ctx,
+			`INSERT INTO storage_objects (namespace, cid, name, pinned, created_by, created_at, updated_at) VALUES (?, ?, ?, TRUE, ?, ?, ?)
+			 ON CONFLICT(namespace, cid) DO UPDATE SET
+			   name = CASE WHEN excluded.name != '' THEN excluded.name ELSE storage_objects.name END,
+			   pinned = TRUE,
+			   updated_at = excluded.updated_at`,
+			namespace, cid, name, createdBy, now, now)
+	} else {
+		_, err = h.config.DB.Exec(ctx,
+			"UPDATE storage_objects SET pinned = FALSE, updated_at = ? WHERE namespace = ? AND cid = ?",
+			now, namespace, cid)
+	}
+	if err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to record pin state",
+			zap.Error(err), zap.String("cid", cid), zap.String("namespace", namespace))
+	}
+}
+
+// touchObject records a download of obj for lifecycle rules on access time.
+// Writes are skipped while the recorded access is recent.
+func (h *Handlers) touchObject(ctx context.Context, obj *objectRow) {
+	now := time.Now().UTC()
+	if last, err := time.Parse(time.RFC3339, obj.AccessedAt); err == nil && now.Sub(last) < accessTouchInterval {
+		return
+	}
+	if _, err := h.config.DB.Exec(ctx,
+		"UPDATE storage_objects SET accessed_at = ? WHERE namespace = ? AND cid = ?",
+		now.Format(time.RFC3339), obj.Namespace, obj.Cid); err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to record storage object access",
+			zap.Error(err), zap.String("cid", obj.Cid), zap.String("namespace", obj.Namespace))
+	}
+}
+
+// lookupObject returns namespace's index entry for cid, or nil if there is none.
+func (h *Handlers) lookupObject(ctx context.Context, namespace, cid string) (*objectRow, error) {
+	var rows []objectRow
+	if err := h.config.DB.Query(ctx, &rows,
+		"SELECT "+objectColumns+" FROM storage_objects WHERE namespace = ? AND cid = ? LIMIT 1", namespace, cid); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// normalizeTags trims, de-duplicates and validates tags.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > maxTagLength {
+			return nil, fmt.Errorf("tag %q exceeds %d characters", t, maxTagLength)
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	return out, nil
+}
+
+// splitTags parses a comma-separated tag list from a form field or query parameter.
+func splitTags(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// callerIdentity returns who made the request: the wallet from a JWT, or a
+// fingerprint of the API key. The raw API key is never recorded.
+func callerIdentity(ctx context.Context) string {
+	if v := ctx.Value(ctxkeys.JWT); v != nil {
+		if claims, ok := v.(*auth.JWTClaims); ok && claims != nil && claims.Sub != "" {
+			return claims.Sub
+		}
+	}
+	if v, ok := ctx.Value(ctxkeys.APIKey).(string); ok && v != "" {
+		sum := sha256.Sum256([]byte(v))
+		return "apikey:" + hex.EncodeToString(sum[:6])
+	}
+	return ""
+}
+
+// sniffReader keeps the first sniffLen bytes read so the content type can be
+// detected without buffering the whole object.
+type sniffReader struct {
+	r    io.Reader
+	head []byte
+}
+
+func (s *sniffReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if room := sniffLen - len(s.head); room > 0 && n > 0 {
+		s.head = append(s.head, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+// detectContentType picks the declared type, then the file extension, then
+// the sniffed content.
+func detectContentType(declared, name string, head []byte) string {
+	if declared != "" && declared != "application/octet-stream" {
+		return declared
+	}
+	if ext := path.Ext(name); ext != "" {
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
+	}
+	if len(head) == 0 {
+		return "application/octet-stream"
+	}
+	return http.DetectContentType(head)
+}
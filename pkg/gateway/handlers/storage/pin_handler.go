@@ -34,17 +34,42 @@ func (h *Handlers) PinHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get replication factor from config (default: 3)
-	replicationFactor := h.config.IPFSReplicationFactor
+	if req.ReplicationFactor < 0 || req.ReplicationFactor > maxReplicationFactor {
+		httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("replication_factor must be between 0 and %d (0 uses the default)", maxReplicationFactor))
+		return
+	}
+
+	// Use the requested replication factor, else the configured one (default: 3)
+	replicationFactor := req.ReplicationFactor
 	if replicationFactor == 0 {
-		replicationFactor = 3
+		replicationFactor = h.replicationFactor()
 	}
 
 	ctx := r.Context()
+	namespace := h.getNamespaceFromContext(ctx)
+	addedRef := false
+	if namespace != "" && h.config.DB != nil {
+		// Take the reference first so a concurrent unpin from another
+		// namespace cannot drop the cluster pin underneath us
+		var err error
+		if addedRef, err = h.takePinRef(ctx, namespace, req.Cid); err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to record pin reference",
+				zap.Error(err), zap.String("cid", req.Cid))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to record pin reference")
+			return
+		}
+	}
+
 	pinResp, err := h.ipfsClient.Pin(ctx, req.Cid, req.Name, replicationFactor)
 	if err != nil {
 		h.logger.ComponentError(logging.ComponentGeneral, "failed to pin CID",
 			zap.Error(err), zap.String("cid", req.Cid))
+		if addedRef {
+			if _, _, rerr := h.releasePinRef(ctx, namespace, req.Cid); rerr != nil {
+				h.logger.ComponentWarn(logging.ComponentGeneral, "failed to drop pin reference",
+					zap.Error(rerr), zap.String("cid", req.Cid), zap.String("namespace", namespace))
+			}
+		}
 		httputil.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("failed to pin: %v", err))
 		return
 	}
@@ -55,6 +80,10 @@ func (h *Handlers) PinHandler(w http.ResponseWriter, r *http.Request) {
 		name = req.Name
 	}
 
+	if namespace != "" {
+		h.recordPin(ctx, namespace, pinResp.Cid, name, true, callerIdentity(ctx))
+	}
+
 	response := StoragePinResponse{
 		Cid:  pinResp.Cid,
 		Name: name,
@@ -0,0 +1,387 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/ipfs"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
+	"go.uber.org/zap"
+)
+
+// Pin job states, as reported by StatusHandler.
+const (
+	pinStateQueued  = "queued"
+	pinStatePinning = "pinning"
+	pinStatePinned  = "pinned"
+	pinStateFailed  = "failed"
+)
+
+// Defaults for the background pin queue
+const (
+	DefaultPinMaxAttempts       = 10
+	DefaultPinReconcileInterval = 15 * time.Minute
+
+	pinPollInterval  = 2 * time.Second
+	pinBatchSize     = 20
+	pinLease         = 5 * time.Minute // a claimed job is retried by any gateway after this
+	pinTimeout       = 2 * time.Minute
+	pinBackoffBase   = 2 * time.Second
+	pinBackoffMax    = 10 * time.Minute
+	reconcileBatch   = 100
+	reconcileTimeout = 30 * time.Second
+)
+
+// peerCounter is implemented by IPFS clients that can count cluster peers.
+type peerCounter interface {
+	GetPeerCount(ctx context.Context) (int, error)
+}
+
+const pinJobColumns = "namespace, cid, name, size, replication_factor, state, attempts, last_error, next_attempt_at"
+
+// pinJob is a row of storage_pin_jobs.
+type pinJob struct {
+	Namespace         string `db:"namespace"`
+	Cid               string `db:"cid"`
+	Name              string `db:"name"`
+	Size              int64  `db:"size"`
+	ReplicationFactor int    `db:"replication_factor"`
+	State             string `db:"state"`
+	Attempts          int    `db:"attempts"`
+	LastError         string `db:"last_error"`
+	NextAttemptAt     string `db:"next_attempt_at"`
+}
+
+// pinQueue drives durable pin jobs to completion and periodically reconciles
+// expected pins against the cluster. Jobs are claimed with a lease, so several
+// gateways can share the queue and jobs held by a crashed gateway are retried.
+type pinQueue struct {
+	h    *Handlers
+	wake chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newPinQueue starts the background pin worker.
+func newPinQueue(h *Handlers) *pinQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &pinQueue{
+		h:      h,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Close stops the worker. Jobs in flight are picked up again once their lease expires.
+func (q *pinQueue) Close() {
+	if q == nil {
+		return
+	}
+	q.once.Do(func() {
+		q.cancel()
+		<-q.done
+	})
+}
+
+func (q *pinQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *pinQueue) run() {
+	defer close(q.done)
+
+	poll := time.NewTicker(pinPollInterval)
+	defer poll.Stop()
+
+	var reconcile <-chan time.Time
+	if every := q.h.pinReconcileInterval(); every > 0 {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		reconcile = t.C
+	}
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-q.wake:
+			q.processDue()
+		case <-poll.C:
+			q.processDue()
+		case <-reconcile:
+			q.reconcile()
+		}
+	}
+}
+
+// enqueuePin schedules a durable pin of cid on behalf of namespace. Without a
+// database the pin falls back to in-memory retries.
+func (h *Handlers) enqueuePin(ctx context.Context, namespace, cid, name string, size int64, replicationFactor int) {
+	if replicationFactor <= 0 {
+		replicationFactor = h.replicationFactor()
+	}
+	if h.pins != nil && namespace != "" {
+		now := time.Now().UTC().Format(time.RFC3339)
+		_, err := h.config.DB.Exec(ctx,
+			`INSERT INTO storage_pin_jobs (namespace, cid, name, size, replication_factor, state, attempts, last_error, next_attempt_at, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, 'queued', 0, '', ?, ?, ?)
+			 ON CONFLICT(namespace, cid) DO UPDATE SET
+			   name = excluded.name,
+			   size = excluded.size,
+			   replication_factor = excluded.replication_factor,
+			   state = CASE WHEN storage_pin_jobs.state IN ('pinned', 'pinning') THEN storage_pin_jobs.state ELSE 'queued' END,
+			   attempts = CASE WHEN storage_pin_jobs.state IN ('pinned', 'pinning') THEN storage_pin_jobs.attempts ELSE 0 END,
+			   next_attempt_at = CASE WHEN storage_pin_jobs.state IN ('pinned', 'pinning') THEN storage_pin_jobs.next_attempt_at ELSE excluded.next_attempt_at END,
+			   updated_at = excluded.updated_at`,
+			namespace, cid, name, size, replicationFactor, now, now, now)
+		if err == nil {
+			h.pins.notify()
+			return
+		}
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to queue pin, pinning in background",
+			zap.Error(err), zap.String("cid", cid))
+	}
+	go h.pinAsync(context.WithoutCancel(ctx), cid, name, size, replicationFactor)
+}
+
+// lookupPinJob returns namespace's pin job for cid, or nil if there is none.
+func (h *Handlers) lookupPinJob(ctx context.Context, namespace, cid string) (*pinJob, error) {
+	var jobs []pinJob
+	if err := h.config.DB.Query(ctx, &jobs,
+		"SELECT "+pinJobColumns+" FROM storage_pin_jobs WHERE namespace = ? AND cid = ? LIMIT 1", namespace, cid); err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// processDue claims and runs every job whose next attempt is due.
+func (q *pinQueue) processDue() {
+	h := q.h
+	for q.ctx.Err() == nil {
+		now := time.Now().UTC()
+		var jobs []pinJob
+		if err := h.config.DB.Query(q.ctx, &jobs,
+			"SELECT "+pinJobColumns+" FROM storage_pin_jobs WHERE state IN ('queued', 'pinning') AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?",
+			now.Format(time.RFC3339), pinBatchSize); err != nil {
+			if q.ctx.Err() == nil {
+				h.logger.ComponentWarn(logging.ComponentGeneral, "failed to load due pin jobs", zap.Error(err))
+			}
+			return
+		}
+
+		claimed := 0
+		for i := range jobs {
+			if q.claim(&jobs[i], now) {
+				claimed++
+				q.pin(&jobs[i])
+			}
+		}
+		if len(jobs) < pinBatchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+// claim marks job as pinning for the lease period. It fails if another
+// worker claimed or changed the job since it was read.
+func (q *pinQueue) claim(job *pinJob, now time.Time) bool {
+	res, err := q.h.config.DB.Exec(q.ctx,
+		`UPDATE storage_pin_jobs SET state = 'pinning', attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		 WHERE namespace = ? AND cid = ? AND state = ? AND next_attempt_at = ?`,
+		now.Add(pinLease).Format(time.RFC3339), now.Format(time.RFC3339),
+		job.Namespace, job.Cid, job.State, job.NextAttemptAt)
+	if err != nil {
+		return false
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n != 1 {
+		return false
+	}
+	job.Attempts++
+	job.State = pinStatePinning
+	return true
+}
+
+// pin runs a claimed job and records its outcome.
+func (q *pinQueue) pin(job *pinJob) {
+	h := q.h
+	rf := job.ReplicationFactor
+	if rf <= 0 {
+		rf = h.replicationFactor()
+	}
+
+	ctx, cancel := context.WithTimeout(q.ctx, pinTimeout)
+	_, pinErr := h.ipfsClient.Pin(ctx, job.Cid, job.Name, rf)
+	cancel()
+	if q.ctx.Err() != nil {
+		return // shutting down; the lease expires and the job is retried
+	}
+
+	now := time.Now().UTC()
+	if pinErr == nil {
+		res, err := h.config.DB.Exec(q.ctx,
+			"UPDATE storage_pin_jobs SET state = 'pinned', last_error = '', updated_at = ? WHERE namespace = ? AND cid = ? AND state = 'pinning'",
+			now.Format(time.RFC3339), job.Namespace, job.Cid)
+		if err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to record pin success",
+				zap.Error(err), zap.String("cid", job.Cid))
+			return
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			// Unpinned while we were pinning; don't leave an orphaned cluster pin
+			if refs, err := h.countPinRefs(q.ctx, job.Cid); err == nil && refs == 0 {
+				_ = h.ipfsClient.Unpin(q.ctx, job.Cid)
+			}
+			return
+		}
+		if h.config.Meter != nil {
+			h.config.Meter.Record(job.Namespace, metering.StorageBytesPinned, float64(job.Size))
+		}
+		h.logger.ComponentInfo(logging.ComponentGeneral, "queued pin succeeded",
+			zap.String("cid", job.Cid), zap.Int("attempts", job.Attempts))
+		return
+	}
+
+	state, next := pinStateQueued, now.Add(pinBackoff(job.Attempts))
+	if job.Attempts >= h.pinMaxAttempts() {
+		state = pinStateFailed
+		h.logger.ComponentError(logging.ComponentGeneral, "pin failed permanently",
+			zap.Error(pinErr), zap.String("cid", job.Cid), zap.String("namespace", job.Namespace),
+			zap.Int("attempts", job.Attempts))
+	} else {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "pin attempt failed, will retry",
+			zap.Error(pinErr), zap.String("cid", job.Cid), zap.Int("attempts", job.Attempts),
+			zap.Time("next_attempt_at", next))
+	}
+	if _, err := h.config.DB.Exec(q.ctx,
+		"UPDATE storage_pin_jobs SET state = ?, last_error = ?, next_attempt_at = ?, updated_at = ? WHERE namespace = ? AND cid = ? AND state = 'pinning'",
+		state, pinErr.Error(), next.Format(time.RFC3339), now.Format(time.RFC3339), job.Namespace, job.Cid); err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to record pin failure",
+			zap.Error(err), zap.String("cid", job.Cid))
+	}
+}
+
+// reconcile walks every CID some namespace holds a pin reference on and
+// re-pins content the cluster has lost or under-replicated. Each CID keeps
+// the replication factor it was uploaded with, the highest one when several
+// namespaces uploaded it.
+func (q *pinQueue) reconcile() {
+	h := q.h
+	peers := q.clusterPeers()
+	repinned := 0
+	after := ""
+	for q.ctx.Err() == nil {
+		var rows []struct {
+			Cid               string `db:"cid"`
+			ReplicationFactor int    `db:"replication_factor"`
+		}
+		if err := h.config.DB.Query(q.ctx, &rows,
+			`SELECT r.cid AS cid, COALESCE(MAX(j.replication_factor), 0) AS replication_factor
+			 FROM storage_pin_refs r
+			 LEFT JOIN storage_pin_jobs j ON j.namespace = r.namespace AND j.cid = r.cid
+			 WHERE r.cid > ? GROUP BY r.cid ORDER BY r.cid LIMIT ?`, after, reconcileBatch); err != nil {
+			if q.ctx.Err() == nil {
+				h.logger.ComponentWarn(logging.ComponentGeneral, "failed to load pin references for reconciliation", zap.Error(err))
+			}
+			return
+		}
+
+		for _, row := range rows {
+			rf := row.ReplicationFactor
+			if rf <= 0 {
+				rf = h.replicationFactor()
+			}
+			ctx, cancel := context.WithTimeout(q.ctx, reconcileTimeout)
+			status, err := h.ipfsClient.PinStatus(ctx, row.Cid)
+			if err == nil && pinHealthy(status, rf, peers) {
+				cancel()
+				continue
+			}
+			if _, err := h.ipfsClient.Pin(ctx, row.Cid, "", rf); err != nil {
+				h.logger.ComponentWarn(logging.ComponentGeneral, "failed to re-pin under-replicated content",
+					zap.Error(err), zap.String("cid", row.Cid))
+			} else {
+				repinned++
+			}
+			cancel()
+		}
+
+		if len(rows) < reconcileBatch {
+			break
+		}
+		after = rows[len(rows)-1].Cid
+	}
+	if repinned > 0 {
+		h.logger.ComponentInfo(logging.ComponentGeneral, "pin reconciliation re-pinned content", zap.Int("count", repinned))
+	}
+}
+
+// clusterPeers returns the number of cluster peers, or 0 when the client
+// cannot tell.
+func (q *pinQueue) clusterPeers() int {
+	counter, ok := q.h.ipfsClient.(peerCounter)
+	if !ok {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(q.ctx, reconcileTimeout)
+	defer cancel()
+	n, err := counter.GetPeerCount(ctx)
+	if err != nil {
+		q.h.logger.ComponentWarn(logging.ComponentGeneral, "failed to count cluster peers for reconciliation", zap.Error(err))
+		return 0
+	}
+	return n
+}
+
+// pinHealthy reports whether the cluster tracks the pin on at least rf peers,
+// or on every peer when the cluster has fewer than rf (peers > 0).
+func pinHealthy(status *ipfs.PinStatus, rf, peers int) bool {
+	switch strings.ToLower(status.Status) {
+	case "pinned", "pinning", "queued", "pin_queued", "remote":
+	default:
+		return false
+	}
+	if peers > 0 && peers < rf {
+		rf = peers
+	}
+	return len(status.Peers) >= rf
+}
+
+// pinBackoff returns the delay before retrying after attempts failures.
+func pinBackoff(attempts int) time.Duration {
+	d := pinBackoffBase
+	for i := 1; i < attempts && d < pinBackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, pinBackoffMax)
+}
+
+func (h *Handlers) pinMaxAttempts() int {
+	if h.config.PinMaxAttempts > 0 {
+		return h.config.PinMaxAttempts
+	}
+	return DefaultPinMaxAttempts
+}
+
+func (h *Handlers) pinReconcileInterval() time.Duration {
+	if h.config.PinReconcileInterval != 0 {
+		return h.config.PinReconcileInterval
+	}
+	return DefaultPinReconcileInterval
+}
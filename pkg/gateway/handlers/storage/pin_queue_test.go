@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/ipfs"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
+	"github.com/DeBrosOfficial/network/pkg/rqlite"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// flakyCluster fails the first failures pin calls and tracks pinned CIDs.
+type flakyCluster struct {
+	memIPFS
+	failures int
+	pins     int
+	pinned   map[string]bool
+	lastRF   int // replication factor of the latest pin call
+	peers    int // reported cluster size; 0 when unknown
+}
+
+func (c *flakyCluster) Pin(ctx context.Context, cid, name string, replicationFactor int) (*ipfs.PinResponse, error) {
+	c.pins++
+	c.lastRF = replicationFactor
+	if c.failures > 0 {
+		c.failures--
+		return nil, fmt.Errorf("cluster unavailable")
+	}
+	c.pinned[cid] = true
+	return &ipfs.PinResponse{Cid: cid, Name: name}, nil
+}
+
+func (c *flakyCluster) PinStatus(ctx context.Context, cid string) (*ipfs.PinStatus, error) {
+	if !c.pinned[cid] {
+		return nil, fmt.Errorf("pin not found: %s", cid)
+	}
+	return &ipfs.PinStatus{Cid: cid, Status: "pinned", Peers: []string{"a", "b", "c"}}, nil
+}
+
+func (c *flakyCluster) GetPeerCount(ctx context.Context) (int, error) {
+	return c.peers, nil
+}
+
+type recordedUsage map[string]float64
+
+func (r recordedUsage) Record(namespace, metric string, delta float64) {
+	r[namespace+"/"+metric] += delta
+}
+
+// newStorageTestDB opens an in-memory SQLite database with the storage migrations applied.
+func newStorageTestDB(t *testing.T) (DB, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("Failed to create schema_migrations: %v", err)
+	}
+	for _, name := range []string{"008_storage_encryption.sql", "009_storage_objects.sql", "010_storage_pin_refs.sql", "011_storage_pin_jobs.sql", "012_storage_sites.sql", "013_storage_lifecycle.sql", "014_storage_presign_key.sql", "021_storage_presign_uploads.sql"} {
+		migration, err := os.ReadFile(filepath.Join("..", "..", "..", "..", "migrations", name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("Failed to apply %s: %v", name, err)
+		}
+	}
+	return rqlite.NewClient(db), db
+}
+
+// newQueueTestHandlers returns handlers with a pin queue that is driven by the
+// test instead of a background goroutine.
+func newQueueTestHandlers(t *testing.T, cluster *flakyCluster, usage recordedUsage) (*Handlers, *sql.DB) {
+	t.Helper()
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	orm, db := newStorageTestDB(t)
+	h := &Handlers{
+		ipfsClient: cluster,
+		logger:     logger,
+		config:     Config{DB: orm, Meter: usage, PinMaxAttempts: 3},
+	}
+	h.pins = &pinQueue{h: h, wake: make(chan struct{}, 1), ctx: context.Background()}
+	return h, db
+}
+
+func pinStatus(t *testing.T, h *Handlers, cid string) StorageStatusResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/v1/storage/status/"+cid, nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, "ns"))
+	w := httptest.NewRecorder()
+	h.StatusHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp StorageStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	return resp
+}
+
+// makeDue moves every pending job's next attempt into the past.
+func makeDue(t *testing.T, db *sql.DB) {
+	t.Helper()
+	past := time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+	if _, err := db.Exec("UPDATE storage_pin_jobs SET next_attempt_at = ?", past); err != nil {
+		t.Fatalf("Failed to reschedule jobs: %v", err)
+	}
+}
+
+func TestPinQueue_RetriesUntilPinned(t *testing.T) {
+	cluster := &flakyCluster{failures: 1, pinned: map[string]bool{}}
+	usage := recordedUsage{}
+	h, db := newQueueTestHandlers(t, cluster, usage)
+	ctx := context.Background()
+
+	h.enqueuePin(ctx, "ns", "QmQueued", "report.pdf", 1024, 0)
+	if got := pinStatus(t, h, "QmQueued"); got.Status != pinStateQueued {
+		t.Fatalf("Expected %q before the worker runs, got %q", pinStateQueued, got.Status)
+	}
+
+	h.pins.processDue()
+	got := pinStatus(t, h, "QmQueued")
+	if got.Status != pinStateQueued || got.Attempts != 1 || got.Error == "" || got.NextAttemptAt == "" {
+		t.Fatalf("Expected a scheduled retry after the first failure, got %+v", got)
+	}
+
+	// Backoff keeps the job from being retried immediately
+	h.pins.processDue()
+	if cluster.pins != 1 {
+		t.Fatalf("Expected retry to wait for backoff, got %d pin calls", cluster.pins)
+	}
+
+	makeDue(t, db)
+	h.pins.processDue()
+	if !cluster.pinned["QmQueued"] {
+		t.Fatal("Expected CID to be pinned on retry")
+	}
+	if got := pinStatus(t, h, "QmQueued"); got.Status != "pinned" {
+		t.Errorf("Expected cluster status once pinned, got %q", got.Status)
+	}
+	if usage["ns/"+metering.StorageBytesPinned] != 1024 {
+		t.Errorf("Expected pinned bytes to be metered, got %v", usage)
+	}
+}
+
+func TestPinQueue_ReplicationOverride(t *testing.T) {
+	h, _ := newQueueTestHandlers(t, &flakyCluster{pinned: map[string]bool{}}, recordedUsage{})
+	ctx := context.Background()
+
+	h.enqueuePin(ctx, "ns", "QmDefault", "", 1, 0)
+	h.enqueuePin(ctx, "ns", "QmReplicated", "", 1, 5)
+	for cid, want := range map[string]int{"QmDefault": h.replicationFactor(), "QmReplicated": 5} {
+		job, err := h.lookupPinJob(ctx, "ns", cid)
+		if err != nil || job == nil {
+			t.Fatalf("Failed to look up pin job for %s: %v", cid, err)
+		}
+		if job.ReplicationFactor != want {
+			t.Errorf("%s: expected replication factor %d, got %d", cid, want, job.ReplicationFactor)
+		}
+	}
+
+	if _, err := parseReplicationFactor("11"); err == nil {
+		t.Error("Expected replication factor above the maximum to be rejected")
+	}
+}
+
+func TestPinQueue_FailsAfterMaxAttempts(t *testing.T) {
+	cluster := &flakyCluster{failures: 10, pinned: map[string]bool{}}
+	h, db := newQueueTestHandlers(t, cluster, recordedUsage{})
+
+	h.enqueuePin(context.Background(), "ns", "QmBroken", "", 1, 0)
+	for range 5 {
+		makeDue(t, db)
+		h.pins.processDue()
+	}
+
+	got := pinStatus(t, h, "QmBroken")
+	if got.Status != pinStateFailed || got.Attempts != 3 {
+		t.Fatalf("Expected failed after 3 attempts, got %+v", got)
+	}
+	if cluster.pins != 3 {
+		t.Errorf("Expected 3 pin calls, got %d", cluster.pins)
+	}
+
+	// Uploading the content again re-queues it
+	h.enqueuePin(context.Background(), "ns", "QmBroken", "", 1, 0)
+	if got := pinStatus(t, h, "QmBroken"); got.Status != pinStateQueued || got.Attempts != 0 {
+		t.Errorf("Expected re-queued job, got %+v", got)
+	}
+}
+
+func TestPinQueue_ClaimIsExclusive(t *testing.T) {
+	cluster := &flakyCluster{pinned: map[string]bool{}}
+	h, _ := newQueueTestHandlers(t, cluster, recordedUsage{})
+	ctx := context.Background()
+
+	h.enqueuePin(ctx, "ns", "QmOnce", "", 1, 0)
+	job, err := h.lookupPinJob(ctx, "ns", "QmOnce")
+	if err != nil || job == nil {
+		t.Fatalf("Expected queued job, got %v, %v", job, err)
+	}
+	stale := *job
+
+	now := time.Now().UTC()
+	if !h.pins.claim(job, now) {
+		t.Fatal("Expected first claim to succeed")
+	}
+	if h.pins.claim(&stale, now) {
+		t.Error("Expected a second claim of the same job to fail")
+	}
+}
+
+func TestPinQueue_ReconcileRepinsLostContent(t *testing.T) {
+	cluster := &flakyCluster{pinned: map[string]bool{"QmHealthy": true}}
+	h, _ := newQueueTestHandlers(t, cluster, recordedUsage{})
+	ctx := context.Background()
+
+	for _, cid := range []string{"QmHealthy", "QmLost"} {
+		if err := h.addPinRef(ctx, "ns", cid); err != nil {
+			t.Fatalf("Failed to add pin reference: %v", err)
+		}
+	}
+
+	h.pins.reconcile()
+	if !cluster.pinned["QmLost"] {
+		t.Error("Expected lost content to be re-pinned")
+	}
+	if cluster.pins != 1 || cluster.lastRF != h.replicationFactor() {
+		t.Errorf("Expected only the lost CID to be re-pinned at the default factor, got %d pin calls (factor %d)", cluster.pins, cluster.lastRF)
+	}
+}
+
+func TestPinQueue_ReconcileKeepsUploadReplication(t *testing.T) {
+	cluster := &flakyCluster{pinned: map[string]bool{"QmWide": true}}
+	h, db := newQueueTestHandlers(t, cluster, recordedUsage{})
+	ctx := context.Background()
+
+	// Three replicas satisfy the default but not the factor it was uploaded with
+	if _, err := db.Exec(`INSERT INTO storage_pin_jobs (namespace, cid, name, size, replication_factor, state, attempts, last_error, next_attempt_at, created_at, updated_at)
+		VALUES ('ns', 'QmWide', '', 1, 5, 'pinned', 1, '', '', '', '')`); err != nil {
+		t.Fatalf("Failed to insert pin job: %v", err)
+	}
+	if err := h.addPinRef(ctx, "ns", "QmWide"); err != nil {
+		t.Fatalf("Failed to add pin reference: %v", err)
+	}
+
+	h.pins.reconcile()
+	if cluster.pins != 1 || cluster.lastRF != 5 {
+		t.Errorf("Expected a re-pin at the upload's factor of 5, got %d pin calls (factor %d)", cluster.pins, cluster.lastRF)
+	}
+}
+
+func TestPinQueue_ReconcileCapsReplicationAtClusterSize(t *testing.T) {
+	cluster := &flakyCluster{pinned: map[string]bool{"QmWide": true}, peers: 3}
+	h, db := newQueueTestHandlers(t, cluster, recordedUsage{})
+	ctx := context.Background()
+
+	// A factor of 5 cannot be met on three peers; pinned on all of them is healthy
+	if _, err := db.Exec(`INSERT INTO storage_pin_jobs (namespace, cid, name, size, replication_factor, state, attempts, last_error, next_attempt_at, created_at, updated_at)
+		VALUES ('ns', 'QmWide', '', 1, 5, 'pinned', 1, '', '', '', '')`); err != nil {
+		t.Fatalf("Failed to insert pin job: %v", err)
+	}
+	if err := h.addPinRef(ctx, "ns", "QmWide"); err != nil {
+		t.Fatalf("Failed to add pin reference: %v", err)
+	}
+
+	h.pins.reconcile()
+	if cluster.pins != 0 {
+		t.Errorf("Expected no re-pin when every peer holds the pin, got %d pin calls", cluster.pins)
+	}
+}
+
+func TestPinBackoff(t *testing.T) {
+	if got := pinBackoff(1); got != pinBackoffBase {
+		t.Errorf("Expected %s after the first failure, got %s", pinBackoffBase, got)
+	}
+	if got := pinBackoff(3); got != 4*pinBackoffBase {
+		t.Errorf("Expected %s after three failures, got %s", 4*pinBackoffBase, got)
+	}
+	if got := pinBackoff(50); got != pinBackoffMax {
+		t.Errorf("Expected backoff to be capped at %s, got %s", pinBackoffMax, got)
+	}
+}
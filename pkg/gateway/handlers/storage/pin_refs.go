@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Identical content uploaded by different namespaces deduplicates to the same
+// CID, so a single cluster pin can be shared. Each namespace holding the pin is
+// recorded in storage_pin_refs; the cluster pin is released only once the last
+// reference is gone.
+
+// pinRefCount is the result row of a reference count query.
+type pinRefCount struct {
+	Count int64 `db:"count"`
+}
+
+// addPinRef records that namespace holds a pin on cid. It is idempotent.
+func (h *Handlers) addPinRef(ctx context.Context, namespace, cid string) error {
+	_, err := h.takePinRef(ctx, namespace, cid)
+	return err
+}
+
+// takePinRef is addPinRef, also reporting whether the reference is new, so a
+// caller that fails to pin can drop a reference it did not already hold.
+func (h *Handlers) takePinRef(ctx context.Context, namespace, cid string) (added bool, err error) {
+	res, err := h.config.DB.Exec(ctx,
+		"INSERT OR IGNORE INTO storage_pin_refs (namespace, cid, created_at) VALUES (?, ?, ?)",
+		namespace, cid, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// releasePinRef drops namespace's reference to cid, along with any queued pin
+// job for it. held reports whether the namespace had a reference at all;
+// remaining is the number of references other namespaces still hold.
+func (h *Handlers) releasePinRef(ctx context.Context, namespace, cid string) (held bool, remaining int64, err error) {
+	res, err := h.config.DB.Exec(ctx,
+		"DELETE FROM storage_pin_refs WHERE namespace = ? AND cid = ?", namespace, cid)
+	if err != nil {
+		return false, 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, 0, err
+	}
+	if _, err := h.config.DB.Exec(ctx,
+		"DELETE FROM storage_pin_jobs WHERE namespace = ? AND cid = ?", namespace, cid); err != nil {
+		return true, 0, err
+	}
+	remaining, err = h.countPinRefs(ctx, cid)
+	return true, remaining, err
+}
+
+// releasePin drops namespace's reference to cid and unpins it from the cluster
+// once no namespace references it anymore. If the cluster unpin fails the
+// reference is restored so the caller can retry.
+func (h *Handlers) releasePin(ctx context.Context, namespace, cid string) (held bool, remaining int64, err error) {
+	held, remaining, err = h.releasePinRef(ctx, namespace, cid)
+	if err != nil || !held || remaining > 0 {
+		return held, remaining, err
+	}
+
+	if err := h.ipfsClient.Unpin(ctx, cid); err != nil {
+		if rerr := h.addPinRef(ctx, namespace, cid); rerr != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to restore pin reference",
+				zap.Error(rerr), zap.String("cid", cid), zap.String("namespace", namespace))
+		}
+		return true, 0, err
+	}
+	h.repinIfReferenced(ctx, cid)
+	return true, 0, nil
+}
+
+// repinIfReferenced restores the cluster pin on cid if another namespace took
+// a reference while it was being unpinned.
+func (h *Handlers) repinIfReferenced(ctx context.Context, cid string) {
+	n, err := h.countPinRefs(ctx, cid)
+	if err != nil || n == 0 {
+		return
+	}
+	if _, err := h.ipfsClient.Pin(ctx, cid, "", h.replicationFactor()); err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to restore pin after concurrent reference",
+			zap.Error(err), zap.String("cid", cid))
+	}
+}
+
+// countPinRefs returns the number of namespaces holding a pin on cid.
+func (h *Handlers) countPinRefs(ctx context.Context, cid string) (int64, error) {
+	var rows []pinRefCount
+	if err := h.config.DB.Query(ctx, &rows,
+		"SELECT COUNT(*) AS count FROM storage_pin_refs WHERE cid = ?", cid); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].Count, nil
+}
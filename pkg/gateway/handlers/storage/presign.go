@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Presigned URLs carry a namespace-scoped grant in their query string, signed
+// with a key shared by every gateway:
+//
+//	GET  /v1/storage/get/{cid}?ns=…&expires=…&sig=…
+//	POST /v1/storage/upload?ns=…&expires=…&max_size=…&nonce=…&sig=…
+//
+// The signature covers the operation, namespace, CID, size limit, nonce and
+// expiry, so none of them can be changed without invalidating the URL. Upload
+// nonces are recorded in storage_presign_uploads when used, so each upload URL
+// works once.
+const (
+	presignParamNamespace = "ns"
+	presignParamExpires   = "expires"
+	presignParamMaxSize   = "max_size"
+	presignParamNonce     = "nonce"
+	presignParamSignature = "sig"
+
+	presignGet    = "get"
+	presignUpload = "upload"
+
+	// DefaultPresignExpiry is the lifetime of a presigned URL when none is requested.
+	DefaultPresignExpiry = 15 * time.Minute
+	maxPresignExpiry     = 7 * 24 * time.Hour
+
+	presignKeySize = 32
+)
+
+var (
+	errPresignInvalid = errors.New("invalid presigned URL signature")
+	errPresignExpired = errors.New("presigned URL has expired")
+	errPresignUsed    = errors.New("presigned URL has already been used")
+)
+
+// presignGrant is the operation a presigned URL allows.
+type presignGrant struct {
+	Op        string
+	Namespace string
+	Cid       string
+	MaxSize   int64
+	Nonce     string // Uploads only; makes the URL single-use
+	Expires   time.Time
+}
+
+// payload is the signed representation of g.
+func (g *presignGrant) payload() []byte {
+	return []byte(strings.Join([]string{
+		"v2", g.Op, g.Namespace, g.Cid,
+		strconv.FormatInt(g.MaxSize, 10),
+		g.Nonce,
+		strconv.FormatInt(g.Expires.Unix(), 10),
+	}, "\n"))
+}
+
+func signPresign(key []byte, g *presignGrant) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(g.payload())
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// presignTarget returns the operation and CID addressed by a storage path.
+func presignTarget(p string) (op, cid string, ok bool) {
+	if p == "/v1/storage/upload" {
+		return presignUpload, "", true
+	}
+	if cid, ok := strings.CutPrefix(p, "/v1/storage/get/"); ok && cid != "" {
+		return presignGet, cid, true
+	}
+	return "", "", false
+}
+
+// IsPresigned reports whether r is a storage request authorized by a
+// presigned URL rather than by credentials.
+func IsPresigned(r *http.Request) bool {
+	if _, _, ok := presignTarget(r.URL.Path); !ok {
+		return false
+	}
+	return r.URL.Query().Has(presignParamSignature)
+}
+
+// VerifyPresigned checks the signature and expiry of a presigned request and
+// returns the namespace it acts for.
+func (h *Handlers) VerifyPresigned(r *http.Request) (string, error) {
+	g, err := h.presignedGrant(r)
+	if err != nil {
+		return "", err
+	}
+	return g.Namespace, nil
+}
+
+// presignedGrant verifies the presigned URL of r and returns its grant.
+func (h *Handlers) presignedGrant(r *http.Request) (*presignGrant, error) {
+	op, cid, ok := presignTarget(r.URL.Path)
+	if !ok {
+		return nil, errPresignInvalid
+	}
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get(presignParamExpires), 10, 64)
+	if err != nil {
+		return nil, errPresignInvalid
+	}
+	var maxSize int64
+	if v := q.Get(presignParamMaxSize); v != "" {
+		if maxSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errPresignInvalid
+		}
+	}
+	g := &presignGrant{
+		Op:        op,
+		Namespace: q.Get(presignParamNamespace),
+		Cid:       cid,
+		MaxSize:   maxSize,
+		Nonce:     q.Get(presignParamNonce),
+		Expires:   time.Unix(expires, 0),
+	}
+	if g.Namespace == "" || (op == presignUpload && g.Nonce == "") {
+		return nil, errPresignInvalid
+	}
+
+	key, err := h.presignKey(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get(presignParamSignature))
+	if err != nil {
+		return nil, errPresignInvalid
+	}
+	want, _ := base64.RawURLEncoding.DecodeString(signPresign(key, g))
+	if !hmac.Equal(sig, want) {
+		return nil, errPresignInvalid
+	}
+	if time.Now().After(g.Expires) {
+		return nil, errPresignExpired
+	}
+	return g, nil
+}
+
+// writePresignError maps presigned URL verification errors to HTTP responses.
+func (h *Handlers) writePresignError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errPresignInvalid), errors.Is(err, errPresignExpired), errors.Is(err, errPresignUsed):
+		httputil.WriteError(w, http.StatusForbidden, err.Error())
+	default:
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to verify presigned URL", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to verify presigned URL")
+	}
+}
+
+// claimPresignedUpload records the nonce of an upload grant, failing with
+// errPresignUsed when the URL has been used before.
+func (h *Handlers) claimPresignedUpload(ctx context.Context, g *presignGrant) error {
+	res, err := h.config.DB.Exec(ctx,
+		"INSERT OR IGNORE INTO storage_presign_uploads (nonce, namespace, expires_at) VALUES (?, ?, ?)",
+		g.Nonce, g.Namespace, g.Expires.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record presigned upload: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errPresignUsed
+	}
+	return nil
+}
+
+// releasePresignedUpload forgets the nonce of an upload that failed before
+// anything was stored, so the URL can be retried.
+func (h *Handlers) releasePresignedUpload(ctx context.Context, g *presignGrant) {
+	if _, err := h.config.DB.Exec(ctx, "DELETE FROM storage_presign_uploads WHERE nonce = ?", g.Nonce); err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to release presigned upload",
+			zap.Error(err), zap.String("namespace", g.Namespace))
+	}
+}
+
+// purgePresignedUploads drops the nonces of presigned URLs that have expired.
+func (h *Handlers) purgePresignedUploads(ctx context.Context, now time.Time) error {
+	_, err := h.config.DB.Exec(ctx, "DELETE FROM storage_presign_uploads WHERE expires_at < ?", now.UTC().Format(time.RFC3339))
+	return err
+}
+
+type presignKeyRow struct {
+	Key string `db:"key_hex"`
+}
+
+// presignKey returns the configured signing key, or loads (creating if
+// needed) the cluster signing key stored in RQLite.
+func (h *Handlers) presignKey(ctx context.Context) ([]byte, error) {
+	if len(h.config.PresignKey) > 0 {
+		return h.config.PresignKey, nil
+	}
+	h.presignMu.Lock()
+	defer h.presignMu.Unlock()
+	if h.presignKeyCache != nil {
+		return h.presignKeyCache, nil
+	}
+	if h.config.DB == nil {
+		return nil, errors.New("presigned URLs require a presign key or a database")
+	}
+
+	key := make([]byte, presignKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate presign key: %w", err)
+	}
+	if _, err := h.config.DB.Exec(ctx,
+		"INSERT OR IGNORE INTO storage_presign_key (id, key_hex) VALUES (1, ?)", hex.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to store presign key: %w", err)
+	}
+	var rows []presignKeyRow
+	if err := h.config.DB.Query(ctx, &rows, "SELECT key_hex FROM storage_presign_key WHERE id = 1"); err != nil {
+		return nil, fmt.Errorf("failed to query presign key: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("presign key not found after insert")
+	}
+	stored, err := hex.DecodeString(rows[0].Key)
+	if err != nil || len(stored) != presignKeySize {
+		return nil, fmt.Errorf("stored presign key is invalid")
+	}
+	h.presignKeyCache = stored
+	return stored, nil
+}
+
+// PresignHandler handles POST /v1/storage/presign.
+// It issues a URL that downloads one CID, or uploads one object up to a size
+// limit, on behalf of the caller's namespace until it expires. Browsers and
+// mobile clients can use it directly without an API key.
+func (h *Handlers) PresignHandler(w http.ResponseWriter, r *http.Request) {
+	if !httputil.CheckMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	ctx := r.Context()
+	namespace := h.getNamespaceFromContext(ctx)
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+	var req StoragePresignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
+		return
+	}
+
+	expiry := DefaultPresignExpiry
+	if req.ExpiresIn < 0 || req.ExpiresIn > int64(maxPresignExpiry/time.Second) {
+		httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("expires_in must be between 1 and %d seconds", int64(maxPresignExpiry/time.Second)))
+		return
+	}
+	if req.ExpiresIn > 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+	}
+
+	g := &presignGrant{Namespace: namespace, Expires: time.Now().Add(expiry).Truncate(time.Second)}
+	var path, method string
+	switch strings.ToLower(req.Method) {
+	case presignGet:
+		if req.Cid == "" || strings.ContainsAny(req.Cid, "/?#") {
+			httputil.WriteError(w, http.StatusBadRequest, "a valid cid is required for get")
+			return
+		}
+		g.Op, g.Cid = presignGet, req.Cid
+		path, method = "/v1/storage/get/"+req.Cid, http.MethodGet
+	case presignUpload:
+		if h.config.DB == nil {
+			httputil.WriteError(w, http.StatusServiceUnavailable, "presigned uploads not available without the storage index")
+			return
+		}
+		if req.MaxSize < 0 || req.MaxSize > h.maxObjectSize() {
+			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("max_size must be between 1 and %d bytes", h.maxObjectSize()))
+			return
+		}
+		g.Op, g.MaxSize = presignUpload, req.MaxSize
+		if g.MaxSize == 0 {
+			g.MaxSize = h.maxObjectSize()
+		}
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to generate nonce")
+			return
+		}
+		g.Nonce = hex.EncodeToString(nonce)
+		path, method = "/v1/storage/upload", http.MethodPost
+	default:
+		httputil.WriteError(w, http.StatusBadRequest, `method must be "get" or "upload"`)
+		return
+	}
+
+	key, err := h.presignKey(ctx)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to load presign key", zap.Error(err))
+		httputil.WriteError(w, http.StatusServiceUnavailable, "presigned URLs not available")
+		return
+	}
+
+	q := url.Values{}
+	q.Set(presignParamNamespace, g.Namespace)
+	q.Set(presignParamExpires, strconv.FormatInt(g.Expires.Unix(), 10))
+	if g.Op == presignUpload {
+		q.Set(presignParamMaxSize, strconv.FormatInt(g.MaxSize, 10))
+		q.Set(presignParamNonce, g.Nonce)
+	}
+	q.Set(presignParamSignature, signPresign(key, g))
+
+	u := url.URL{Scheme: h.requestScheme(r), Host: r.Host, Path: path, RawQuery: q.Encode()}
+	resp := StoragePresignResponse{URL: u.String(), Method: method, ExpiresAt: g.Expires.UTC()}
+	if g.Op == presignUpload {
+		resp.MaxSize = g.MaxSize
+	}
+	httputil.WriteJSON(w, http.StatusOK, resp)
+}
+
+// requestScheme returns the scheme the client used to reach the gateway.
+// X-Forwarded-Proto is only believed from a trusted proxy.
+func (h *Handlers) requestScheme(r *http.Request) string {
+	if h.config.TrustedProxies.FromProxy(r) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/ipfs"
+)
+
+// addCluster stores added content under a content-derived CID.
+type addCluster struct {
+	flakyCluster
+}
+
+func (c *addCluster) Add(ctx context.Context, r io.Reader, name string) (*ipfs.AddResponse, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	cid := "Qm" + hex.EncodeToString(sum[:8])
+	c.objects[cid] = data
+	return &ipfs.AddResponse{Name: name, Cid: cid, Size: int64(len(data))}, nil
+}
+
+func newPresignTestHandlers(t *testing.T) *Handlers {
+	t.Helper()
+	h, _ := newQueueTestHandlers(t, nil, nil)
+	h.ipfsClient = &addCluster{flakyCluster{memIPFS: memIPFS{objects: map[string][]byte{}}, pinned: map[string]bool{}}}
+	return h
+}
+
+func presign(t *testing.T, h *Handlers, req StoragePresignRequest) StoragePresignResponse {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/v1/storage/presign", bytes.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), ctxkeys.NamespaceOverride, "ns"))
+	w := httptest.NewRecorder()
+	h.PresignHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp StoragePresignResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode presign response: %v", err)
+	}
+	return resp
+}
+
+// presignedUpload posts data to a presigned upload URL without credentials.
+func presignedUpload(h *Handlers, rawURL string, data []byte) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(StorageUploadRequest{Name: "photo.jpg", Data: base64.StdEncoding.EncodeToString(data)})
+	r := httptest.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.UploadHandler(w, r)
+	return w
+}
+
+func TestPresign_UploadAndDownload(t *testing.T) {
+	h := newPresignTestHandlers(t)
+
+	up := presign(t, h, StoragePresignRequest{Method: "upload", MaxSize: 16, ExpiresIn: 60})
+	if up.Method != http.MethodPost || up.MaxSize != 16 || !strings.HasPrefix(up.URL, "http://example.com/v1/storage/upload?") {
+		t.Fatalf("Unexpected upload presign: %+v", up)
+	}
+
+	if w := presignedUpload(h, up.URL, []byte("far too large for the limit")); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d for oversized upload, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	}
+	w := presignedUpload(h, up.URL, []byte("hello"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var uploaded StorageUploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &uploaded); err != nil {
+		t.Fatalf("Failed to decode upload response: %v", err)
+	}
+	if obj := objectExists(t, h, uploaded.Cid); obj == nil {
+		t.Fatalf("Expected presigned upload to be indexed in namespace ns")
+	}
+	if w := presignedUpload(h, up.URL, []byte("again")); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected a used upload URL to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+
+	get := presign(t, h, StoragePresignRequest{Method: "get", Cid: uploaded.Cid})
+	if get.Method != http.MethodGet || time.Until(get.ExpiresAt) > DefaultPresignExpiry {
+		t.Fatalf("Unexpected get presign: %+v", get)
+	}
+	r := httptest.NewRequest(http.MethodGet, get.URL, nil)
+	rec := httptest.NewRecorder()
+	h.DownloadHandler(rec, r)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("Expected presigned download of %q, got %d: %s", "hello", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPresign_RejectsTamperedAndExpired(t *testing.T) {
+	h := newPresignTestHandlers(t)
+	h.config.PresignKey = bytes.Repeat([]byte{7}, presignKeySize)
+	get := presign(t, h, StoragePresignRequest{Method: "get", Cid: "QmOne"})
+
+	tamper := func(rawURL string, edit func(p string, q url.Values) (string, url.Values)) string {
+		u, _ := url.Parse(rawURL)
+		var q url.Values
+		u.Path, q = edit(u.Path, u.Query())
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	cases := map[string]string{
+		"other cid": tamper(get.URL, func(p string, q url.Values) (string, url.Values) {
+			return "/v1/storage/get/QmTwo", q
+		}),
+		"other namespace": tamper(get.URL, func(p string, q url.Values) (string, url.Values) {
+			q.Set(presignParamNamespace, "victim")
+			return p, q
+		}),
+		"later expiry": tamper(get.URL, func(p string, q url.Values) (string, url.Values) {
+			q.Set(presignParamExpires, "9999999999")
+			return p, q
+		}),
+		"as upload": tamper(get.URL, func(p string, q url.Values) (string, url.Values) {
+			return "/v1/storage/upload", q
+		}),
+	}
+	for name, rawURL := range cases {
+		r := httptest.NewRequest(http.MethodGet, rawURL, nil)
+		if _, err := h.VerifyPresigned(r); err != errPresignInvalid {
+			t.Errorf("%s: expected %v, got %v", name, errPresignInvalid, err)
+		}
+	}
+
+	expired := &presignGrant{Op: presignGet, Namespace: "ns", Cid: "QmOne", Expires: time.Now().Add(-time.Minute)}
+	q := url.Values{}
+	q.Set(presignParamNamespace, "ns")
+	q.Set(presignParamExpires, strconv.FormatInt(expired.Expires.Unix(), 10))
+	q.Set(presignParamSignature, signPresign(h.config.PresignKey, expired))
+	r := httptest.NewRequest(http.MethodGet, "/v1/storage/get/QmOne?"+q.Encode(), nil)
+	rec := httptest.NewRecorder()
+	h.DownloadHandler(rec, r)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "expired") {
+		t.Fatalf("Expected %d for expired URL, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+}
+
+func TestPresign_ClusterKey(t *testing.T) {
+	h := newPresignTestHandlers(t)
+	key, err := h.presignKey(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create presign key: %v", err)
+	}
+
+	// Another gateway sharing the database signs with the same key
+	other := &Handlers{config: h.config}
+	otherKey, err := other.presignKey(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to load presign key: %v", err)
+	}
+	if !bytes.Equal(key, otherKey) || len(key) != presignKeySize {
+		t.Fatalf("Expected gateways to share one %d-byte presign key", presignKeySize)
+	}
+}
+
+func TestPresignHandler_Validation(t *testing.T) {
+	h := newPresignTestHandlers(t)
+	cases := []StoragePresignRequest{
+		{Method: "delete", Cid: "QmOne"},
+		{Method: "get"},
+		{Method: "get", Cid: "QmOne/../x"},
+		{Method: "get", Cid: "QmOne", ExpiresIn: int64(maxPresignExpiry/time.Second) + 1},
+		{Method: "upload", MaxSize: h.maxObjectSize() + 1},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(c)
+		r := httptest.NewRequest(http.MethodPost, "/v1/storage/presign", bytes.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), ctxkeys.NamespaceOverride, "ns"))
+		w := httptest.NewRecorder()
+		h.PresignHandler(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%+v: expected status %d, got %d: %s", c, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPresign_RequestScheme(t *testing.T) {
+	h := newPresignTestHandlers(t)
+	r := httptest.NewRequest(http.MethodPost, "/v1/storage/presign", nil) // RemoteAddr 192.0.2.1
+	r.Header.Set("X-Forwarded-Proto", "https")
+	if got := h.requestScheme(r); got != "http" {
+		t.Errorf("Expected X-Forwarded-Proto from an untrusted client to be ignored, got %q", got)
+	}
+
+	h.config.TrustedProxies, _ = httputil.ParseTrustedProxies([]string{"192.0.2.1"})
+	if got := h.requestScheme(r); got != "https" {
+		t.Errorf("Expected X-Forwarded-Proto from a trusted proxy to be used, got %q", got)
+	}
+}
+
+func TestPresign_PurgeExpiredUploads(t *testing.T) {
+	h := newPresignTestHandlers(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, g := range []*presignGrant{
+		{Op: presignUpload, Namespace: "ns", Nonce: "old", Expires: now.Add(-time.Minute)},
+		{Op: presignUpload, Namespace: "ns", Nonce: "live", Expires: now.Add(time.Minute)},
+	} {
+		if err := h.claimPresignedUpload(ctx, g); err != nil {
+			t.Fatalf("Failed to claim %s: %v", g.Nonce, err)
+		}
+	}
+
+	if err := h.purgePresignedUploads(ctx, now); err != nil {
+		t.Fatalf("Failed to purge presigned uploads: %v", err)
+	}
+	var rows []struct {
+		Nonce string `db:"nonce"`
+	}
+	if err := h.config.DB.Query(ctx, &rows, "SELECT nonce FROM storage_presign_uploads"); err != nil {
+		t.Fatalf("Failed to list presigned uploads: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Nonce != "live" {
+		t.Errorf("Expected only the unexpired nonce to remain, got %+v", rows)
+	}
+}
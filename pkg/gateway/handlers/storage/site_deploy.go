@@ -0,0 +1,394 @@
+package storage
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/ipfs"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxSiteFiles bounds the number of files in one site deployment.
+	maxSiteFiles = 10000
+
+	// siteManifestBatch is the number of manifest rows per INSERT.
+	siteManifestBatch = 100
+)
+
+var errInvalidSite = errors.New("invalid site")
+
+// dirAdder is implemented by IPFS clients that can add a local directory as a
+// UnixFS directory.
+type dirAdder interface {
+	AddDirectory(ctx context.Context, dir string, name string) (*ipfs.AddResponse, error)
+}
+
+// siteFile is a row of the storage_site_files manifest.
+type siteFile struct {
+	Path        string `db:"path"`
+	Size        int64  `db:"size"`
+	ContentType string `db:"content_type"`
+}
+
+// siteStage collects the files of a deployment on local disk so they can be
+// validated and added to IPFS in directory order.
+type siteStage struct {
+	dir       string
+	files     int
+	remaining int64
+}
+
+// add writes one file of the site at the slash-separated path name.
+func (s *siteStage) add(name string, r io.Reader) error {
+	rel := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+	if rel == "" {
+		return nil
+	}
+	if s.files >= maxSiteFiles {
+		return fmt.Errorf("%w: more than %d files", errInvalidSite, maxSiteFiles)
+	}
+	s.files++
+
+	target := filepath.Join(s.dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return fmt.Errorf("%w: conflicting path %q", errInvalidSite, rel)
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: duplicate or conflicting path %q", errInvalidSite, rel)
+	}
+	defer f.Close()
+
+	limited := &sizeLimitReader{r: r, remaining: s.remaining}
+	_, err = io.Copy(f, limited)
+	s.remaining = limited.remaining
+	return err
+}
+
+// readTar stages the regular files of a tar archive, gzip-compressed or not.
+// Directories are implied by file paths; links and other entries are skipped.
+func (s *siteStage) readTar(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidSite, err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidSite, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := s.add(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// readMultipart stages every file part, using its filename as the path within
+// the site, and returns the plain form fields.
+func (s *siteStage) readMultipart(r *http.Request) (map[string]string, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidSite, err)
+	}
+	fields := make(map[string]string)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidSite, err)
+		}
+		// Part.FileName drops directories, so read the raw parameter
+		_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		if filename := params["filename"]; filename != "" {
+			err = s.add(filename, part)
+		} else {
+			var value []byte
+			value, err = io.ReadAll(io.LimitReader(part, 1024))
+			fields[part.FormName()] = strings.TrimSpace(string(value))
+		}
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// root returns the directory to publish: the staging directory, or its only
+// subdirectory when an archive wraps the site in a top-level folder.
+func (s *siteStage) root() (string, error) {
+	if fileExists(filepath.Join(s.dir, "index.html")) {
+		return s.dir, nil
+	}
+	entries, _ := os.ReadDir(s.dir)
+	if len(entries) == 1 && entries[0].IsDir() {
+		sub := filepath.Join(s.dir, entries[0].Name())
+		if fileExists(filepath.Join(sub, "index.html")) {
+			return sub, nil
+		}
+	}
+	return "", fmt.Errorf("%w: index.html missing at the site root", errInvalidSite)
+}
+
+// siteManifest lists the files under root with their sizes and content types.
+func siteManifest(root string) ([]siteFile, int64, error) {
+	var files []siteFile
+	var total int64
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+
+		rel = filepath.ToSlash(rel)
+		files = append(files, siteFile{
+			Path:        rel,
+			Size:        info.Size(),
+			ContentType: detectContentType("", rel, head[:n]),
+		})
+		total += info.Size()
+		return nil
+	})
+	return files, total, err
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// deploySite handles POST /v1/storage/sites/:name/deploy.
+// The body is a tar archive (optionally gzip-compressed) or a multipart form
+// whose file parts are named by their path within the site. The directory is
+// added to IPFS and pinned, and the site then switches to the new root in a
+// single statement, so visitors see either the old or the new version.
+// Optional "spa" and "domain" settings come from query parameters or form fields.
+func (h *Handlers) deploySite(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	adder, ok := h.ipfsClient.(dirAdder)
+	if !ok {
+		httputil.WriteError(w, http.StatusNotImplemented, "directory uploads not supported by the IPFS client")
+		return
+	}
+
+	ctx := r.Context()
+	q := r.URL.Query()
+	options := map[string]string{}
+	for _, k := range []string{"spa", "domain"} {
+		if q.Has(k) {
+			options[k] = q.Get(k)
+		}
+	}
+
+	err := os.MkdirAll(h.uploads.dir, 0o700)
+	var stagingDir string
+	if err == nil {
+		stagingDir, err = os.MkdirTemp(h.uploads.dir, "site-")
+	}
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to create site staging directory", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to stage site")
+		return
+	}
+	defer os.RemoveAll(stagingDir)
+
+	stage := &siteStage{dir: stagingDir, remaining: h.maxObjectSize()}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		var fields map[string]string
+		fields, err = stage.readMultipart(r)
+		for k, v := range fields {
+			if k == "spa" || k == "domain" {
+				options[k] = v
+			}
+		}
+	} else {
+		err = stage.readTar(r.Body)
+	}
+	var root string
+	if err == nil {
+		root, err = stage.root()
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidSite):
+			httputil.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			h.writeStoreError(w, err)
+		}
+		return
+	}
+
+	files, size, err := siteManifest(root)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to read staged site", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to stage site")
+		return
+	}
+
+	prev, err := h.lookupSite(ctx, namespace, name)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to look up site", zap.Error(err), zap.String("site", name))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to look up site")
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	site := &siteRow{Namespace: namespace, Name: name, CreatedAt: now}
+	if prev != nil {
+		site.Domain, site.SPA, site.CreatedAt = prev.Domain, prev.SPA, prev.CreatedAt
+	}
+	if v, ok := options["spa"]; ok {
+		site.SPA = v == "true" || v == "1"
+	}
+	if v, ok := options["domain"]; ok {
+		domain, status, err := h.resolveSiteDomain(ctx, namespace, v)
+		if err != nil {
+			httputil.WriteError(w, status, err.Error())
+			return
+		}
+		site.Domain = domain
+	}
+
+	added, err := adder.AddDirectory(ctx, root, name)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to add site to IPFS", zap.Error(err), zap.String("site", name))
+		httputil.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("failed to add site: %v", err))
+		return
+	}
+	metering.Record(ctx, metering.StorageBytesUploaded, float64(size))
+	site.RootCid, site.Files, site.Size = added.Cid, len(files), size
+	site.UpdatedAt, site.DeployedAt = now, now
+
+	addedRef, err := h.takePinRef(ctx, namespace, site.RootCid)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to record pin reference", zap.Error(err), zap.String("cid", site.RootCid))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to record pin reference")
+		return
+	}
+	// A deploy that fails from here on must not keep the new root referenced
+	dropRef := func() {
+		if !addedRef {
+			return
+		}
+		if _, _, err := h.releasePinRef(ctx, namespace, site.RootCid); err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to drop pin reference",
+				zap.Error(err), zap.String("cid", site.RootCid), zap.String("namespace", namespace))
+		}
+	}
+
+	if err := h.saveSiteManifest(ctx, site.RootCid, files); err != nil {
+		dropRef()
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to save site manifest", zap.Error(err), zap.String("site", name))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to save site")
+		return
+	}
+
+	// Switch the site to the new root in one statement
+	if _, err := h.config.DB.Exec(ctx,
+		`INSERT INTO storage_sites (namespace, name, root_cid, domain, spa, files, size, created_at, updated_at, deployed_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(namespace, name) DO UPDATE SET
+		   root_cid = excluded.root_cid,
+		   domain = excluded.domain,
+		   spa = excluded.spa,
+		   files = excluded.files,
+		   size = excluded.size,
+		   updated_at = excluded.updated_at,
+		   deployed_at = excluded.deployed_at`,
+		namespace, name, site.RootCid, site.Domain, site.SPA, site.Files, site.Size,
+		site.CreatedAt, site.UpdatedAt, site.DeployedAt); err != nil {
+		dropRef()
+		if isUniqueViolation(err) {
+			httputil.WriteError(w, http.StatusConflict, fmt.Sprintf("domain already in use: %s", site.Domain))
+			return
+		}
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to save site", zap.Error(err), zap.String("site", name))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to save site")
+		return
+	}
+
+	h.enqueuePin(ctx, namespace, site.RootCid, "site:"+name, size, 0)
+
+	response := StorageSiteDeployResponse{StorageSite: site.toSite()}
+	h.siteDomains.invalidate(site.Domain)
+	if prev != nil {
+		h.siteDomains.invalidate(prev.Domain)
+		if prev.RootCid != site.RootCid {
+			response.PreviousCid = prev.RootCid
+			h.retireSiteRoot(ctx, namespace, prev.RootCid)
+		}
+	}
+
+	h.logger.ComponentInfo(logging.ComponentGeneral, "site deployed",
+		zap.String("namespace", namespace), zap.String("site", name),
+		zap.String("cid", site.RootCid), zap.Int("files", site.Files))
+	httputil.WriteJSON(w, http.StatusOK, response)
+}
+
+// saveSiteManifest records the files of a deployed root. Roots are content
+// addressed, so an existing manifest for the same root is left as is.
+func (h *Handlers) saveSiteManifest(ctx context.Context, rootCid string, files []siteFile) error {
+	for start := 0; start < len(files); start += siteManifestBatch {
+		batch := files[start:min(start+siteManifestBatch, len(files))]
+		var sb strings.Builder
+		sb.WriteString("INSERT OR IGNORE INTO storage_site_files (root_cid, path, size, content_type) VALUES ")
+		args := make([]any, 0, len(batch)*4)
+		for i, f := range batch {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(?, ?, ?, ?)")
+			args = append(args, rootCid, f.Path, f.Size, f.ContentType)
+		}
+		if _, err := h.config.DB.Exec(ctx, sb.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
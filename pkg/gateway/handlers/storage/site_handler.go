@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// sitePreviewCSP isolates sites served from the gateway's own origin, so their
+// scripts cannot reach the API with a visitor's credentials.
+const sitePreviewCSP = "sandbox allow-scripts allow-forms allow-popups allow-modals allow-downloads"
+
+// ServeSiteHandler handles GET and HEAD /v1/sites/:namespace/:name/*path.
+// It serves a site from the gateway's own domain without authentication.
+func (h *Handlers) ServeSiteHandler(w http.ResponseWriter, r *http.Request) {
+	if h.ipfsClient == nil || h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "site hosting not available")
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/v1/sites/")
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || !dnsLabel.MatchString(parts[1]) {
+		httputil.WriteError(w, http.StatusNotFound, "site not found")
+		return
+	}
+	// Relative links only resolve against the site root with a trailing slash
+	if len(parts) == 2 {
+		http.Redirect(w, r, r.URL.Path+"/"+queryString(r), http.StatusMovedPermanently)
+		return
+	}
+
+	site, err := h.lookupSite(r.Context(), parts[0], parts[1])
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to look up site", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to look up site")
+		return
+	}
+	if site == nil {
+		httputil.WriteError(w, http.StatusNotFound, "site not found")
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", sitePreviewCSP)
+	h.serveSite(w, r, site, parts[2])
+}
+
+// ServeSiteDomain serves r from the site bound to its Host, if any, and
+// reports whether it did.
+func (h *Handlers) ServeSiteDomain(w http.ResponseWriter, r *http.Request) bool {
+	if h.ipfsClient == nil || h.config.DB == nil {
+		return false
+	}
+	host := strings.ToLower(r.Host)
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	if !strings.Contains(host, ".") {
+		return false
+	}
+
+	site, err := h.siteForDomain(r.Context(), host)
+	if err != nil {
+		h.logger.ComponentWarn(logging.ComponentGeneral, "failed to look up site domain",
+			zap.Error(err), zap.String("host", host))
+		return false
+	}
+	if site == nil {
+		return false
+	}
+	h.serveSite(w, r, site, strings.TrimPrefix(r.URL.Path, "/"))
+	return true
+}
+
+// serveSite resolves p within site and writes the file. Directories serve
+// their index.html; SPA sites serve the root index.html for unknown
+// extensionless paths; otherwise a 404.html from the site is used if present.
+func (h *Handlers) serveSite(w http.ResponseWriter, r *http.Request, site *siteRow, p string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		httputil.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	dir := p == "" || strings.HasSuffix(p, "/")
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	file, dirIndex := p, ""
+	switch {
+	case p == "":
+		file = "index.html"
+	case dir:
+		file = p + "/index.html"
+	default:
+		dirIndex = p + "/index.html"
+	}
+
+	ctx := r.Context()
+	found, err := h.lookupSiteFiles(ctx, site.RootCid, file, dirIndex, "index.html", "404.html")
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to look up site files",
+			zap.Error(err), zap.String("cid", site.RootCid))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to load site")
+		return
+	}
+
+	switch f, ok := found[file]; {
+	case ok:
+		h.writeSiteFile(w, r, site, f, http.StatusOK)
+	case found[dirIndex] != nil:
+		http.Redirect(w, r, r.URL.Path+"/"+queryString(r), http.StatusMovedPermanently)
+	case site.SPA && path.Ext(p) == "" && found["index.html"] != nil:
+		h.writeSiteFile(w, r, site, found["index.html"], http.StatusOK)
+	case found["404.html"] != nil:
+		h.writeSiteFile(w, r, site, found["404.html"], http.StatusNotFound)
+	default:
+		httputil.WriteError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// lookupSiteFiles returns the manifest entries of root among paths.
+func (h *Handlers) lookupSiteFiles(ctx context.Context, rootCid string, paths ...string) (map[string]*siteFile, error) {
+	args := []any{rootCid}
+	marks := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p != "" {
+			args = append(args, p)
+			marks = append(marks, "?")
+		}
+	}
+	var rows []siteFile
+	if err := h.config.DB.Query(ctx, &rows,
+		"SELECT path, size, content_type FROM storage_site_files WHERE root_cid = ? AND path IN ("+strings.Join(marks, ", ")+")",
+		args...); err != nil {
+		return nil, err
+	}
+	found := make(map[string]*siteFile, len(rows))
+	for i := range rows {
+		found[rows[i].Path] = &rows[i]
+	}
+	return found, nil
+}
+
+// writeSiteFile streams one file of a site. HTML is revalidated on every visit
+// so redeploys show up immediately; other assets may be cached briefly.
+func (h *Handlers) writeSiteFile(w http.ResponseWriter, r *http.Request, site *siteRow, f *siteFile, status int) {
+	etag := `"` + site.RootCid + "/" + f.Path + `"`
+	if status == http.StatusOK {
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	var body io.ReadCloser
+	if r.Method == http.MethodGet {
+		var err error
+		body, err = h.ipfsClient.Get(r.Context(), site.RootCid+"/"+f.Path, h.config.IPFSAPIURL)
+		if err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to get site file",
+				zap.Error(err), zap.String("cid", site.RootCid), zap.String("path", f.Path))
+			httputil.WriteError(w, http.StatusBadGateway, "failed to load site file")
+			return
+		}
+		defer body.Close()
+	}
+
+	w.Header().Set("Content-Type", f.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(f.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if strings.HasPrefix(f.ContentType, "text/html") {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	}
+	w.WriteHeader(status)
+
+	if body != nil {
+		if _, err := io.Copy(w, body); err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to stream site file",
+				zap.Error(err), zap.String("cid", site.RootCid), zap.String("path", f.Path))
+		}
+	}
+}
+
+func queryString(r *http.Request) string {
+	if r.URL.RawQuery == "" {
+		return ""
+	}
+	return "?" + r.URL.RawQuery
+}
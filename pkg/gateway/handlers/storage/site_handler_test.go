@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/ipfs"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+)
+
+// dirCluster adds directories under a content-derived CID and serves their
+// files as "<root>/<path>".
+type dirCluster struct {
+	flakyCluster
+}
+
+func (c *dirCluster) AddDirectory(ctx context.Context, dir, name string) (*ipfs.AddResponse, error) {
+	files := map[string][]byte{}
+	sum := sha256.New()
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		files[filepath.ToSlash(rel)] = data
+		fmt.Fprintf(sum, "%s\x00%s\x00", rel, data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	root := "Qm" + hex.EncodeToString(sum.Sum(nil))[:16]
+	for p, data := range files {
+		c.objects[root+"/"+p] = data
+	}
+	return &ipfs.AddResponse{Name: name, Cid: root}, nil
+}
+
+func newSiteTestHandlers(t *testing.T) *Handlers {
+	t.Helper()
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	orm, _ := newStorageTestDB(t)
+	cluster := &dirCluster{flakyCluster{memIPFS: memIPFS{objects: map[string][]byte{}}, pinned: map[string]bool{}}}
+	h := &Handlers{
+		ipfsClient: cluster,
+		logger:     logger,
+		config:     Config{DB: orm, ReservedDomains: []string{"gateway.example.com"}},
+		uploads:    newUploadStore(t.TempDir(), 0),
+	}
+	h.pins = &pinQueue{h: h, wake: make(chan struct{}, 1), ctx: context.Background()}
+	return h
+}
+
+func siteTar(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("Failed to write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	return &buf
+}
+
+func deploySiteTar(t *testing.T, h *Handlers, target string, files map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, siteTar(t, files))
+	req.Header.Set("Content-Type", "application/x-tar")
+	req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, "ns"))
+	w := httptest.NewRecorder()
+	h.SiteHandler(w, req)
+	return w
+}
+
+func getSite(h *Handlers, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeSiteHandler(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestSites_DeployAndServe(t *testing.T) {
+	h := newSiteTestHandlers(t)
+
+	w := deploySiteTar(t, h, "/v1/storage/sites/blog/deploy?spa=true", map[string]string{
+		"dist/index.html":      "<html>home</html>",
+		"dist/app.js":          "console.log(1)",
+		"dist/docs/index.html": "<html>docs</html>",
+		"dist/404.html":        "<html>missing</html>",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var first StorageSiteDeployResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &first); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if first.RootCid == "" || first.Files != 4 || !first.SPA || first.Path != "/v1/sites/ns/blog/" {
+		t.Fatalf("Unexpected deploy response: %+v", first)
+	}
+	if !hasPinJob(t, h, first.RootCid) {
+		t.Error("Expected the site root to be queued for pinning")
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+		ctype  string
+	}{
+		{"/v1/sites/ns/blog/", http.StatusOK, "home", "text/html"},
+		{"/v1/sites/ns/blog/app.js", http.StatusOK, "console.log", "javascript"},
+		{"/v1/sites/ns/blog/docs/", http.StatusOK, "docs", "text/html"},
+		{"/v1/sites/ns/blog/settings/profile", http.StatusOK, "home", "text/html"},
+		{"/v1/sites/ns/blog/missing.png", http.StatusNotFound, "missing", "text/html"},
+		{"/v1/sites/ns/other/", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		w := getSite(h, tt.path)
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) || !strings.Contains(w.Header().Get("Content-Type"), tt.ctype) {
+			t.Errorf("GET %s: got %d %q (%s)", tt.path, w.Code, w.Body.String(), w.Header().Get("Content-Type"))
+		}
+	}
+
+	if w := getSite(h, "/v1/sites/ns/blog/docs"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/v1/sites/ns/blog/docs/" {
+		t.Errorf("Expected directory redirect, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// A redeploy switches the root and releases the previous one
+	w = deploySiteTar(t, h, "/v1/storage/sites/blog/deploy", map[string]string{"index.html": "<html>v2</html>"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var second StorageSiteDeployResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &second); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if second.PreviousCid != first.RootCid || second.RootCid == first.RootCid || !second.SPA {
+		t.Fatalf("Unexpected redeploy response: %+v", second)
+	}
+	if w := getSite(h, "/v1/sites/ns/blog/"); !strings.Contains(w.Body.String(), "v2") {
+		t.Errorf("Expected the new version to be served, got %q", w.Body.String())
+	}
+	if hasPinJob(t, h, first.RootCid) {
+		t.Error("Expected the previous root to be released")
+	}
+}
+
+func TestSites_DeployRequiresIndex(t *testing.T) {
+	h := newSiteTestHandlers(t)
+	w := deploySiteTar(t, h, "/v1/storage/sites/blog/deploy", map[string]string{"about.html": "x"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestSites_CustomDomain(t *testing.T) {
+	h := newSiteTestHandlers(t)
+	records := map[string][]string{}
+	old := lookupTXT
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) { return records[name], nil }
+	t.Cleanup(func() { lookupTXT = old })
+
+	files := map[string]string{"index.html": "<html>home</html>"}
+	if w := deploySiteTar(t, h, "/v1/storage/sites/blog/deploy?domain=www.example.org", files); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected unverified domain to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := deploySiteTar(t, h, "/v1/storage/sites/blog/deploy?domain=api.gateway.example.com", files); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected reserved domain to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	records["_orama-site.www.example.org"] = []string{"orama-site=ns"}
+	if w := deploySiteTar(t, h, "/v1/storage/sites/blog/deploy?domain=www.example.org", files); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !h.HasSiteDomain(context.Background(), "www.example.org") {
+		t.Error("Expected the domain to be bound")
+	}
+
+	// A deploy refused for a taken domain keeps no reference to its root
+	other := map[string]string{"index.html": "<html>docs</html>"}
+	if w := deploySiteTar(t, h, "/v1/storage/sites/docs/deploy?domain=www.example.org", other); w.Code != http.StatusConflict {
+		t.Fatalf("Expected a taken domain to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+	var refs []pinRefCount
+	if err := h.config.DB.Query(context.Background(), &refs,
+		"SELECT COUNT(*) AS count FROM storage_pin_refs WHERE namespace = ?", "ns"); err != nil || len(refs) == 0 || refs[0].Count != 1 {
+		t.Errorf("Expected only the deployed root to be referenced, got %+v, %v", refs, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "WWW.example.org:443"
+	w := httptest.NewRecorder()
+	if !h.ServeSiteDomain(w, req) || !strings.Contains(w.Body.String(), "home") {
+		t.Errorf("Expected the site to be served on its domain, got %d %q", w.Code, w.Body.String())
+	}
+
+	req.Host = "unknown.example.org"
+	if h.ServeSiteDomain(httptest.NewRecorder(), req) {
+		t.Error("Expected unknown hosts to fall through")
+	}
+}
+
+// hasPinJob reports whether the test namespace holds a pin on cid.
+func hasPinJob(t *testing.T, h *Handlers, cid string) bool {
+	t.Helper()
+	job, err := h.lookupPinJob(context.Background(), "ns", cid)
+	if err != nil {
+		t.Fatalf("Failed to look up pin job: %v", err)
+	}
+	return job != nil
+}
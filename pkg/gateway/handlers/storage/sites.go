@@ -0,0 +1,368 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	siteColumns = "namespace, name, root_cid, domain, spa, files, size, created_at, updated_at, deployed_at"
+
+	// siteDomainTTL is how long a custom domain lookup is cached, including misses.
+	siteDomainTTL = 30 * time.Second
+
+	// siteVerifyPrefix is the TXT record name prefix proving ownership of a
+	// custom domain; the record must contain siteVerifyValue + namespace.
+	siteVerifyPrefix = "_orama-site."
+	siteVerifyValue  = "orama-site="
+)
+
+var (
+	// dnsLabel matches site names and the labels of custom domains
+	dnsLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+	// lookupTXT resolves domain ownership records; replaced in tests.
+	lookupTXT = net.DefaultResolver.LookupTXT
+)
+
+// siteRow is a row of the storage_sites table.
+type siteRow struct {
+	Namespace  string `db:"namespace"`
+	Name       string `db:"name"`
+	RootCid    string `db:"root_cid"`
+	Domain     string `db:"domain"`
+	SPA        bool   `db:"spa"`
+	Files      int    `db:"files"`
+	Size       int64  `db:"size"`
+	CreatedAt  string `db:"created_at"`
+	UpdatedAt  string `db:"updated_at"`
+	DeployedAt string `db:"deployed_at"`
+}
+
+func (s *siteRow) toSite() StorageSite {
+	site := StorageSite{
+		Name:    s.Name,
+		RootCid: s.RootCid,
+		Domain:  s.Domain,
+		SPA:     s.SPA,
+		Files:   s.Files,
+		Size:    s.Size,
+		Path:    "/v1/sites/" + s.Namespace + "/" + s.Name + "/",
+	}
+	site.CreatedAt, _ = time.Parse(time.RFC3339, s.CreatedAt)
+	site.UpdatedAt, _ = time.Parse(time.RFC3339, s.UpdatedAt)
+	site.DeployedAt, _ = time.Parse(time.RFC3339, s.DeployedAt)
+	return site
+}
+
+// siteDomainCache caches custom domain lookups so host routing does not hit
+// the database on every request.
+type siteDomainCache struct {
+	mu      sync.Mutex
+	entries map[string]siteDomainEntry
+}
+
+type siteDomainEntry struct {
+	site    *siteRow // nil when no site uses the domain
+	expires time.Time
+}
+
+func (c *siteDomainCache) get(domain string) (*siteRow, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[domain]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.site, true
+}
+
+func (c *siteDomainCache) put(domain string, site *siteRow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]siteDomainEntry)
+	}
+	if len(c.entries) > 10000 {
+		clear(c.entries)
+	}
+	c.entries[domain] = siteDomainEntry{site: site, expires: time.Now().Add(siteDomainTTL)}
+}
+
+func (c *siteDomainCache) invalidate(domains ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range domains {
+		delete(c.entries, d)
+	}
+}
+
+// lookupSite returns namespace's site called name, or nil if there is none.
+func (h *Handlers) lookupSite(ctx context.Context, namespace, name string) (*siteRow, error) {
+	var rows []siteRow
+	if err := h.config.DB.Query(ctx, &rows,
+		"SELECT "+siteColumns+" FROM storage_sites WHERE namespace = ? AND name = ? LIMIT 1", namespace, name); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// siteForDomain returns the site bound to domain, or nil if there is none.
+func (h *Handlers) siteForDomain(ctx context.Context, domain string) (*siteRow, error) {
+	if site, ok := h.siteDomains.get(domain); ok {
+		return site, nil
+	}
+	var rows []siteRow
+	if err := h.config.DB.Query(ctx, &rows,
+		"SELECT "+siteColumns+" FROM storage_sites WHERE domain = ? LIMIT 1", domain); err != nil {
+		return nil, err
+	}
+	var site *siteRow
+	if len(rows) > 0 {
+		site = &rows[0]
+	}
+	h.siteDomains.put(domain, site)
+	return site, nil
+}
+
+// HasSiteDomain reports whether a site is bound to domain. It is used as the
+// TLS certificate host policy for custom domains.
+func (h *Handlers) HasSiteDomain(ctx context.Context, domain string) bool {
+	if h.config.DB == nil {
+		return false
+	}
+	site, err := h.siteForDomain(ctx, strings.ToLower(domain))
+	return err == nil && site != nil
+}
+
+// normalizeSiteDomain validates a custom domain and returns it in canonical form.
+func (h *Handlers) normalizeSiteDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	labels := strings.Split(domain, ".")
+	if len(domain) > 253 || len(labels) < 2 {
+		return "", fmt.Errorf("invalid domain: %q", domain)
+	}
+	for _, l := range labels {
+		if !dnsLabel.MatchString(l) {
+			return "", fmt.Errorf("invalid domain: %q", domain)
+		}
+	}
+	for _, reserved := range h.config.ReservedDomains {
+		reserved = strings.ToLower(strings.TrimSpace(reserved))
+		if reserved != "" && (domain == reserved || strings.HasSuffix(domain, "."+reserved)) {
+			return "", fmt.Errorf("domain %q is reserved by the gateway", domain)
+		}
+	}
+	return domain, nil
+}
+
+// verifySiteDomain checks that the owner of domain published a TXT record
+// delegating it to namespace.
+func verifySiteDomain(ctx context.Context, domain, namespace string) error {
+	records, err := lookupTXT(ctx, siteVerifyPrefix+domain)
+	if err == nil {
+		for _, r := range records {
+			if strings.TrimSpace(r) == siteVerifyValue+namespace {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("domain not verified: add a TXT record %s%s with value %q", siteVerifyPrefix, domain, siteVerifyValue+namespace)
+}
+
+// resolveSiteDomain normalizes and verifies a custom domain for namespace.
+// An empty domain clears the binding and needs no verification.
+func (h *Handlers) resolveSiteDomain(ctx context.Context, namespace, domain string) (string, int, error) {
+	if strings.TrimSpace(domain) == "" {
+		return "", 0, nil
+	}
+	domain, err := h.normalizeSiteDomain(domain)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	if err := verifySiteDomain(ctx, domain, namespace); err != nil {
+		return "", http.StatusForbidden, err
+	}
+	return domain, 0, nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint failure.
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// retireSiteRoot releases a root no longer served by a site: the namespace's
+// pin once none of its sites use the root, and the file manifest once no site
+// at all does.
+func (h *Handlers) retireSiteRoot(ctx context.Context, namespace, rootCid string) {
+	var counts []pinRefCount
+	if err := h.config.DB.Query(ctx, &counts,
+		"SELECT COUNT(*) AS count FROM storage_sites WHERE namespace = ? AND root_cid = ?", namespace, rootCid); err != nil || len(counts) == 0 {
+		return
+	}
+	if counts[0].Count == 0 {
+		if _, _, err := h.releasePin(ctx, namespace, rootCid); err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to release previous site root",
+				zap.Error(err), zap.String("cid", rootCid), zap.String("namespace", namespace))
+		}
+	}
+
+	counts = nil
+	if err := h.config.DB.Query(ctx, &counts,
+		"SELECT COUNT(*) AS count FROM storage_sites WHERE root_cid = ?", rootCid); err != nil || len(counts) == 0 {
+		return
+	}
+	if counts[0].Count == 0 {
+		if _, err := h.config.DB.Exec(ctx, "DELETE FROM storage_site_files WHERE root_cid = ?", rootCid); err != nil {
+			h.logger.ComponentWarn(logging.ComponentGeneral, "failed to delete site manifest",
+				zap.Error(err), zap.String("cid", rootCid))
+		}
+	}
+}
+
+// ListSitesHandler handles GET /v1/storage/sites.
+func (h *Handlers) ListSitesHandler(w http.ResponseWriter, r *http.Request) {
+	if h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "site hosting not available")
+		return
+	}
+
+	if !httputil.CheckMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(r.Context())
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	var rows []siteRow
+	if err := h.config.DB.Query(r.Context(), &rows,
+		"SELECT "+siteColumns+" FROM storage_sites WHERE namespace = ? ORDER BY name", namespace); err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to list sites", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to list sites")
+		return
+	}
+
+	sites := make([]StorageSite, 0, len(rows))
+	for i := range rows {
+		sites = append(sites, rows[i].toSite())
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{"sites": sites, "count": len(sites)})
+}
+
+// SiteHandler handles /v1/storage/sites/:name.
+// GET returns the site, PUT updates its custom domain or SPA mode, DELETE
+// removes it, and POST /v1/storage/sites/:name/deploy publishes a new version.
+func (h *Handlers) SiteHandler(w http.ResponseWriter, r *http.Request) {
+	if h.ipfsClient == nil || h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "site hosting not available")
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/storage/sites/"), "/")
+	name, action, _ := strings.Cut(rest, "/")
+	if !dnsLabel.MatchString(name) {
+		httputil.WriteError(w, http.StatusBadRequest, "invalid site name; use lowercase letters, digits and hyphens")
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(r.Context())
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	switch action {
+	case "deploy":
+		if !httputil.CheckMethod(w, r, http.MethodPost) {
+			return
+		}
+		h.deploySite(w, r, namespace, name)
+		return
+	case "":
+	default:
+		httputil.WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if !httputil.CheckMethodOneOf(w, r, http.MethodGet, http.MethodPut, http.MethodDelete) {
+		return
+	}
+
+	ctx := r.Context()
+	site, err := h.lookupSite(ctx, namespace, name)
+	if err != nil {
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to look up site", zap.Error(err), zap.String("site", name))
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to look up site")
+		return
+	}
+	if site == nil {
+		httputil.WriteError(w, http.StatusNotFound, fmt.Sprintf("site not found: %s", name))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req StorageSiteUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
+			return
+		}
+		oldDomain := site.Domain
+		if req.Domain != nil {
+			domain, status, err := h.resolveSiteDomain(ctx, namespace, *req.Domain)
+			if err != nil {
+				httputil.WriteError(w, status, err.Error())
+				return
+			}
+			site.Domain = domain
+		}
+		if req.SPA != nil {
+			site.SPA = *req.SPA
+		}
+		site.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+		if _, err := h.config.DB.Exec(ctx,
+			"UPDATE storage_sites SET domain = ?, spa = ?, updated_at = ? WHERE namespace = ? AND name = ?",
+			site.Domain, site.SPA, site.UpdatedAt, namespace, name); err != nil {
+			if isUniqueViolation(err) {
+				httputil.WriteError(w, http.StatusConflict, fmt.Sprintf("domain already in use: %s", site.Domain))
+				return
+			}
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to update site", zap.Error(err), zap.String("site", name))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to update site")
+			return
+		}
+		h.siteDomains.invalidate(oldDomain, site.Domain)
+
+	case http.MethodDelete:
+		if _, err := h.config.DB.Exec(ctx,
+			"DELETE FROM storage_sites WHERE namespace = ? AND name = ?", namespace, name); err != nil {
+			h.logger.ComponentError(logging.ComponentGeneral, "failed to delete site", zap.Error(err), zap.String("site", name))
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to delete site")
+			return
+		}
+		h.siteDomains.invalidate(site.Domain)
+		h.retireSiteRoot(ctx, namespace, site.RootCid)
+		httputil.WriteJSON(w, http.StatusOK, map[string]any{"status": "ok", "name": name})
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, site.toSite())
+}
@@ -1,5 +1,7 @@
 package storage
 
+import "time"
+
 // StorageUploadRequest represents a request to upload content to IPFS.
 // It supports JSON-based uploads with base64-encoded data.
 type StorageUploadRequest struct {
@@ -7,6 +9,16 @@ type StorageUploadRequest struct {
 	Name string `json:"name,omitempty"`
 	// Data is the base64-encoded content data (alternative to multipart upload)
 	Data string `json:"data,omitempty"`
+	// Public stores the content unencrypted so it can be fetched from any IPFS gateway
+	Public bool `json:"public,omitempty"`
+	// ContentType is the MIME type of the content; detected if omitted
+	ContentType string `json:"content_type,omitempty"`
+	// Tags are labels recorded in the namespace object index
+	Tags []string `json:"tags,omitempty"`
+	// TTL expires the object after a duration such as "24h" or "7d", or a number of seconds
+	TTL StorageTTL `json:"ttl,omitempty"`
+	// ReplicationFactor overrides the configured number of replicas for the pin
+	ReplicationFactor int `json:"replication_factor,omitempty"`
 }
 
 // StorageUploadResponse represents the response from uploading content to IPFS.
@@ -17,6 +29,12 @@ type StorageUploadResponse struct {
 	Name string `json:"name"`
 	// Size is the size of the uploaded content in bytes
 	Size int64 `json:"size"`
+	// ContentType is the declared or detected MIME type of the content
+	ContentType string `json:"content_type"`
+	// Encrypted reports whether the content was encrypted before it was added to IPFS
+	Encrypted bool `json:"encrypted"`
+	// ExpiresAt is when the object is removed from the namespace, if it has a TTL
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // StoragePinRequest represents a request to pin a CID in the IPFS cluster.
@@ -25,6 +43,8 @@ type StoragePinRequest struct {
 	Cid string `json:"cid"`
 	// Name is an optional human-readable name for the pinned content
 	Name string `json:"name,omitempty"`
+	// ReplicationFactor overrides the configured number of replicas
+	ReplicationFactor int `json:"replication_factor,omitempty"`
 }
 
 // StoragePinResponse represents the response from pinning a CID.
@@ -41,7 +61,7 @@ type StorageStatusResponse struct {
 	Cid string `json:"cid"`
 	// Name is the human-readable name associated with the pin
 	Name string `json:"name"`
-	// Status indicates the pin state (e.g., "pinned", "pinning", "unpinned")
+	// Status indicates the pin state (e.g., "queued", "pinning", "pinned", "failed", "unpinned")
 	Status string `json:"status"`
 	// ReplicationMin is the minimum number of replicas
 	ReplicationMin int `json:"replication_min"`
@@ -53,4 +73,212 @@ type StorageStatusResponse struct {
 	Peers []string `json:"peers"`
 	// Error contains any error message related to the pin status
 	Error string `json:"error,omitempty"`
+	// Attempts is the number of pin attempts made by the pin queue
+	Attempts int `json:"attempts,omitempty"`
+	// NextAttemptAt is when a queued pin is next tried (RFC3339)
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+}
+
+// StorageCreateUploadRequest starts a resumable chunked upload.
+type StorageCreateUploadRequest struct {
+	// Name is the optional filename for the uploaded content
+	Name string `json:"name,omitempty"`
+	// Size is the total size in bytes, if known; completing requires exactly this many bytes
+	Size int64 `json:"size,omitempty"`
+	// Pin controls whether the object is pinned after completion (default: true)
+	Pin *bool `json:"pin,omitempty"`
+	// Public stores the content unencrypted so it can be fetched from any IPFS gateway
+	Public bool `json:"public,omitempty"`
+	// ContentType is the MIME type of the content; detected if omitted
+	ContentType string `json:"content_type,omitempty"`
+	// Tags are labels recorded in the namespace object index
+	Tags []string `json:"tags,omitempty"`
+	// TTL expires the object after a duration such as "24h" or "7d", or a number of seconds
+	TTL StorageTTL `json:"ttl,omitempty"`
+}
+
+// StorageUploadSessionResponse describes the state of a chunked upload.
+type StorageUploadSessionResponse struct {
+	// UploadID identifies the session in /v1/storage/uploads/{id}
+	UploadID string `json:"upload_id"`
+	// Name is the filename associated with the content
+	Name string `json:"name"`
+	// Offset is the number of bytes received; the next chunk must start here
+	Offset int64 `json:"offset"`
+	// Size is the declared total size (0 if unknown)
+	Size int64 `json:"size,omitempty"`
+	// MaxObjectSize is the largest object the gateway accepts
+	MaxObjectSize int64 `json:"max_object_size"`
+	// ExpiresAt is when the session is discarded if no further chunks arrive
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StorageObject is an entry in a namespace's object index.
+type StorageObject struct {
+	// Cid is the Content Identifier of the object
+	Cid string `json:"cid"`
+	// Name is the filename associated with the content
+	Name string `json:"name"`
+	// Size is the plaintext size in bytes (0 if the object was pinned by CID)
+	Size int64 `json:"size"`
+	// ContentType is the MIME type recorded at upload
+	ContentType string `json:"content_type"`
+	// Tags are the labels attached to the object
+	Tags []string `json:"tags"`
+	// Encrypted reports whether the content is stored encrypted
+	Encrypted bool `json:"encrypted"`
+	// Pinned reports whether the namespace has the object pinned
+	Pinned bool `json:"pinned"`
+	// CreatedBy is the wallet address or API key fingerprint that stored the object
+	CreatedBy string `json:"created_by"`
+	// CreatedAt is when the object was first recorded
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is when the object's metadata last changed
+	UpdatedAt time.Time `json:"updated_at"`
+	// ExpiresAt is when the object is removed from the namespace, if it has a TTL
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// AccessedAt is roughly when the object was last downloaded (updated at most daily)
+	AccessedAt *time.Time `json:"accessed_at,omitempty"`
+}
+
+// StorageObjectListResponse is a page of a namespace's object index.
+type StorageObjectListResponse struct {
+	// Objects is the requested page
+	Objects []StorageObject `json:"objects"`
+	// Count is the number of objects in this page
+	Count int `json:"count"`
+	// Total is the number of objects matching the filters
+	Total int64 `json:"total"`
+	// Limit is the maximum page size that was applied
+	Limit int `json:"limit"`
+	// Offset is the number of matching objects skipped
+	Offset int `json:"offset"`
+}
+
+// StorageObjectUpdateRequest changes an object's name or tags.
+type StorageObjectUpdateRequest struct {
+	// Name replaces the object's name if set
+	Name *string `json:"name,omitempty"`
+	// Tags replaces the object's tags if set
+	Tags *[]string `json:"tags,omitempty"`
+}
+
+// StorageSite is a static website served from an IPFS directory.
+type StorageSite struct {
+	// Name identifies the site within its namespace
+	Name string `json:"name"`
+	// RootCid is the UnixFS directory currently served
+	RootCid string `json:"root_cid"`
+	// Domain is the custom domain the site is served on, if any
+	Domain string `json:"domain,omitempty"`
+	// SPA serves index.html for unknown paths so client-side routing works
+	SPA bool `json:"spa"`
+	// Files is the number of files in the current deployment
+	Files int `json:"files"`
+	// Size is the total size of the current deployment in bytes
+	Size int64 `json:"size"`
+	// Path is where the site is served on the gateway's own domain
+	Path string `json:"path"`
+	// CreatedAt is when the site was first deployed
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is when the site last changed
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeployedAt is when the current root was deployed
+	DeployedAt time.Time `json:"deployed_at"`
+}
+
+// StorageSiteDeployResponse is returned after a site deployment.
+type StorageSiteDeployResponse struct {
+	StorageSite
+	// PreviousCid is the root the site served before this deployment, if it changed
+	PreviousCid string `json:"previous_cid,omitempty"`
+}
+
+// StorageSiteUpdateRequest changes a site's settings. Nil fields are left unchanged.
+type StorageSiteUpdateRequest struct {
+	// Domain binds a verified custom domain; an empty string removes it
+	Domain *string `json:"domain,omitempty"`
+	// SPA toggles single-page application routing
+	SPA *bool `json:"spa,omitempty"`
+}
+
+// StorageTTL is an object lifetime: a duration such as "36h" or "7d", or a
+// number of seconds. JSON numbers are accepted as seconds.
+type StorageTTL string
+
+// StorageLifecycleRule expires objects in a namespace. An object matches when
+// it satisfies every filter that is set and every age condition that is set.
+type StorageLifecycleRule struct {
+	// ID names the rule in reports
+	ID string `json:"id"`
+	// Tag restricts the rule to objects carrying this tag
+	Tag string `json:"tag,omitempty"`
+	// Prefix restricts the rule to objects whose name starts with it
+	Prefix string `json:"prefix,omitempty"`
+	// ExpireAfterDays matches objects created at least this many days ago
+	ExpireAfterDays int `json:"expire_after_days,omitempty"`
+	// UnaccessedDays matches objects not downloaded for this many days (or since upload)
+	UnaccessedDays int `json:"unaccessed_days,omitempty"`
+	// Action is "delete" (default) to drop the object from the index and release
+	// its pin, or "unpin" to release the pin but keep the index entry
+	Action string `json:"action,omitempty"`
+	// Disabled keeps the rule without applying it
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// StorageLifecyclePolicy is a namespace's set of lifecycle rules.
+type StorageLifecyclePolicy struct {
+	// Rules are evaluated in order; an object is expired by the first that matches
+	Rules []StorageLifecycleRule `json:"rules"`
+	// UpdatedAt is when the rules last changed
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// StorageExpiredObject is an object expired, or due to expire, by a lifecycle run.
+type StorageExpiredObject struct {
+	// Cid is the Content Identifier of the object
+	Cid string `json:"cid"`
+	// Name is the filename associated with the content
+	Name string `json:"name"`
+	// Rule is the ID of the matching rule, or "ttl" for an upload TTL
+	Rule string `json:"rule"`
+	// Action is "delete" or "unpin"
+	Action string `json:"action"`
+}
+
+// StorageLifecycleReport describes a lifecycle run.
+type StorageLifecycleReport struct {
+	// DryRun reports whether objects were only listed, not expired
+	DryRun bool `json:"dry_run"`
+	// Expired are the matching objects
+	Expired []StorageExpiredObject `json:"expired"`
+	// Count is the number of matching objects
+	Count int `json:"count"`
+	// Truncated reports that more objects match than one run handles
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// StoragePresignRequest asks for a URL that performs one storage operation
+// without credentials.
+type StoragePresignRequest struct {
+	// Method is "get" to download Cid or "upload" to upload one object
+	Method string `json:"method"`
+	// Cid is the object a get URL downloads
+	Cid string `json:"cid,omitempty"`
+	// MaxSize caps the size of an upload in bytes (default: the gateway's maximum object size)
+	MaxSize int64 `json:"max_size,omitempty"`
+	// ExpiresIn is the URL lifetime in seconds (default: 900, max: 604800)
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+}
+
+// StoragePresignResponse is a presigned storage URL.
+type StoragePresignResponse struct {
+	// URL performs the operation until ExpiresAt; it may be used more than once
+	URL string `json:"url"`
+	// Method is the HTTP method to use with URL
+	Method string `json:"method"`
+	// ExpiresAt is when the URL stops working
+	ExpiresAt time.Time `json:"expires_at"`
+	// MaxSize is the largest upload the URL accepts
+	MaxSize int64 `json:"max_size,omitempty"`
 }
@@ -11,8 +11,10 @@ import (
 )
 
 // UnpinHandler handles DELETE /v1/storage/unpin/:cid.
-// It unpins a CID from the IPFS cluster, removing it from persistent storage
-// and allowing it to be garbage collected.
+// It releases the caller's namespace reference to a CID. The CID is unpinned
+// from the IPFS cluster, allowing it to be garbage collected, only once no
+// namespace references it anymore. Namespaces that never pinned the CID are
+// refused.
 func (h *Handlers) UnpinHandler(w http.ResponseWriter, r *http.Request) {
 	if h.ipfsClient == nil {
 		httputil.WriteError(w, http.StatusServiceUnavailable, "IPFS storage not available")
@@ -31,12 +33,37 @@ func (h *Handlers) UnpinHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	if err := h.ipfsClient.Unpin(ctx, path); err != nil {
+
+	// Without the reference table there is no way to tell tenants apart
+	if h.config.DB == nil {
+		httputil.WriteError(w, http.StatusServiceUnavailable, "unpin not available without the storage index")
+		return
+	}
+
+	namespace := h.getNamespaceFromContext(ctx)
+	if namespace == "" {
+		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
+		return
+	}
+
+	held, remaining, err := h.releasePin(ctx, namespace, path)
+	if err != nil {
 		h.logger.ComponentError(logging.ComponentGeneral, "failed to unpin CID",
-			zap.Error(err), zap.String("cid", path))
+			zap.Error(err), zap.String("cid", path), zap.String("namespace", namespace))
 		httputil.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("failed to unpin: %v", err))
 		return
 	}
+	if !held {
+		httputil.WriteError(w, http.StatusNotFound, fmt.Sprintf("cid not pinned by this namespace: %s", path))
+		return
+	}
+
+	h.recordPin(ctx, namespace, path, "", false, "")
 
-	httputil.WriteJSON(w, http.StatusOK, map[string]any{"status": "ok", "cid": path})
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{
+		"status":     "ok",
+		"cid":        path,
+		"references": remaining,
+		"unpinned":   remaining == 0,
+	})
 }
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/ctxkeys"
+	"github.com/DeBrosOfficial/network/pkg/ipfs"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+)
+
+// refsDB keeps storage_pin_refs in memory and ignores index writes.
+type refsDB struct {
+	refs map[[2]string]bool
+}
+
+func (d *refsDB) Query(ctx context.Context, dest any, query string, args ...any) error {
+	if rows, ok := dest.(*[]pinRefCount); ok {
+		var n int64
+		for k := range d.refs {
+			if k[1] == args[0] {
+				n++
+			}
+		}
+		*rows = append(*rows, pinRefCount{Count: n})
+	}
+	return nil
+}
+
+func (d *refsDB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT OR IGNORE INTO storage_pin_refs"):
+		k := [2]string{args[0].(string), args[1].(string)}
+		if d.refs[k] {
+			return driver.RowsAffected(0), nil
+		}
+		d.refs[k] = true
+	case strings.HasPrefix(query, "DELETE FROM storage_pin_refs"):
+		k := [2]string{args[0].(string), args[1].(string)}
+		if !d.refs[k] {
+			return driver.RowsAffected(0), nil
+		}
+		delete(d.refs, k)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// pinTracker records cluster pin state.
+type pinTracker struct {
+	memIPFS
+	pinned map[string]bool
+	down   bool // fail every pin
+}
+
+func (p *pinTracker) Pin(ctx context.Context, cid, name string, replicationFactor int) (*ipfs.PinResponse, error) {
+	if p.down {
+		return nil, fmt.Errorf("cluster unavailable")
+	}
+	p.pinned[cid] = true
+	return &ipfs.PinResponse{Cid: cid, Name: name}, nil
+}
+
+func (p *pinTracker) Unpin(ctx context.Context, cid string) error {
+	if !p.pinned[cid] {
+		return fmt.Errorf("not pinned")
+	}
+	delete(p.pinned, cid)
+	return nil
+}
+
+func TestUnpinHandler_ReferenceCounted(t *testing.T) {
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	cluster := &pinTracker{pinned: map[string]bool{}}
+	db := &refsDB{refs: map[[2]string]bool{}}
+	h := New(cluster, logger, Config{DB: db})
+
+	call := func(handler http.HandlerFunc, method, path, body, namespace string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, namespace))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	pin := func(namespace string) {
+		w := call(h.PinHandler, http.MethodPost, "/v1/storage/pin", `{"cid":"QmShared"}`, namespace)
+		if w.Code != http.StatusOK {
+			t.Fatalf("pin from %s: expected status %d, got %d: %s", namespace, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	unpin := func(namespace string) *httptest.ResponseRecorder {
+		return call(h.UnpinHandler, http.MethodDelete, "/v1/storage/unpin/QmShared", "", namespace)
+	}
+
+	pin("alpha")
+	pin("beta")
+
+	// A namespace that never pinned the CID cannot unpin it
+	if w := unpin("mallory"); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d for foreign unpin, got %d", http.StatusNotFound, w.Code)
+	}
+	if !cluster.pinned["QmShared"] {
+		t.Fatal("Foreign unpin must not touch the cluster pin")
+	}
+
+	w := unpin("alpha")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["references"] != float64(1) || resp["unpinned"] != false {
+		t.Errorf("Expected one remaining reference and no cluster unpin, got %v", resp)
+	}
+	if !cluster.pinned["QmShared"] {
+		t.Fatal("Cluster pin dropped while beta still references it")
+	}
+
+	// Releasing twice is refused
+	if w := unpin("alpha"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for repeated unpin, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if w := unpin("beta"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if cluster.pinned["QmShared"] {
+		t.Error("Expected cluster pin to be dropped with the last reference")
+	}
+
+	// A failed pin does not leave a reference behind
+	cluster.down = true
+	if w := call(h.PinHandler, http.MethodPost, "/v1/storage/pin", `{"cid":"QmShared"}`, "alpha"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d for a failed pin, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if db.refs[[2]string{"alpha", "QmShared"}] {
+		t.Error("Expected the reference to be dropped when the pin fails")
+	}
+	cluster.down = false
+
+	// Pins no namespace references cannot be claimed by anyone
+	cluster.pinned["QmLegacy"] = true
+	if w := call(h.UnpinHandler, http.MethodDelete, "/v1/storage/unpin/QmLegacy", "", "alpha"); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d for an unreferenced pin, got %d", http.StatusNotFound, w.Code)
+	}
+	if !cluster.pinned["QmLegacy"] {
+		t.Error("Expected the unreferenced cluster pin to be kept")
+	}
+
+	// Without the reference table ownership cannot be checked
+	noIndex := New(cluster, logger, Config{})
+	if w := call(noIndex.UnpinHandler, http.MethodDelete, "/v1/storage/unpin/QmLegacy", "", "alpha"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d without a database, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
@@ -5,23 +5,55 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/DeBrosOfficial/network/pkg/encryption"
 	"github.com/DeBrosOfficial/network/pkg/httputil"
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"go.uber.org/zap"
 )
 
 // Note: Context keys are imported from the gateway package
 // This avoids duplication and ensures compatibility with middleware
 
+const (
+	// DefaultMaxObjectSize is the largest object accepted when none is configured (5 GiB).
+	DefaultMaxObjectSize int64 = 5 << 30
+
+	// maxJSONUploadSize caps base64 JSON uploads, which are decoded in memory.
+	// Larger files must use multipart or chunked uploads.
+	maxJSONUploadSize int64 = 32 << 20
+
+	// maxFormFieldSize caps non-file multipart fields such as "pin".
+	maxFormFieldSize = 1 << 10
+
+	// maxReplicationFactor caps per-request replication overrides.
+	maxReplicationFactor = 10
+)
+
+var (
+	errObjectTooLarge        = errors.New("object exceeds maximum size")
+	errEncryptionUnavailable = errors.New("storage encryption unavailable; upload with public=true to store unencrypted")
+)
+
 // UploadHandler handles POST /v1/storage/upload.
 // It supports both multipart/form-data and JSON-based uploads with base64-encoded data.
-// Files are added to IPFS and optionally pinned for persistence.
+// Files are added to IPFS and optionally pinned for persistence. When encryption is
+// enabled, content is encrypted with a per-object data key unless the upload is
+// marked public.
+//
+// Multipart bodies are streamed straight to IPFS without buffering; the "pin",
+// "public", "tags", "ttl" and "replication_factor" fields must precede the
+// file part (or be passed as query parameters). A ttl removes the object from
+// the namespace once it passes. A "name" query parameter overrides the
+// multipart filename, which cannot carry directories.
 func (h *Handlers) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	if h.ipfsClient == nil {
 		httputil.WriteError(w, http.StatusServiceUnavailable, "IPFS storage not available")
@@ -34,48 +66,96 @@ func (h *Handlers) UploadHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get namespace from context
 	namespace := h.getNamespaceFromContext(r.Context())
+	var maxSize int64
+	var claimed *presignGrant // Released again unless the object is stored
+	if IsPresigned(r) {
+		if h.config.DB == nil {
+			httputil.WriteError(w, http.StatusServiceUnavailable, "presigned uploads not available without the storage index")
+			return
+		}
+		grant, err := h.presignedGrant(r)
+		if err == nil {
+			err = h.claimPresignedUpload(r.Context(), grant)
+		}
+		if err != nil {
+			h.writePresignError(w, err)
+			return
+		}
+		claimed = grant
+		defer func() {
+			if claimed != nil {
+				h.releasePresignedUpload(context.WithoutCancel(r.Context()), claimed)
+			}
+		}()
+		namespace, maxSize = grant.Namespace, grant.MaxSize
+	}
 	if namespace == "" {
 		httputil.WriteError(w, http.StatusUnauthorized, "namespace required")
 		return
 	}
 
-	// Get replication factor from config (default: 3)
-	replicationFactor := h.config.IPFSReplicationFactor
-	if replicationFactor == 0 {
-		replicationFactor = 3
-	}
-
 	// Check if it's multipart/form-data or JSON
-	contentType := r.Header.Get("Content-Type")
+	requestType := r.Header.Get("Content-Type")
 	var reader io.Reader
-	var name string
-	var shouldPin bool = true // Default to true
+	var name, contentType string
+	shouldPin := httputil.QueryParamBool(r, "pin", true) // Default to true
+	public := httputil.QueryParamBool(r, "public", false)
+	tags := splitTags(r.URL.Query().Get("tags"))
+	ttl := r.URL.Query().Get("ttl")
+	replication := r.URL.Query().Get("replication_factor")
 
-	if strings.HasPrefix(contentType, "multipart/form-data") {
-		// Handle multipart upload
-		if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB max
+	if strings.HasPrefix(requestType, "multipart/form-data") {
+		// Stream the multipart body part by part
+		mr, err := r.MultipartReader()
+		if err != nil {
 			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse multipart form: %v", err))
 			return
 		}
 
-		file, header, err := r.FormFile("file")
-		if err != nil {
-			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to get file: %v", err))
-			return
-		}
-		defer file.Close()
+		for reader == nil {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				httputil.WriteError(w, http.StatusBadRequest, "failed to get file: missing file part")
+				return
+			}
+			if err != nil {
+				httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse multipart form: %v", err))
+				return
+			}
 
-		reader = file
-		name = header.Filename
+			if part.FormName() == "file" {
+				reader = part
+				name = part.FileName()
+				contentType = part.Header.Get("Content-Type")
+				break
+			}
 
-		// Parse pin flag from form (default: true)
-		if pinValue := r.FormValue("pin"); pinValue != "" {
-			shouldPin = strings.ToLower(pinValue) == "true"
+			value, _ := io.ReadAll(io.LimitReader(part, maxFormFieldSize))
+			switch part.FormName() {
+			case "pin":
+				// Parse pin flag from form (default: true)
+				shouldPin = strings.ToLower(string(value)) == "true"
+			case "public":
+				public = strings.ToLower(string(value)) == "true"
+			case "tags":
+				tags = append(tags, splitTags(string(value))...)
+			case "ttl":
+				ttl = string(value)
+			case "replication_factor":
+				replication = string(value)
+			}
 		}
 	} else {
 		// Handle JSON request with base64 data
+		r.Body = http.MaxBytesReader(w, r.Body, maxJSONUploadSize)
 		var req StorageUploadRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				httputil.WriteError(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("JSON uploads are limited to %d bytes; use multipart or chunked uploads for larger files", maxJSONUploadSize))
+				return
+			}
 			httputil.WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
 			return
 		}
@@ -94,58 +174,248 @@ func (h *Handlers) UploadHandler(w http.ResponseWriter, r *http.Request) {
 
 		reader = bytes.NewReader(data)
 		name = req.Name
+		public = req.Public
+		contentType = req.ContentType
+		tags = req.Tags
+		if req.TTL != "" {
+			ttl = string(req.TTL)
+		}
+		if req.ReplicationFactor != 0 {
+			replication = strconv.Itoa(req.ReplicationFactor)
+		}
 		// For JSON requests, pin defaults to true (can be extended if needed)
 	}
 
-	// Add to IPFS
-	ctx := r.Context()
-	addResp, err := h.ipfsClient.Add(ctx, reader, name)
+	if v := r.URL.Query().Get("name"); v != "" {
+		name = v
+	}
+
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	expiry, err := parseTTL(ttl)
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	replicationFactor, err := parseReplicationFactor(replication)
 	if err != nil {
-		h.logger.ComponentError(logging.ComponentGeneral, "failed to add content to IPFS", zap.Error(err))
-		httputil.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("failed to add content: %v", err))
+		httputil.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Return response immediately - don't block on pinning
-	response := StorageUploadResponse{
-		Cid:  addResp.Cid,
-		Name: addResp.Name,
-		Size: addResp.Size,
+	ctx := r.Context()
+	response, err := h.storeObject(ctx, reader, storeOptions{
+		Namespace:   namespace,
+		Name:        name,
+		ContentType: contentType,
+		Tags:        tags,
+		Public:      public,
+		Pin:         shouldPin,
+		CreatedBy:   callerIdentity(ctx),
+		TTL:         expiry,
+		MaxSize:     maxSize,
+	})
+	if maxSize > 0 && errors.Is(err, errObjectTooLarge) {
+		httputil.WriteError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("object exceeds the presigned limit of %d bytes", maxSize))
+		return
+	}
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
 	}
+	claimed = nil
 
-	// Pin asynchronously in background if requested
+	// Queue the pin if requested; progress is reported by the status endpoint
 	if shouldPin {
-		go h.pinAsync(addResp.Cid, name, replicationFactor)
+		h.enqueuePin(ctx, namespace, response.Cid, name, response.Size, replicationFactor)
 	}
 
+	// Return response immediately - don't block on pinning
 	httputil.WriteJSON(w, http.StatusOK, response)
 }
 
-// pinAsync pins a CID asynchronously in the background with retry logic.
-// It retries once if the first attempt fails, then gives up.
-func (h *Handlers) pinAsync(cid, name string, replicationFactor int) {
-	ctx := context.Background()
+// storeOptions describes an object being stored.
+type storeOptions struct {
+	Namespace   string
+	Name        string
+	ContentType string // Declared type; detected from the name or content if empty
+	Tags        []string
+	Public      bool
+	Pin         bool
+	CreatedBy   string
+	TTL         time.Duration // Expire the object after this long; 0 keeps it
+	MaxSize     int64         // Lower size limit than the configured maximum; 0 uses it
+}
 
-	// First attempt
-	_, err := h.ipfsClient.Pin(ctx, cid, name, replicationFactor)
-	if err == nil {
-		h.logger.ComponentWarn(logging.ComponentGeneral, "async pin succeeded", zap.String("cid", cid))
-		return
+// storeObject adds reader to IPFS, encrypting it first unless public, enforces
+// the maximum object size and records the object in the namespace index. The
+// returned size is the plaintext size.
+func (h *Handlers) storeObject(ctx context.Context, reader io.Reader, opts storeOptions) (*StorageUploadResponse, error) {
+	namespace, name := opts.Namespace, opts.Name
+	limit := h.maxObjectSize()
+	if opts.MaxSize > 0 && opts.MaxSize < limit {
+		limit = opts.MaxSize
 	}
+	limited := &sizeLimitReader{r: reader, remaining: limit}
+	sniff := &sniffReader{r: limited}
+	reader = sniff
 
-	// Log first failure
-	h.logger.ComponentWarn(logging.ComponentGeneral, "async pin failed, retrying once",
-		zap.Error(err), zap.String("cid", cid))
+	// Encrypt private uploads with a fresh data key
+	var objKey *encryption.ObjectKey
+	var encReader *encryption.EncryptingReader
+	if h.config.EnableEncryption && !opts.Public {
+		if h.config.Keyring == nil {
+			return nil, errEncryptionUnavailable
+		}
+		var err error
+		objKey, err = h.config.Keyring.NewObjectKey(ctx, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create object key: %w", err)
+		}
+		encReader, err = encryption.NewEncryptingReader(reader, objKey.DataKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare encryption: %w", err)
+		}
+		reader = encReader
+	}
 
-	// Retry once after a short delay
-	time.Sleep(2 * time.Second)
-	_, err = h.ipfsClient.Pin(ctx, cid, name, replicationFactor)
+	// Add to IPFS
+	addResp, err := h.ipfsClient.Add(ctx, reader, name)
+	if limited.exceeded {
+		return nil, errObjectTooLarge
+	}
 	if err != nil {
-		// Final failure - log and give up
-		h.logger.ComponentWarn(logging.ComponentGeneral, "async pin retry failed, giving up",
-			zap.Error(err), zap.String("cid", cid))
-	} else {
-		h.logger.ComponentWarn(logging.ComponentGeneral, "async pin succeeded on retry", zap.String("cid", cid))
+		return nil, fmt.Errorf("failed to add content: %w", err)
+	}
+
+	metering.Record(ctx, metering.StorageBytesUploaded, float64(addResp.Size))
+
+	response := &StorageUploadResponse{
+		Cid:         addResp.Cid,
+		Name:        addResp.Name,
+		Size:        addResp.Size,
+		ContentType: detectContentType(opts.ContentType, name, sniff.head),
+	}
+	if opts.TTL > 0 {
+		expires := time.Now().UTC().Add(opts.TTL).Truncate(time.Second)
+		response.ExpiresAt = &expires
+	}
+
+	if objKey != nil {
+		// Without its metadata the object could never be decrypted, so fail the upload
+		if err := h.config.Keyring.SaveObject(ctx, addResp.Cid, objKey, encReader.PlaintextSize()); err != nil {
+			return nil, fmt.Errorf("failed to record encryption metadata: %w", err)
+		}
+		response.Size = encReader.PlaintextSize()
+		response.Encrypted = true
+	}
+
+	if opts.Pin && namespace != "" && h.config.DB != nil {
+		// Unpin is refused to namespaces without a reference, so this must stick
+		if err := h.addPinRef(ctx, namespace, addResp.Cid); err != nil {
+			return nil, fmt.Errorf("failed to record pin reference: %w", err)
+		}
+	}
+
+	h.recordObject(ctx, namespace, response, opts.Tags, opts.Pin, opts.CreatedBy)
+
+	return response, nil
+}
+
+// writeStoreError maps storeObject errors to HTTP responses.
+func (h *Handlers) writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errObjectTooLarge):
+		httputil.WriteError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("object exceeds maximum size of %d bytes", h.maxObjectSize()))
+	case errors.Is(err, errEncryptionUnavailable):
+		httputil.WriteError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		h.logger.ComponentError(logging.ComponentGeneral, "failed to store object", zap.Error(err))
+		httputil.WriteError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// maxObjectSize returns the configured object size limit.
+func (h *Handlers) maxObjectSize() int64 {
+	if h.config.MaxObjectSize > 0 {
+		return h.config.MaxObjectSize
+	}
+	return DefaultMaxObjectSize
+}
+
+// parseReplicationFactor parses a per-request replication override; empty
+// or 0 uses the configured factor.
+func parseReplicationFactor(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 0 || n > maxReplicationFactor {
+		return 0, fmt.Errorf("replication_factor must be between 0 and %d (0 uses the default)", maxReplicationFactor)
+	}
+	return n, nil
+}
+
+// replicationFactor returns the configured replication factor (default: 3).
+func (h *Handlers) replicationFactor() int {
+	if h.config.IPFSReplicationFactor > 0 {
+		return h.config.IPFSReplicationFactor
+	}
+	return 3
+}
+
+// sizeLimitReader fails once more than remaining bytes have been read.
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		l.exceeded = true
+		return 0, errObjectTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.exceeded = true
+		return 0, errObjectTooLarge
+	}
+	return n, err
+}
+
+// pinAsync pins a CID in the background, retrying with exponential backoff.
+// It is the fallback when the durable pin queue is unavailable, so progress is
+// lost on restart. ctx must not be tied to the request lifetime; it carries
+// the caller's metering binding.
+func (h *Handlers) pinAsync(ctx context.Context, cid, name string, size int64, replicationFactor int) {
+	maxAttempts := h.pinMaxAttempts()
+	for attempt := 1; ; attempt++ {
+		_, err := h.ipfsClient.Pin(ctx, cid, name, replicationFactor)
+		if err == nil {
+			metering.Record(ctx, metering.StorageBytesPinned, float64(size))
+			h.logger.ComponentInfo(logging.ComponentGeneral, "async pin succeeded",
+				zap.String("cid", cid), zap.Int("attempts", attempt))
+			return
+		}
+		if attempt >= maxAttempts {
+			h.logger.ComponentError(logging.ComponentGeneral, "async pin failed permanently",
+				zap.Error(err), zap.String("cid", cid), zap.Int("attempts", attempt))
+			return
+		}
+		delay := pinBackoff(attempt)
+		h.logger.ComponentWarn(logging.ComponentGeneral, "async pin failed, will retry",
+			zap.Error(err), zap.String("cid", cid), zap.Duration("retry_in", delay))
+		time.Sleep(delay)
 	}
 }
 
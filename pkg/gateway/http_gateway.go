@@ -16,6 +16,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/DeBrosOfficial/network/pkg/config"
+	gwhttputil "github.com/DeBrosOfficial/network/pkg/httputil"
 	"github.com/DeBrosOfficial/network/pkg/logging"
 )
 
@@ -25,6 +26,7 @@ type HTTPGateway struct {
 	config         *config.HTTPGatewayConfig
 	router         chi.Router
 	reverseProxies map[string]*httputil.ReverseProxy
+	trustedProxies gwhttputil.TrustedProxies
 	mu             sync.RWMutex
 	server         *http.Server
 }
@@ -50,6 +52,12 @@ func NewHTTPGateway(logger *logging.ColoredLogger, cfg *config.HTTPGatewayConfig
 		reverseProxies: make(map[string]*httputil.ReverseProxy),
 	}
 
+	trusted, err := gwhttputil.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.ComponentWarn(logging.ComponentGeneral, "ignoring invalid trusted proxies", zap.Error(err))
+	}
+	gateway.trustedProxies = trusted
+
 	// Set up router middleware
 	gateway.router.Use(middleware.RequestID)
 	gateway.router.Use(middleware.Logger)
@@ -95,7 +103,7 @@ func (hg *HTTPGateway) initializeRoutes() error {
 				// Keep original host for Host header
 				r.Out.Host = r.In.Host
 				// Set X-Forwarded-For header for logging
-				r.Out.Header.Set("X-Forwarded-For", getClientIP(r.In))
+				r.Out.Header.Set("X-Forwarded-For", getClientIP(r.In, hg.trustedProxies))
 			},
 			ErrorHandler: hg.proxyErrorHandler(routeName),
 		}
@@ -161,7 +169,7 @@ func (hg *HTTPGateway) handleProxyRequest(w http.ResponseWriter, req *http.Reque
 		zap.String("stripped_path", req.URL.Path),
 		zap.String("backend", routeConfig.BackendURL),
 		zap.String("method", req.Method),
-		zap.String("client_ip", getClientIP(req)),
+		zap.String("client_ip", getClientIP(req, hg.trustedProxies)),
 	)
 
 	// Handle WebSocket upgrades if configured
@@ -21,6 +21,24 @@ func (g *Gateway) Close() {
 		cancel()
 	}
 
+	// Stop the pin queue; unfinished jobs resume on the next start
+	if g.storageHandlers != nil {
+		g.storageHandlers.Close()
+	}
+
+	// Stop pruning durable pub/sub topic history and refreshing presence
+	if g.pubsubHandlers != nil {
+		g.pubsubHandlers.Close()
+	}
+
+	// Stop push notifications after pub/sub, which feeds the bridge
+	g.pushBridge.Close()
+	g.pushReceipts.Close()
+
+	// Flush buffered request logs and usage counters while the database is still reachable
+	g.requestLogs.Close()
+	g.meter.Close()
+
 	// Disconnect network client
 	if g.client != nil {
 		if err := g.client.Disconnect(); err != nil {
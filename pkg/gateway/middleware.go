@@ -5,33 +5,45 @@ import (
 	"encoding/json"
 	"net"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/DeBrosOfficial/network/pkg/client"
 	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/gateway/handlers/storage"
+	"github.com/DeBrosOfficial/network/pkg/httputil"
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 // Note: context keys (ctxKeyAPIKey, ctxKeyJWT, CtxKeyNamespaceOverride) are now defined in context.go
 
-// withMiddleware adds CORS and logging middleware
+// withMiddleware adds CORS, logging and rate limiting middleware
 func (g *Gateway) withMiddleware(next http.Handler) http.Handler {
-	// Order: logging (outermost) -> CORS -> auth -> handler
+	// Order: logging (outermost) -> CORS -> IP rate limit -> site domains -> auth -> namespace CORS -> identity rate limit -> authorization -> usage/quotas -> handler
 	// Add authorization layer after auth to enforce namespace ownership
-	return g.loggingMiddleware(g.corsMiddleware(g.authMiddleware(g.authorizationMiddleware(next))))
+	return g.loggingMiddleware(g.corsMiddleware(g.ipRateLimitMiddleware(g.siteHostMiddleware(g.authMiddleware(g.namespaceCORSMiddleware(g.identityRateLimitMiddleware(g.authorizationMiddleware(g.usageMiddleware(next)))))))))
 }
 
 // loggingMiddleware logs basic request info and duration
 func (g *Gateway) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		// Propagate or assign a request ID so clients and logs can correlate requests
+		reqID := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+		if reqID == "" {
+			reqID = uuid.NewString()
+			r.Header.Set("X-Request-ID", reqID)
+		}
+		w.Header().Set("X-Request-ID", reqID)
 		srw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		info := &requestLogInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), ctxKeyRequestLog, info))
 		next.ServeHTTP(srw, r)
 		dur := time.Since(start)
 		g.logger.ComponentInfo(logging.ComponentGeneral, "request",
+			zap.String("request_id", reqID),
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Int("status", srw.status),
@@ -39,8 +51,18 @@ func (g *Gateway) loggingMiddleware(next http.Handler) http.Handler {
 			zap.String("duration", dur.String()),
 		)
 
-		// Persist request log asynchronously (best-effort)
-		go g.persistRequestLog(r, srw, dur)
+		// Queue the request log for batched persistence (best-effort)
+		g.requestLogs.Enqueue(requestLogEntry{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     srw.status,
+			BytesOut:   srw.bytes,
+			DurationMS: dur.Milliseconds(),
+			IP:         getClientIP(r, g.trustedProxies),
+			APIKey:     info.APIKey,
+			Namespace:  info.Namespace,
+			CreatedAt:  start,
+		})
 	})
 }
 
@@ -57,6 +79,19 @@ func (g *Gateway) authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Presigned storage URLs carry their own signed namespace grant
+		if g.storageHandlers != nil && storage.IsPresigned(r) {
+			ns, err := g.storageHandlers.VerifyPresigned(r)
+			if err != nil {
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			ctx := context.WithValue(r.Context(), CtxKeyNamespaceOverride, ns)
+			setRequestLogIdentity(ctx, "", ns)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		isPublic := isPublicPath(r.URL.Path)
 
 		// 1) Try JWT Bearer first if Authorization looks like one
@@ -70,6 +105,7 @@ func (g *Gateway) authMiddleware(next http.Handler) http.Handler {
 						ctx := context.WithValue(r.Context(), ctxKeyJWT, claims)
 						if ns := strings.TrimSpace(claims.Namespace); ns != "" {
 							ctx = context.WithValue(ctx, CtxKeyNamespaceOverride, ns)
+							setRequestLogIdentity(ctx, "", ns)
 						}
 						next.ServeHTTP(w, r.WithContext(ctx))
 						return
@@ -127,6 +163,7 @@ func (g *Gateway) authMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Attach auth metadata to context for downstream use
+		setRequestLogIdentity(r.Context(), key, ns)
 		reqCtx := context.WithValue(r.Context(), ctxKeyAPIKey, key)
 		reqCtx = context.WithValue(reqCtx, CtxKeyNamespaceOverride, ns)
 		next.ServeHTTP(w, r.WithContext(reqCtx))
@@ -191,6 +228,11 @@ func isPublicPath(p string) bool {
 		return true
 	}
 
+	// Static sites are public; their content is served without credentials
+	if strings.HasPrefix(p, "/v1/sites/") {
+		return true
+	}
+
 	switch p {
 	case "/health", "/v1/health", "/status", "/v1/status", "/v1/auth/jwks", "/.well-known/jwks.json", "/v1/version", "/v1/auth/login", "/v1/auth/challenge", "/v1/auth/verify", "/v1/auth/register", "/v1/auth/refresh", "/v1/auth/logout", "/v1/auth/api-key", "/v1/auth/simple-key", "/v1/network/status", "/v1/network/peers":
 		return true
@@ -326,6 +368,9 @@ func requiresNamespaceOwnership(p string) bool {
 	if strings.HasPrefix(p, "/v1/pubsub") {
 		return true
 	}
+	if strings.HasPrefix(p, "/v1/push/") {
+		return true
+	}
 	if strings.HasPrefix(p, "/v1/rqlite/") {
 		return true
 	}
@@ -335,94 +380,40 @@ func requiresNamespaceOwnership(p string) bool {
 	if strings.HasPrefix(p, "/v1/functions") {
 		return true
 	}
+	if strings.HasPrefix(p, "/v1/namespaces/") {
+		return true
+	}
 	return false
 }
 
-// corsMiddleware applies permissive CORS headers suitable for early development
-func (g *Gateway) corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
-		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(600))
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-// persistRequestLog writes request metadata to the database (best-effort)
-func (g *Gateway) persistRequestLog(r *http.Request, srw *statusResponseWriter, dur time.Duration) {
-	if g.client == nil {
-		return
-	}
-	// Use a short timeout to avoid blocking shutdowns
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	db := g.client.Database()
-
-	// Resolve API key ID if available
-	var apiKeyID interface{} = nil
-	if v := r.Context().Value(ctxKeyAPIKey); v != nil {
-		if key, ok := v.(string); ok && key != "" {
-			if res, err := db.Query(ctx, "SELECT id FROM api_keys WHERE key = ? LIMIT 1", key); err == nil {
-				if res != nil && res.Count > 0 && len(res.Rows) > 0 && len(res.Rows[0]) > 0 {
-					switch idv := res.Rows[0][0].(type) {
-					case int64:
-						apiKeyID = idv
-					case float64:
-						apiKeyID = int64(idv)
-					case int:
-						apiKeyID = int64(idv)
-					case string:
-						// best effort parse
-						if n, err := strconv.Atoi(idv); err == nil {
-							apiKeyID = int64(n)
-						}
-					}
-				}
-			}
-		}
+// getClientIP returns the address of the client behind any trusted proxies.
+// X-Forwarded-For is only believed when the connection comes from a trusted
+// proxy; it is then walked from the right, skipping trusted proxies, so a
+// client cannot pick its own address by sending the header itself.
+func getClientIP(r *http.Request, trusted httputil.TrustedProxies) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
 	}
-
-	ip := getClientIP(r)
-
-	// Insert the log row
-	_, _ = db.Query(ctx,
-		"INSERT INTO request_logs (method, path, status_code, bytes_out, duration_ms, ip, api_key_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		r.Method,
-		r.URL.Path,
-		srw.status,
-		srw.bytes,
-		dur.Milliseconds(),
-		ip,
-		apiKeyID,
-	)
-
-	// Update last_used_at for the API key if present
-	if apiKeyID != nil {
-		_, _ = db.Query(ctx, "UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", apiKeyID)
+	ip := net.ParseIP(host)
+	if ip == nil || !trusted.Contains(ip) {
+		return host
 	}
-}
-
-// getClientIP extracts the client IP from headers or RemoteAddr
-func getClientIP(r *http.Request) string {
-	// X-Forwarded-For may contain a list of IPs, take the first
-	if xff := strings.TrimSpace(r.Header.Get("X-Forwarded-For")); xff != "" {
-		parts := strings.Split(xff, ",")
-		if len(parts) > 0 {
-			return strings.TrimSpace(parts[0])
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		host = hop.String()
+		if !trusted.Contains(hop) {
+			return host
 		}
 	}
-	if xr := strings.TrimSpace(r.Header.Get("X-Real-IP")); xr != "" {
-		return xr
-	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	if host == ip.String() {
+		if xr := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); xr != nil {
+			return xr.String()
+		}
 	}
 	return host
 }
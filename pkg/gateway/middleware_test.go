@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
 )
 
 func TestExtractAPIKey(t *testing.T) {
@@ -26,3 +28,28 @@ func TestExtractAPIKey(t *testing.T) {
 		t.Fatalf("got %q", got)
 	}
 }
+
+func TestGetClientIP(t *testing.T) {
+	trusted, _ := httputil.ParseTrustedProxies([]string{"192.0.2.1"})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil) // RemoteAddr 192.0.2.1
+	r.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.1")
+	if got := getClientIP(r, trusted); got != "10.0.0.1" {
+		t.Fatalf("behind a trusted proxy: got %q", got)
+	}
+	r.Header = http.Header{}
+	r.Header.Set("X-Real-IP", "10.0.0.2")
+	if got := getClientIP(r, trusted); got != "10.0.0.2" {
+		t.Fatalf("X-Real-IP behind a trusted proxy: got %q", got)
+	}
+
+	// Headers from anyone else are ignored
+	r.RemoteAddr = "198.51.100.7:4000"
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	if got := getClientIP(r, trusted); got != "198.51.100.7" {
+		t.Fatalf("untrusted client: got %q", got)
+	}
+	if got := getClientIP(r, nil); got != "198.51.100.7" {
+		t.Fatalf("no trusted proxies: got %q", got)
+	}
+}
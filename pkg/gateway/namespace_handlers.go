@@ -0,0 +1,303 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+)
+
+// namespaceRoutesHandler dispatches /v1/namespaces/{ns}/{resource} requests.
+// The caller must be authenticated for {ns}; ownership is enforced by authorizationMiddleware.
+func (g *Gateway) namespaceRoutesHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/namespaces/"), "/")
+	ns, resource, _ := strings.Cut(rest, "/")
+	ns = strings.TrimSpace(ns)
+	if ns == "" || resource == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if ns != g.requestNamespace(r) {
+		writeError(w, http.StatusForbidden, "forbidden: namespace mismatch")
+		return
+	}
+
+	switch resource {
+	case "cors":
+		g.namespaceCORSHandler(w, r, ns)
+	case "requests":
+		g.namespaceRequestsHandler(w, r, ns)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// namespaceCORSHandler handles GET/PUT/DELETE /v1/namespaces/{ns}/cors.
+//
+// PUT body:
+//
+//	{
+//	  "allowed_origins": ["https://app.example.com", "https://*.example.com"],
+//	  "allow_credentials": true,
+//	  "exposed_headers": ["X-Custom"],
+//	  "max_age_seconds": 600
+//	}
+func (g *Gateway) namespaceCORSHandler(w http.ResponseWriter, r *http.Request, ns string) {
+	if g.client == nil {
+		writeError(w, http.StatusServiceUnavailable, "client not initialized")
+		return
+	}
+	ctx := client.WithInternalAuth(r.Context())
+	db := g.client.Database()
+
+	switch r.Method {
+	case http.MethodGet:
+		policy, ok := g.corsPolicies.get(r.Context(), ns)
+		if !ok {
+			d := g.corsDefaults()
+			writeJSON(w, http.StatusOK, map[string]any{
+				"namespace": ns,
+				"custom":    false,
+				"policy": CORSPolicy{
+					AllowedOrigins:   d.AllowedOrigins,
+					AllowCredentials: d.AllowCredentials,
+					ExposedHeaders:   d.ExposedHeaders,
+					MaxAgeSeconds:    int(d.MaxAge.Seconds()),
+				},
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"namespace": ns, "custom": true, "policy": policy})
+
+	case http.MethodPut, http.MethodPost:
+		var policy CORSPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json body")
+			return
+		}
+		if len(policy.AllowedOrigins) == 0 {
+			writeError(w, http.StatusBadRequest, "allowed_origins is required")
+			return
+		}
+		for _, o := range policy.AllowedOrigins {
+			if err := validateCORSOrigin(o); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if policy.AllowCredentials && strings.TrimSpace(o) == "*" {
+				writeError(w, http.StatusBadRequest, "allow_credentials cannot be combined with origin \"*\"")
+				return
+			}
+		}
+		if policy.MaxAgeSeconds < 0 || policy.MaxAgeSeconds > 86400 {
+			writeError(w, http.StatusBadRequest, "max_age_seconds must be between 0 and 86400")
+			return
+		}
+		if policy.MaxAgeSeconds == 0 {
+			policy.MaxAgeSeconds = int(defaultCORSMaxAge.Seconds())
+		}
+
+		origins, _ := json.Marshal(policy.AllowedOrigins)
+		exposed, _ := json.Marshal(policy.ExposedHeaders)
+		_, err := db.Query(ctx,
+			`INSERT INTO namespace_cors_policies (namespace, allowed_origins, allow_credentials, exposed_headers, max_age_seconds, updated_by)
+			 VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(namespace) DO UPDATE SET
+			   allowed_origins = excluded.allowed_origins,
+			   allow_credentials = excluded.allow_credentials,
+			   exposed_headers = excluded.exposed_headers,
+			   max_age_seconds = excluded.max_age_seconds,
+			   updated_by = excluded.updated_by,
+			   updated_at = CURRENT_TIMESTAMP`,
+			ns, string(origins), policy.AllowCredentials, string(exposed), policy.MaxAgeSeconds, g.requestActor(r),
+		)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store CORS policy: %v", err))
+			return
+		}
+		g.corsPolicies.invalidate()
+		writeJSON(w, http.StatusOK, map[string]any{"namespace": ns, "custom": true, "policy": policy})
+
+	case http.MethodDelete:
+		if _, err := db.Query(ctx, "DELETE FROM namespace_cors_policies WHERE namespace = ?", ns); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete CORS policy: %v", err))
+			return
+		}
+		g.corsPolicies.invalidate()
+		writeJSON(w, http.StatusOK, map[string]any{"namespace": ns, "status": "deleted"})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// namespaceRequestsHandler handles GET /v1/namespaces/{ns}/requests.
+// It lists request logs for the namespace, newest first, or aggregates them
+// when group_by is set.
+//
+// Query parameters:
+//   - path: exact path, or a prefix when it ends with "*"
+//   - method: HTTP method
+//   - status: exact status code (e.g. 404) or class (e.g. 5xx)
+//   - since, until: RFC3339 timestamps or Unix seconds
+//   - group_by: "path" or "status" to return counts instead of rows
+//   - limit (default 100, max 1000), offset
+func (g *Gateway) namespaceRequestsHandler(w http.ResponseWriter, r *http.Request, ns string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if g.client == nil {
+		writeError(w, http.StatusServiceUnavailable, "client not initialized")
+		return
+	}
+
+	q := r.URL.Query()
+	where := []string{"namespace = ?"}
+	args := []interface{}{ns}
+
+	if p := strings.TrimSpace(q.Get("path")); p != "" {
+		if strings.HasSuffix(p, "*") {
+			where = append(where, "path LIKE ?")
+			args = append(args, strings.TrimSuffix(p, "*")+"%")
+		} else {
+			where = append(where, "path = ?")
+			args = append(args, p)
+		}
+	}
+	if m := strings.TrimSpace(q.Get("method")); m != "" {
+		where = append(where, "method = ?")
+		args = append(args, strings.ToUpper(m))
+	}
+	if st := strings.ToLower(strings.TrimSpace(q.Get("status"))); st != "" {
+		if len(st) == 3 && strings.HasSuffix(st, "xx") && st[0] >= '1' && st[0] <= '5' {
+			base := int(st[0]-'0') * 100
+			where = append(where, "status_code >= ? AND status_code < ?")
+			args = append(args, base, base+100)
+		} else if code, err := strconv.Atoi(st); err == nil {
+			where = append(where, "status_code = ?")
+			args = append(args, code)
+		} else {
+			writeError(w, http.StatusBadRequest, "invalid status; expected a code like 404 or a class like 5xx")
+			return
+		}
+	}
+	for _, bound := range []struct{ param, op string }{{"since", ">="}, {"until", "<"}} {
+		v := strings.TrimSpace(q.Get(bound.param))
+		if v == "" {
+			continue
+		}
+		t, err := parseTimeParam(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %v", bound.param, err))
+			return
+		}
+		where = append(where, "created_at "+bound.op+" ?")
+		args = append(args, t.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = min(n, 1000)
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset")
+			return
+		}
+		offset = n
+	}
+
+	ctx := client.WithInternalAuth(r.Context())
+	db := g.client.Database()
+	cond := strings.Join(where, " AND ")
+
+	if groupBy := strings.TrimSpace(q.Get("group_by")); groupBy != "" {
+		col := map[string]string{"path": "path", "status": "status_code"}[groupBy]
+		if col == "" {
+			writeError(w, http.StatusBadRequest, "invalid group_by; expected path or status")
+			return
+		}
+		res, err := db.Query(ctx,
+			"SELECT "+col+", COUNT(*), AVG(duration_ms), SUM(bytes_out) FROM request_logs WHERE "+cond+
+				" GROUP BY "+col+" ORDER BY COUNT(*) DESC LIMIT ? OFFSET ?",
+			append(args, limit, offset)...)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to query request logs: %v", err))
+			return
+		}
+		groups := make([]map[string]any, 0)
+		if res != nil {
+			for _, row := range res.Rows {
+				if len(row) < 4 {
+					continue
+				}
+				groups = append(groups, map[string]any{
+					groupBy:           row[0],
+					"count":           rowInt(row[1]),
+					"avg_duration_ms": row[2],
+					"bytes_out":       rowInt(row[3]),
+				})
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"namespace": ns,
+			"group_by":  groupBy,
+			"groups":    groups,
+			"count":     len(groups),
+		})
+		return
+	}
+
+	res, err := db.Query(ctx,
+		"SELECT method, path, status_code, bytes_out, duration_ms, ip, created_at FROM request_logs WHERE "+cond+
+			" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to query request logs: %v", err))
+		return
+	}
+	requests := make([]map[string]any, 0)
+	if res != nil {
+		for _, row := range res.Rows {
+			if len(row) < 7 {
+				continue
+			}
+			requests = append(requests, map[string]any{
+				"method":      row[0],
+				"path":        row[1],
+				"status_code": rowInt(row[2]),
+				"bytes_out":   rowInt(row[3]),
+				"duration_ms": rowInt(row[4]),
+				"ip":          row[5],
+				"created_at":  row[6],
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"namespace": ns,
+		"requests":  requests,
+		"count":     len(requests),
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// parseTimeParam accepts RFC3339 timestamps or Unix seconds.
+func parseTimeParam(v string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
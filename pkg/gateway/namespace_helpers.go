@@ -3,9 +3,11 @@ package gateway
 import (
 	"context"
 	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
 )
 
 // resolveNamespaceID ensures the given namespace exists and returns its primary key ID.
@@ -34,3 +36,35 @@ func (g *Gateway) resolveNamespaceID(ctx context.Context, ns string) (interface{
 	}
 	return res.Rows[0][0], nil
 }
+
+// requestNamespace returns the namespace resolved by the auth middleware,
+// falling back to the gateway's configured client namespace.
+func (g *Gateway) requestNamespace(r *http.Request) string {
+	if v, ok := r.Context().Value(CtxKeyNamespaceOverride).(string); ok {
+		if ns := strings.TrimSpace(v); ns != "" {
+			return ns
+		}
+	}
+	if g.cfg != nil {
+		return strings.TrimSpace(g.cfg.ClientNamespace)
+	}
+	return ""
+}
+
+// requestActor identifies the authenticated caller for audit columns: the JWT
+// subject when present, otherwise a truncated API key (never the full secret).
+func (g *Gateway) requestActor(r *http.Request) string {
+	if claims, ok := r.Context().Value(ctxKeyJWT).(*auth.JWTClaims); ok && claims != nil {
+		if sub := strings.TrimSpace(claims.Sub); sub != "" {
+			return sub
+		}
+	}
+	if key, ok := r.Context().Value(ctxKeyAPIKey).(string); ok {
+		key = strings.TrimSpace(key)
+		if len(key) > 12 {
+			key = key[:12] + "..."
+		}
+		return key
+	}
+	return ""
+}
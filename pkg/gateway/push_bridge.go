@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	pubsubhandlers "github.com/DeBrosOfficial/network/pkg/gateway/handlers/pubsub"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	pushBridgeQueueSize = 256
+	pushBridgeTimeout   = 30 * time.Second
+	maxPushTitleLength  = 100
+	maxPushBodyLength   = 240
+)
+
+// pushBridge notifies the devices registered for a pubsub topic when a
+// message is published to it, unless their wallet is present in the topic
+// (and so already receives it) or published it.
+type pushBridge struct {
+	gw    *Gateway
+	queue chan pubsubhandlers.PublishedMessage
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newPushBridge(gw *Gateway) *pushBridge {
+	b := &pushBridge{
+		gw:    gw,
+		queue: make(chan pubsubhandlers.PublishedMessage, pushBridgeQueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// enqueue queues a published message without blocking the publisher; when
+// the queue is full the notification is dropped.
+func (b *pushBridge) enqueue(m pubsubhandlers.PublishedMessage) {
+	select {
+	case b.queue <- m:
+	default:
+		b.gw.logger.ComponentWarn(logging.ComponentGeneral, "push bridge queue full, dropping notification",
+			zap.String("namespace", m.Namespace), zap.String("topic", m.Topic))
+	}
+}
+
+func (b *pushBridge) run() {
+	defer close(b.done)
+	for {
+		select {
+		case <-b.stop:
+			return
+		case m := <-b.queue:
+			ctx, cancel := context.WithTimeout(context.Background(), pushBridgeTimeout)
+			b.notify(ctx, m)
+			cancel()
+		}
+	}
+}
+
+// notify pushes m to the offline devices registered for its topic.
+func (b *pushBridge) notify(ctx context.Context, m pubsubhandlers.PublishedMessage) {
+	g := b.gw
+	var rows []struct {
+		Token  string `db:"token"`
+		Wallet string `db:"wallet"`
+	}
+	if err := g.ormClient.Query(ctx, &rows,
+		`SELECT t.token, t.wallet FROM push_subscriptions s
+		 JOIN push_tokens t ON t.namespace = s.namespace AND t.token = s.token
+		 WHERE s.namespace = ? AND s.kind = ? AND s.value = ?
+		 ORDER BY t.token LIMIT ?`,
+		m.Namespace, pushKindTopic, m.Topic, maxPushRecipients); err != nil {
+		g.logger.ComponentWarn(logging.ComponentGeneral, "push bridge failed to load subscriptions",
+			zap.String("topic", m.Topic), zap.Error(err))
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	// Members present in the topic receive the message over their connection
+	skip := map[string]bool{strings.ToLower(m.From): true}
+	if g.pubsubHandlers != nil {
+		for _, id := range g.pubsubHandlers.PresentMembers(ctx, m.Namespace, m.Topic) {
+			skip[strings.ToLower(id)] = true
+		}
+	}
+	// ACLs may have changed since the devices registered
+	allowed := map[string]bool{}
+	var tokens []string
+	for _, row := range rows {
+		wallet := strings.ToLower(row.Wallet)
+		if skip[wallet] {
+			continue
+		}
+		ok, checked := allowed[wallet]
+		if !checked {
+			var err error
+			if ok, err = g.walletMaySubscribe(ctx, m.Namespace, m.Topic, row.Wallet); err != nil {
+				g.logger.ComponentWarn(logging.ComponentGeneral, "push bridge failed to check topic ACL",
+					zap.String("topic", m.Topic), zap.Error(err))
+				return
+			}
+			allowed[wallet] = ok
+		}
+		if ok {
+			tokens = append(tokens, row.Token)
+		}
+	}
+	if len(tokens) == 0 {
+		return
+	}
+
+	tickets := g.push.SendBulkNotifications(ctx, tokens, bridgeMessage(m))
+	resp := g.handlePushTickets(m.Namespace, tokens, tickets)
+	if resp.Failed > 0 {
+		g.logger.ComponentWarn(logging.ComponentGeneral, "push bridge notifications failed",
+			zap.String("topic", m.Topic), zap.Int("failed", resp.Failed), zap.Int("sent", resp.Sent))
+	}
+}
+
+// bridgeMessage builds the notification for a published message. JSON
+// messages may carry their own "title" and "body"; the payload itself is not
+// forwarded.
+func bridgeMessage(m pubsubhandlers.PublishedMessage) ExpoPushMessage {
+	msg := ExpoPushMessage{
+		Title: m.Topic,
+		Body:  "New message",
+		Data: map[string]interface{}{
+			"topic":      m.Topic,
+			"message_id": m.ID,
+		},
+		Sound: "default",
+	}
+	if m.Seq > 0 {
+		msg.Data["seq"] = m.Seq
+	}
+	var fields struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if json.Unmarshal(m.Data, &fields) == nil {
+		if fields.Title != "" {
+			msg.Title = truncateRunes(fields.Title, maxPushTitleLength)
+		}
+		if fields.Body != "" {
+			msg.Body = truncateRunes(fields.Body, maxPushBodyLength)
+		}
+	}
+	return msg
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// Close stops the bridge; queued notifications are dropped.
+func (b *pushBridge) Close() {
+	if b == nil {
+		return
+	}
+	close(b.stop)
+	<-b.done
+}
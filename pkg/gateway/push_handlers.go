@@ -0,0 +1,603 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	maxPushRecipients    = 10000 // devices one send may reach
+	maxPushTargets       = 1000  // wallets, topics or segments named by one send
+	maxPushSubscriptions = 100   // topics and segments per device
+	maxPushTokenLength   = 256
+	maxPushSegmentLength = 64
+
+	pushKindTopic   = "topic"
+	pushKindSegment = "segment"
+
+	defaultPushReceiptDelay = 15 * time.Minute
+	pushReceiptInterval     = time.Minute
+	// Expo keeps receipts for a day; tickets older than that are dropped
+	pushReceiptTTL     = 24 * time.Hour
+	maxPendingReceipts = 100000
+	pushDBTimeout      = 10 * time.Second
+)
+
+// pushDB is the subset of the RQLite ORM client used for push tokens
+type pushDB interface {
+	Query(ctx context.Context, dest any, query string, args ...any) error
+	Exec(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// PushRegisterRequest is the body of POST /v1/push/register
+type PushRegisterRequest struct {
+	Token    string   `json:"token"`              // Expo push token
+	Platform string   `json:"platform,omitempty"` // ios, android or web
+	Topics   []string `json:"topics,omitempty"`   // pubsub topics to be notified about while offline
+	Segments []string `json:"segments,omitempty"` // audiences /v1/push/send may target
+}
+
+// PushDevice is a device token registered by a wallet
+type PushDevice struct {
+	Token     string    `json:"token"`
+	Platform  string    `json:"platform,omitempty"`
+	Topics    []string  `json:"topics"`
+	Segments  []string  `json:"segments"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PushSendRequest is the body of POST /v1/push/send. The notification goes
+// to every device of the wallets, and every device registered for the topics
+// or segments, once.
+type PushSendRequest struct {
+	Wallets   []string       `json:"wallets,omitempty"`
+	Topics    []string       `json:"topics,omitempty"`
+	Segments  []string       `json:"segments,omitempty"`
+	Title     string         `json:"title"`
+	Body      string         `json:"body"`
+	Data      map[string]any `json:"data,omitempty"`
+	Sound     string         `json:"sound,omitempty"`
+	Badge     int            `json:"badge,omitempty"`
+	ChannelID string         `json:"channel_id,omitempty"`
+	Priority  string         `json:"priority,omitempty"` // default, normal or high
+}
+
+// PushSendResponse is the response of POST /v1/push/send
+type PushSendResponse struct {
+	Recipients int            `json:"recipients"`
+	Sent       int            `json:"sent"`
+	Failed     int            `json:"failed"`
+	Errors     map[string]int `json:"errors,omitempty"` // Expo error -> devices
+}
+
+// pushTokenRow is a row of push_tokens
+type pushTokenRow struct {
+	Token     string `db:"token"`
+	Wallet    string `db:"wallet"`
+	Platform  string `db:"platform"`
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// pushSubscriptionRow is a row of push_subscriptions
+type pushSubscriptionRow struct {
+	Token string `db:"token"`
+	Kind  string `db:"kind"`
+	Value string `db:"value"`
+}
+
+// requestWallet returns the wallet of a JWT session; API keys have none.
+func requestWallet(r *http.Request) string {
+	claims, ok := r.Context().Value(ctxKeyJWT).(*auth.JWTClaims)
+	if !ok || claims == nil {
+		return ""
+	}
+	sub := strings.TrimSpace(claims.Sub)
+	// API key subjects (ak_<random>:<namespace>) are not wallets
+	if sub == "" || strings.HasPrefix(strings.ToLower(sub), "ak_") || strings.Contains(sub, ":") {
+		return ""
+	}
+	return normalizeWallet(sub)
+}
+
+// normalizeWallet lowercases EVM addresses, which are case-insensitive.
+// Other wallets (e.g. Solana) are case-sensitive and kept as they are.
+func normalizeWallet(w string) string {
+	w = strings.TrimSpace(w)
+	if strings.HasPrefix(w, "0x") || strings.HasPrefix(w, "0X") {
+		return strings.ToLower(w)
+	}
+	return w
+}
+
+// validExpoToken reports whether token looks like an Expo push token.
+func validExpoToken(token string) bool {
+	if len(token) > maxPushTokenLength || !strings.HasSuffix(token, "]") {
+		return false
+	}
+	return strings.HasPrefix(token, "ExponentPushToken[") || strings.HasPrefix(token, "ExpoPushToken[")
+}
+
+// cleanPushValues trims, deduplicates and validates topics or segments.
+func cleanPushValues(field string, values []string, maxLen int, topic bool) ([]string, error) {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || len(v) > maxLen {
+			return nil, fmt.Errorf("%s: values must be 1 to %d characters", field, maxLen)
+		}
+		if topic && strings.ContainsAny(v, "*> ") {
+			return nil, fmt.Errorf("%s: %q must be a topic, not a pattern", field, v)
+		}
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// placeholders returns n comma-separated SQL placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// pushRegisterHandler handles /v1/push/register for the signed-in wallet:
+//
+//	GET                                     lists the wallet's devices
+//	POST {token,platform,topics,segments}   registers a device, replacing its topics and segments
+//	DELETE ?token=                          unregisters a device
+func (g *Gateway) pushRegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if g.push == nil || g.ormClient == nil {
+		writeError(w, http.StatusServiceUnavailable, "push notifications not available")
+		return
+	}
+	ns := g.requestNamespace(r)
+	wallet := requestWallet(r)
+	if wallet == "" {
+		writeError(w, http.StatusForbidden, "push registration requires a wallet session")
+		return
+	}
+	ctx := r.Context()
+	db := g.ormClient
+
+	switch r.Method {
+	case http.MethodGet:
+		devices, err := g.pushDevices(ctx, ns, wallet)
+		if err != nil {
+			g.logger.ComponentError(logging.ComponentGeneral, "failed to list push devices", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to list devices")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"devices": devices, "count": len(devices)})
+
+	case http.MethodPost, http.MethodPut:
+		var req PushRegisterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid body: expected {token,platform,topics,segments}")
+			return
+		}
+		req.Token = strings.TrimSpace(req.Token)
+		if !validExpoToken(req.Token) {
+			writeError(w, http.StatusBadRequest, "invalid token: expected an Expo push token")
+			return
+		}
+		switch req.Platform {
+		case "", "ios", "android", "web":
+		default:
+			writeError(w, http.StatusBadRequest, "invalid platform: expected ios, android or web")
+			return
+		}
+		topics, err := cleanPushValues("topics", req.Topics, maxPushTokenLength, true)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		segments, err := cleanPushValues("segments", req.Segments, maxPushSegmentLength, false)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(topics)+len(segments) > maxPushSubscriptions {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d topics and segments per device", maxPushSubscriptions))
+			return
+		}
+		for _, topic := range topics {
+			allowed, err := g.walletMaySubscribe(ctx, ns, topic, wallet)
+			if err != nil {
+				g.logger.ComponentError(logging.ComponentGeneral, "failed to check push topic ACL", zap.Error(err))
+				writeError(w, http.StatusServiceUnavailable, "failed to check topic permissions")
+				return
+			}
+			if !allowed {
+				writeError(w, http.StatusForbidden, fmt.Sprintf("forbidden: not allowed to subscribe to topic %q", topic))
+				return
+			}
+		}
+
+		// A token stays with its wallet until that wallet unregisters it
+		now := time.Now().UTC().Format(time.RFC3339)
+		res, err := db.Exec(ctx,
+			`INSERT INTO push_tokens (namespace, token, wallet, platform, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(namespace, token) DO UPDATE SET
+			   platform = excluded.platform,
+			   updated_at = excluded.updated_at
+			 WHERE LOWER(push_tokens.wallet) = LOWER(excluded.wallet)`,
+			ns, req.Token, wallet, req.Platform, now, now)
+		if err != nil {
+			g.logger.ComponentError(logging.ComponentGeneral, "failed to save push token", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to save device")
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeError(w, http.StatusConflict, "token is registered to another wallet; it must be unregistered first")
+			return
+		}
+		if _, err := db.Exec(ctx, "DELETE FROM push_subscriptions WHERE namespace = ? AND token = ?", ns, req.Token); err != nil {
+			g.logger.ComponentError(logging.ComponentGeneral, "failed to replace push subscriptions", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to save device")
+			return
+		}
+		for kind, values := range map[string][]string{pushKindTopic: topics, pushKindSegment: segments} {
+			for _, v := range values {
+				if _, err := db.Exec(ctx,
+					"INSERT OR IGNORE INTO push_subscriptions (namespace, token, kind, value) VALUES (?, ?, ?, ?)",
+					ns, req.Token, kind, v); err != nil {
+					g.logger.ComponentError(logging.ComponentGeneral, "failed to save push subscription", zap.Error(err))
+					writeError(w, http.StatusInternalServerError, "failed to save device")
+					return
+				}
+			}
+		}
+
+		devices, err := g.pushDevices(ctx, ns, wallet)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load device")
+			return
+		}
+		for _, d := range devices {
+			if d.Token == req.Token {
+				writeJSON(w, http.StatusOK, d)
+				return
+			}
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load device")
+
+	case http.MethodDelete:
+		token := strings.TrimSpace(r.URL.Query().Get("token"))
+		if token == "" {
+			writeError(w, http.StatusBadRequest, "missing 'token'")
+			return
+		}
+		res, err := db.Exec(ctx, "DELETE FROM push_tokens WHERE namespace = ? AND token = ? AND wallet = ?", ns, token, wallet)
+		if err != nil {
+			g.logger.ComponentError(logging.ComponentGeneral, "failed to delete push token", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "failed to delete device")
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeError(w, http.StatusNotFound, "device not registered")
+			return
+		}
+		_, _ = db.Exec(ctx, "DELETE FROM push_subscriptions WHERE namespace = ? AND token = ?", ns, token)
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "token": token})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// walletMaySubscribe applies the pubsub topic ACLs of ns to wallet.
+func (g *Gateway) walletMaySubscribe(ctx context.Context, ns, topic, wallet string) (bool, error) {
+	if g.pubsubHandlers == nil {
+		return true, nil
+	}
+	return g.pubsubHandlers.WalletMaySubscribe(ctx, ns, topic, wallet)
+}
+
+// pushDevices returns the devices of wallet with their topics and segments.
+func (g *Gateway) pushDevices(ctx context.Context, ns, wallet string) ([]PushDevice, error) {
+	var tokens []pushTokenRow
+	if err := g.ormClient.Query(ctx, &tokens,
+		"SELECT token, wallet, platform, created_at, updated_at FROM push_tokens WHERE namespace = ? AND wallet = ? ORDER BY created_at, token",
+		ns, wallet); err != nil {
+		return nil, err
+	}
+	var subs []pushSubscriptionRow
+	if err := g.ormClient.Query(ctx, &subs,
+		`SELECT s.token, s.kind, s.value FROM push_subscriptions s
+		 JOIN push_tokens t ON t.namespace = s.namespace AND t.token = s.token
+		 WHERE s.namespace = ? AND t.wallet = ? ORDER BY s.value`,
+		ns, wallet); err != nil {
+		return nil, err
+	}
+	devices := make([]PushDevice, 0, len(tokens))
+	for _, t := range tokens {
+		d := PushDevice{Token: t.Token, Platform: t.Platform, Topics: []string{}, Segments: []string{}}
+		d.CreatedAt, _ = time.Parse(time.RFC3339, t.CreatedAt)
+		d.UpdatedAt, _ = time.Parse(time.RFC3339, t.UpdatedAt)
+		for _, s := range subs {
+			if s.Token != t.Token {
+				continue
+			}
+			if s.Kind == pushKindTopic {
+				d.Topics = append(d.Topics, s.Value)
+			} else {
+				d.Segments = append(d.Segments, s.Value)
+			}
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// pushSendHandler handles POST /v1/push/send
+func (g *Gateway) pushSendHandler(w http.ResponseWriter, r *http.Request) {
+	if g.push == nil || g.ormClient == nil {
+		writeError(w, http.StatusServiceUnavailable, "push notifications not available")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	ns := g.requestNamespace(r)
+	var req PushSendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: expected {wallets|topics|segments,title,body}")
+		return
+	}
+	if req.Title == "" && req.Body == "" {
+		writeError(w, http.StatusBadRequest, "a title or body is required")
+		return
+	}
+	targets := len(req.Wallets) + len(req.Topics) + len(req.Segments)
+	if targets == 0 {
+		writeError(w, http.StatusBadRequest, "no recipients: set wallets, topics or segments")
+		return
+	}
+	if targets > maxPushTargets {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d wallets, topics and segments per send", maxPushTargets))
+		return
+	}
+	switch req.Priority {
+	case "", "default", "normal", "high":
+	default:
+		writeError(w, http.StatusBadRequest, "invalid priority: expected default, normal or high")
+		return
+	}
+
+	tokens, err := g.pushRecipients(r.Context(), ns, req)
+	if err != nil {
+		g.logger.ComponentError(logging.ComponentGeneral, "failed to resolve push recipients", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to resolve recipients")
+		return
+	}
+	if len(tokens) > maxPushRecipients {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%d devices match; at most %d per send", len(tokens), maxPushRecipients))
+		return
+	}
+
+	msg := ExpoPushMessage{
+		Title:     req.Title,
+		Body:      req.Body,
+		Data:      req.Data,
+		Sound:     req.Sound,
+		Badge:     req.Badge,
+		ChannelID: req.ChannelID,
+		Priority:  req.Priority,
+	}
+	tickets := g.push.SendBulkNotifications(r.Context(), tokens, msg)
+	writeJSON(w, http.StatusOK, g.handlePushTickets(ns, tokens, tickets))
+}
+
+// pushRecipients returns the distinct device tokens a send targets.
+func (g *Gateway) pushRecipients(ctx context.Context, ns string, req PushSendRequest) ([]string, error) {
+	var rows []struct {
+		Token string `db:"token"`
+	}
+	var tokens []string
+	seen := make(map[string]bool)
+	collect := func() {
+		for _, row := range rows {
+			if !seen[row.Token] {
+				seen[row.Token] = true
+				tokens = append(tokens, row.Token)
+			}
+		}
+		rows = nil
+	}
+
+	if len(req.Wallets) > 0 {
+		args := []any{ns}
+		for _, w := range req.Wallets {
+			args = append(args, normalizeWallet(w))
+		}
+		if err := g.ormClient.Query(ctx, &rows,
+			"SELECT token FROM push_tokens WHERE namespace = ? AND wallet IN ("+placeholders(len(req.Wallets))+")",
+			args...); err != nil {
+			return nil, err
+		}
+		collect()
+	}
+	for kind, values := range map[string][]string{pushKindTopic: req.Topics, pushKindSegment: req.Segments} {
+		if len(values) == 0 {
+			continue
+		}
+		args := []any{ns, kind}
+		for _, v := range values {
+			args = append(args, v)
+		}
+		if err := g.ormClient.Query(ctx, &rows,
+			"SELECT token FROM push_subscriptions WHERE namespace = ? AND kind = ? AND value IN ("+placeholders(len(values))+")",
+			args...); err != nil {
+			return nil, err
+		}
+		collect()
+	}
+	return tokens, nil
+}
+
+// handlePushTickets tallies the tickets of a send, forgets devices Expo no
+// longer knows and queues the other tickets for receipt checking.
+func (g *Gateway) handlePushTickets(ns string, tokens []string, tickets []ExpoTicket) PushSendResponse {
+	resp := PushSendResponse{Recipients: len(tokens)}
+	for i, t := range tickets {
+		if i >= len(tokens) {
+			break
+		}
+		if t.Status != "error" {
+			resp.Sent++
+			if t.ID != "" && g.pushReceipts != nil {
+				g.pushReceipts.add(t.ID, ns, tokens[i], time.Now())
+			}
+			continue
+		}
+		resp.Failed++
+		code := t.errorCode()
+		if resp.Errors == nil {
+			resp.Errors = make(map[string]int)
+		}
+		resp.Errors[code]++
+		if code == expoDeviceNotRegistered {
+			g.forgetPushToken(ns, tokens[i])
+		}
+	}
+	return resp
+}
+
+// forgetPushToken removes a device Expo reported as no longer registered.
+func (g *Gateway) forgetPushToken(ns, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), pushDBTimeout)
+	defer cancel()
+	if _, err := g.ormClient.Exec(ctx, "DELETE FROM push_tokens WHERE namespace = ? AND token = ?", ns, token); err != nil {
+		g.logger.ComponentWarn(logging.ComponentGeneral, "failed to remove unregistered push token", zap.Error(err))
+		return
+	}
+	_, _ = g.ormClient.Exec(ctx, "DELETE FROM push_subscriptions WHERE namespace = ? AND token = ?", ns, token)
+}
+
+// pushReceiptChecker checks the receipts of sent notifications once Expo has
+// them, and forgets devices that turned out to be unregistered.
+type pushReceiptChecker struct {
+	push   *PushNotificationService
+	forget func(ns, token string)
+	delay  time.Duration
+	logger *logging.ColoredLogger
+
+	mu      sync.Mutex
+	pending map[string]pendingReceipt // ticket ID ->
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type pendingReceipt struct {
+	namespace string
+	token     string
+	sentAt    time.Time
+}
+
+func newPushReceiptChecker(push *PushNotificationService, delay time.Duration, forget func(ns, token string), logger *logging.ColoredLogger) *pushReceiptChecker {
+	if delay <= 0 {
+		delay = defaultPushReceiptDelay
+	}
+	c := &pushReceiptChecker{
+		push:    push,
+		forget:  forget,
+		delay:   delay,
+		logger:  logger,
+		pending: make(map[string]pendingReceipt),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// add queues a ticket; tickets beyond maxPendingReceipts are not checked.
+func (c *pushReceiptChecker) add(id, ns, token string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) < maxPendingReceipts {
+		c.pending[id] = pendingReceipt{namespace: ns, token: token, sentAt: now}
+	}
+}
+
+func (c *pushReceiptChecker) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(pushReceiptInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.check(now)
+		}
+	}
+}
+
+// check fetches the receipts of the tickets sent at least delay ago.
+func (c *pushReceiptChecker) check(now time.Time) {
+	c.mu.Lock()
+	var ids []string
+	for id, p := range c.pending {
+		switch {
+		case now.Sub(p.sentAt) > pushReceiptTTL:
+			delete(c.pending, id)
+		case now.Sub(p.sentAt) >= c.delay:
+			ids = append(ids, id)
+		}
+	}
+	c.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	receipts, err := c.push.CheckReceipts(ctx, ids)
+	if err != nil {
+		c.logger.ComponentWarn(logging.ComponentGeneral, "failed to check push receipts", zap.Error(err))
+	}
+
+	c.mu.Lock()
+	var unregistered []pendingReceipt
+	for id, receipt := range receipts {
+		p, ok := c.pending[id]
+		if !ok {
+			continue
+		}
+		delete(c.pending, id)
+		if receipt.Status == "error" && receipt.Details != nil && receipt.Details.Error == expoDeviceNotRegistered {
+			unregistered = append(unregistered, p)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, p := range unregistered {
+		c.forget(p.namespace, p.token)
+	}
+}
+
+// Close stops checking receipts.
+func (c *pushReceiptChecker) Close() {
+	if c == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+}
@@ -7,21 +7,68 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+const (
+	// defaultExpoURL is the base of Expo's push API
+	defaultExpoURL = "https://exp.host/--/api/v2/push"
+
+	// Expo accepts at most 100 messages per send request and 1000 receipt
+	// IDs per receipt request
+	expoSendBatchSize    = 100
+	expoReceiptBatchSize = 1000
+
+	// expoDeviceNotRegistered is the error of a ticket or receipt whose device
+	// token is no longer valid; the token should not be used again
+	expoDeviceNotRegistered = "DeviceNotRegistered"
+)
+
+// PushConfig configures push notifications.
+type PushConfig struct {
+	ExpoURL      string        // Base URL of the Expo push API (default: https://exp.host/--/api/v2/push)
+	AccessToken  string        // Expo access token, if the project requires one
+	ReceiptDelay time.Duration // How long after sending receipts are checked (default: 15m)
+}
+
 // PushNotificationService handles sending push notifications via Expo
 type PushNotificationService struct {
-	logger *zap.Logger
-	client *http.Client
+	logger      *zap.Logger
+	client      *http.Client
+	baseURL     string
+	accessToken string
 }
 
-// ExpoTicket represents the response from Expo API
+// ExpoTicket is Expo's answer to one message: an ID to check the receipt with,
+// or an error
 type ExpoTicket struct {
-	ID    string `json:"id"`
-	Error string `json:"error,omitempty"`
+	Status  string            `json:"status"` // "ok" or "error"
+	ID      string            `json:"id,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Details *ExpoErrorDetails `json:"details,omitempty"`
+}
+
+// ExpoReceipt reports whether a message was delivered to Apple or Google
+type ExpoReceipt struct {
+	Status  string            `json:"status"` // "ok" or "error"
+	Message string            `json:"message,omitempty"`
+	Details *ExpoErrorDetails `json:"details,omitempty"`
+}
+
+// ExpoErrorDetails carries the error code of a failed ticket or receipt
+type ExpoErrorDetails struct {
+	Error string `json:"error,omitempty"` // e.g. DeviceNotRegistered, MessageTooBig
+}
+
+// errorCode returns the Expo error code of a failed ticket, or its message.
+func (t ExpoTicket) errorCode() string {
+	if t.Details != nil && t.Details.Error != "" {
+		return t.Details.Error
+	}
+	return t.Message
 }
 
 // ExpoPushMessage represents a message to send via Expo
@@ -41,13 +88,28 @@ type ExpoPushMessage struct {
 	ChannelID           string `json:"channelId,omitempty"`
 }
 
+// expoResponse is the body of Expo API responses
+type expoResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors,omitempty"`
+}
+
 // NewPushNotificationService creates a new push notification service
-func NewPushNotificationService(logger *zap.Logger) *PushNotificationService {
+func NewPushNotificationService(logger *zap.Logger, cfg PushConfig) *PushNotificationService {
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.ExpoURL), "/")
+	if baseURL == "" {
+		baseURL = defaultExpoURL
+	}
 	return &PushNotificationService{
 		logger: logger,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		baseURL:     baseURL,
+		accessToken: cfg.AccessToken,
 	}
 }
 
@@ -85,99 +147,130 @@ func (pns *PushNotificationService) SendNotification(
 		message.Data["avatar_url"] = avatarURL
 	}
 
-	return pns.sendExpoRequest(ctx, message)
+	tickets, err := pns.sendExpoRequest(ctx, []ExpoPushMessage{message})
+	if err != nil {
+		return err
+	}
+	if len(tickets) > 0 && tickets[0].Status == "error" {
+		return fmt.Errorf("ticket error: %s", tickets[0].errorCode())
+	}
+	return nil
 }
 
-// SendBulkNotifications sends notifications to multiple users
+// SendBulkNotifications sends msg to every token, in requests of up to 100
+// messages. It returns one ticket per token, in order; the messages of a
+// request that failed get error tickets.
 func (pns *PushNotificationService) SendBulkNotifications(
 	ctx context.Context,
 	expoPushTokens []string,
-	title string,
-	body string,
-	data map[string]interface{},
-	avatarURL string,
-) []error {
-	errors := make([]error, 0)
+	msg ExpoPushMessage,
+) []ExpoTicket {
+	tickets := make([]ExpoTicket, 0, len(expoPushTokens))
+	for start := 0; start < len(expoPushTokens); start += expoSendBatchSize {
+		batch := expoPushTokens[start:min(start+expoSendBatchSize, len(expoPushTokens))]
+		messages := make([]ExpoPushMessage, len(batch))
+		for i, token := range batch {
+			messages[i] = msg
+			messages[i].To = token
+		}
 
-	for _, token := range expoPushTokens {
-		if err := pns.SendNotification(ctx, token, title, body, data, avatarURL); err != nil {
-			errors = append(errors, fmt.Errorf("failed to send to token %s: %w", token, err))
+		got, err := pns.sendExpoRequest(ctx, messages)
+		if err == nil && len(got) != len(batch) {
+			err = fmt.Errorf("expected %d tickets, got %d", len(batch), len(got))
+		}
+		if err != nil {
+			for range batch {
+				tickets = append(tickets, ExpoTicket{Status: "error", Message: err.Error()})
+			}
+			continue
 		}
+		tickets = append(tickets, got...)
 	}
+	return tickets
+}
 
-	return errors
+// CheckReceipts fetches the receipts of tickets, in requests of up to 1000
+// IDs. Receipts that are not ready yet are missing from the result.
+func (pns *PushNotificationService) CheckReceipts(ctx context.Context, ids []string) (map[string]ExpoReceipt, error) {
+	receipts := make(map[string]ExpoReceipt, len(ids))
+	for start := 0; start < len(ids); start += expoReceiptBatchSize {
+		batch := ids[start:min(start+expoReceiptBatchSize, len(ids))]
+		var got map[string]ExpoReceipt
+		if err := pns.post(ctx, "/getReceipts", map[string][]string{"ids": batch}, &got); err != nil {
+			return receipts, err
+		}
+		for id, r := range got {
+			receipts[id] = r
+		}
+	}
+	return receipts, nil
 }
 
-// sendExpoRequest sends a request to the Expo push notification API
-func (pns *PushNotificationService) sendExpoRequest(ctx context.Context, message ExpoPushMessage) error {
-	const expoAPIURL = "https://exp.host/--/api/v2/push/send"
+// sendExpoRequest sends messages to the Expo push notification API and
+// returns their tickets
+func (pns *PushNotificationService) sendExpoRequest(ctx context.Context, messages []ExpoPushMessage) ([]ExpoTicket, error) {
+	var tickets []ExpoTicket
+	if err := pns.post(ctx, "/send", messages, &tickets); err != nil {
+		pns.logger.Warn("failed to send push notifications",
+			zap.Error(err),
+			zap.Int("messages", len(messages)))
+		return nil, err
+	}
 
-	body, err := json.Marshal(message)
+	for i, ticket := range tickets {
+		if ticket.Status == "error" && i < len(messages) {
+			pns.logger.Warn("push notification error in ticket",
+				zap.String("error", ticket.errorCode()),
+				zap.String("to", messages[i].To))
+		}
+	}
+	pns.logger.Info("push notifications sent",
+		zap.Int("messages", len(messages)))
+	return tickets, nil
+}
+
+// post sends a request to the Expo API and decodes the data of its response
+func (pns *PushNotificationService) post(ctx context.Context, path string, payload any, out any) error {
+	body, err := json.Marshal(payload)
 	if err != nil {
-		pns.logger.Error("failed to marshal push notification",
-			zap.Error(err),
-			zap.String("to", message.To))
 		return fmt.Errorf("marshal error: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, expoAPIURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pns.baseURL+path, bytes.NewBuffer(body))
 	if err != nil {
-		pns.logger.Error("failed to create push notification request",
-			zap.Error(err),
-			zap.String("to", message.To))
 		return fmt.Errorf("request creation error: %w", err)
 	}
-
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if pns.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+pns.accessToken)
+	}
 
 	resp, err := pns.client.Do(req)
 	if err != nil {
-		pns.logger.Error("failed to send push notification",
-			zap.Error(err),
-			zap.String("to", message.To))
 		return fmt.Errorf("send error: %w", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
 	if err != nil {
-		pns.logger.Error("failed to read push notification response",
-			zap.Error(err),
-			zap.String("to", message.To))
 		return fmt.Errorf("response read error: %w", err)
 	}
 
 	// Check for API errors
 	if resp.StatusCode != http.StatusOK {
-		pns.logger.Warn("push notification API error",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("response", string(respBody)),
-			zap.String("to", message.To))
 		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
 	}
 
-	// Parse response
-	var tickets []ExpoTicket
-	if err := json.Unmarshal(respBody, &tickets); err != nil {
-		pns.logger.Error("failed to parse push notification response",
-			zap.Error(err),
-			zap.String("response", string(respBody)))
+	var parsed expoResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
 		return fmt.Errorf("parse error: %w", err)
 	}
-
-	// Check for errors in tickets
-	for _, ticket := range tickets {
-		if ticket.Error != "" {
-			pns.logger.Warn("push notification error in ticket",
-				zap.String("error", ticket.Error),
-				zap.String("to", message.To))
-			return fmt.Errorf("ticket error: %s", ticket.Error)
-		}
+	if len(parsed.Errors) > 0 {
+		return fmt.Errorf("API error %s: %s", parsed.Errors[0].Code, parsed.Errors[0].Message)
+	}
+	if err := json.Unmarshal(parsed.Data, out); err != nil {
+		return fmt.Errorf("parse error: %w", err)
 	}
-
-	pns.logger.Info("push notification sent successfully",
-		zap.String("to", message.To),
-		zap.String("title", message.Title))
-
 	return nil
 }
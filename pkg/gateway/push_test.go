@@ -0,0 +1,303 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	pubsubhandlers "github.com/DeBrosOfficial/network/pkg/gateway/handlers/pubsub"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/rqlite"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// expoStandIn is a local stand-in for the Expo push API. Tokens in
+// unregistered get DeviceNotRegistered tickets.
+type expoStandIn struct {
+	mu           sync.Mutex
+	batches      [][]ExpoPushMessage
+	unregistered map[string]bool
+	receipts     map[string]ExpoReceipt
+}
+
+func (e *expoStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch r.URL.Path {
+	case "/send":
+		var messages []ExpoPushMessage
+		if err := json.NewDecoder(r.Body).Decode(&messages); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		e.batches = append(e.batches, messages)
+		tickets := make([]ExpoTicket, len(messages))
+		for i, m := range messages {
+			if e.unregistered[m.To] {
+				tickets[i] = ExpoTicket{Status: "error", Message: "not registered", Details: &ExpoErrorDetails{Error: expoDeviceNotRegistered}}
+			} else {
+				tickets[i] = ExpoTicket{Status: "ok", ID: "ticket-" + m.To}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": tickets})
+	case "/getReceipts":
+		var req struct {
+			IDs []string `json:"ids"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := make(map[string]ExpoReceipt)
+		for _, id := range req.IDs {
+			if rc, ok := e.receipts[id]; ok {
+				out[id] = rc
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": out})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (e *expoStandIn) sent() []ExpoPushMessage {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var all []ExpoPushMessage
+	for _, b := range e.batches {
+		all = append(all, b...)
+	}
+	return all
+}
+
+func expoToken(i int) string {
+	return fmt.Sprintf("ExponentPushToken[%04d]", i)
+}
+
+func newPushTestGateway(t *testing.T, expo *expoStandIn) *Gateway {
+	t.Helper()
+	logger, err := logging.NewColoredLogger(logging.ComponentGeneral, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec("CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("Failed to create schema_migrations: %v", err)
+	}
+	for _, name := range []string{"017_pubsub_acl.sql", "019_push_tokens.sql"} {
+		migration, err := os.ReadFile(filepath.Join("..", "..", "migrations", name))
+		if err != nil {
+			t.Fatalf("Failed to read migration: %v", err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("Failed to apply migration %s: %v", name, err)
+		}
+	}
+
+	srv := httptest.NewServer(expo)
+	t.Cleanup(srv.Close)
+
+	gw := &Gateway{logger: logger, cfg: &Config{}, ormClient: rqlite.NewClient(db)}
+	gw.push = NewPushNotificationService(logger.Logger, PushConfig{ExpoURL: srv.URL + "/"})
+	return gw
+}
+
+func pushRequest(t *testing.T, handler http.HandlerFunc, method, target, wallet string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	ctx := context.WithValue(req.Context(), CtxKeyNamespaceOverride, "ns")
+	if wallet != "" {
+		ctx = context.WithValue(ctx, ctxKeyJWT, &auth.JWTClaims{Sub: wallet})
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req.WithContext(ctx))
+	return rec
+}
+
+func TestPushNotificationService_BatchesAndChecksReceipts(t *testing.T) {
+	expo := &expoStandIn{
+		unregistered: map[string]bool{expoToken(150): true},
+		receipts:     map[string]ExpoReceipt{"ticket-" + expoToken(3): {Status: "error", Details: &ExpoErrorDetails{Error: expoDeviceNotRegistered}}},
+	}
+	gw := newPushTestGateway(t, expo)
+
+	tokens := make([]string, 250)
+	for i := range tokens {
+		tokens[i] = expoToken(i)
+	}
+	tickets := gw.push.SendBulkNotifications(context.Background(), tokens, ExpoPushMessage{Title: "hi"})
+	if len(expo.batches) != 3 || len(expo.batches[0]) != 100 || len(expo.batches[2]) != 50 {
+		t.Fatalf("Expected batches of 100, 100 and 50 messages, got %d batches", len(expo.batches))
+	}
+	if len(tickets) != len(tokens) {
+		t.Fatalf("Expected one ticket per token, got %d", len(tickets))
+	}
+	if tickets[150].errorCode() != expoDeviceNotRegistered || tickets[149].ID != "ticket-"+expoToken(149) {
+		t.Fatalf("Expected tickets aligned with tokens, got %+v and %+v", tickets[149], tickets[150])
+	}
+
+	receipts, err := gw.push.CheckReceipts(context.Background(), []string{"ticket-" + expoToken(3), "ticket-" + expoToken(4)})
+	if err != nil {
+		t.Fatalf("CheckReceipts failed: %v", err)
+	}
+	if len(receipts) != 1 || receipts["ticket-"+expoToken(3)].Details.Error != expoDeviceNotRegistered {
+		t.Fatalf("Expected one DeviceNotRegistered receipt, got %+v", receipts)
+	}
+
+	// The checker waits for the receipt delay, then forgets unregistered devices
+	var forgotten []string
+	checker := newPushReceiptChecker(gw.push, time.Minute, func(ns, token string) { forgotten = append(forgotten, token) }, gw.logger)
+	defer checker.Close()
+	sentAt := time.Now()
+	checker.add("ticket-"+expoToken(3), "ns", expoToken(3), sentAt)
+	checker.add("ticket-"+expoToken(4), "ns", expoToken(4), sentAt)
+	checker.check(sentAt.Add(30 * time.Second))
+	if len(forgotten) != 0 {
+		t.Fatalf("Expected receipts not to be checked before the delay, got %v", forgotten)
+	}
+	checker.check(sentAt.Add(2 * time.Minute))
+	if len(forgotten) != 1 || forgotten[0] != expoToken(3) || len(checker.pending) != 1 {
+		t.Fatalf("Expected the unregistered device forgotten and the pending receipt kept, got %v", forgotten)
+	}
+}
+
+func TestPushHandlers_RegisterSendAndBridge(t *testing.T) {
+	expo := &expoStandIn{unregistered: map[string]bool{expoToken(2): true}}
+	gw := newPushTestGateway(t, expo)
+
+	// API keys have no wallet to register devices for
+	if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodPost, "/v1/push/register", "ak_key:ns",
+		PushRegisterRequest{Token: expoToken(1)}); rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected API keys to be refused, got %d", rec.Code)
+	}
+	if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodPost, "/v1/push/register", "0xAlice",
+		PushRegisterRequest{Token: "not-a-token"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected an invalid token to be rejected, got %d", rec.Code)
+	}
+
+	for i, reg := range []struct {
+		wallet string
+		req    PushRegisterRequest
+	}{
+		{"0xAlice", PushRegisterRequest{Token: expoToken(1), Platform: "ios", Topics: []string{"chat"}, Segments: []string{"beta"}}},
+		{"0xBob", PushRegisterRequest{Token: expoToken(2), Topics: []string{"chat"}}},
+		{"0xCarol", PushRegisterRequest{Token: expoToken(3), Platform: "android", Topics: []string{"chat"}, Segments: []string{"beta"}}},
+	} {
+		if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodPost, "/v1/push/register", reg.wallet, reg.req); rec.Code != http.StatusOK {
+			t.Fatalf("Register %d failed: %d %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := pushRequest(t, gw.pushRegisterHandler, http.MethodGet, "/v1/push/register", "0xalice", nil)
+	var listed struct {
+		Devices []PushDevice `json:"devices"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Devices) != 1 || listed.Devices[0].Platform != "ios" || len(listed.Devices[0].Topics) != 1 || listed.Devices[0].Segments[0] != "beta" {
+		t.Fatalf("Expected Alice's device with its topic and segment, got %s", rec.Body.String())
+	}
+
+	// Wallets and segments overlap on Alice's device, which is sent to once
+	rec = pushRequest(t, gw.pushSendHandler, http.MethodPost, "/v1/push/send", "",
+		PushSendRequest{Wallets: []string{"0xALICE", "0xbob"}, Segments: []string{"beta"}, Title: "Hello"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Send failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var sent PushSendResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &sent)
+	if sent.Recipients != 3 || sent.Sent != 2 || sent.Failed != 1 || sent.Errors[expoDeviceNotRegistered] != 1 {
+		t.Fatalf("Expected 3 recipients with Bob's device unregistered, got %+v", sent)
+	}
+
+	// Bob's unregistered device was forgotten
+	rec = pushRequest(t, gw.pushRegisterHandler, http.MethodGet, "/v1/push/register", "0xbob", nil)
+	_ = json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Devices) != 0 {
+		t.Fatalf("Expected Bob's device to be removed, got %s", rec.Body.String())
+	}
+
+	// The bridge skips the publisher; Alice's JSON title is used
+	before := len(expo.sent())
+	b := &pushBridge{gw: gw}
+	b.notify(context.Background(), pubsubhandlers.PublishedMessage{
+		Namespace: "ns", Topic: "chat", ID: "m1", Seq: 7, From: "0xalice",
+		Data: []byte(`{"title":"Alice","body":"lunch?"}`), Time: time.Now(),
+	})
+	bridged := expo.sent()[before:]
+	if len(bridged) != 1 || bridged[0].To != expoToken(3) || bridged[0].Title != "Alice" || bridged[0].Body != "lunch?" || bridged[0].Data["message_id"] != "m1" {
+		t.Fatalf("Expected one notification to Carol, got %+v", bridged)
+	}
+
+	if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodDelete, "/v1/push/register?token="+expoToken(3), "0xalice", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected another wallet's device not to be deleted, got %d", rec.Code)
+	}
+	if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodDelete, "/v1/push/register?token="+expoToken(3), "0xCarol", nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected Carol to delete the device, got %d", rec.Code)
+	}
+}
+
+func TestPushHandlers_TopicACLsAndTokenOwnership(t *testing.T) {
+	expo := &expoStandIn{}
+	gw := newPushTestGateway(t, expo)
+	newHandlers := func() *pubsubhandlers.PubSubHandlers {
+		h := pubsubhandlers.NewPubSubHandlers(nil, gw.logger, pubsubhandlers.Config{DB: gw.ormClient})
+		t.Cleanup(h.Close)
+		return h
+	}
+	gw.pubsubHandlers = newHandlers()
+	ctx := context.Background()
+	if _, err := gw.ormClient.Exec(ctx,
+		"INSERT INTO pubsub_topic_acls (namespace, topic, publish, subscribe, updated_at, updated_by) VALUES ('ns', 'team.>', '', ?, '', '')",
+		`["wallet:0xalice","wallet:0xbob"]`); err != nil {
+		t.Fatalf("Failed to insert ACL: %v", err)
+	}
+
+	if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodPost, "/v1/push/register", "0xMallory",
+		PushRegisterRequest{Token: expoToken(9), Topics: []string{"team.news"}}); rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected a wallet without subscribe access to be refused, got %d", rec.Code)
+	}
+	for i, wallet := range []string{"0xAlice", "0xBob"} {
+		if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodPost, "/v1/push/register", wallet,
+			PushRegisterRequest{Token: expoToken(i + 1), Topics: []string{"team.news"}}); rec.Code != http.StatusOK {
+			t.Fatalf("Register %s failed: %d %s", wallet, rec.Code, rec.Body.String())
+		}
+	}
+
+	// A token registered by one wallet cannot be taken over by another
+	if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodPost, "/v1/push/register", "0xBob",
+		PushRegisterRequest{Token: expoToken(1), Topics: []string{"team.news"}}); rec.Code != http.StatusConflict {
+		t.Fatalf("Expected re-registering another wallet's token to conflict, got %d", rec.Code)
+	}
+	if rec := pushRequest(t, gw.pushRegisterHandler, http.MethodPost, "/v1/push/register", "0xalice",
+		PushRegisterRequest{Token: expoToken(1), Platform: "ios", Topics: []string{"team.news"}}); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the owner to re-register its token, got %d", rec.Code)
+	}
+
+	// Access revoked after registration is enforced when sending, here by a
+	// gateway that has not cached the previous rules
+	if _, err := gw.ormClient.Exec(ctx, "UPDATE pubsub_topic_acls SET subscribe = ? WHERE namespace = 'ns'", `["wallet:0xalice"]`); err != nil {
+		t.Fatalf("Failed to update ACL: %v", err)
+	}
+	gw.pubsubHandlers = newHandlers()
+	b := &pushBridge{gw: gw}
+	b.notify(ctx, pubsubhandlers.PublishedMessage{Namespace: "ns", Topic: "team.news", ID: "m1", From: "0xcarol", Data: []byte("hi"), Time: time.Now()})
+	if sent := expo.sent(); len(sent) != 1 || sent[0].To != expoToken(1) {
+		t.Fatalf("Expected only Alice to be notified, got %+v", sent)
+	}
+}
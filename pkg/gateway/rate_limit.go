@@ -0,0 +1,307 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "github.com/DeBrosOfficial/network/pkg/errors"
+	"github.com/DeBrosOfficial/network/pkg/gateway/auth"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/olric"
+	"go.uber.org/zap"
+)
+
+// Route classes used to select a rate limit policy
+const (
+	RateLimitClassAuth    = "auth"    // Login, challenge/verify and key issuance endpoints
+	RateLimitClassInvoke  = "invoke"  // Public serverless invocation endpoints
+	RateLimitClassDefault = "default" // Everything else
+)
+
+// defaultRateLimitDMap is the Olric DMap holding shared rate limit counters
+const defaultRateLimitDMap = "gateway_rate_limits"
+
+// RateLimitPolicy bounds how many requests a single client may make per window.
+type RateLimitPolicy struct {
+	Requests int           // Maximum requests per window; <= 0 disables the policy
+	Window   time.Duration // Length of the fixed counting window
+}
+
+// RateLimitConfig configures gateway-wide rate limiting.
+// Each policy is applied independently per client IP, API key and wallet.
+type RateLimitConfig struct {
+	Enabled  bool
+	Policies map[string]RateLimitPolicy // Route class -> policy; missing classes use DefaultRateLimitPolicies
+	DMap     string                     // Olric DMap for shared counters (default: "gateway_rate_limits")
+}
+
+// DefaultRateLimitPolicies returns the built-in policy for each route class.
+func DefaultRateLimitPolicies() map[string]RateLimitPolicy {
+	return map[string]RateLimitPolicy{
+		RateLimitClassAuth:    {Requests: 20, Window: time.Minute},
+		RateLimitClassInvoke:  {Requests: 300, Window: time.Minute},
+		RateLimitClassDefault: {Requests: 600, Window: time.Minute},
+	}
+}
+
+// rateLimitStore counts hits for a key within fixed windows.
+type rateLimitStore interface {
+	// Hit records one request for key and returns the count in the current window.
+	Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, error)
+}
+
+// rateLimiter evaluates route-class policies against a shared counter store,
+// falling back to process-local counters when Olric is unavailable.
+type rateLimiter struct {
+	policies map[string]RateLimitPolicy
+	dmap     string
+	olric    func() *olric.Client
+	local    *memoryRateLimitStore
+	logger   *logging.ColoredLogger
+	now      func() time.Time
+}
+
+// newRateLimiter builds a limiter from config. getOlric is consulted on every
+// request so that a late Olric connection is picked up without a restart.
+func newRateLimiter(cfg RateLimitConfig, getOlric func() *olric.Client, logger *logging.ColoredLogger) *rateLimiter {
+	policies := DefaultRateLimitPolicies()
+	for class, p := range cfg.Policies {
+		policies[class] = p
+	}
+	dmap := strings.TrimSpace(cfg.DMap)
+	if dmap == "" {
+		dmap = defaultRateLimitDMap
+	}
+	return &rateLimiter{
+		policies: policies,
+		dmap:     dmap,
+		olric:    getOlric,
+		local:    newMemoryRateLimitStore(),
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// allow records a hit for (dimension, id) under the route class policy and
+// reports whether the request may proceed and, if not, when to retry.
+func (rl *rateLimiter) allow(ctx context.Context, class, dimension, id string) (bool, time.Duration, RateLimitPolicy) {
+	policy, ok := rl.policies[class]
+	if !ok || policy.Requests <= 0 || policy.Window <= 0 || id == "" {
+		return true, 0, policy
+	}
+
+	now := rl.now()
+	key := rateLimitKey(class, dimension, id)
+
+	count, err := rl.sharedHit(ctx, key, policy.Window, now)
+	if err != nil {
+		if rl.logger != nil {
+			rl.logger.ComponentDebug(logging.ComponentGeneral, "shared rate limit counter unavailable, using local counter",
+				zap.Error(err))
+		}
+		count, _ = rl.local.Hit(ctx, key, policy.Window, now)
+	}
+
+	if count <= policy.Requests {
+		return true, 0, policy
+	}
+	return false, windowRemaining(policy.Window, now), policy
+}
+
+// sharedHit increments the cluster-wide counter in Olric.
+func (rl *rateLimiter) sharedHit(ctx context.Context, key string, window time.Duration, now time.Time) (int, error) {
+	if rl.olric == nil {
+		return 0, fmt.Errorf("olric not configured")
+	}
+	client := rl.olric()
+	if client == nil {
+		return 0, fmt.Errorf("olric not connected")
+	}
+	return (&olricRateLimitStore{client: client, dmap: rl.dmap}).Hit(ctx, key, window, now)
+}
+
+// olricRateLimitStore keeps counters in an Olric DMap so every gateway sees the same totals.
+type olricRateLimitStore struct {
+	client *olric.Client
+	dmap   string
+}
+
+func (s *olricRateLimitStore) Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	dm, err := s.client.GetClient().NewDMap(s.dmap)
+	if err != nil {
+		return 0, err
+	}
+	windowKey := key + ":" + strconv.FormatInt(windowIndex(window, now), 10)
+	n, err := dm.Incr(ctx, windowKey, 1)
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		// First hit in this window: let the counter expire shortly after the window closes
+		_ = dm.Expire(ctx, windowKey, window+time.Second)
+	}
+	return n, nil
+}
+
+// memoryRateLimitStore is a process-local fixed window counter.
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]memoryRateWindow
+	lastSweep time.Time
+}
+
+type memoryRateWindow struct {
+	index   int64
+	count   int
+	expires time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{windows: make(map[string]memoryRateWindow)}
+}
+
+func (s *memoryRateLimitStore) Hit(_ context.Context, key string, window time.Duration, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired windows at most once a minute to bound memory
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, w := range s.windows {
+			if now.After(w.expires) {
+				delete(s.windows, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	idx := windowIndex(window, now)
+	w := s.windows[key]
+	if w.index != idx {
+		w = memoryRateWindow{index: idx, expires: now.Add(windowRemaining(window, now))}
+	}
+	w.count++
+	s.windows[key] = w
+	return w.count, nil
+}
+
+// windowIndex returns the fixed window number containing now.
+func windowIndex(window time.Duration, now time.Time) int64 {
+	return now.UnixNano() / int64(window)
+}
+
+// windowRemaining returns the time until the current fixed window ends.
+func windowRemaining(window time.Duration, now time.Time) time.Duration {
+	end := time.Unix(0, (windowIndex(window, now)+1)*int64(window))
+	return end.Sub(now)
+}
+
+// rateLimitKey builds a counter key. Identifiers are hashed so raw API keys
+// are never written into the shared cache.
+func rateLimitKey(class, dimension, id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return "rl:" + class + ":" + dimension + ":" + hex.EncodeToString(sum[:12])
+}
+
+// rateLimitClass maps a request path to its policy class. An empty class
+// means the path is never throttled (health probes and ACME challenges).
+func rateLimitClass(p string) string {
+	if strings.HasPrefix(p, "/.well-known/acme-challenge/") {
+		return ""
+	}
+	switch p {
+	case "/health", "/v1/health", "/status", "/v1/status", "/v1/version":
+		return ""
+	case "/v1/auth/login", "/v1/auth/challenge", "/v1/auth/verify", "/v1/auth/register",
+		"/v1/auth/refresh", "/v1/auth/token", "/v1/auth/api-key", "/v1/auth/simple-key":
+		return RateLimitClassAuth
+	}
+	if strings.HasPrefix(p, "/v1/invoke/") || (strings.HasPrefix(p, "/v1/functions/") && strings.HasSuffix(p, "/invoke")) {
+		return RateLimitClassInvoke
+	}
+	return RateLimitClassDefault
+}
+
+// ipRateLimitMiddleware throttles by client IP. It runs before authentication
+// so unauthenticated floods never reach the API key lookup.
+func (g *Gateway) ipRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.rateLimiter == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		class := rateLimitClass(r.URL.Path)
+		if class == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, retry, policy := g.rateLimiter.allow(r.Context(), class, "ip", getClientIP(r, g.trustedProxies)); !ok {
+			g.writeRateLimited(w, r, "ip", policy, retry)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// identityRateLimitMiddleware throttles by API key or wallet once the auth
+// middleware has attached them to the request context.
+func (g *Gateway) identityRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.rateLimiter == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		class := rateLimitClass(r.URL.Path)
+		if class == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		if v, ok := ctx.Value(ctxKeyAPIKey).(string); ok && strings.TrimSpace(v) != "" {
+			if ok, retry, policy := g.rateLimiter.allow(ctx, class, "api_key", strings.TrimSpace(v)); !ok {
+				g.writeRateLimited(w, r, "api_key", policy, retry)
+				return
+			}
+		}
+		if claims, ok := ctx.Value(ctxKeyJWT).(*auth.JWTClaims); ok && claims != nil {
+			if sub := strings.TrimSpace(claims.Sub); sub != "" {
+				if ok, retry, policy := g.rateLimiter.allow(ctx, class, "wallet", strings.ToLower(sub)); !ok {
+					g.writeRateLimited(w, r, "wallet", policy, retry)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeRateLimited responds with a 429 and Retry-After via the shared error mapping.
+func (g *Gateway) writeRateLimited(w http.ResponseWriter, r *http.Request, dimension string, policy RateLimitPolicy, retry time.Duration) {
+	retryAfter := int(math.Ceil(retry.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	if g.logger != nil {
+		g.logger.ComponentWarn(logging.ComponentGeneral, "rate limit exceeded",
+			zap.String("path", r.URL.Path),
+			zap.String("dimension", dimension),
+			zap.String("ip", getClientIP(r, g.trustedProxies)),
+			zap.Int("limit", policy.Requests),
+			zap.Duration("window", policy.Window),
+		)
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(policy.Requests))
+	w.Header().Set("X-RateLimit-Remaining", "0")
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(retry).Unix(), 10))
+	apierrors.WriteHTTPError(w, apierrors.NewRateLimitError(policy.Requests, retryAfter), r.Header.Get("X-Request-ID"))
+}
@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/httputil"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+)
+
+func TestMemoryRateLimitStore_FixedWindow(t *testing.T) {
+	store := newMemoryRateLimitStore()
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 1; i <= 3; i++ {
+		n, err := store.Hit(context.Background(), "k", time.Minute, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != i {
+			t.Fatalf("hit %d: expected count %d, got %d", i, i, n)
+		}
+	}
+
+	// Next window resets the counter
+	n, _ := store.Hit(context.Background(), "k", time.Minute, now.Add(time.Minute))
+	if n != 1 {
+		t.Fatalf("expected counter reset in new window, got %d", n)
+	}
+}
+
+func TestRateLimitClass(t *testing.T) {
+	cases := map[string]string{
+		"/v1/health":                    "",
+		"/.well-known/acme-challenge/x": "",
+		"/v1/auth/challenge":            RateLimitClassAuth,
+		"/v1/auth/simple-key":           RateLimitClassAuth,
+		"/v1/invoke/ns/fn":              RateLimitClassInvoke,
+		"/v1/functions/fn/invoke":       RateLimitClassInvoke,
+		"/v1/storage/upload":            RateLimitClassDefault,
+	}
+	for path, want := range cases {
+		if got := rateLimitClass(path); got != want {
+			t.Errorf("rateLimitClass(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestIPRateLimitMiddleware(t *testing.T) {
+	logger, _ := logging.NewDefaultLogger(logging.ComponentGeneral)
+	trusted, err := httputil.ParseTrustedProxies([]string{"192.0.2.1", "10.9.0.0/16"})
+	if err != nil {
+		t.Fatalf("parse trusted proxies: %v", err)
+	}
+	gw := &Gateway{logger: logger, trustedProxies: trusted}
+	gw.rateLimiter = newRateLimiter(RateLimitConfig{
+		Enabled: true,
+		Policies: map[string]RateLimitPolicy{
+			RateLimitClassAuth: {Requests: 2, Window: time.Minute},
+		},
+	}, nil, logger)
+
+	h := gw.ipRateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// httptest requests come from 192.0.2.1, a trusted proxy
+	do := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/challenge", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do("10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+
+	w := do("10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	// A different client is unaffected
+	if w := do("10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for other IP, got %d", w.Code)
+	}
+
+	// Entries prepended by the client are skipped; trusted hops are walked past
+	if w := do("203.0.113.9, 10.0.0.1, 10.9.1.1"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the spoofed entry to be ignored, got %d", w.Code)
+	}
+
+	// Clients that are not trusted proxies are limited by their own address
+	var code int
+	for _, spoofed := range []string{"10.1.0.1", "10.1.0.2", "10.1.0.3"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/challenge", nil)
+		req.RemoteAddr = "198.51.100.7:4000"
+		req.Header.Set("X-Forwarded-For", spoofed)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		code = w.Code
+	}
+	if code != http.StatusTooManyRequests {
+		t.Fatalf("expected a rotating X-Forwarded-For from an untrusted client to be limited, got %d", code)
+	}
+}
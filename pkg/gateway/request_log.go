@@ -0,0 +1,299 @@
+package gateway
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Defaults for the asynchronous request log writer
+const (
+	defaultRequestLogBufferSize    = 4096
+	defaultRequestLogBatchSize     = 200
+	defaultRequestLogFlushInterval = 2 * time.Second
+	defaultRequestLogSampleRate    = 0.1
+	defaultRequestLogRetention     = 30 * 24 * time.Hour
+	requestLogAPIKeyCacheTTL       = 5 * time.Minute
+	requestLogRetentionInterval    = time.Hour
+)
+
+// RequestLogConfig controls how request logs are buffered and persisted.
+type RequestLogConfig struct {
+	Disabled      bool          // Skip persisting request logs entirely
+	BufferSize    int           // Max queued entries before new ones are dropped (default: 4096)
+	BatchSize     int           // Max rows per INSERT (default: 200)
+	FlushInterval time.Duration // Max delay before a partial batch is written (default: 2s)
+	SampleRate    float64       // Fraction of successful requests kept once the buffer is 75% full (default: 0.1)
+	Retention     time.Duration // Rows older than this are deleted (default: 30 days; < 0 keeps forever)
+}
+
+// requestLogEntry is a single request captured by loggingMiddleware.
+type requestLogEntry struct {
+	Method     string
+	Path       string
+	Status     int
+	BytesOut   int
+	DurationMS int64
+	IP         string
+	APIKey     string
+	Namespace  string
+	CreatedAt  time.Time
+}
+
+// requestLogDB is the subset of client.DatabaseClient used by the writer.
+type requestLogDB interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (*client.QueryResult, error)
+}
+
+// requestLogWriter batches request log rows and writes them off the request path.
+type requestLogWriter struct {
+	cfg    RequestLogConfig
+	db     func() requestLogDB
+	logger *logging.ColoredLogger
+
+	queue chan requestLogEntry
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	keyMu    sync.Mutex
+	keyCache map[string]cachedAPIKeyID
+
+	dropped atomic.Int64
+	sampled atomic.Int64
+}
+
+type cachedAPIKeyID struct {
+	id      interface{} // int64 or nil when the key is unknown
+	expires time.Time
+}
+
+// newRequestLogWriter applies defaults and starts the background flusher.
+func newRequestLogWriter(cfg RequestLogConfig, db func() requestLogDB, logger *logging.ColoredLogger) *requestLogWriter {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultRequestLogBufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultRequestLogBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultRequestLogFlushInterval
+	}
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = defaultRequestLogSampleRate
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = defaultRequestLogRetention
+	}
+
+	w := &requestLogWriter{
+		cfg:      cfg,
+		db:       db,
+		logger:   logger,
+		queue:    make(chan requestLogEntry, cfg.BufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		keyCache: make(map[string]cachedAPIKeyID),
+	}
+	go w.run()
+	return w
+}
+
+// Enqueue records an entry without blocking. Under pressure successful
+// requests are sampled and, if the buffer is full, entries are dropped.
+func (w *requestLogWriter) Enqueue(e requestLogEntry) {
+	if w == nil {
+		return
+	}
+	if e.Status < 400 && len(w.queue) >= cap(w.queue)*3/4 && rand.Float64() >= w.cfg.SampleRate {
+		w.sampled.Add(1)
+		return
+	}
+	select {
+	case w.queue <- e:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// Close flushes queued entries and stops the background goroutine.
+func (w *requestLogWriter) Close() {
+	if w == nil {
+		return
+	}
+	w.once.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+}
+
+func (w *requestLogWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+	retention := time.NewTicker(requestLogRetentionInterval)
+	defer retention.Stop()
+
+	batch := make([]requestLogEntry, 0, w.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.writeBatch(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e := <-w.queue:
+			batch = append(batch, e)
+			if len(batch) >= w.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			w.reportLoss()
+		case <-retention.C:
+			w.enforceRetention()
+		case <-w.stop:
+			for {
+				select {
+				case e := <-w.queue:
+					batch = append(batch, e)
+					if len(batch) >= w.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeBatch inserts a batch with one multi-row INSERT and touches
+// last_used_at once per distinct API key.
+func (w *requestLogWriter) writeBatch(batch []requestLogEntry) {
+	db := w.db()
+	if db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(client.WithInternalAuth(context.Background()), 5*time.Second)
+	defer cancel()
+
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO request_logs (method, path, status_code, bytes_out, duration_ms, ip, api_key_id, namespace, created_at) VALUES ")
+	args := make([]interface{}, 0, len(batch)*9)
+	usedKeys := make(map[int64]struct{})
+	for i, e := range batch {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		keyID := w.apiKeyID(ctx, db, e.APIKey)
+		if id, ok := keyID.(int64); ok {
+			usedKeys[id] = struct{}{}
+		}
+		var ns interface{}
+		if e.Namespace != "" {
+			ns = e.Namespace
+		}
+		args = append(args, e.Method, e.Path, e.Status, e.BytesOut, e.DurationMS, e.IP, keyID, ns,
+			e.CreatedAt.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	if _, err := db.Query(ctx, sb.String(), args...); err != nil {
+		w.logger.ComponentWarn(logging.ComponentGeneral, "failed to persist request logs",
+			zap.Int("rows", len(batch)), zap.Error(err))
+		return
+	}
+
+	if len(usedKeys) > 0 {
+		ids := make([]string, 0, len(usedKeys))
+		for id := range usedKeys {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		_, _ = db.Query(ctx, "UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id IN ("+strings.Join(ids, ",")+")")
+	}
+}
+
+// apiKeyID resolves an API key to its row ID, caching hits and misses.
+func (w *requestLogWriter) apiKeyID(ctx context.Context, db requestLogDB, key string) interface{} {
+	if key == "" {
+		return nil
+	}
+	now := time.Now()
+	w.keyMu.Lock()
+	if c, ok := w.keyCache[key]; ok && now.Before(c.expires) {
+		w.keyMu.Unlock()
+		return c.id
+	}
+	w.keyMu.Unlock()
+
+	var id interface{}
+	if res, err := db.Query(ctx, "SELECT id FROM api_keys WHERE key = ? LIMIT 1", key); err == nil &&
+		res != nil && res.Count > 0 && len(res.Rows) > 0 && len(res.Rows[0]) > 0 {
+		switch v := res.Rows[0][0].(type) {
+		case int64:
+			id = v
+		case float64:
+			id = int64(v)
+		case int:
+			id = int64(v)
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				id = n
+			}
+		}
+	} else if err != nil {
+		// Don't cache transient failures
+		return nil
+	}
+
+	w.keyMu.Lock()
+	if len(w.keyCache) > 10000 {
+		w.keyCache = make(map[string]cachedAPIKeyID)
+	}
+	w.keyCache[key] = cachedAPIKeyID{id: id, expires: now.Add(requestLogAPIKeyCacheTTL)}
+	w.keyMu.Unlock()
+	return id
+}
+
+// enforceRetention deletes request logs older than the retention window.
+func (w *requestLogWriter) enforceRetention() {
+	if w.cfg.Retention < 0 {
+		return
+	}
+	db := w.db()
+	if db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(client.WithInternalAuth(context.Background()), 30*time.Second)
+	defer cancel()
+	cutoff := time.Now().Add(-w.cfg.Retention).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Query(ctx, "DELETE FROM request_logs WHERE created_at < ?", cutoff); err != nil {
+		w.logger.ComponentWarn(logging.ComponentGeneral, "request log retention cleanup failed", zap.Error(err))
+	}
+}
+
+// reportLoss logs how many entries were sampled out or dropped since the last report.
+func (w *requestLogWriter) reportLoss() {
+	dropped := w.dropped.Swap(0)
+	sampled := w.sampled.Swap(0)
+	if dropped > 0 || sampled > 0 {
+		w.logger.ComponentWarn(logging.ComponentGeneral, "request log writer under pressure",
+			zap.Int64("dropped", dropped),
+			zap.Int64("sampled_out", sampled),
+		)
+	}
+}
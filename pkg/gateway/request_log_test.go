@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DeBrosOfficial/network/pkg/client"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+)
+
+// recordingDB captures queries issued by the request log writer.
+type recordingDB struct {
+	mu      sync.Mutex
+	queries []string
+	args    [][]interface{}
+}
+
+func (d *recordingDB) Query(ctx context.Context, sql string, args ...interface{}) (*client.QueryResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, sql)
+	d.args = append(d.args, args)
+	if strings.HasPrefix(sql, "SELECT id FROM api_keys") {
+		return &client.QueryResult{Count: 1, Rows: [][]interface{}{{int64(7)}}}, nil
+	}
+	return &client.QueryResult{}, nil
+}
+
+func (d *recordingDB) count(prefix string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, q := range d.queries {
+		if strings.HasPrefix(q, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRequestLogWriter_BatchesAndCachesKeys(t *testing.T) {
+	logger, _ := logging.NewDefaultLogger(logging.ComponentGeneral)
+	db := &recordingDB{}
+	w := newRequestLogWriter(RequestLogConfig{BatchSize: 10, FlushInterval: time.Hour}, func() requestLogDB { return db }, logger)
+
+	for i := 0; i < 10; i++ {
+		w.Enqueue(requestLogEntry{Method: "GET", Path: "/v1/x", Status: 200, APIKey: "ak_test:ns", Namespace: "ns", CreatedAt: time.Now()})
+	}
+	w.Close()
+
+	if got := db.count("INSERT INTO request_logs"); got != 1 {
+		t.Fatalf("expected 1 batched insert, got %d", got)
+	}
+	if got := db.count("SELECT id FROM api_keys"); got != 1 {
+		t.Fatalf("expected API key lookup to be cached, got %d lookups", got)
+	}
+	if got := db.count("UPDATE api_keys SET last_used_at"); got != 1 {
+		t.Fatalf("expected 1 last_used_at update, got %d", got)
+	}
+}
+
+func TestRequestLogWriter_DropsWhenFull(t *testing.T) {
+	logger, _ := logging.NewDefaultLogger(logging.ComponentGeneral)
+	w := &requestLogWriter{
+		cfg:    RequestLogConfig{SampleRate: 1},
+		logger: logger,
+		queue:  make(chan requestLogEntry, 1),
+	}
+
+	w.Enqueue(requestLogEntry{Status: 500})
+	w.Enqueue(requestLogEntry{Status: 500})
+
+	if got := w.dropped.Load(); got != 1 {
+		t.Fatalf("expected 1 dropped entry, got %d", got)
+	}
+}
@@ -44,12 +44,28 @@ func (g *Gateway) Routes() http.Handler {
 	mux.HandleFunc("/v1/network/connect", g.networkConnectHandler)
 	mux.HandleFunc("/v1/network/disconnect", g.networkDisconnectHandler)
 
+	// namespace settings (CORS policy, request logs) and usage
+	mux.HandleFunc("/v1/namespaces/", g.namespaceRoutesHandler)
+	mux.HandleFunc("/v1/usage", g.usageHandler)
+
 	// pubsub
 	if g.pubsubHandlers != nil {
 		mux.HandleFunc("/v1/pubsub/ws", g.pubsubHandlers.WebsocketHandler)
+		mux.HandleFunc("/v1/pubsub/sse", g.pubsubHandlers.SSEHandler)
 		mux.HandleFunc("/v1/pubsub/publish", g.pubsubHandlers.PublishHandler)
 		mux.HandleFunc("/v1/pubsub/topics", g.pubsubHandlers.TopicsHandler)
 		mux.HandleFunc("/v1/pubsub/presence", g.pubsubHandlers.PresenceHandler)
+		mux.HandleFunc("/v1/pubsub/history", g.pubsubHandlers.HistoryHandler)
+		mux.HandleFunc("/v1/pubsub/durable", g.pubsubHandlers.DurableTopicsHandler)
+		mux.HandleFunc("/v1/pubsub/acl", g.pubsubHandlers.ACLHandler)
+		mux.HandleFunc("/v1/pubsub/roles", g.pubsubHandlers.RolesHandler)
+		mux.HandleFunc("/v1/pubsub/policies", g.pubsubHandlers.PoliciesHandler)
+	}
+
+	// push notifications
+	if g.push != nil {
+		mux.HandleFunc("/v1/push/register", g.pushRegisterHandler)
+		mux.HandleFunc("/v1/push/send", g.pushSendHandler)
 	}
 
 	// anon proxy (authenticated users only)
@@ -72,6 +88,16 @@ func (g *Gateway) Routes() http.Handler {
 		mux.HandleFunc("/v1/storage/status/", g.storageHandlers.StatusHandler)
 		mux.HandleFunc("/v1/storage/get/", g.storageHandlers.DownloadHandler)
 		mux.HandleFunc("/v1/storage/unpin/", g.storageHandlers.UnpinHandler)
+		mux.HandleFunc("/v1/storage/presign", g.storageHandlers.PresignHandler)
+		mux.HandleFunc("/v1/storage/uploads", g.storageHandlers.CreateUploadHandler)
+		mux.HandleFunc("/v1/storage/uploads/", g.storageHandlers.UploadSessionHandler)
+		mux.HandleFunc("/v1/storage/objects", g.storageHandlers.ListObjectsHandler)
+		mux.HandleFunc("/v1/storage/objects/", g.storageHandlers.ObjectHandler)
+		mux.HandleFunc("/v1/storage/lifecycle", g.storageHandlers.LifecycleHandler)
+		mux.HandleFunc("/v1/storage/lifecycle/run", g.storageHandlers.LifecycleRunHandler)
+		mux.HandleFunc("/v1/storage/sites", g.storageHandlers.ListSitesHandler)
+		mux.HandleFunc("/v1/storage/sites/", g.storageHandlers.SiteHandler)
+		mux.HandleFunc("/v1/sites/", g.storageHandlers.ServeSiteHandler)
 	}
 
 	// serverless functions (if enabled)
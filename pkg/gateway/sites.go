@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// siteHostMiddleware serves static sites bound to a custom domain. Requests
+// for the gateway's own domain, and ACME challenges, pass through to the API.
+func (g *Gateway) siteHostMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.storageHandlers == nil || strings.HasPrefix(r.URL.Path, "/.well-known/acme-challenge/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if g.cfg != nil && g.cfg.DomainName != "" && strings.EqualFold(host, g.cfg.DomainName) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if g.storageHandlers.ServeSiteDomain(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CertHostPolicy allows certificates for primary and for custom domains bound
+// to a static site.
+func (g *Gateway) CertHostPolicy(primary string) autocert.HostPolicy {
+	return func(ctx context.Context, host string) error {
+		if strings.EqualFold(host, primary) {
+			return nil
+		}
+		if g.storageHandlers != nil && g.storageHandlers.HasSiteDomain(ctx, host) {
+			return nil
+		}
+		return fmt.Errorf("host %q not configured", host)
+	}
+}
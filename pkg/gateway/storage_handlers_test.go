@@ -473,8 +473,77 @@ func TestStorageGetHandler_Success(t *testing.T) {
 		t.Errorf("Expected content %s, got %s", expectedContent, w.Body.String())
 	}
 
-	if w.Header().Get("Content-Type") != "application/octet-stream" {
-		t.Errorf("Expected Content-Type 'application/octet-stream', got %s", w.Header().Get("Content-Type"))
+	if w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("Expected sniffed Content-Type 'text/plain; charset=utf-8', got %s", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("ETag") != `"`+expectedCID+`"` {
+		t.Errorf("Expected ETag to be the quoted CID, got %s", w.Header().Get("ETag"))
+	}
+	if !strings.Contains(w.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("Expected immutable Cache-Control, got %s", w.Header().Get("Cache-Control"))
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "inline; filename="+expectedCID {
+		t.Errorf("Expected inline disposition for text, got %s", got)
+	}
+}
+
+func TestStorageGetHandler_NotModified(t *testing.T) {
+	fetched := false
+	mockClient := &mockIPFSClient{
+		getFunc: func(ctx context.Context, cid string, ipfsAPIURL string) (io.ReadCloser, error) {
+			fetched = true
+			return io.NopCloser(strings.NewReader("content")), nil
+		},
+	}
+	gw := newTestGatewayWithIPFS(t, mockClient)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/storage/get/QmCached", nil)
+	req.Header.Set("If-None-Match", `W/"QmOther", "QmCached"`)
+	req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, "test-ns"))
+	w := httptest.NewRecorder()
+
+	gw.storageHandlers.DownloadHandler(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+	if fetched {
+		t.Error("Expected content not to be fetched from IPFS")
+	}
+}
+
+func TestStorageGetHandler_Disposition(t *testing.T) {
+	html := "<!DOCTYPE html><html><body>hi</body></html>"
+	mockClient := &mockIPFSClient{
+		getFunc: func(ctx context.Context, cid string, ipfsAPIURL string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(html)), nil
+		},
+	}
+	gw := newTestGatewayWithIPFS(t, mockClient)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/storage/get/QmPage", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, "test-ns"))
+	w := httptest.NewRecorder()
+	gw.storageHandlers.DownloadHandler(w, req)
+
+	if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment") {
+		t.Errorf("Expected HTML to be an attachment, got %s", got)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != "sandbox" {
+		t.Errorf("Expected sandbox CSP for HTML, got %q", got)
+	}
+
+	// Explicit inline still keeps the sandbox
+	req = httptest.NewRequest(http.MethodGet, "/v1/storage/get/QmPage?disposition=inline", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxkeys.NamespaceOverride, "test-ns"))
+	w = httptest.NewRecorder()
+	gw.storageHandlers.DownloadHandler(w, req)
+
+	if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "inline") {
+		t.Errorf("Expected inline disposition, got %s", got)
+	}
+	if w.Body.String() != html {
+		t.Errorf("Expected full body, got %q", w.Body.String())
 	}
 }
 
@@ -491,36 +560,28 @@ func TestStorageGetHandler_MissingNamespace(t *testing.T) {
 	}
 }
 
-func TestStorageUnpinHandler_Success(t *testing.T) {
-	expectedCID := "QmUnpin123"
-
+func TestStorageUnpinHandler_RequiresIndex(t *testing.T) {
+	unpinned := false
 	mockClient := &mockIPFSClient{
 		unpinFunc: func(ctx context.Context, cid string) error {
-			if cid != expectedCID {
-				return io.ErrUnexpectedEOF
-			}
+			unpinned = true
 			return nil
 		},
 	}
 
 	gw := newTestGatewayWithIPFS(t, mockClient)
 
-	req := httptest.NewRequest(http.MethodDelete, "/v1/storage/unpin/"+expectedCID, nil)
+	req := httptest.NewRequest(http.MethodDelete, "/v1/storage/unpin/QmUnpin123", nil)
 	w := httptest.NewRecorder()
 
 	gw.storageHandlers.UnpinHandler(w, req)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
-	}
-
-	var resp map[string]any
-	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
-		t.Fatalf("Failed to decode response: %v", err)
+	// Without the reference table the caller's ownership cannot be checked
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
 	}
-
-	if resp["cid"] != expectedCID {
-		t.Errorf("Expected CID %s, got %v", expectedCID, resp["cid"])
+	if unpinned {
+		t.Error("Expected the cluster pin to be left alone")
 	}
 }
 
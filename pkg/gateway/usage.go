@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	apierrors "github.com/DeBrosOfficial/network/pkg/errors"
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
+	"go.uber.org/zap"
+)
+
+// MeteringConfig controls per-namespace usage accounting and quota enforcement.
+type MeteringConfig struct {
+	Disabled      bool            // Skip usage accounting and quota checks entirely
+	FlushInterval time.Duration   // How often counters are aggregated into RQLite (default: 10s)
+	CacheTTL      time.Duration   // How long persisted usage and quota overrides are cached (default: 30s)
+	Quotas        metering.Quotas // Default soft/hard limits per metric; overridden by rows in namespace_quotas
+}
+
+// quotaMetrics returns the metrics a request to path can consume. Requests
+// are only rejected for hard quotas on these metrics.
+func quotaMetrics(path string) []string {
+	switch {
+	case strings.HasPrefix(path, "/v1/rqlite/"):
+		return []string{metering.DBStatements, metering.DBRows}
+	case strings.HasPrefix(path, "/v1/cache/") && path != "/v1/cache/health":
+		return []string{metering.CacheOps, metering.CacheBytes}
+	case path == "/v1/storage/upload" || strings.HasPrefix(path, "/v1/storage/uploads"),
+		strings.HasPrefix(path, "/v1/storage/sites/") && strings.HasSuffix(path, "/deploy"):
+		return []string{metering.StorageBytesUploaded, metering.StorageBytesPinned}
+	case path == "/v1/storage/pin":
+		return []string{metering.StorageBytesPinned}
+	case path == "/v1/pubsub/publish" || path == "/v1/pubsub/ws":
+		return []string{metering.PubSubMessages}
+	case strings.HasPrefix(path, "/v1/invoke/"):
+		return []string{metering.FunctionGBSeconds}
+	case strings.HasPrefix(path, "/v1/functions/") && (strings.HasSuffix(path, "/invoke") || strings.HasSuffix(path, "/ws")):
+		return []string{metering.FunctionGBSeconds}
+	}
+	return nil
+}
+
+// usageMiddleware binds authenticated requests to the meter so handlers can
+// record consumption, and rejects requests whose namespace has reached a hard
+// quota on a metric the route consumes. Soft quotas add an X-Quota-Warning header.
+func (g *Gateway) usageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.meter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ns, _ := r.Context().Value(CtxKeyNamespaceOverride).(string)
+		ns = strings.TrimSpace(ns)
+		if ns != "" {
+			r = r.WithContext(metering.WithNamespace(r.Context(), g.meter, ns))
+		}
+
+		metrics := quotaMetrics(r.URL.Path)
+		if len(metrics) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Direct invocations are charged to the function's namespace
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/v1/invoke/"); ok {
+			if pathNS, _, _ := strings.Cut(rest, "/"); pathNS != "" {
+				ns = pathNS
+			}
+		}
+		if ns == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		hard, soft, err := g.checkQuotas(r.Context(), ns, metrics...)
+		if err != nil {
+			// Fail open: metering problems must not take the API down
+			g.logger.ComponentDebug(logging.ComponentGeneral, "quota check skipped",
+				zap.String("namespace", ns), zap.Error(err))
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(hard) > 0 {
+			v := hard[0]
+			retryAfter := int(time.Until(metering.PeriodEnd(time.Now())).Seconds()) + 1
+			apierrors.WriteHTTPError(w, apierrors.NewQuotaExceededError(v.Metric, v.Used, v.Limit, retryAfter), r.Header.Get("X-Request-ID"))
+			return
+		}
+		if len(soft) > 0 {
+			warnings := make([]string, len(soft))
+			for i, v := range soft {
+				warnings[i] = fmt.Sprintf("%s=%g/%g", v.Metric, v.Used, v.Limit)
+			}
+			w.Header().Set("X-Quota-Warning", strings.Join(warnings, ", "))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkQuotas evaluates the namespace's current-period usage against its quotas.
+func (g *Gateway) checkQuotas(ctx context.Context, ns string, metrics ...string) (hard, soft []metering.Violation, err error) {
+	usage, err := g.meter.Usage(ctx, ns)
+	if err != nil {
+		return nil, nil, err
+	}
+	quotas, err := g.meter.Quotas(ctx, ns)
+	if err != nil {
+		return nil, nil, err
+	}
+	hard, soft = quotas.Check(usage, metrics...)
+	return hard, soft, nil
+}
+
+// usageHandler handles GET /v1/usage.
+// It reports the caller namespace's consumption for the current billing
+// period together with the effective quotas.
+//
+// Response:
+//
+//	{
+//	  "namespace": "default",
+//	  "period": "2026-10",
+//	  "resets_at": "2026-11-01T00:00:00Z",
+//	  "usage": {"db_statements": 1520, "cache_bytes": 20480, ...},
+//	  "quotas": {"db_rows": {"soft": 800000, "hard": 1000000}},
+//	  "exceeded": [],
+//	  "warnings": [{"metric": "db_rows", "used": 812000, "limit": 800000}]
+//	}
+func (g *Gateway) usageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if g.meter == nil {
+		writeError(w, http.StatusServiceUnavailable, "usage metering disabled")
+		return
+	}
+	ns := g.requestNamespace(r)
+	if ns == "" {
+		writeError(w, http.StatusForbidden, "namespace not resolved")
+		return
+	}
+
+	usage, err := g.meter.Usage(r.Context(), ns)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	quotas, err := g.meter.Quotas(r.Context(), ns)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, m := range metering.Metrics {
+		if _, ok := usage[m]; !ok {
+			usage[m] = 0
+		}
+	}
+	hard, soft := quotas.Check(usage)
+	if hard == nil {
+		hard = []metering.Violation{}
+	}
+	if soft == nil {
+		soft = []metering.Violation{}
+	}
+
+	now := time.Now()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"namespace": ns,
+		"period":    metering.Period(now),
+		"resets_at": metering.PeriodEnd(now).Format(time.RFC3339),
+		"usage":     usage,
+		"quotas":    quotas,
+		"exceeded":  hard,
+		"warnings":  soft,
+	})
+}
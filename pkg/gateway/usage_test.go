@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/metering"
+)
+
+// emptyUsageDB is a metering.DB with no persisted usage or quota overrides.
+type emptyUsageDB struct{}
+
+func (emptyUsageDB) Query(ctx context.Context, dest any, query string, args ...any) error {
+	return nil
+}
+
+func (emptyUsageDB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, nil
+}
+
+func TestQuotaMetrics(t *testing.T) {
+	cases := map[string]string{
+		"/v1/rqlite/query":        metering.DBStatements,
+		"/v1/cache/put":           metering.CacheOps,
+		"/v1/storage/upload":      metering.StorageBytesUploaded,
+		"/v1/pubsub/publish":      metering.PubSubMessages,
+		"/v1/functions/fn/invoke": metering.FunctionGBSeconds,
+		"/v1/invoke/ns/fn":        metering.FunctionGBSeconds,
+	}
+	for path, want := range cases {
+		got := quotaMetrics(path)
+		if len(got) == 0 || got[0] != want {
+			t.Errorf("quotaMetrics(%q) = %v, want %s first", path, got, want)
+		}
+	}
+	for _, path := range []string{"/v1/cache/health", "/v1/usage", "/v1/functions"} {
+		if got := quotaMetrics(path); got != nil {
+			t.Errorf("quotaMetrics(%q) = %v, want none", path, got)
+		}
+	}
+}
+
+func TestUsageMiddleware_EnforcesQuotas(t *testing.T) {
+	logger, _ := logging.NewDefaultLogger(logging.ComponentGeneral)
+	meter := metering.NewMeter(emptyUsageDB{}, metering.Config{Quotas: metering.Quotas{
+		metering.CacheOps:       {Hard: 2},
+		metering.PubSubMessages: {Soft: 1, Hard: 10},
+	}}, nil)
+	gw := &Gateway{logger: logger, cfg: &Config{}, meter: meter}
+
+	h := gw.usageMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metering.Record(r.Context(), metering.CacheOps, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), CtxKeyNamespaceOverride, "tenant"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do("/v1/cache/get"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := do("/v1/cache/get")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the hard quota is reached, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After until the period resets")
+	}
+
+	// Other metrics are unaffected; soft limits only warn
+	meter.Record("tenant", metering.PubSubMessages, 1)
+	w = do("/v1/pubsub/publish")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for pubsub, got %d", w.Code)
+	}
+	if w.Header().Get("X-Quota-Warning") == "" {
+		t.Error("expected X-Quota-Warning for soft limit")
+	}
+}
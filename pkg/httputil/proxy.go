@@ -0,0 +1,62 @@
+package httputil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies is the set of reverse proxies whose forwarding headers
+// (X-Forwarded-For, X-Real-IP, X-Forwarded-Proto) are believed.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses CIDRs and single IPs, returning the valid ones
+// along with an error naming the first invalid entry.
+func ParseTrustedProxies(list []string) (TrustedProxies, error) {
+	var nets TrustedProxies
+	var firstErr error
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("invalid trusted proxy %q: expected a CIDR or IP address", s)
+			}
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets, firstErr
+}
+
+// Contains reports whether ip belongs to a trusted proxy.
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, n := range t {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromProxy reports whether the request's connection comes from a trusted
+// proxy, i.e. whether its forwarding headers may be believed.
+func (t TrustedProxies) FromProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && t.Contains(ip)
+}
@@ -1,15 +1,19 @@
 package ipfs
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -31,7 +35,11 @@ type IPFSClient interface {
 type Client struct {
 	apiURL     string
 	httpClient *http.Client
-	logger     *zap.Logger
+	// streamClient has no overall deadline so large uploads and downloads are
+	// bounded by the request context instead; only waiting for response
+	// headers is limited by the configured timeout.
+	streamClient *http.Client
+	logger       *zap.Logger
 }
 
 // Config holds configuration for the IPFS client
@@ -86,10 +94,14 @@ func NewClient(cfg Config, logger *zap.Logger) (*Client, error) {
 		Timeout: timeout,
 	}
 
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+
 	return &Client{
-		apiURL:     apiURL,
-		httpClient: httpClient,
-		logger:     logger,
+		apiURL:       apiURL,
+		httpClient:   httpClient,
+		streamClient: &http.Client{Transport: transport},
+		logger:       logger,
 	}, nil
 }
 
@@ -149,42 +161,130 @@ func (c *Client) GetPeerCount(ctx context.Context) (int, error) {
 	return peerCount, nil
 }
 
-// Add adds content to IPFS and returns the CID
+// Add adds content to IPFS and returns the CID.
+// The content is streamed to the cluster as a multipart body through an
+// io.Pipe, so memory use does not grow with object size.
 func (c *Client) Add(ctx context.Context, reader io.Reader, name string) (*AddResponse, error) {
-	// Track original size by reading into memory first
-	// This allows us to return the actual byte count, not the DAG size
-	data, err := io.ReadAll(reader)
+	// Count bytes as they stream so we return the actual byte count, not the DAG size
+	counter := &countingReader{r: reader}
+
+	outputs, err := c.addMultipart(ctx, func(writer *multipart.Writer) error {
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			return fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := io.Copy(part, counter); err != nil {
+			return fmt.Errorf("failed to copy data: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read data: %w", err)
+		return nil, err
 	}
-	originalSize := int64(len(data))
-
-	// Create multipart form request for IPFS Cluster API
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
+	last := outputs[len(outputs)-1]
 
-	// Create form file field
-	part, err := writer.CreateFormFile("file", name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
+	// Ensure name is set if provided
+	if last.Name == "" && name != "" {
+		last.Name = name
 	}
 
-	if _, err := io.Copy(part, bytes.NewReader(data)); err != nil {
-		return nil, fmt.Errorf("failed to copy data: %w", err)
+	// Override size with original byte count (not DAG size)
+	last.Size = counter.N()
+
+	return &last, nil
+}
+
+// AddDirectory adds the local directory dir to IPFS as a UnixFS directory
+// called name and returns its root. Only regular files and directories are
+// added; files are streamed in lexical order. The returned size is the total
+// byte count of the files.
+func (c *Client) AddDirectory(ctx context.Context, dir string, name string) (*AddResponse, error) {
+	var total int64
+	outputs, err := c.addMultipart(ctx, func(writer *multipart.Writer) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			partName := name
+			if rel != "." {
+				partName = name + "/" + filepath.ToSlash(rel)
+			}
+
+			header := make(textproto.MIMEHeader)
+			// Escaped so the slashes survive multipart filename handling
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, url.QueryEscape(partName)))
+			switch {
+			case d.IsDir():
+				header.Set("Content-Type", "application/x-directory")
+				_, err := writer.CreatePart(header)
+				return err
+			case d.Type().IsRegular():
+				header.Set("Content-Type", "application/octet-stream")
+				part, err := writer.CreatePart(header)
+				if err != nil {
+					return err
+				}
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				n, err := io.Copy(part, f)
+				total += n
+				return err
+			default:
+				return nil
+			}
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close writer: %w", err)
+	// The directory itself is reported after its contents
+	root := outputs[len(outputs)-1]
+	for _, out := range outputs {
+		if out.Name == name {
+			root = out
+		}
 	}
+	root.Name = name
+	root.Size = total
+	return &root, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL+"/add", &buf)
+// addMultipart streams the multipart body produced by write to the cluster's
+// add endpoint and returns every reported entry.
+func (c *Client) addMultipart(ctx context.Context, write func(*multipart.Writer) error) ([]AddResponse, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := write(writer); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(writer.Close())
+	}()
+	// Unblock the writer if the request ends before the body was consumed
+	defer func() {
+		pr.Close()
+		<-done
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL+"/add", pr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create add request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.streamClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("add request failed: %w", err)
 	}
@@ -197,11 +297,9 @@ func (c *Client) Add(ctx context.Context, reader io.Reader, name string) (*AddRe
 
 	// IPFS Cluster streams NDJSON responses. We need to drain the entire stream
 	// to prevent the connection from closing prematurely, which would cancel
-	// the cluster's pinning operation. Read all JSON objects and keep the last one.
+	// the cluster's pinning operation.
 	dec := json.NewDecoder(resp.Body)
-	var last AddResponse
-	var hasResult bool
-
+	var outputs []AddResponse
 	for {
 		var chunk AddResponse
 		if err := dec.Decode(&chunk); err != nil {
@@ -210,23 +308,13 @@ func (c *Client) Add(ctx context.Context, reader io.Reader, name string) (*AddRe
 			}
 			return nil, fmt.Errorf("failed to decode add response: %w", err)
 		}
-		last = chunk
-		hasResult = true
+		outputs = append(outputs, chunk)
 	}
 
-	if !hasResult {
+	if len(outputs) == 0 {
 		return nil, fmt.Errorf("add response missing CID")
 	}
-
-	// Ensure name is set if provided
-	if last.Name == "" && name != "" {
-		last.Name = name
-	}
-
-	// Override size with original byte count (not DAG size)
-	last.Size = originalSize
-
-	return &last, nil
+	return outputs, nil
 }
 
 // Pin pins a CID with specified replication factor
@@ -388,17 +476,30 @@ func (c *Client) Unpin(ctx context.Context, cid string) error {
 // Get retrieves content from IPFS by CID
 // Note: This uses the IPFS HTTP API (typically on port 5001), not the Cluster API
 func (c *Client) Get(ctx context.Context, cid string, ipfsAPIURL string) (io.ReadCloser, error) {
+	return c.GetRange(ctx, cid, ipfsAPIURL, 0, -1)
+}
+
+// GetRange retrieves length bytes of content starting at offset. A negative
+// length reads to the end of the content.
+func (c *Client) GetRange(ctx context.Context, cid string, ipfsAPIURL string, offset, length int64) (io.ReadCloser, error) {
 	if ipfsAPIURL == "" {
 		ipfsAPIURL = "http://localhost:5001"
 	}
 
-	url := fmt.Sprintf("%s/api/v0/cat?arg=%s", ipfsAPIURL, cid)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	// cid may carry a path into a directory, e.g. <cid>/assets/app.js
+	reqURL := fmt.Sprintf("%s/api/v0/cat?arg=%s", ipfsAPIURL, url.QueryEscape(cid))
+	if offset > 0 {
+		reqURL += fmt.Sprintf("&offset=%d", offset)
+	}
+	if length >= 0 {
+		reqURL += fmt.Sprintf("&length=%d", length)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create get request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.streamClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("get request failed: %w", err)
 	}
@@ -420,3 +521,21 @@ func (c *Client) Close(ctx context.Context) error {
 	// HTTP client doesn't need explicit closing
 	return nil
 }
+
+// countingReader counts the bytes read through it. It is safe to call N
+// concurrently with Read.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// N returns the number of bytes read so far.
+func (c *countingReader) N() int64 {
+	return c.n.Load()
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"mime"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -124,6 +128,49 @@ func TestClient_Add(t *testing.T) {
 		}
 	})
 
+	t.Run("streams_large_body", func(t *testing.T) {
+		const contentSize = 16 << 20
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The body is streamed, so its length is not known up front
+			if r.ContentLength != -1 {
+				t.Errorf("Expected chunked request body, got ContentLength %d", r.ContentLength)
+			}
+
+			mr, err := r.MultipartReader()
+			if err != nil {
+				t.Errorf("Failed to read multipart body: %v", err)
+				return
+			}
+			part, err := mr.NextPart()
+			if err != nil {
+				t.Errorf("Failed to get file part: %v", err)
+				return
+			}
+			n, _ := io.Copy(io.Discard, part)
+			if n != contentSize {
+				t.Errorf("Expected %d bytes, server received %d", contentSize, n)
+			}
+
+			json.NewEncoder(w).Encode(AddResponse{Cid: "QmLarge", Name: "large.bin"})
+		}))
+		defer server.Close()
+
+		client, err := NewClient(Config{ClusterAPIURL: server.URL}, logger)
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		reader := io.LimitReader(zeroReader{}, contentSize)
+		resp, err := client.Add(context.Background(), reader, "large.bin")
+		if err != nil {
+			t.Fatalf("Failed to add content: %v", err)
+		}
+		if resp.Size != contentSize {
+			t.Errorf("Expected size %d, got %d", contentSize, resp.Size)
+		}
+	})
+
 	t.Run("server_error", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -145,6 +192,69 @@ func TestClient_Add(t *testing.T) {
 	})
 }
 
+func TestClient_AddDirectory(t *testing.T) {
+	logger := zap.NewNop()
+
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "assets"), 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>hi</h1>"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("console.log(1)"), 0o644)
+
+	var parts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Errorf("Expected multipart body: %v", err)
+			return
+		}
+		enc := json.NewEncoder(w)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("Failed to read part: %v", err)
+				return
+			}
+			_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+			name, _ := url.QueryUnescape(params["filename"])
+			parts = append(parts, part.Header.Get("Content-Type")+" "+name)
+			if part.Header.Get("Content-Type") != "application/x-directory" {
+				_ = enc.Encode(AddResponse{Cid: "QmFile", Name: name})
+			}
+		}
+		_ = enc.Encode(AddResponse{Cid: "QmRoot", Name: "site", Size: 1})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{ClusterAPIURL: server.URL}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	resp, err := client.AddDirectory(context.Background(), dir, "site")
+	if err != nil {
+		t.Fatalf("Failed to add directory: %v", err)
+	}
+	if resp.Cid != "QmRoot" || resp.Name != "site" {
+		t.Errorf("Expected root QmRoot named site, got %+v", resp)
+	}
+	if resp.Size != int64(len("<h1>hi</h1>")+len("console.log(1)")) {
+		t.Errorf("Expected total file size, got %d", resp.Size)
+	}
+
+	want := []string{
+		"application/x-directory site",
+		"application/x-directory site/assets",
+		"application/octet-stream site/assets/app.js",
+		"application/octet-stream site/index.html",
+	}
+	if strings.Join(parts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected parts:\n%s\nwant:\n%s", strings.Join(parts, "\n"), strings.Join(want, "\n"))
+	}
+}
+
 func TestClient_Pin(t *testing.T) {
 	logger := zap.NewNop()
 
@@ -406,6 +516,31 @@ func TestClient_Get(t *testing.T) {
 		}
 	})
 
+	t.Run("range", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if query.Get("offset") != "100" || query.Get("length") != "50" {
+				t.Errorf("Expected offset=100&length=50, got %s", r.URL.RawQuery)
+			}
+			w.Write([]byte("partial"))
+		}))
+		defer server.Close()
+
+		client, err := NewClient(Config{ClusterAPIURL: "http://localhost:9094"}, logger)
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		reader, err := client.GetRange(context.Background(), "QmRange", server.URL, 100, 50)
+		if err != nil {
+			t.Fatalf("Failed to get range: %v", err)
+		}
+		defer reader.Close()
+		if data, _ := io.ReadAll(reader); string(data) != "partial" {
+			t.Errorf("Expected 'partial', got %q", data)
+		}
+	})
+
 	t.Run("default_ipfs_api_url", func(t *testing.T) {
 		expectedCID := "QmDefault"
 
@@ -489,3 +624,11 @@ func TestClient_Close(t *testing.T) {
 		t.Errorf("Close should not error, got: %v", err)
 	}
 }
+
+// zeroReader is an endless stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,265 @@
+package metering
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Defaults for the Meter
+const (
+	DefaultFlushInterval = 10 * time.Second
+	DefaultCacheTTL      = 30 * time.Second
+)
+
+// DB is the subset of rqlite.Client used by the Meter.
+type DB interface {
+	Query(ctx context.Context, dest any, query string, args ...any) error
+	Exec(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Config controls aggregation and quota defaults.
+type Config struct {
+	FlushInterval time.Duration // How often pending counters are written to RQLite (default: 10s)
+	CacheTTL      time.Duration // How long persisted usage and quota overrides are cached (default: 30s)
+	Quotas        Quotas        // Default quotas applied to every namespace
+}
+
+type usageKey struct {
+	namespace string
+	period    string
+	metric    string
+}
+
+type usageRow struct {
+	Metric string  `db:"metric"`
+	Value  float64 `db:"value"`
+}
+
+type quotaRow struct {
+	Metric string          `db:"metric"`
+	Soft   sql.NullFloat64 `db:"soft_limit"`
+	Hard   sql.NullFloat64 `db:"hard_limit"`
+}
+
+type cachedUsage struct {
+	values  map[string]float64
+	expires time.Time
+}
+
+type cachedQuotas struct {
+	quotas  Quotas
+	expires time.Time
+}
+
+// Meter accumulates usage in memory and periodically aggregates it into RQLite.
+type Meter struct {
+	cfg    Config
+	db     DB
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[usageKey]float64
+
+	cacheMu    sync.Mutex
+	usageCache map[string]cachedUsage  // namespace -> persisted totals for the current period
+	quotaCache map[string]cachedQuotas // namespace -> effective quotas
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewMeter creates a Meter. Call Start to begin periodic aggregation.
+func NewMeter(db DB, cfg Config, logger *zap.Logger) *Meter {
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Meter{
+		cfg:        cfg,
+		db:         db,
+		logger:     logger,
+		now:        time.Now,
+		pending:    make(map[usageKey]float64),
+		usageCache: make(map[string]cachedUsage),
+		quotaCache: make(map[string]cachedQuotas),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Record implements Recorder.
+func (m *Meter) Record(namespace, metric string, delta float64) {
+	if m == nil || namespace == "" || delta <= 0 {
+		return
+	}
+	k := usageKey{namespace: namespace, period: Period(m.now()), metric: metric}
+	m.mu.Lock()
+	m.pending[k] += delta
+	m.mu.Unlock()
+}
+
+// Start runs the background aggregation loop until Close is called.
+func (m *Meter) Start() {
+	go m.run()
+}
+
+// Close flushes pending counters and stops the aggregation loop.
+func (m *Meter) Close() {
+	if m == nil {
+		return
+	}
+	m.once.Do(func() {
+		close(m.stop)
+		select {
+		case <-m.done:
+		case <-time.After(10 * time.Second):
+		}
+	})
+}
+
+func (m *Meter) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.flushWithTimeout()
+		case <-m.stop:
+			m.flushWithTimeout()
+			return
+		}
+	}
+}
+
+func (m *Meter) flushWithTimeout() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.Flush(ctx); err != nil {
+		m.logger.Warn("Failed to flush usage counters", zap.Error(err))
+	}
+}
+
+// Flush writes pending counters with a single upsert. On failure the
+// counters are kept and retried on the next flush.
+func (m *Meter) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	if len(m.pending) == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	batch := m.pending
+	m.pending = make(map[usageKey]float64)
+	m.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO namespace_usage (namespace, period, metric, value) VALUES ")
+	args := make([]any, 0, len(batch)*4)
+	i := 0
+	for k, v := range batch {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(?, ?, ?, ?)")
+		args = append(args, k.namespace, k.period, k.metric, v)
+		i++
+	}
+	sb.WriteString(" ON CONFLICT(namespace, period, metric) DO UPDATE SET value = value + excluded.value, updated_at = CURRENT_TIMESTAMP")
+
+	if _, err := m.db.Exec(ctx, sb.String(), args...); err != nil {
+		m.mu.Lock()
+		for k, v := range batch {
+			m.pending[k] += v
+		}
+		m.mu.Unlock()
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+
+	// Persisted totals changed; drop cached copies so the next read sees them
+	m.cacheMu.Lock()
+	for k := range batch {
+		delete(m.usageCache, k.namespace)
+	}
+	m.cacheMu.Unlock()
+	return nil
+}
+
+// Usage returns the namespace's consumption for the current period,
+// including counters that have not been flushed yet.
+func (m *Meter) Usage(ctx context.Context, namespace string) (map[string]float64, error) {
+	now := m.now()
+	period := Period(now)
+
+	m.cacheMu.Lock()
+	c, ok := m.usageCache[namespace]
+	m.cacheMu.Unlock()
+
+	if !ok || now.After(c.expires) {
+		var rows []usageRow
+		if err := m.db.Query(ctx, &rows,
+			"SELECT metric, value FROM namespace_usage WHERE namespace = ? AND period = ?", namespace, period); err != nil {
+			return nil, fmt.Errorf("failed to query usage: %w", err)
+		}
+		c = cachedUsage{values: make(map[string]float64, len(rows)), expires: now.Add(m.cfg.CacheTTL)}
+		for _, r := range rows {
+			c.values[r.Metric] = r.Value
+		}
+		m.cacheMu.Lock()
+		m.usageCache[namespace] = c
+		m.cacheMu.Unlock()
+	}
+
+	out := make(map[string]float64, len(Metrics))
+	for k, v := range c.values {
+		out[k] = v
+	}
+	m.mu.Lock()
+	for k, v := range m.pending {
+		if k.namespace == namespace && k.period == period {
+			out[k.metric] += v
+		}
+	}
+	m.mu.Unlock()
+	return out, nil
+}
+
+// Quotas returns the effective quotas for a namespace: configured defaults
+// overridden by rows in namespace_quotas.
+func (m *Meter) Quotas(ctx context.Context, namespace string) (Quotas, error) {
+	now := m.now()
+	m.cacheMu.Lock()
+	c, ok := m.quotaCache[namespace]
+	m.cacheMu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.quotas, nil
+	}
+
+	var rows []quotaRow
+	if err := m.db.Query(ctx, &rows,
+		"SELECT metric, soft_limit, hard_limit FROM namespace_quotas WHERE namespace = ?", namespace); err != nil {
+		return nil, fmt.Errorf("failed to query quotas: %w", err)
+	}
+	override := make(Quotas, len(rows))
+	for _, r := range rows {
+		override[r.Metric] = Quota{Soft: r.Soft.Float64, Hard: r.Hard.Float64}
+	}
+	quotas := m.cfg.Quotas.Merge(override)
+
+	m.cacheMu.Lock()
+	m.quotaCache[namespace] = cachedQuotas{quotas: quotas, expires: now.Add(m.cfg.CacheTTL)}
+	m.cacheMu.Unlock()
+	return quotas, nil
+}
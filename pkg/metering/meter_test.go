@@ -0,0 +1,163 @@
+package metering
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDB records Exec calls and serves namespace_usage rows from a map.
+type fakeDB struct {
+	mu      sync.Mutex
+	execs   []string
+	failing bool
+	stored  map[string]float64
+	quotas  []quotaRow
+}
+
+func (d *fakeDB) Query(ctx context.Context, dest any, query string, args ...any) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch out := dest.(type) {
+	case *[]usageRow:
+		for m, v := range d.stored {
+			*out = append(*out, usageRow{Metric: m, Value: v})
+		}
+	case *[]quotaRow:
+		*out = append(*out, d.quotas...)
+	}
+	return nil
+}
+
+func (d *fakeDB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failing {
+		return nil, errors.New("unavailable")
+	}
+	d.execs = append(d.execs, query)
+	if d.stored == nil {
+		d.stored = make(map[string]float64)
+	}
+	for i := 0; i+3 < len(args); i += 4 {
+		d.stored[args[i+2].(string)] += args[i+3].(float64)
+	}
+	return nil, nil
+}
+
+func TestMeter_RecordFlushAndUsage(t *testing.T) {
+	db := &fakeDB{}
+	m := NewMeter(db, Config{}, nil)
+	ctx := context.Background()
+
+	m.Record("ns", DBStatements, 2)
+	m.Record("ns", DBStatements, 3)
+	m.Record("other", CacheOps, 1)
+
+	usage, err := m.Usage(ctx, "ns")
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	if usage[DBStatements] != 5 {
+		t.Fatalf("expected pending usage 5, got %v", usage[DBStatements])
+	}
+
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(db.execs) != 1 || !strings.Contains(db.execs[0], "ON CONFLICT(namespace, period, metric)") {
+		t.Fatalf("expected a single upsert, got %v", db.execs)
+	}
+
+	usage, _ = m.Usage(ctx, "ns")
+	if usage[DBStatements] != 5 {
+		t.Fatalf("expected persisted usage 5 after flush, got %v", usage[DBStatements])
+	}
+}
+
+func TestMeter_FlushFailureKeepsCounters(t *testing.T) {
+	db := &fakeDB{failing: true}
+	m := NewMeter(db, Config{}, nil)
+	m.Record("ns", PubSubMessages, 4)
+
+	if err := m.Flush(context.Background()); err == nil {
+		t.Fatal("expected flush error")
+	}
+	db.failing = false
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if db.stored[PubSubMessages] != 4 {
+		t.Fatalf("expected counters to survive failed flush, got %v", db.stored[PubSubMessages])
+	}
+}
+
+func TestMeter_QuotaOverrides(t *testing.T) {
+	db := &fakeDB{quotas: []quotaRow{{Metric: DBRows, Hard: sql.NullFloat64{Float64: 50, Valid: true}}}}
+	m := NewMeter(db, Config{Quotas: Quotas{
+		DBRows:   {Soft: 10, Hard: 100},
+		CacheOps: {Hard: 1000},
+	}}, nil)
+
+	q, err := m.Quotas(context.Background(), "ns")
+	if err != nil {
+		t.Fatalf("quotas: %v", err)
+	}
+	if q[DBRows].Hard != 50 || q[DBRows].Soft != 0 {
+		t.Fatalf("expected namespace override, got %+v", q[DBRows])
+	}
+	if q[CacheOps].Hard != 1000 {
+		t.Fatalf("expected default quota, got %+v", q[CacheOps])
+	}
+}
+
+func TestQuotasCheck(t *testing.T) {
+	q := Quotas{
+		DBRows:   {Soft: 10, Hard: 20},
+		CacheOps: {Soft: 5},
+	}
+	usage := map[string]float64{DBRows: 25, CacheOps: 6}
+
+	hard, soft := q.Check(usage)
+	if len(hard) != 1 || hard[0].Metric != DBRows || hard[0].Limit != 20 {
+		t.Fatalf("unexpected hard violations: %+v", hard)
+	}
+	if len(soft) != 1 || soft[0].Metric != CacheOps {
+		t.Fatalf("unexpected soft violations: %+v", soft)
+	}
+
+	hard, soft = q.Check(usage, CacheOps)
+	if len(hard) != 0 || len(soft) != 1 {
+		t.Fatalf("expected only cache_ops to be checked, got hard=%v soft=%v", hard, soft)
+	}
+}
+
+func TestRecordUsesContextBinding(t *testing.T) {
+	m := NewMeter(&fakeDB{}, Config{}, nil)
+
+	Record(context.Background(), CacheBytes, 10) // unbound: ignored
+	ctx := WithNamespace(context.Background(), m, "ns")
+	Record(ctx, CacheBytes, 10)
+
+	usage, _ := m.Usage(context.Background(), "ns")
+	if usage[CacheBytes] != 10 {
+		t.Fatalf("expected 10 cache bytes, got %v", usage[CacheBytes])
+	}
+}
+
+func TestPeriodHelpers(t *testing.T) {
+	ts := time.Date(2026, time.December, 15, 10, 0, 0, 0, time.UTC)
+	if got := Period(ts); got != "2026-12" {
+		t.Fatalf("unexpected period %q", got)
+	}
+	if got := PeriodEnd(ts); !got.Equal(time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected period end %v", got)
+	}
+	if got := GBSeconds(512, 2*time.Second); got != 1 {
+		t.Fatalf("expected 1 GB-second, got %v", got)
+	}
+}
@@ -0,0 +1,99 @@
+// Package metering tracks per-namespace resource consumption across gateway
+// services (database, cache, storage, pubsub and serverless functions).
+//
+// Handlers report usage through a Recorder bound to the request context;
+// the Meter accumulates counters in memory and periodically folds them into
+// the namespace_usage table so every gateway sees cluster-wide totals.
+package metering
+
+import (
+	"context"
+	"time"
+)
+
+// Metric names tracked per namespace.
+const (
+	DBStatements         = "db_statements"
+	DBRows               = "db_rows"
+	CacheOps             = "cache_ops"
+	CacheBytes           = "cache_bytes"
+	StorageBytesUploaded = "storage_bytes_uploaded"
+	StorageBytesPinned   = "storage_bytes_pinned"
+	PubSubMessages       = "pubsub_messages"
+	FunctionGBSeconds    = "function_gb_seconds"
+)
+
+// Metrics lists every metric in a stable order.
+var Metrics = []string{
+	DBStatements,
+	DBRows,
+	CacheOps,
+	CacheBytes,
+	StorageBytesUploaded,
+	StorageBytesPinned,
+	PubSubMessages,
+	FunctionGBSeconds,
+}
+
+// IsMetric reports whether name is a known metric.
+func IsMetric(name string) bool {
+	for _, m := range Metrics {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder accepts usage deltas for a namespace.
+type Recorder interface {
+	Record(namespace, metric string, delta float64)
+}
+
+// Period returns the billing period (UTC calendar month, e.g. "2026-10") containing t.
+func Period(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// PeriodEnd returns the start of the billing period following the one containing t.
+func PeriodEnd(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// GBSeconds converts a memory limit and execution time into GB-seconds.
+func GBSeconds(memoryLimitMB int, d time.Duration) float64 {
+	if memoryLimitMB <= 0 || d <= 0 {
+		return 0
+	}
+	return float64(memoryLimitMB) / 1024 * d.Seconds()
+}
+
+type ctxKey string
+
+const ctxKeyBinding ctxKey = "metering_binding"
+
+type binding struct {
+	recorder  Recorder
+	namespace string
+}
+
+// WithNamespace returns a context that attributes usage recorded through
+// Record to namespace.
+func WithNamespace(ctx context.Context, r Recorder, namespace string) context.Context {
+	if r == nil || namespace == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKeyBinding, binding{recorder: r, namespace: namespace})
+}
+
+// Record attributes delta to the namespace bound to ctx. It is a no-op when
+// metering is disabled or the request is not bound to a namespace.
+func Record(ctx context.Context, metric string, delta float64) {
+	if ctx == nil || delta <= 0 {
+		return
+	}
+	if b, ok := ctx.Value(ctxKeyBinding).(binding); ok {
+		b.recorder.Record(b.namespace, metric, delta)
+	}
+}
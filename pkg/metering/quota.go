@@ -0,0 +1,57 @@
+package metering
+
+import "sort"
+
+// Quota bounds a metric for one billing period. Zero means unlimited.
+type Quota struct {
+	Soft float64 `json:"soft,omitempty"` // Crossing it only produces a warning
+	Hard float64 `json:"hard,omitempty"` // Crossing it rejects further requests
+}
+
+// Quotas maps metric names to their limits.
+type Quotas map[string]Quota
+
+// Merge returns a copy of q with the entries of override applied on top.
+func (q Quotas) Merge(override Quotas) Quotas {
+	out := make(Quotas, len(q)+len(override))
+	for m, v := range q {
+		out[m] = v
+	}
+	for m, v := range override {
+		out[m] = v
+	}
+	return out
+}
+
+// Violation describes a metric that reached one of its limits.
+type Violation struct {
+	Metric string  `json:"metric"`
+	Used   float64 `json:"used"`
+	Limit  float64 `json:"limit"`
+}
+
+// Check compares usage against the quotas for the given metrics (all metrics
+// when none are given) and returns the hard and soft limits that were reached.
+func (q Quotas) Check(usage map[string]float64, metrics ...string) (hard, soft []Violation) {
+	if len(metrics) == 0 {
+		metrics = make([]string, 0, len(q))
+		for m := range q {
+			metrics = append(metrics, m)
+		}
+		sort.Strings(metrics)
+	}
+	for _, m := range metrics {
+		limit, ok := q[m]
+		if !ok {
+			continue
+		}
+		used := usage[m]
+		switch {
+		case limit.Hard > 0 && used >= limit.Hard:
+			hard = append(hard, Violation{Metric: m, Used: used, Limit: limit.Hard})
+		case limit.Soft > 0 && used >= limit.Soft:
+			soft = append(soft, Violation{Metric: m, Used: used, Limit: limit.Soft})
+		}
+	}
+	return hard, soft
+}
@@ -46,6 +46,11 @@ func (n *Node) startHTTPGateway(ctx context.Context) error {
 		EnableHTTPS:     n.config.HTTPGateway.HTTPS.Enabled,
 		DomainName:      n.config.HTTPGateway.HTTPS.Domain,
 		TLSCacheDir:     n.config.HTTPGateway.HTTPS.CacheDir,
+		TrustedProxies:  n.config.HTTPGateway.TrustedProxies,
+		RateLimit:       gateway.RateLimitConfig{Enabled: true},
+	}
+	if enabled := n.config.HTTPGateway.RateLimit.Enabled; enabled != nil {
+		gwCfg.RateLimit.Enabled = *enabled
 	}
 
 	apiGateway, err := gateway.New(gatewayLogger, gwCfg)
@@ -64,7 +69,7 @@ func (n *Node) startHTTPGateway(ctx context.Context) error {
 
 		certManager = &autocert.Manager{
 			Prompt:     autocert.AcceptTOS,
-			HostPolicy: autocert.HostWhitelist(gwCfg.DomainName),
+			HostPolicy: apiGateway.CertHostPolicy(gwCfg.DomainName),
 			Cache:      autocert.DirCache(tlsCacheDir),
 			Email:      fmt.Sprintf("admin@%s", gwCfg.DomainName),
 			Client: &acme.Client{
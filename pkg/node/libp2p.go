@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DeBrosOfficial/network/pkg/config"
 	"github.com/DeBrosOfficial/network/pkg/discovery"
 	"github.com/DeBrosOfficial/network/pkg/encryption"
 	"github.com/DeBrosOfficial/network/pkg/logging"
@@ -81,10 +82,9 @@ func (n *Node) startLibP2P() error {
 
 	n.host = h
 
-	// Initialize pubsub
-	ps, err := libp2ppubsub.NewGossipSub(context.Background(), h,
+	// Initialize pubsub with peer scoring and message validation
+	ps, validator, err := pubsub.NewGossipSub(context.Background(), h, gossipConfig(n.config.PubSub),
 		libp2ppubsub.WithPeerExchange(true),
-		libp2ppubsub.WithFloodPublish(true),
 		libp2ppubsub.WithDirectPeers(nil),
 	)
 	if err != nil {
@@ -92,7 +92,7 @@ func (n *Node) startLibP2P() error {
 	}
 
 	// Create pubsub adapter
-	n.pubsub = pubsub.NewClientAdapter(ps, n.config.Discovery.NodeNamespace)
+	n.pubsub = pubsub.NewClientAdapter(ps, n.config.Discovery.NodeNamespace, validator)
 	n.logger.Info("Initialized pubsub adapter on namespace", zap.String("namespace", n.config.Discovery.NodeNamespace))
 
 	// Connect to peers
@@ -270,6 +270,31 @@ func (n *Node) GetPeerID() string {
 	return n.host.ID().String()
 }
 
+// gossipConfig converts the node's pub/sub settings for the pubsub package
+func gossipConfig(c config.PubSubConfig) pubsub.Config {
+	return pubsub.Config{
+		D:                           c.MeshD,
+		Dlo:                         c.MeshDlo,
+		Dhi:                         c.MeshDhi,
+		Dlazy:                       c.MeshDlazy,
+		HeartbeatInterval:           c.HeartbeatInterval,
+		HistoryLength:               c.HistoryLength,
+		HistoryGossip:               c.HistoryGossip,
+		FloodPublish:                c.FloodPublish,
+		DisableScoring:              c.DisableScoring,
+		GossipThreshold:             c.GossipThreshold,
+		PublishThreshold:            c.PublishThreshold,
+		GraylistThreshold:           c.GraylistThreshold,
+		AcceptPXThreshold:           c.AcceptPXThreshold,
+		OpportunisticGraftThreshold: c.OpportunisticGraftThreshold,
+		MaxMessageSize:              c.MaxMessageSize,
+		PeerMessageRate:             c.PeerMessageRate,
+		AllowedNamespaces:           c.AllowedNamespaces,
+		ValidateQueueSize:           c.ValidateQueueSize,
+		ValidateThrottle:            c.ValidateThrottle,
+	}
+}
+
 func peerSource(peerAddrs []string, logger *zap.Logger) func(context.Context, int) <-chan peer.AddrInfo {
 	return func(ctx context.Context, num int) <-chan peer.AddrInfo {
 		out := make(chan peer.AddrInfo, num)
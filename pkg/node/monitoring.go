@@ -12,6 +12,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/DeBrosOfficial/network/pkg/logging"
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
 )
 
 func logPeerStatus(n *Node, currentPeerCount int, lastPeerCount int, firstCheck bool) (int, bool) {
@@ -100,6 +101,7 @@ func announceMetrics(n *Node, peers []peer.ID, cpuUsage uint64, memUsage *memory
 		Memory        uint64                 `json:"memory_usage"`
 		Timestamp     int64                  `json:"timestamp"`
 		ClusterHealth map[string]interface{} `json:"cluster_health,omitempty"`
+		PubSub        pubsub.Metrics         `json:"pubsub"`
 	}{
 		PeerID:    n.host.ID().String(),
 		PeerCount: len(peers),
@@ -107,6 +109,7 @@ func announceMetrics(n *Node, peers []peer.ID, cpuUsage uint64, memUsage *memory
 		CPU:       cpuUsage,
 		Memory:    memUsage.Used,
 		Timestamp: time.Now().Unix(),
+		PubSub:    n.pubsub.Metrics(),
 	}
 
 	// Add cluster health metrics if available
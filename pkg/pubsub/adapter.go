@@ -11,10 +11,11 @@ type ClientAdapter struct {
 	manager *Manager
 }
 
-// NewClientAdapter creates a new adapter for the pubsub manager
-func NewClientAdapter(ps *pubsub.PubSub, namespace string) *ClientAdapter {
+// NewClientAdapter creates a new adapter for the pubsub manager. validator,
+// as returned by NewGossipSub, may be nil.
+func NewClientAdapter(ps *pubsub.PubSub, namespace string, validator *Validator) *ClientAdapter {
 	return &ClientAdapter{
-		manager: NewManager(ps, namespace),
+		manager: NewManager(ps, namespace, validator),
 	}
 }
 
@@ -28,6 +29,11 @@ func (a *ClientAdapter) Publish(ctx context.Context, topic string, data []byte)
 	return a.manager.Publish(ctx, topic, data)
 }
 
+// PublishWithResult publishes a message to a topic and reports the topic's peers
+func (a *ClientAdapter) PublishWithResult(ctx context.Context, topic string, data []byte) (PublishResult, error) {
+	return a.manager.PublishWithResult(ctx, topic, data)
+}
+
 // Unsubscribe unsubscribes from a topic
 func (a *ClientAdapter) Unsubscribe(ctx context.Context, topic string) error {
 	return a.manager.Unsubscribe(ctx, topic)
@@ -38,6 +44,11 @@ func (a *ClientAdapter) ListTopics(ctx context.Context) ([]string, error) {
 	return a.manager.ListTopics(ctx)
 }
 
+// Metrics returns the counts of validated, rejected and throttled messages
+func (a *ClientAdapter) Metrics() Metrics {
+	return a.manager.validator.Metrics()
+}
+
 // Close closes all subscriptions and topics
 func (a *ClientAdapter) Close() error {
 	return a.manager.Close()
@@ -0,0 +1,218 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Defaults for Config
+const (
+	DefaultMaxMessageSize = 1 << 20 // libp2p's own limit
+
+	// rpcOverhead is the room left in an RPC for the framing of its messages
+	rpcOverhead = 64 << 10
+
+	DefaultGossipThreshold             = -500
+	DefaultPublishThreshold            = -1000
+	DefaultGraylistThreshold           = -2500
+	DefaultOpportunisticGraftThreshold = 1
+)
+
+// Config tunes GossipSub, peer scoring and the validation of incoming
+// messages. Zero values use the defaults.
+type Config struct {
+	// GossipSub mesh: the number of peers kept in a topic's mesh (D, between
+	// Dlo and Dhi) and the number of peers gossiped to outside it (Dlazy)
+	D, Dlo, Dhi, Dlazy int
+	HeartbeatInterval  time.Duration // default: 1s
+	HistoryLength      int           // heartbeats messages are cached for (default: 5)
+	HistoryGossip      int           // heartbeats messages are gossiped for (default: 3)
+	FloodPublish       *bool         // send own messages to every topic peer, not just the mesh (default: true)
+
+	// Peer score thresholds. Peers below GossipThreshold get no gossip, below
+	// PublishThreshold none of our messages, and below GraylistThreshold are
+	// ignored altogether. Peer exchange is only accepted from peers above
+	// AcceptPXThreshold (default: 0, any peer in good standing).
+	DisableScoring              bool
+	GossipThreshold             float64
+	PublishThreshold            float64
+	GraylistThreshold           float64
+	AcceptPXThreshold           float64
+	OpportunisticGraftThreshold float64
+
+	// Validation of incoming messages
+	MaxMessageSize    int      // bytes (default: 1 MiB)
+	PeerMessageRate   float64  // messages per second one publisher may send to a topic (default: unlimited)
+	AllowedNamespaces []string // namespaces whose topics are accepted (default: any valid namespace)
+	ValidateQueueSize int      // messages waiting for validation before new ones are dropped (default: 32)
+	ValidateThrottle  int      // messages validated concurrently before new ones are throttled (default: 8192)
+}
+
+// withDefaults returns c with zero values replaced by the defaults.
+func (c Config) withDefaults() Config {
+	if c.FloodPublish == nil {
+		flood := true
+		c.FloodPublish = &flood
+	}
+	if c.GossipThreshold == 0 && c.PublishThreshold == 0 && c.GraylistThreshold == 0 {
+		c.GossipThreshold = DefaultGossipThreshold
+		c.PublishThreshold = DefaultPublishThreshold
+		c.GraylistThreshold = DefaultGraylistThreshold
+	}
+	if c.OpportunisticGraftThreshold == 0 {
+		c.OpportunisticGraftThreshold = DefaultOpportunisticGraftThreshold
+	}
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = DefaultMaxMessageSize
+	}
+	return c
+}
+
+// gossipSubParams returns libp2p's defaults overridden by the configured values.
+func (c Config) gossipSubParams() pubsub.GossipSubParams {
+	params := pubsub.DefaultGossipSubParams()
+	if c.D > 0 {
+		params.D = c.D
+	}
+	if c.Dlo > 0 {
+		params.Dlo = c.Dlo
+	}
+	if c.Dhi > 0 {
+		params.Dhi = c.Dhi
+	}
+	if c.Dlazy > 0 {
+		params.Dlazy = c.Dlazy
+	}
+	// Keep the derived degrees consistent with a custom mesh size
+	params.Dscore = min(params.Dscore, params.D)
+	params.Dout = min(params.Dout, params.Dlo-1, params.D/2)
+	if c.HeartbeatInterval > 0 {
+		params.HeartbeatInterval = c.HeartbeatInterval
+	}
+	if c.HistoryLength > 0 {
+		params.HistoryLength = c.HistoryLength
+	}
+	if c.HistoryGossip > 0 {
+		params.HistoryGossip = c.HistoryGossip
+	}
+	return params
+}
+
+// Validate reports an inconsistent configuration.
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	params := c.gossipSubParams()
+	if params.Dlo > params.D || params.D > params.Dhi {
+		return fmt.Errorf("mesh degrees must satisfy dlo <= d <= dhi; got %d, %d, %d", params.Dlo, params.D, params.Dhi)
+	}
+	if params.HistoryGossip > params.HistoryLength {
+		return fmt.Errorf("history_gossip must not exceed history_length; got %d > %d", params.HistoryGossip, params.HistoryLength)
+	}
+	if c.GossipThreshold > 0 || c.PublishThreshold > c.GossipThreshold || c.GraylistThreshold > c.PublishThreshold {
+		return fmt.Errorf("score thresholds must satisfy graylist <= publish <= gossip <= 0")
+	}
+	if c.AcceptPXThreshold < 0 || c.OpportunisticGraftThreshold < 0 {
+		return fmt.Errorf("accept_px and opportunistic_graft thresholds must be >= 0")
+	}
+	if c.PeerMessageRate < 0 || c.ValidateQueueSize < 0 || c.ValidateThrottle < 0 {
+		return fmt.Errorf("peer_message_rate, validate_queue_size and validate_throttle must be >= 0")
+	}
+	for _, ns := range c.AllowedNamespaces {
+		if !validNamespace(ns) {
+			return fmt.Errorf("invalid allowed namespace %q", ns)
+		}
+	}
+	return nil
+}
+
+// peerScoreParams returns the router-wide score parameters. Topic parameters
+// are set when a topic is joined.
+func peerScoreParams() *pubsub.PeerScoreParams {
+	return &pubsub.PeerScoreParams{
+		Topics:            make(map[string]*pubsub.TopicScoreParams),
+		TopicScoreCap:     100,
+		AppSpecificScore:  func(peer.ID) float64 { return 0 },
+		AppSpecificWeight: 1,
+
+		// Sybils sharing an address; local clusters run on loopback
+		IPColocationFactorWeight:    -5,
+		IPColocationFactorThreshold: 10,
+		IPColocationFactorWhitelist: []*net.IPNet{
+			{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+			{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+		},
+
+		// Broken promises (IHAVE without IWANT follow-up, early re-grafts)
+		BehaviourPenaltyWeight:    -10,
+		BehaviourPenaltyThreshold: 6,
+		BehaviourPenaltyDecay:     pubsub.ScoreParameterDecay(10 * time.Minute),
+
+		DecayInterval: time.Second,
+		DecayToZero:   0.01,
+		RetainScore:   time.Hour,
+	}
+}
+
+// topicScoreParams rewards peers for first deliveries and penalizes invalid
+// messages: five rejected messages take a peer to the graylist.
+func topicScoreParams() *pubsub.TopicScoreParams {
+	return &pubsub.TopicScoreParams{
+		SkipAtomicValidation: true,
+		TopicWeight:          1,
+
+		FirstMessageDeliveriesWeight: 1,
+		FirstMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(time.Hour),
+		FirstMessageDeliveriesCap:    50,
+
+		InvalidMessageDeliveriesWeight: -100,
+		InvalidMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(time.Hour),
+	}
+}
+
+// NewGossipSub creates the GossipSub router configured by cfg. The returned
+// Validator checks incoming messages; pass it to NewClientAdapter so that
+// topics register it when they are joined.
+func NewGossipSub(ctx context.Context, h host.Host, cfg Config, opts ...pubsub.Option) (*pubsub.PubSub, *Validator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid pubsub config: %w", err)
+	}
+	cfg = cfg.withDefaults()
+	v := newValidator(cfg, h.ID())
+
+	options := []pubsub.Option{
+		pubsub.WithGossipSubParams(cfg.gossipSubParams()),
+		pubsub.WithFloodPublish(*cfg.FloodPublish),
+		pubsub.WithMessageSignaturePolicy(pubsub.StrictSign),
+		// Caps whole RPCs; smaller per-message limits are left to the validator
+		// so that a large message costs its forwarder score, not the stream
+		pubsub.WithMaxMessageSize(max(DefaultMaxMessageSize, cfg.MaxMessageSize+rpcOverhead)),
+		pubsub.WithRawTracer(v.metrics),
+	}
+	if cfg.ValidateQueueSize > 0 {
+		options = append(options, pubsub.WithValidateQueueSize(cfg.ValidateQueueSize))
+	}
+	if cfg.ValidateThrottle > 0 {
+		options = append(options, pubsub.WithValidateThrottle(cfg.ValidateThrottle))
+	}
+	if !cfg.DisableScoring {
+		options = append(options, pubsub.WithPeerScore(peerScoreParams(), &pubsub.PeerScoreThresholds{
+			GossipThreshold:             cfg.GossipThreshold,
+			PublishThreshold:            cfg.PublishThreshold,
+			GraylistThreshold:           cfg.GraylistThreshold,
+			AcceptPXThreshold:           cfg.AcceptPXThreshold,
+			OpportunisticGraftThreshold: cfg.OpportunisticGraftThreshold,
+		}))
+	}
+
+	ps, err := pubsub.NewGossipSub(ctx, h, append(options, opts...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ps, v, nil
+}
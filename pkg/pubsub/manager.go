@@ -14,6 +14,7 @@ type Manager struct {
     topics        map[string]*pubsub.Topic
     subscriptions map[string]*topicSubscription
     namespace     string
+    validator     *Validator // nil when messages are not validated
     mu            sync.RWMutex
 }
 
@@ -26,13 +27,15 @@ type topicSubscription struct {
     mu        sync.RWMutex
 }
 
-// NewManager creates a new pubsub manager
-func NewManager(ps *pubsub.PubSub, namespace string) *Manager {
+// NewManager creates a new pubsub manager. If validator is not nil, it
+// validates the messages of every topic the manager joins.
+func NewManager(ps *pubsub.PubSub, namespace string, validator *Validator) *Manager {
     return &Manager {
         pubsub:        ps,
         topics:        make(map[string]*pubsub.Topic),
         subscriptions: make(map[string]*topicSubscription),
         namespace:     namespace,
+        validator:     validator,
     }
 }
 
@@ -3,13 +3,40 @@ package pubsub
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 )
 
+// ReservedPayloadPrefix starts the frames gateways wrap messages in. Raw
+// publishes, such as those of serverless functions, must not start with it.
+const ReservedPayloadPrefix = "\x00orama-"
+
+// HasReservedPrefix reports whether data looks like a gateway frame.
+func HasReservedPrefix(data []byte) bool {
+	return strings.HasPrefix(string(data), ReservedPayloadPrefix)
+}
+
+// ReservedTopicPrefix starts the topics gateways use among themselves, such as
+// topic announcements. Clients and functions may not use them.
+const ReservedTopicPrefix = "_orama."
+
+// IsReservedTopic reports whether topic is reserved for gateways.
+func IsReservedTopic(topic string) bool {
+	return strings.HasPrefix(topic, ReservedTopicPrefix)
+}
+
 // Publish publishes a message to a topic
 func (m *Manager) Publish(ctx context.Context, topic string, data []byte) error {
+	_, err := m.PublishWithResult(ctx, topic, data)
+	return err
+}
+
+// PublishWithResult publishes a message to a topic and reports how many peers
+// had joined the topic when it was sent
+func (m *Manager) PublishWithResult(ctx context.Context, topic string, data []byte) (PublishResult, error) {
+	var res PublishResult
 	if m.pubsub == nil {
-		return fmt.Errorf("pubsub not initialized")
+		return res, fmt.Errorf("pubsub not initialized")
 	}
 
 	// Determine namespace (allow per-call override via context)
@@ -25,7 +52,7 @@ func (m *Manager) Publish(ctx context.Context, topic string, data []byte) error
 	// Get or create topic
 	libp2pTopic, err := m.getOrCreateTopic(namespacedTopic)
 	if err != nil {
-		return fmt.Errorf("failed to get topic for publishing: %w", err)
+		return res, fmt.Errorf("failed to get topic for publishing: %w", err)
 	}
 
 	// Wait briefly for mesh formation if no peers are in the mesh yet
@@ -52,9 +79,10 @@ func (m *Manager) Publish(ctx context.Context, topic string, data []byte) error
 	}
 
 	// Publish message
+	res.Peers = len(libp2pTopic.ListPeers())
 	if err := libp2pTopic.Publish(ctx, data); err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return res, fmt.Errorf("failed to publish message: %w", err)
 	}
 
-	return nil
+	return res, nil
 }
@@ -200,8 +200,9 @@ func (m *Manager) Close() error {
 	m.subscriptions = make(map[string]*topicSubscription)
 
 	// Close all topics
-	for _, topic := range m.topics {
+	for name, topic := range m.topics {
 		topic.Close()
+		m.unregisterValidator(name)
 	}
 	m.topics = make(map[string]*pubsub.Topic)
 
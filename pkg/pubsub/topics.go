@@ -16,12 +16,35 @@ func (m *Manager) getOrCreateTopic(topicName string) (*pubsub.Topic, error) {
 		return topic, nil
 	}
 
+	// Validate incoming messages before they are delivered or forwarded
+	if v := m.validator; v != nil {
+		if !v.checkTopic(topicName) {
+			return nil, fmt.Errorf("topic %q is not in an allowed namespace", topicName)
+		}
+		if err := m.pubsub.RegisterTopicValidator(topicName, v.validate); err != nil {
+			return nil, fmt.Errorf("failed to register topic validator: %w", err)
+		}
+	}
+
 	// Join the topic - LibP2P allows multiple clients to join the same topic
 	topic, err := m.pubsub.Join(topicName)
 	if err != nil {
+		m.unregisterValidator(topicName)
 		return nil, fmt.Errorf("failed to join topic: %w", err)
 	}
+	if m.validator != nil && !m.validator.cfg.DisableScoring {
+		if err := topic.SetScoreParams(topicScoreParams()); err != nil {
+			Logf("[PUBSUB] failed to set score params for %s: %v", topicName, err)
+		}
+	}
 
 	m.topics[topicName] = topic
 	return topic, nil
 }
+
+// unregisterValidator removes the validator of a topic being left
+func (m *Manager) unregisterValidator(topicName string) {
+	if m.validator != nil {
+		_ = m.pubsub.UnregisterTopicValidator(topicName)
+	}
+}
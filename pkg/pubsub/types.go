@@ -12,4 +12,12 @@ type MessageHandler func(topic string, data []byte) error
 // Each call to Subscribe generates a new HandlerID, allowing
 // multiple subscribers to the same topic with independent lifecycles.
 // Unsubscribe operations are ref-counted per topic.
-type HandlerID string
\ No newline at end of file
+type HandlerID string
+
+// PublishResult describes a published message.
+// Peers is the number of peers known to have joined the topic when the
+// message was sent. It does not mean all of them received it: unless
+// FloodPublish is enabled, the message goes to the mesh peers only.
+type PublishResult struct {
+	Peers int
+}
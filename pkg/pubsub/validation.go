@@ -0,0 +1,237 @@
+package pubsub
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Reasons the validator rejects or drops a message, as counted in Metrics
+const (
+	RejectTooLarge     = "message too large"
+	RejectUnsigned     = "unsigned message"
+	RejectNamespace    = "namespace not allowed"
+	ThrottledPeerRate  = "peer rate exceeded"
+	maxRateBuckets     = 100000
+	rateBucketIdleTime = time.Minute
+)
+
+// namespaceRe matches namespace names, as accepted by the gateway
+var namespaceRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,63}$`)
+
+func validNamespace(ns string) bool {
+	return namespaceRe.MatchString(ns)
+}
+
+// topicNamespace returns the namespace prefix of a namespaced topic.
+func topicNamespace(topic string) (string, bool) {
+	ns, rest, ok := strings.Cut(topic, ".")
+	if !ok || rest == "" || !validNamespace(ns) {
+		return "", false
+	}
+	return ns, true
+}
+
+// Validator checks the messages of the topics a Manager joins: their size,
+// their signature, their topic's namespace and, optionally, the rate at which
+// each publisher sends them. Invalid messages are rejected, which lowers the
+// score of the peer that forwarded them; messages over the rate are ignored.
+type Validator struct {
+	cfg     Config
+	self    peer.ID // our own messages are not rate limited
+	metrics *metricsTracer
+
+	mu      sync.Mutex
+	buckets map[rateKey]*rateBucket
+}
+
+type rateKey struct {
+	from  peer.ID
+	topic string
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newValidator(cfg Config, self peer.ID) *Validator {
+	return &Validator{
+		cfg:     cfg,
+		self:    self,
+		metrics: &metricsTracer{rejected: make(map[string]uint64), throttled: make(map[string]uint64)},
+		buckets: make(map[rateKey]*rateBucket),
+	}
+}
+
+// checkTopic reports whether messages on topic may be accepted at all.
+func (v *Validator) checkTopic(topic string) bool {
+	ns, ok := topicNamespace(topic)
+	if !ok {
+		return false
+	}
+	return len(v.cfg.AllowedNamespaces) == 0 || slices.Contains(v.cfg.AllowedNamespaces, ns)
+}
+
+// validate is the topic validator registered for every joined topic.
+func (v *Validator) validate(_ context.Context, src peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	switch {
+	case len(msg.Data) > v.cfg.MaxMessageSize:
+		v.metrics.reject(RejectTooLarge)
+		return pubsub.ValidationReject
+	case len(msg.Signature) == 0 || msg.GetFrom() == "":
+		// StrictSign verifies signatures before validators run; this keeps
+		// unsigned messages out should the policy ever be relaxed
+		v.metrics.reject(RejectUnsigned)
+		return pubsub.ValidationReject
+	case !v.checkTopic(msg.GetTopic()):
+		v.metrics.reject(RejectNamespace)
+		return pubsub.ValidationReject
+	case src != v.self && !v.allow(msg.GetFrom(), msg.GetTopic(), time.Now()):
+		v.metrics.throttle(ThrottledPeerRate)
+		return pubsub.ValidationIgnore
+	}
+	return pubsub.ValidationAccept
+}
+
+// allow takes a token from the publisher's bucket for topic. Buckets hold up
+// to one second's worth of messages.
+func (v *Validator) allow(from peer.ID, topic string, now time.Time) bool {
+	rate := v.cfg.PeerMessageRate
+	if rate <= 0 {
+		return true
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := rateKey{from: from, topic: topic}
+	b, ok := v.buckets[key]
+	if !ok {
+		if len(v.buckets) >= maxRateBuckets {
+			v.pruneBuckets(now)
+		}
+		b = &rateBucket{tokens: max(rate, 1), last: now}
+		v.buckets[key] = b
+	}
+	b.tokens = min(max(rate, 1), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// pruneBuckets forgets the buckets of publishers that went quiet.
+func (v *Validator) pruneBuckets(now time.Time) {
+	for key, b := range v.buckets {
+		if now.Sub(b.last) > rateBucketIdleTime {
+			delete(v.buckets, key)
+		}
+	}
+}
+
+// Metrics returns the counts of validated, rejected and throttled messages.
+func (v *Validator) Metrics() Metrics {
+	if v == nil {
+		return Metrics{}
+	}
+	return v.metrics.snapshot()
+}
+
+// Metrics counts what happened to the messages received from the network
+type Metrics struct {
+	Validated     uint64            `json:"validated"`       // entered validation
+	Delivered     uint64            `json:"delivered"`       // passed validation
+	Duplicates    uint64            `json:"duplicates"`      // already seen
+	Rejected      map[string]uint64 `json:"rejected"`        // invalid, by reason; penalizes the sender
+	Throttled     map[string]uint64 `json:"throttled"`       // dropped under load, by reason
+	ThrottledPeer uint64            `json:"throttled_peers"` // times a peer was throttled by the router
+	Undeliverable uint64            `json:"undeliverable"`   // dropped because a subscriber was too slow
+}
+
+// metricsTracer counts router events for Metrics
+type metricsTracer struct {
+	validated     atomic.Uint64
+	delivered     atomic.Uint64
+	duplicates    atomic.Uint64
+	throttledPeer atomic.Uint64
+	undeliverable atomic.Uint64
+
+	mu        sync.Mutex
+	rejected  map[string]uint64
+	throttled map[string]uint64
+}
+
+var _ pubsub.RawTracer = (*metricsTracer)(nil)
+
+func (t *metricsTracer) reject(reason string) {
+	t.mu.Lock()
+	t.rejected[reason]++
+	t.mu.Unlock()
+}
+
+func (t *metricsTracer) throttle(reason string) {
+	t.mu.Lock()
+	t.throttled[reason]++
+	t.mu.Unlock()
+}
+
+func (t *metricsTracer) snapshot() Metrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := Metrics{
+		Validated:     t.validated.Load(),
+		Delivered:     t.delivered.Load(),
+		Duplicates:    t.duplicates.Load(),
+		Rejected:      make(map[string]uint64, len(t.rejected)),
+		Throttled:     make(map[string]uint64, len(t.throttled)),
+		ThrottledPeer: t.throttledPeer.Load(),
+		Undeliverable: t.undeliverable.Load(),
+	}
+	for reason, n := range t.rejected {
+		m.Rejected[reason] = n
+	}
+	for reason, n := range t.throttled {
+		m.Throttled[reason] = n
+	}
+	return m
+}
+
+func (t *metricsTracer) ValidateMessage(*pubsub.Message) { t.validated.Add(1) }
+func (t *metricsTracer) DeliverMessage(*pubsub.Message)  { t.delivered.Add(1) }
+func (t *metricsTracer) DuplicateMessage(*pubsub.Message) {
+	t.duplicates.Add(1)
+}
+func (t *metricsTracer) ThrottlePeer(peer.ID)                 { t.throttledPeer.Add(1) }
+func (t *metricsTracer) UndeliverableMessage(*pubsub.Message) { t.undeliverable.Add(1) }
+
+// RejectMessage counts the router's own rejections. Validator outcomes were
+// already counted with their reason, and our own messages are not counted.
+func (t *metricsTracer) RejectMessage(_ *pubsub.Message, reason string) {
+	switch reason {
+	case pubsub.RejectValidationFailed, pubsub.RejectValidationIgnored, pubsub.RejectSelfOrigin:
+	case pubsub.RejectValidationThrottled, pubsub.RejectValidationQueueFull:
+		t.throttle(reason)
+	default:
+		t.reject(reason)
+	}
+}
+
+func (t *metricsTracer) AddPeer(peer.ID, protocol.ID) {}
+func (t *metricsTracer) RemovePeer(peer.ID)           {}
+func (t *metricsTracer) Join(string)                  {}
+func (t *metricsTracer) Leave(string)                 {}
+func (t *metricsTracer) Graft(peer.ID, string)        {}
+func (t *metricsTracer) Prune(peer.ID, string)        {}
+func (t *metricsTracer) RecvRPC(*pubsub.RPC)          {}
+func (t *metricsTracer) SendRPC(*pubsub.RPC, peer.ID) {}
+func (t *metricsTracer) DropRPC(*pubsub.RPC, peer.ID) {}
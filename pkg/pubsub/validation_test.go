@@ -0,0 +1,124 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestValidator_AllowRefillsPerPublisherAndTopic(t *testing.T) {
+	v := newValidator(Config{PeerMessageRate: 2}.withDefaults(), "self")
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if !v.allow("a", "ns.t", now) {
+			t.Fatalf("expected message %d within the burst to be allowed", i)
+		}
+	}
+	if v.allow("a", "ns.t", now) {
+		t.Fatal("expected the third message in the same instant to be throttled")
+	}
+	if !v.allow("b", "ns.t", now) || !v.allow("a", "ns.other", now) {
+		t.Fatal("expected other publishers and topics to have their own buckets")
+	}
+	if !v.allow("a", "ns.t", now.Add(500*time.Millisecond)) {
+		t.Fatal("expected the bucket to refill at the configured rate")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (Config{}).Validate(); err != nil {
+		t.Fatalf("expected defaults to be valid: %v", err)
+	}
+	for name, cfg := range map[string]Config{
+		"mesh":       {D: 4, Dlo: 6},
+		"history":    {HistoryLength: 2, HistoryGossip: 3},
+		"thresholds": {GossipThreshold: -10, PublishThreshold: -5, GraylistThreshold: -20},
+		"namespace":  {AllowedNamespaces: []string{"bad.ns"}},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidator_RejectsAndThrottlesRemoteMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The sender validates nothing, so it can send what the receiver refuses
+	h1, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create host: %v", err)
+	}
+	defer h1.Close()
+	ps1, err := pubsub.NewGossipSub(ctx, h1)
+	if err != nil {
+		t.Fatalf("failed to create gossipsub: %v", err)
+	}
+	sender := NewManager(ps1, "test", nil)
+	defer sender.Close()
+
+	h2, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create host: %v", err)
+	}
+	defer h2.Close()
+	ps2, v, err := NewGossipSub(ctx, h2, Config{MaxMessageSize: 64, PeerMessageRate: 1, AllowedNamespaces: []string{"test"}})
+	if err != nil {
+		t.Fatalf("failed to create gossipsub: %v", err)
+	}
+	receiver := NewManager(ps2, "test", v)
+	defer receiver.Close()
+
+	if err := receiver.Subscribe(WithNamespace(ctx, "other"), "chat", func(string, []byte) error { return nil }); err == nil {
+		t.Fatal("expected joining a topic outside the allowed namespaces to fail")
+	}
+
+	if err := h1.Connect(ctx, peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}); err != nil {
+		t.Fatalf("failed to connect hosts: %v", err)
+	}
+	received := make(chan []byte, 16)
+	if err := receiver.Subscribe(ctx, "chat", func(_ string, d []byte) error {
+		received <- d
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+
+	// An oversized message is rejected; the first valid one is delivered
+	oversized := bytes.Repeat([]byte("x"), 65)
+	deadline := time.After(5 * time.Second)
+	for delivered := false; !delivered; {
+		_ = sender.Publish(ctx, "chat", oversized)
+		_ = sender.Publish(ctx, "chat", []byte("hello"))
+		select {
+		case d := <-received:
+			if string(d) != "hello" {
+				t.Fatalf("expected only the valid message, got %q", d)
+			}
+			delivered = true
+		case <-deadline:
+			t.Fatalf("timed out waiting for the valid message: %+v", v.Metrics())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Within the same second, the sender is over its rate of one message
+	for i := 0; i < 3; i++ {
+		_ = sender.Publish(ctx, "chat", []byte("spam"))
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	m := v.Metrics()
+	if m.Rejected[RejectTooLarge] == 0 {
+		t.Errorf("expected oversized messages to be counted as rejected, got %+v", m.Rejected)
+	}
+	if m.Throttled[ThrottledPeerRate] == 0 {
+		t.Errorf("expected messages over the rate to be counted as throttled, got %+v", m.Throttled)
+	}
+}
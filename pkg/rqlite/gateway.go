@@ -49,6 +49,10 @@ type HTTPGateway struct {
 
 	// Optional: Request timeout. If > 0, handlers will use a context with this timeout.
 	Timeout time.Duration
+
+	// Optional: OnUsage is called after each successful request with the number of
+	// statements executed and rows returned or affected (used for usage metering).
+	OnUsage func(ctx context.Context, statements, rows int64)
 }
 
 // NewHTTPGateway constructs a new HTTPGateway with sensible defaults.
@@ -87,6 +91,12 @@ func (g *HTTPGateway) base() string {
 	return b
 }
 
+func (g *HTTPGateway) reportUsage(ctx context.Context, statements, rows int64) {
+	if g.OnUsage != nil {
+		g.OnUsage(ctx, statements, rows)
+	}
+}
+
 func (g *HTTPGateway) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
 	if g.Timeout > 0 {
 		return context.WithTimeout(ctx, g.Timeout)
@@ -243,6 +253,7 @@ func (g *HTTPGateway) handleQuery(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	g.reportUsage(r.Context(), 1, int64(len(out)))
 	writeJSON(w, http.StatusOK, map[string]any{
 		"items": out,
 		"count": len(out),
@@ -273,6 +284,7 @@ func (g *HTTPGateway) handleExec(w http.ResponseWriter, r *http.Request) {
 	}
 	liid, _ := res.LastInsertId()
 	ra, _ := res.RowsAffected()
+	g.reportUsage(r.Context(), 1, ra)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"rows_affected":   ra,
 		"last_insert_id":  liid,
@@ -302,6 +314,7 @@ func (g *HTTPGateway) handleFind(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	g.reportUsage(r.Context(), 1, int64(len(out)))
 	writeJSON(w, http.StatusOK, map[string]any{
 		"items": out,
 		"count": len(out),
@@ -334,6 +347,7 @@ func (g *HTTPGateway) handleFindOne(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	g.reportUsage(r.Context(), 1, 1)
 	writeJSON(w, http.StatusOK, row)
 }
 
@@ -404,6 +418,7 @@ func (g *HTTPGateway) handleSelect(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		g.reportUsage(r.Context(), 1, 1)
 		writeJSON(w, http.StatusOK, row)
 		return
 	}
@@ -413,6 +428,7 @@ func (g *HTTPGateway) handleSelect(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	g.reportUsage(r.Context(), 1, int64(len(rows)))
 	writeJSON(w, http.StatusOK, map[string]any{
 		"items": rows,
 		"count": len(rows),
@@ -449,6 +465,7 @@ func (g *HTTPGateway) handleTransaction(w http.ResponseWriter, r *http.Request)
 	defer cancel()
 
 	results := make([]any, 0, len(body.Ops))
+	var rowCount int64
 	err := g.Client.Tx(ctx, func(tx Tx) error {
 		for _, op := range body.Ops {
 			switch strings.ToLower(strings.TrimSpace(op.Kind)) {
@@ -457,9 +474,10 @@ func (g *HTTPGateway) handleTransaction(w http.ResponseWriter, r *http.Request)
 				if err != nil {
 					return err
 				}
+				ra, _ := res.RowsAffected()
+				rowCount += ra
 				if body.ReturnResults {
 					li, _ := res.LastInsertId()
-					ra, _ := res.RowsAffected()
 					results = append(results, map[string]any{
 						"rows_affected":  ra,
 						"last_insert_id": li,
@@ -470,6 +488,7 @@ func (g *HTTPGateway) handleTransaction(w http.ResponseWriter, r *http.Request)
 				if err := tx.Query(ctx, &rows, op.SQL, normalizeArgs(op.Args)...); err != nil {
 					return err
 				}
+				rowCount += int64(len(rows))
 				if body.ReturnResults {
 					results = append(results, rows)
 				}
@@ -483,6 +502,7 @@ func (g *HTTPGateway) handleTransaction(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	g.reportUsage(r.Context(), int64(len(body.Ops)), rowCount)
 	if body.ReturnResults {
 		writeJSON(w, http.StatusOK, map[string]any{
 			"status":  "ok",
@@ -542,6 +562,7 @@ func (g *HTTPGateway) handleCreateTable(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	g.reportUsage(r.Context(), 1, 0)
 	writeJSON(w, http.StatusCreated, map[string]any{"status": "ok"})
 }
 
@@ -579,6 +600,7 @@ func (g *HTTPGateway) handleDropTable(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	g.reportUsage(r.Context(), 1, 0)
 	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
 }
 
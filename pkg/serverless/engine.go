@@ -11,6 +11,7 @@ import (
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 	"go.uber.org/zap"
 
+	"github.com/DeBrosOfficial/network/pkg/metering"
 	"github.com/DeBrosOfficial/network/pkg/serverless/cache"
 	"github.com/DeBrosOfficial/network/pkg/serverless/execution"
 )
@@ -46,6 +47,9 @@ type Engine struct {
 
 	// Rate limiter
 	rateLimiter RateLimiter
+
+	// Usage recorder for per-namespace GB-second accounting (optional)
+	usageRecorder metering.Recorder
 }
 
 // InvocationLogger logs function invocations (optional).
@@ -86,6 +90,13 @@ func WithInvocationLogger(logger InvocationLogger) EngineOption {
 	}
 }
 
+// WithUsageRecorder sets the recorder charged with execution GB-seconds.
+func WithUsageRecorder(r metering.Recorder) EngineOption {
+	return func(e *Engine) {
+		e.usageRecorder = r
+	}
+}
+
 // WithRateLimiter sets the rate limiter.
 func WithRateLimiter(limiter RateLimiter) EngineOption {
 	return func(e *Engine) {
@@ -170,6 +181,7 @@ func (e *Engine) Execute(ctx context.Context, fn *Function, input []byte, invCtx
 		contextClearer = func() { hf.ClearContext() }
 	}
 	output, err := e.executor.ExecuteModule(execCtx, module, fn.Name, input, contextSetter, contextClearer)
+	e.recordUsage(fn, time.Since(startTime))
 	if err != nil {
 		status := InvocationStatusError
 		if execCtx.Err() == context.DeadlineExceeded {
@@ -252,6 +264,19 @@ func (e *Engine) getOrCompileModule(ctx context.Context, wasmCID string) (wazero
 	})
 }
 
+// recordUsage charges the function's namespace for the memory reserved
+// during execution.
+func (e *Engine) recordUsage(fn *Function, elapsed time.Duration) {
+	if e.usageRecorder == nil {
+		return
+	}
+	memoryMB := fn.MemoryLimitMB
+	if memoryMB <= 0 {
+		memoryMB = e.config.DefaultMemoryLimitMB
+	}
+	e.usageRecorder.Record(fn.Namespace, metering.FunctionGBSeconds, metering.GBSeconds(memoryMB, elapsed))
+}
+
 // logInvocation logs an invocation record.
 func (e *Engine) logInvocation(ctx context.Context, fn *Function, invCtx *InvocationContext, startTime time.Time, outputSize int, status InvocationStatus, err error) {
 	if e.invocationLogger == nil || !e.config.LogInvocations {
@@ -301,6 +326,8 @@ func (e *Engine) registerHostModule(ctx context.Context) error {
 			NewFunctionBuilder().WithFunc(e.hCacheSet).Export("cache_set").
 			NewFunctionBuilder().WithFunc(e.hCacheIncr).Export("cache_incr").
 			NewFunctionBuilder().WithFunc(e.hCacheIncrBy).Export("cache_incr_by").
+			NewFunctionBuilder().WithFunc(e.hStoragePut).Export("storage_put").
+			NewFunctionBuilder().WithFunc(e.hStorageGet).Export("storage_get").
 			NewFunctionBuilder().WithFunc(e.hHTTPFetch).Export("http_fetch").
 			NewFunctionBuilder().WithFunc(e.hPubSubPublish).Export("pubsub_publish").
 			NewFunctionBuilder().WithFunc(e.hLogInfo).Export("log_info").
@@ -473,6 +500,32 @@ func (e *Engine) hHTTPFetch(ctx context.Context, mod api.Module, methodPtr, meth
 	return e.executor.WriteToGuest(ctx, mod, resp)
 }
 
+func (e *Engine) hStoragePut(ctx context.Context, mod api.Module, dataPtr, dataLen uint32, ttl int64) uint64 {
+	data, ok := e.executor.ReadFromGuest(mod, dataPtr, dataLen)
+	if !ok {
+		return 0
+	}
+	cid, err := e.hostServices.StoragePut(ctx, data, ttl)
+	if err != nil {
+		e.logger.Error("host function storage_put failed", zap.Error(err))
+		return 0
+	}
+	return e.executor.WriteToGuest(ctx, mod, []byte(cid))
+}
+
+func (e *Engine) hStorageGet(ctx context.Context, mod api.Module, cidPtr, cidLen uint32) uint64 {
+	cid, ok := e.executor.ReadFromGuest(mod, cidPtr, cidLen)
+	if !ok {
+		return 0
+	}
+	data, err := e.hostServices.StorageGet(ctx, string(cid))
+	if err != nil {
+		e.logger.Error("host function storage_get failed", zap.Error(err), zap.String("cid", string(cid)))
+		return 0
+	}
+	return e.executor.WriteToGuest(ctx, mod, data)
+}
+
 func (e *Engine) hPubSubPublish(ctx context.Context, mod api.Module, topicPtr, topicLen, dataPtr, dataLen uint32) uint32 {
 	topic, ok := e.executor.ReadFromGuest(mod, topicPtr, topicLen)
 	if !ok {
@@ -27,7 +27,7 @@ func TestHostFunctions_Cache(t *testing.T) {
 	ctx := context.Background()
 
 	// Test Storage interface
-	cid, err := h.StoragePut(ctx, []byte("data"))
+	cid, err := h.StoragePut(ctx, []byte("data"), 0)
 	if err != nil {
 		t.Fatalf("StoragePut failed: %v", err)
 	}
@@ -76,7 +76,7 @@ func (m *mockHostServices) CacheIncrBy(ctx context.Context, key string, delta in
 	return 0, nil
 }
 
-func (m *mockHostServices) StoragePut(ctx context.Context, data []byte) (string, error) {
+func (m *mockHostServices) StoragePut(ctx context.Context, data []byte, ttlSeconds int64) (string, error) {
 	// Mock implementation - just return a fake CID
 	return "QmTest123", nil
 }
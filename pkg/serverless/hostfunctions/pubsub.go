@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/DeBrosOfficial/network/pkg/pubsub"
 	"github.com/DeBrosOfficial/network/pkg/serverless"
 )
 
@@ -12,6 +13,13 @@ func (h *HostFunctions) PubSubPublish(ctx context.Context, topic string, data []
 	if h.pubsub == nil {
 		return &serverless.HostFunctionError{Function: "pubsub_publish", Cause: fmt.Errorf("pubsub not available")}
 	}
+	if pubsub.IsReservedTopic(topic) {
+		return &serverless.HostFunctionError{Function: "pubsub_publish", Cause: fmt.Errorf("topic %q is reserved", topic)}
+	}
+	// Gateways wrap messages in signed frames; functions may not send lookalikes
+	if pubsub.HasReservedPrefix(data) {
+		return &serverless.HostFunctionError{Function: "pubsub_publish", Cause: fmt.Errorf("payload uses a reserved prefix")}
+	}
 
 	// The pubsub adapter handles namespacing internally
 	if err := h.pubsub.Publish(ctx, topic, data); err != nil {
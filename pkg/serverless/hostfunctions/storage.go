@@ -5,22 +5,57 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/DeBrosOfficial/network/pkg/serverless"
 )
 
-// StoragePut uploads data to IPFS and returns the CID.
-func (h *HostFunctions) StoragePut(ctx context.Context, data []byte) (string, error) {
+// ObjectStore stores objects written by functions the way the storage API
+// stores uploads: encrypted, recorded in the namespace's index, pinned and
+// expired. It also reads them back, decrypting the namespace's own objects.
+type ObjectStore interface {
+	PutObject(ctx context.Context, namespace string, data io.Reader, name string, ttl time.Duration, createdBy string) (string, error)
+	GetObject(ctx context.Context, namespace, cid string) (io.ReadCloser, error)
+}
+
+// SetObjectStore sets the store used by storage_put and storage_get. Without
+// one, objects are added to IPFS as is and not tracked by any namespace.
+func (h *HostFunctions) SetObjectStore(store ObjectStore) {
+	h.objects = store
+}
+
+// invocationOwner returns the namespace and creator of the current invocation.
+func (h *HostFunctions) invocationOwner() (namespace, createdBy string) {
+	h.invCtxLock.RLock()
+	defer h.invCtxLock.RUnlock()
+	if h.invCtx == nil {
+		return "", ""
+	}
+	return h.invCtx.Namespace, "function:" + h.invCtx.FunctionName
+}
+
+// StoragePut uploads data to IPFS and returns the CID. A positive ttlSeconds
+// removes the object from the namespace once it passes.
+func (h *HostFunctions) StoragePut(ctx context.Context, data []byte, ttlSeconds int64) (string, error) {
 	if h.storage == nil {
 		return "", &serverless.HostFunctionError{Function: "storage_put", Cause: serverless.ErrStorageUnavailable}
 	}
 
 	reader := bytes.NewReader(data)
+	if h.objects != nil {
+		namespace, createdBy := h.invocationOwner()
+		ttl := time.Duration(max(ttlSeconds, 0)) * time.Second
+		cid, err := h.objects.PutObject(ctx, namespace, reader, "function-data", ttl, createdBy)
+		if err != nil {
+			return "", &serverless.HostFunctionError{Function: "storage_put", Cause: err}
+		}
+		return cid, nil
+	}
+
 	resp, err := h.storage.Add(ctx, reader, "function-data")
 	if err != nil {
 		return "", &serverless.HostFunctionError{Function: "storage_put", Cause: err}
 	}
-
 	return resp.Cid, nil
 }
 
@@ -30,7 +65,14 @@ func (h *HostFunctions) StorageGet(ctx context.Context, cid string) ([]byte, err
 		return nil, &serverless.HostFunctionError{Function: "storage_get", Cause: serverless.ErrStorageUnavailable}
 	}
 
-	reader, err := h.storage.Get(ctx, cid, h.ipfsAPIURL)
+	var reader io.ReadCloser
+	var err error
+	if h.objects != nil {
+		namespace, _ := h.invocationOwner()
+		reader, err = h.objects.GetObject(ctx, namespace, cid)
+	} else {
+		reader, err = h.storage.Get(ctx, cid, h.ipfsAPIURL)
+	}
 	if err != nil {
 		return nil, &serverless.HostFunctionError{Function: "storage_get", Cause: err}
 	}
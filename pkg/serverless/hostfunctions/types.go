@@ -25,6 +25,7 @@ type HostFunctions struct {
 	db          rqlite.Client
 	cacheClient olriclib.Client
 	storage     ipfs.IPFSClient
+	objects     ObjectStore // stores storage_put objects; may be nil
 	ipfsAPIURL  string
 	pubsub      *pubsub.ClientAdapter
 	wsManager   serverless.WebSocketManager
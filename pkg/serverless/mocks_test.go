@@ -158,7 +158,7 @@ func (m *MockHostServices) CacheIncrBy(ctx context.Context, key string, delta in
 	return newValue, nil
 }
 
-func (m *MockHostServices) StoragePut(ctx context.Context, data []byte) (string, error) {
+func (m *MockHostServices) StoragePut(ctx context.Context, data []byte, ttlSeconds int64) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	cid := "cid-" + time.Now().String()
@@ -332,7 +332,7 @@ type HostServices interface {
 	CacheIncrBy(ctx context.Context, key string, delta int64) (int64, error)
 
 	// Storage operations
-	StoragePut(ctx context.Context, data []byte) (string, error)
+	StoragePut(ctx context.Context, data []byte, ttlSeconds int64) (string, error)
 	StorageGet(ctx context.Context, cid string) ([]byte, error)
 
 	// PubSub operations